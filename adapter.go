@@ -0,0 +1,525 @@
+package cryo
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/gorilla/websocket"
+	"github.com/machinacanis/cryo/log"
+)
+
+// adapterBase 是各个协议适配器共用的部分
+//
+// 它负责插件的生命周期、HTTP 服务、WebSocket 连接的管理和消息ID的分配，
+// 具体的协议只需要提供HTTP处理器、事件的上报方式和连接收到数据时的处理逻辑
+type adapterBase struct {
+	name        string // 插件名称，同时用作日志的前缀
+	version     string
+	description string
+	addr        string // HTTP 服务的监听地址，为空时不监听
+	accessToken string // 访问令牌，为空时不校验，只接受同源的 WebSocket 连接
+	handler     http.Handler
+	report      func(e Event, ae *adapterEvent) // 上报转换后的事件
+	open        func(oc *adapterConn)           // WebSocket 连接建立后调用
+	receive     func(oc *adapterConn, data []byte)
+
+	reverseURLs       []string                        // 反向 WebSocket 的地址
+	reversePerBot     bool                            // 是否每个Bot账号单独建立一条反向连接
+	reverseInterval   time.Duration                   // 反向连接的重连间隔
+	reverseHeader     func(selfId uint32) http.Header // 反向连接的请求头
+	heartbeatInterval time.Duration                   // 心跳间隔，为0时不发送
+	heartbeat         func(clients []*LagrangeClient) // 发送心跳
+
+	bot      *Bot
+	logger   log.CryoLogger
+	store    *adapterMessageStore
+	upgrader websocket.Upgrader
+
+	mutex         sync.Mutex
+	enabled       bool
+	server        *http.Server
+	conns         map[*adapterConn]struct{}
+	reverse       map[uint32]context.CancelFunc // Bot账号 -> 停止反向连接，不区分账号时使用0
+	stopHeartbeat context.CancelFunc
+}
+
+// newAdapterBase 创建适配器的公共部分
+func newAdapterBase(name, version, description string) *adapterBase {
+	a := &adapterBase{
+		name:            name,
+		version:         version,
+		description:     description,
+		store:           newAdapterMessageStore(adapterMessageStoreSize),
+		reverseInterval: 5 * time.Second,
+		conns:           make(map[*adapterConn]struct{}),
+		reverse:         make(map[uint32]context.CancelFunc),
+	}
+	a.upgrader.CheckOrigin = a.checkOrigin
+	return a
+}
+
+// checkOrigin 检查 WebSocket 连接的来源
+//
+// 协议的客户端一般不是浏览器，设置了访问令牌时通过令牌来限制来源；
+// 没有设置访问令牌时拒绝跨域的连接，避免任意网页通过浏览器连接到本机的适配器
+func (a *adapterBase) checkOrigin(r *http.Request) bool {
+	if a.accessToken != "" {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// Init 初始化适配器，注册用于上报事件的后处理中间件
+func (a *adapterBase) Init(bot *Bot) error {
+	a.bot = bot
+	a.logger = bot.GetLogger()
+	mw := NewUniMiddleware()
+	mw.AddTag("adapter", a.name)
+	mw.AddHandler(a.handleEvent)
+	bot.GetBus().AddPostMiddleware(mw)
+	return nil
+}
+
+// GetPluginName 获取插件名称信息
+func (a *adapterBase) GetPluginName() string {
+	return a.name
+}
+
+// GetPluginVersion 获取插件版本号信息
+func (a *adapterBase) GetPluginVersion() string {
+	return a.version
+}
+
+// GetPluginDescription 获取插件描述信息
+func (a *adapterBase) GetPluginDescription() string {
+	return a.description
+}
+
+// GetPluginAuthor 获取插件作者信息
+func (a *adapterBase) GetPluginAuthor() string {
+	return "machinacanis"
+}
+
+// Enable 启用适配器，开始监听并连接反向 WebSocket
+func (a *adapterBase) Enable() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.enabled {
+		return
+	}
+	a.enabled = true
+	if a.addr != "" {
+		if a.accessToken == "" {
+			a.logger.Warnf("[%s] 没有设置访问令牌，任何能访问 %s 的人都可以操作Bot", a.name, a.addr)
+		}
+		ln, err := net.Listen("tcp", a.addr)
+		if err != nil {
+			a.logger.Errorf("[%s] 监听 %s 失败：%v", a.name, a.addr, err)
+		} else {
+			a.server = &http.Server{Handler: a.handler}
+			go func(server *http.Server) {
+				if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					a.logger.Errorf("[%s] HTTP 服务出现错误：%v", a.name, err)
+				}
+			}(a.server)
+			a.logger.Successf("[%s] 正在监听 %s", a.name, a.addr)
+		}
+	}
+	if a.reversePerBot {
+		if a.bot != nil {
+			for _, c := range a.bot.getConnectedClients() {
+				a.startReverseLocked(c.Uin)
+			}
+		}
+	} else {
+		a.startReverseLocked(0)
+	}
+	if a.heartbeatInterval > 0 && a.heartbeat != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		a.stopHeartbeat = cancel
+		go a.heartbeatLoop(ctx)
+	}
+}
+
+// Disable 禁用适配器，关闭所有连接并停止监听
+func (a *adapterBase) Disable() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if !a.enabled {
+		return
+	}
+	a.enabled = false
+	if a.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = a.server.Shutdown(ctx)
+		cancel()
+		a.server = nil
+	}
+	for uin, cancel := range a.reverse {
+		cancel()
+		delete(a.reverse, uin)
+	}
+	// Shutdown 不会关闭已经升级的 WebSocket 连接，需要手动关闭
+	for oc := range a.conns {
+		_ = oc.ws.Close()
+	}
+	if a.stopHeartbeat != nil {
+		a.stopHeartbeat()
+		a.stopHeartbeat = nil
+	}
+}
+
+// IsEnable 是否已启用适配器
+func (a *adapterBase) IsEnable() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.enabled
+}
+
+// handleEvent 维护反向连接，并把事件转换后交给协议上报
+func (a *adapterBase) handleEvent(e Event) Event {
+	if !a.IsEnable() {
+		return e
+	}
+	if a.reversePerBot {
+		switch v := e.(type) {
+		case *BotConnectedEvent:
+			a.mutex.Lock()
+			a.startReverseLocked(v.ClientUin)
+			a.mutex.Unlock()
+		case *BotDisconnectedEvent:
+			a.stopReverse(v.ClientUin)
+		}
+	}
+	if ae := newAdapterEvent(e, a.store); ae != nil && a.report != nil {
+		a.report(e, ae)
+	}
+	return e
+}
+
+// client 获取执行动作的Bot客户端，selfId 为0时使用Uin最小的在线客户端
+func (a *adapterBase) client(selfId uint32) (*LagrangeClient, error) {
+	if a.bot == nil {
+		return nil, ErrNotOnline
+	}
+	return a.bot.selectClient(selfId)
+}
+
+// checkAccessToken 校验请求中的访问令牌，通过时返回0，否则返回对应的HTTP状态码
+//
+// 令牌可以放在 Authorization 请求头（Bearer <token> 或者 Token <token>）或者 access_token 查询参数中
+func checkAccessToken(r *http.Request, accessToken string) int {
+	if accessToken == "" {
+		return 0
+	}
+	token := r.URL.Query().Get("access_token")
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		_, token, _ = strings.Cut(auth, " ")
+	}
+	if token == "" {
+		return http.StatusUnauthorized
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(accessToken)) != 1 {
+		return http.StatusForbidden
+	}
+	return 0
+}
+
+// adapterConn 是适配器的一条 WebSocket 连接
+type adapterConn struct {
+	ws         *websocket.Conn
+	role       string
+	selfId     uint32      // 连接绑定的Bot账号，为0时接收所有账号的事件
+	ready      atomic.Bool // 是否已经可以接收事件
+	writeMutex sync.Mutex
+}
+
+// write 发送一条文本消息，gorilla/websocket 不支持并发写入
+func (oc *adapterConn) write(data []byte) error {
+	oc.writeMutex.Lock()
+	defer oc.writeMutex.Unlock()
+	_ = oc.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return oc.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// acceptEvent 判断连接是否需要接收指定Bot账号的事件
+func (oc *adapterConn) acceptEvent(selfId uint32) bool {
+	return oc.ready.Load() && (oc.selfId == 0 || oc.selfId == selfId)
+}
+
+// serveConn 处理一条 WebSocket 连接，直到连接断开
+func (a *adapterBase) serveConn(oc *adapterConn) {
+	a.mutex.Lock()
+	a.conns[oc] = struct{}{}
+	a.mutex.Unlock()
+	defer func() {
+		a.mutex.Lock()
+		delete(a.conns, oc)
+		a.mutex.Unlock()
+		_ = oc.ws.Close()
+	}()
+
+	if a.open != nil {
+		a.open(oc)
+	}
+	for {
+		_, data, err := oc.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if a.receive != nil {
+			go a.receive(oc, data)
+		}
+	}
+}
+
+// broadcast 把事件发送给所有需要接收的 WebSocket 连接
+func (a *adapterBase) broadcast(selfId uint32, data []byte) {
+	a.mutex.Lock()
+	conns := make([]*adapterConn, 0, len(a.conns))
+	for oc := range a.conns {
+		if oc.acceptEvent(selfId) {
+			conns = append(conns, oc)
+		}
+	}
+	a.mutex.Unlock()
+	for _, oc := range conns {
+		if err := oc.write(data); err != nil {
+			_ = oc.ws.Close() // 关闭后读取协程会把连接移除
+		}
+	}
+}
+
+// startReverseLocked 建立反向 WebSocket 连接，调用时需要持有锁
+func (a *adapterBase) startReverseLocked(selfId uint32) {
+	if (a.reversePerBot && selfId == 0) || !a.enabled || len(a.reverseURLs) == 0 || a.reverse[selfId] != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.reverse[selfId] = cancel
+	for _, url := range a.reverseURLs {
+		go a.reverseLoop(ctx, selfId, url)
+	}
+}
+
+// stopReverse 断开Bot账号的反向 WebSocket 连接
+func (a *adapterBase) stopReverse(selfId uint32) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if cancel, ok := a.reverse[selfId]; ok {
+		cancel()
+		delete(a.reverse, selfId)
+	}
+}
+
+// reverseLoop 维持一条反向 WebSocket 连接，断开后会自动重连
+func (a *adapterBase) reverseLoop(ctx context.Context, selfId uint32, url string) {
+	header := http.Header{}
+	if a.reverseHeader != nil {
+		header = a.reverseHeader(selfId)
+	}
+	for {
+		ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+		if err == nil {
+			a.logger.Infof("[%s] 已连接到反向 WebSocket %s", a.name, url)
+			stop := context.AfterFunc(ctx, func() { _ = ws.Close() })
+			a.serveConn(&adapterConn{ws: ws, selfId: selfId})
+			stop()
+			if ctx.Err() == nil {
+				a.logger.Warnf("[%s] 反向 WebSocket %s 已断开，%v 后重连", a.name, url, a.reverseInterval)
+			}
+		} else if ctx.Err() == nil {
+			a.logger.Warnf("[%s] 连接反向 WebSocket %s 失败：%v", a.name, url, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(a.reverseInterval):
+		}
+	}
+}
+
+// heartbeatLoop 定期发送心跳
+func (a *adapterBase) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(a.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if a.bot != nil {
+				a.heartbeat(a.bot.getConnectedClients())
+			}
+		}
+	}
+}
+
+// sendMessage 发送消息并保存消息ID
+func (a *adapterBase) sendMessage(c *LagrangeClient, target SendTarget, m Message) (int32, error) {
+	sent, err := c.sendMessage(target, &m)
+	if err != nil {
+		return 0, err
+	}
+	return a.store.saveSent(c, sent), nil
+}
+
+// recallMessage 撤回消息ID对应的消息，优先使用收到或者发送这条消息的客户端
+func (a *adapterBase) recallMessage(c *LagrangeClient, rec *adapterMessageRecord) error {
+	if rc := a.bot.GetClientByUin(rec.SelfId); rc != nil {
+		c = rc
+	}
+	switch rec.Target.Type {
+	case GroupTarget:
+		return c.RecallGroupMessage(rec.Target.GroupUin, rec.Seq)
+	case PrivateTarget:
+		return c.RecallPrivateMessage(rec.Target.UserUin, rec.Seq, rec.Random, rec.ClientSeq, rec.Time)
+	}
+	return newActionError("recall_message", rec.Target.GroupUin, rec.Target.UserUin, ErrUnsupportedTarget, nil)
+}
+
+// replyId 获取回复元素引用的消息的消息ID，消息不在表中时会新建一条只有引用信息的记录
+func (a *adapterBase) replyId(selfId uint32, target SendTarget, r *Reply) int32 {
+	if r.GroupUin != 0 {
+		target = SendTarget{Type: GroupTarget, GroupUin: r.GroupUin}
+	}
+	return a.store.save(&adapterMessageRecord{SelfId: selfId, Target: target, SenderUin: r.SenderUin, Seq: r.ReplySeq, Time: r.Time})
+}
+
+// resolveReplies 把消息中回复元素的消息ID还原为被回复的消息，找不到时把ID当作消息序号
+func (a *adapterBase) resolveReplies(m Message) {
+	for i, e := range m {
+		r, ok := e.(*Reply)
+		if !ok {
+			continue
+		}
+		rec := a.store.get(int32(r.ReplySeq))
+		if rec == nil {
+			continue
+		}
+		reply := &Reply{lgrmessage.ReplyElement{
+			ReplySeq: rec.Seq, SenderUin: rec.SenderUin, Time: rec.Time, Elements: rec.Message.ToIMessageElements(),
+		}}
+		if rec.Target.Type == GroupTarget {
+			reply.GroupUin = rec.Target.GroupUin
+		}
+		m[i] = reply
+	}
+}
+
+// adapterMessageStoreSize 最多保存的消息ID数量，超出后会淘汰最早的记录
+const adapterMessageStoreSize = 10000
+
+// adapterMessageRecord 是消息ID对应的QQ消息信息
+type adapterMessageRecord struct {
+	SelfId         uint32     // 收到或者发送这条消息的Bot账号
+	Target         SendTarget // 消息所在的会话，私聊和临时会话的 UserUin 是对方的Uin
+	SenderUin      uint32
+	SenderNickname string
+	Seq            uint32
+	Random         uint32
+	ClientSeq      uint32
+	Time           uint32
+	Message        Message
+}
+
+// key 获取消息在会话中的唯一标识
+func (r *adapterMessageRecord) key() string {
+	switch r.Target.Type {
+	case GroupTarget:
+		return fmt.Sprintf("g:%d:%d", r.Target.GroupUin, r.Seq)
+	case TempTarget:
+		return fmt.Sprintf("t:%d:%d:%d", r.SelfId, r.Target.UserUin, r.Seq)
+	default:
+		return fmt.Sprintf("p:%d:%d:%d", r.SelfId, r.Target.UserUin, r.Seq)
+	}
+}
+
+// adapterMessageStore 保存适配器使用的消息ID和QQ消息的对应关系
+//
+// OneBot v11 的消息ID是一个 int32，不足以表示撤回私聊消息时需要的序号、随机数和时间，所以需要单独分配，
+// 其他协议的消息ID是字符串，为了统一也使用同样的ID
+type adapterMessageStore struct {
+	mutex   sync.Mutex
+	nextId  int32
+	records map[int32]*adapterMessageRecord
+	ids     map[string]int32
+	order   []int32 // 按分配顺序排列的消息ID，用作环形缓冲区
+	pos     int
+}
+
+// newAdapterMessageStore 创建一个新的消息ID表
+func newAdapterMessageStore(size int) *adapterMessageStore {
+	return &adapterMessageStore{
+		records: make(map[int32]*adapterMessageRecord),
+		ids:     make(map[string]int32),
+		order:   make([]int32, size),
+	}
+}
+
+// save 保存消息并返回它的消息ID，同一条消息会返回同一个ID
+func (s *adapterMessageStore) save(r *adapterMessageRecord) int32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := r.key()
+	if id, ok := s.ids[key]; ok {
+		if r.Message != nil { // 之前只保存了引用信息时补全消息内容
+			s.records[id] = r
+		}
+		return id
+	}
+	if s.nextId == math.MaxInt32 {
+		s.nextId = 0
+	}
+	s.nextId++
+	id := s.nextId
+	if old := s.order[s.pos]; old != 0 {
+		if or, ok := s.records[old]; ok {
+			delete(s.ids, or.key())
+			delete(s.records, old)
+		}
+	}
+	s.order[s.pos] = id
+	s.pos = (s.pos + 1) % len(s.order)
+	s.records[id] = r
+	s.ids[key] = id
+	return id
+}
+
+// get 根据消息ID获取消息，不存在时返回nil
+func (s *adapterMessageStore) get(id int32) *adapterMessageRecord {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.records[id]
+}
+
+// saveSent 保存Bot发送的消息
+func (s *adapterMessageStore) saveSent(c *LagrangeClient, sent *SentMessage) int32 {
+	return s.save(&adapterMessageRecord{
+		SelfId:         c.Uin,
+		Target:         sent.Target,
+		SenderUin:      c.Uin,
+		SenderNickname: c.Nickname,
+		Seq:            sent.MessageId,
+		Random:         sent.InternalId,
+		ClientSeq:      sent.ClientSeq,
+		Time:           sent.Time,
+		Message:        sent.Elements,
+	})
+}
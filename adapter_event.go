@@ -0,0 +1,260 @@
+package cryo
+
+import (
+	"fmt"
+	"time"
+)
+
+// adapterEvent 是协议适配器共用的中间事件，由cryo事件转换而来
+//
+// 事件的分类沿用 OneBot v11 的命名，各个协议在此基础上转换为自己的格式
+type adapterEvent struct {
+	Type       string // 事件大类：message、notice、request 或者 meta_event
+	Detail     string // 具体类型，例如 group、group_recall、friend、lifecycle
+	SubType    string
+	Time       int64
+	SelfId     uint32
+	UserId     uint32 // 事件的主体用户，消息事件中是发送者
+	UserName   string
+	GroupId    uint32
+	GroupName  string
+	OperatorId uint32
+	TargetId   uint32
+	Duration   uint32     // 禁言时长，单位为秒
+	MessageId  int32      // 消息ID，来自适配器的消息ID表
+	Target     SendTarget // 消息事件所在的会话
+	Message    Message
+	Card       string // 发送者的群名片
+	Role       string // 发送者的群角色：owner、admin 或者 member
+	Title      string // 群头衔
+	Comment    string // 请求的附加信息
+	Flag       string // 处理请求时使用的标识
+}
+
+// newAdapterEvent 把cryo事件转换为适配器的中间事件，消息会被保存到消息ID表中，没有对应的事件时返回nil
+func newAdapterEvent(e Event, store *adapterMessageStore) *adapterEvent {
+	ue := e.GetUniEvent()
+	ae := &adapterEvent{Time: int64(ue.Time), SelfId: ue.ClientUin}
+	if ae.Time == 0 {
+		ae.Time = time.Now().Unix()
+	}
+	notice := func(detail, subType string) {
+		ae.Type, ae.Detail, ae.SubType = "notice", detail, subType
+	}
+	request := func(detail, subType string) {
+		ae.Type, ae.Detail, ae.SubType = "request", detail, subType
+	}
+
+	switch v := e.(type) {
+	case *PrivateMessageEvent:
+		peer := v.SenderUin
+		if peer == v.ClientUin { // Bot自己在其他设备上发送的消息
+			peer = v.TargetUin
+		}
+		ae.fillMessage(store, &v.UniMessageEvent, SendTarget{Type: PrivateTarget, UserUin: peer}, v.InternalId, v.ClientSeq)
+		ae.Detail, ae.SubType = "private", "other"
+		if v.IsSenderFriend {
+			ae.SubType = "friend"
+		}
+	case *TempMessageEvent:
+		ae.fillMessage(store, &v.UniMessageEvent, SendTarget{Type: TempTarget, GroupUin: v.GroupUin, UserUin: v.SenderUin}, 0, 0)
+		ae.Detail, ae.SubType = "private", "group"
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+	case *GroupMessageEvent:
+		ae.fillMessage(store, &v.UniMessageEvent, SendTarget{Type: GroupTarget, GroupUin: v.GroupUin}, v.InternalId, 0)
+		ae.Detail, ae.SubType = "group", "normal"
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.Card = v.SenderCardname
+		var member *MemberInfo
+		if c := v.GetClient(); c != nil && c.cache != nil {
+			member = c.cache.getMember(v.GroupUin, v.SenderUin) // 只使用缓存，避免上报时请求网络
+		}
+		ae.Role = adapterRole(member)
+		if member != nil {
+			ae.Title = member.SpecialTitle
+		}
+
+	case *FriendRecallEvent:
+		notice("friend_recall", "")
+		ae.UserId = v.Uin
+		ae.Target = SendTarget{Type: PrivateTarget, UserUin: v.Uin}
+		ae.MessageId = store.save(&adapterMessageRecord{
+			SelfId: v.ClientUin, Target: ae.Target, SenderUin: v.Uin, Seq: uint32(v.Seqence), Random: v.Random,
+		})
+	case *GroupRecallEvent:
+		notice("group_recall", "")
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.UserId, ae.UserName = v.SenderUin, v.SenderNickname
+		ae.OperatorId = v.OperatorUin
+		ae.Target = SendTarget{Type: GroupTarget, GroupUin: v.GroupUin}
+		ae.MessageId = store.save(&adapterMessageRecord{
+			SelfId: v.ClientUin, Target: ae.Target, SenderUin: v.SenderUin, Seq: uint32(v.Seqence), Random: v.Random,
+		})
+	case *GroupMemberIncreaseEvent:
+		notice("group_increase", "approve")
+		if v.InviterUin != 0 {
+			ae.SubType = "invite"
+		}
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.UserId, ae.UserName = v.Uin, v.Nickname
+		ae.OperatorId = v.InviterUin
+	case *GroupMemberDecreaseEvent:
+		switch {
+		case v.IsSelf && v.IsKicked:
+			notice("group_decrease", "kick_me")
+		case v.IsKicked:
+			notice("group_decrease", "kick")
+		default:
+			notice("group_decrease", "leave")
+		}
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.UserId, ae.UserName = v.Uin, v.Nickname
+	case *GroupMemberPermissionUpdatedEvent:
+		notice("group_admin", "unset")
+		if v.IsAdmin {
+			ae.SubType = "set"
+		}
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.UserId, ae.UserName = v.Uin, v.Nickname
+	case *GroupMuteEvent:
+		notice("group_ban", "ban")
+		if v.Duration == 0 {
+			ae.SubType = "lift_ban"
+		}
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.UserId, ae.UserName = v.TargetUin, v.TargetNickname // 全体禁言时为0
+		ae.OperatorId = v.OperatorUin
+		ae.Duration = v.Duration
+	case *NewFriendEvent:
+		notice("friend_add", "")
+		ae.UserId, ae.UserName = v.Uin, v.Nickname
+	case *FriendPokeEvent:
+		notice("notify", "poke")
+		ae.UserId = v.SenderUin
+		ae.TargetId = v.TargetUin
+	case *GroupPokeEvent:
+		notice("notify", "poke")
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.UserId, ae.UserName = v.SenderUin, v.SenderNickname
+		ae.TargetId = v.TargetUin
+	case *GroupMemberSpecialTitleUpdated:
+		notice("notify", "title")
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.UserId, ae.UserName = v.Uin, v.Nickname
+		ae.Title = v.NewTitle
+	case *GroupDigestEvent:
+		notice("essence", "add")
+		if v.IsRemove {
+			ae.SubType = "delete"
+		}
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.UserId, ae.UserName = v.SenderUin, v.SenderNickname
+		ae.OperatorId = v.OperatorUin
+		ae.Target = SendTarget{Type: GroupTarget, GroupUin: v.GroupUin}
+		ae.MessageId = store.save(&adapterMessageRecord{
+			SelfId: v.ClientUin, Target: ae.Target, SenderUin: v.SenderUin, Seq: v.MessageId, Random: v.InternalId,
+		})
+
+	case *NewFriendRequestEvent:
+		request("friend", "")
+		ae.UserId, ae.UserName = v.Uin, v.Nickname
+		ae.Comment = v.Message
+		ae.Flag = v.Uid
+	case *GroupMemberJoinRequestEvent:
+		request("group", "add")
+		kind := "add"
+		if v.InviterUin != 0 {
+			kind = "invited"
+		}
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.UserId, ae.UserName = v.SenderUin, v.SenderNickname
+		ae.OperatorId = v.InviterUin
+		ae.Comment = v.Answer
+		ae.Flag = adapterRequestFlag(v.GroupUin, v.RequestSeqence, kind)
+	case *GroupInviteEvent:
+		request("group", "invite")
+		ae.GroupId, ae.GroupName = v.GroupUin, v.GroupName
+		ae.UserId, ae.UserName = v.InviterUin, v.InviterNickname
+		ae.Flag = adapterRequestFlag(v.GroupUin, v.RequestSeqence, "invite")
+
+	case *BotConnectedEvent:
+		ae.Type, ae.Detail, ae.SubType = "meta_event", "lifecycle", "enable"
+	case *BotDisconnectedEvent:
+		ae.Type, ae.Detail, ae.SubType = "meta_event", "lifecycle", "disable"
+	default:
+		return nil
+	}
+	if ae.Type == "meta_event" {
+		// 事件触发时客户端可能还不在已连接的列表中，直接从事件中读取账号信息
+		ae.UserId = ue.ClientUin
+		if c := e.GetClient(); c != nil {
+			ae.UserName = c.Nickname
+		}
+	}
+	return ae
+}
+
+// fillMessage 填充消息事件的公共字段并保存消息
+func (ae *adapterEvent) fillMessage(store *adapterMessageStore, me *UniMessageEvent, target SendTarget, random, clientSeq uint32) {
+	ae.Type = "message"
+	ae.Target = target
+	ae.UserId, ae.UserName = me.SenderUin, me.SenderNickname
+	ae.Message = me.MessageElements
+	ae.MessageId = store.save(&adapterMessageRecord{
+		SelfId:         me.ClientUin,
+		Target:         target,
+		SenderUin:      me.SenderUin,
+		SenderNickname: me.SenderNickname,
+		Seq:            me.MessageId,
+		Random:         random,
+		ClientSeq:      clientSeq,
+		Time:           me.Time,
+		Message:        me.MessageElements,
+	})
+}
+
+// adapterRole 获取群成员的角色名称
+func adapterRole(m *MemberInfo) string {
+	switch {
+	case m == nil:
+		return "member"
+	case m.IsOwner():
+		return "owner"
+	case m.IsAdmin():
+		return "admin"
+	}
+	return "member"
+}
+
+// adapterSex 获取性别名称
+func adapterSex(sex uint32) string {
+	switch sex {
+	case 1:
+		return "male"
+	case 2:
+		return "female"
+	}
+	return "unknown"
+}
+
+// adapterRequestFlag 构造加群请求的标识，kind 为 add、invited 或者 invite
+func adapterRequestFlag(groupUin uint32, sequence uint64, kind string) string {
+	return fmt.Sprintf("%d:%d:%s", groupUin, sequence, kind)
+}
+
+// setAdapterRequest 根据请求标识处理好友请求或者加群请求，好友请求的标识是请求者的Uid
+func setAdapterRequest(c *LagrangeClient, flag string, approve bool, reason string) error {
+	var groupUin uint32
+	var sequence uint64
+	var kind string
+	if _, err := fmt.Sscanf(flag, "%d:%d:%s", &groupUin, &sequence, &kind); err != nil {
+		return c.SetFriendRequest(flag, approve)
+	}
+	switch kind {
+	case "invite":
+		return c.SetGroupInvitation(groupUin, sequence, approve)
+	case "add", "invited":
+		return c.SetGroupJoinRequest(groupUin, sequence, kind == "invited", approve, reason)
+	}
+	return fmt.Errorf("请求标识 %q 无效", flag)
+}
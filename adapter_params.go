@@ -0,0 +1,115 @@
+package cryo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// errAdapterParams 是协议动作的参数错误时返回的错误
+var errAdapterParams = errors.New("参数错误")
+
+// adapterParams 是协议动作的参数，来自 JSON 时数字是 float64，来自查询参数和表单时是字符串
+type adapterParams map[string]any
+
+// getInt64 读取整数参数，第二个返回值表示参数是否存在
+func (p adapterParams) getInt64(key string) (int64, bool, error) {
+	v, ok := p[key]
+	if !ok || v == nil {
+		return 0, false, nil
+	}
+	switch n := v.(type) {
+	case float64:
+		return int64(n), true, nil
+	case int64:
+		return n, true, nil
+	case int:
+		return int64(n), true, nil
+	case uint32:
+		return int64(n), true, nil
+	case string:
+		if n == "" {
+			return 0, false, nil
+		}
+		i, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			return 0, true, fmt.Errorf("%w：%s 不是数字", errAdapterParams, key)
+		}
+		return i, true, nil
+	}
+	return 0, true, fmt.Errorf("%w：%s 不是数字", errAdapterParams, key)
+}
+
+// durationOf 把整数参数转换为时长，超出 time.Duration 范围的值会被限制为最大值或最小值，而不是溢出
+func durationOf(n int64, unit time.Duration) time.Duration {
+	if n > math.MaxInt64/int64(unit) {
+		return math.MaxInt64
+	}
+	if n < math.MinInt64/int64(unit) {
+		return math.MinInt64
+	}
+	return time.Duration(n) * unit
+}
+
+// getUint32 读取 QQ号、群号等无符号整数参数
+func (p adapterParams) getUint32(key string) (uint32, bool, error) {
+	n, ok, err := p.getInt64(key)
+	if err != nil || !ok {
+		return 0, ok, err
+	}
+	if n < 0 || n > 1<<32-1 {
+		return 0, true, fmt.Errorf("%w：%s 超出范围", errAdapterParams, key)
+	}
+	return uint32(n), true, nil
+}
+
+// requireUint32 读取必须存在的无符号整数参数
+func (p adapterParams) requireUint32(key string) (uint32, error) {
+	n, ok, err := p.getUint32(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("%w：缺少 %s", errAdapterParams, key)
+	}
+	return n, nil
+}
+
+// getString 读取字符串参数
+func (p adapterParams) getString(key string) string {
+	switch v := p[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// getBool 读取布尔参数，参数不存在时返回默认值
+func (p adapterParams) getBool(key string, def bool) bool {
+	switch v := p[key].(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	case float64:
+		return v != 0
+	}
+	return def
+}
+
+// groupMember 读取参数中的群号和成员Uin
+func (p adapterParams) groupMember() (groupUin, userUin uint32, err error) {
+	if groupUin, err = p.requireUint32("group_id"); err != nil {
+		return
+	}
+	userUin, err = p.requireUint32("user_id")
+	return
+}
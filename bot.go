@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/go-co-op/gocron/v2"
 	"github.com/machinacanis/cryo/log"
+	"slices"
+	"sync"
 	"time"
 )
 
@@ -14,10 +16,14 @@ import (
 type Bot struct {
 	initFlag         bool                       // 是否初始化完成
 	connectedClients map[string]*LagrangeClient // 已连接的Bot客户端集合
+	clientsMutex     sync.RWMutex               // 保护已连接的Bot客户端集合
 	bus              *EventBus                  // 事件总线
 	conf             Config                     // 配置项
 	plugin           []Plugin                   // 插件列表
+	pluginMutex      sync.RWMutex               // 保护插件列表，远程插件会在运行时加入和移除
 	scheduler        gocron.Scheduler           // 定时任务调度器
+	logTee           *log.TeeLogger             // 包装传入的日志记录器，用于添加日志接收器
+	tasksMutex       sync.RWMutex               // 保护定时任务列表，HTTP 接口和仪表盘会在其他 goroutine 中读取
 
 	Logger log.CryoLogger   // 日志记录器
 	Tasks  []*ScheduledTask // 定时任务列表
@@ -44,8 +50,14 @@ func (b *Bot) Init(logger log.CryoLogger, c ...Config) {
 		EnableMessagePrintMiddleware: true,
 		EnableEventDebugMiddleware:   false,
 		EnableCronScheduler:          false,
+		EnableSendQueue:              false,
+		FriendRequestPolicy:          FriendRequestManual,
+		EnableDedupMiddleware:        false,
+		DedupTTL:                     300,
+		EnableGroupLeaderElection:    false,
 	}
-	b.Logger = logger
+	b.logTee = log.NewTeeLogger(logger)
+	b.Logger = b.logTee
 	if len(c) == 0 { // 如果没有传入配置项，则尝试加载本地配置文件
 		co, err := ReadCryoConfig()
 		if err == nil {
@@ -79,6 +91,28 @@ func (b *Bot) Init(logger log.CryoLogger, c ...Config) {
 		if c[0].EnableCronScheduler {
 			defaultConfig.EnableCronScheduler = c[0].EnableCronScheduler
 		}
+		if c[0].EnableSendQueue {
+			defaultConfig.EnableSendQueue = c[0].EnableSendQueue
+		}
+		defaultConfig.SendQueue = c[0].SendQueue // 没有设置的发送队列配置项会在创建队列时使用默认值
+		if c[0].FriendRequestPolicy != "" {
+			defaultConfig.FriendRequestPolicy = c[0].FriendRequestPolicy
+		}
+		if c[0].FriendRequestPattern != "" {
+			defaultConfig.FriendRequestPattern = c[0].FriendRequestPattern
+		}
+		if c[0].EnableDedupMiddleware {
+			defaultConfig.EnableDedupMiddleware = c[0].EnableDedupMiddleware
+		}
+		if c[0].DedupTTL > 0 {
+			defaultConfig.DedupTTL = c[0].DedupTTL
+		}
+		if c[0].EnableGroupLeaderElection {
+			defaultConfig.EnableGroupLeaderElection = c[0].EnableGroupLeaderElection
+		}
+		defaultConfig.Webhooks = c[0].Webhooks
+		defaultConfig.Dashboard = c[0].Dashboard
+		defaultConfig.Recorder = c[0].Recorder
 	}
 	b.conf = defaultConfig // 初始化配置
 
@@ -95,8 +129,24 @@ func (b *Bot) Init(logger log.CryoLogger, c ...Config) {
 	// setConnectPrintMiddleware()
 	// 设置消息打印中间件
 	// setMessagePrintMiddleware()
+	// 配置了录制文件时添加事件录制器，它需要在内置中间件之前注册
+	if b.conf.Recorder.File != "" {
+		if _, err := b.AddRecorder(b.conf.Recorder); err != nil {
+			b.Logger.Errorf("[Cryo] 事件录制器配置错误：%v", err)
+		}
+	}
 	// 设置事件调试中间件
-	setDefaultMiddleware(b.bus, b.Logger, b.conf)
+	setDefaultMiddleware(b.bus, b.Logger, b.conf, b.getConnectedClients)
+	// 设置配置文件中的 Webhook
+	for _, wc := range b.conf.Webhooks {
+		if _, err := b.AddWebhook(wc); err != nil {
+			b.Logger.Errorf("[Cryo] Webhook %s 配置错误：%v", wc.URL, err)
+		}
+	}
+	// 配置了监听地址时添加 Web 管理面板
+	if b.conf.Dashboard.Addr != "" {
+		b.AddPlugin(NewDashboard(b.conf.Dashboard))
+	}
 
 	b.initFlag = true
 }
@@ -108,6 +158,15 @@ func (b *Bot) IsInit() bool {
 
 // Start 启动cryobot
 func (b *Bot) Start() error {
+	if err := b.Run(); err != nil {
+		return err
+	}
+
+	select {} // 阻塞主线程，运行事件循环
+}
+
+// Run 启动cryobot但不阻塞主线程，适合在测试或者已经有主循环的程序中使用
+func (b *Bot) Run() error {
 	if !b.initFlag {
 		// 没有进行初始化
 		b.Logger.Error("cryobot 没有进行初始化，请先调用 Init() 函数进行初始化！")
@@ -118,8 +177,14 @@ func (b *Bot) Start() error {
 		b.Logger.Success("[Cryo] 定时任务调度器已启用")
 		b.scheduler.Start() // 启动定时任务调度器
 	}
+	return nil
+}
 
-	select {} // 阻塞主线程，运行事件循环
+// SetScheduler 替换定时任务调度器，例如在测试中使用 gocron.WithClock 创建的使用假时钟的调度器
+//
+// 需要在 Run 和添加定时任务之前调用，已经添加到原来的调度器中的任务不会被转移
+func (b *Bot) SetScheduler(s gocron.Scheduler) {
+	b.scheduler = s
 }
 
 // AutoConnect 自动尝试建立连接，如果没有已保存的连接信息或已保存的连接信息无效，则尝试创建并连接新的bot客户端
@@ -162,7 +227,7 @@ func (b *Bot) ConnectSavedClient(info ClientInfo) bool {
 	if !c.SignatureLogin() {
 		return false
 	}
-	b.connectedClients[c.Id] = c
+	b.addConnectedClient(c)
 	return true
 }
 
@@ -174,10 +239,20 @@ func (b *Bot) ConnectNewClient() bool {
 	if !c.QRCodeLogin() {
 		return false
 	}
-	b.connectedClients[c.Id] = c
+	b.addConnectedClient(c)
 	return true
 }
 
+// ConnectWithProtocol 使用指定的协议实现连接一个Bot客户端，不需要登录，一般配合 MockProtocol 在测试中使用
+func (b *Bot) ConnectWithProtocol(protocol Protocol) *LagrangeClient {
+	c := NewLagrangeClient()
+	c.InitWithProtocol(b.bus, b.Logger, b.conf, protocol)
+	c.AfterLogin()
+	b.Logger.Infof("[Cryo] 已连接 %s：%s (%d)", c.Nickname, c.Id, c.Uin)
+	b.addConnectedClient(c)
+	return c
+}
+
 // ConnectAllSavedClient 尝试连接所有已保存的bot客户端
 func (b *Bot) ConnectAllSavedClient() {
 	// 读取历史连接的客户端
@@ -198,8 +273,28 @@ func (b *Bot) ConnectAllSavedClient() {
 	}
 }
 
+// addConnectedClient 把登录成功的Bot客户端加入到已连接的客户端集合中
+func (b *Bot) addConnectedClient(c *LagrangeClient) {
+	b.clientsMutex.Lock()
+	b.connectedClients[c.Id] = c
+	b.clientsMutex.Unlock()
+}
+
+// getConnectedClients 获取已连接的Bot客户端列表的快照
+func (b *Bot) getConnectedClients() []*LagrangeClient {
+	b.clientsMutex.RLock()
+	defer b.clientsMutex.RUnlock()
+	clients := make([]*LagrangeClient, 0, len(b.connectedClients))
+	for _, c := range b.connectedClients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
 // GetClientById 获取指定ID的bot客户端
 func (b *Bot) GetClientById(id string) *LagrangeClient {
+	b.clientsMutex.RLock()
+	defer b.clientsMutex.RUnlock()
 	if client, ok := b.connectedClients[id]; ok {
 		return client
 	}
@@ -208,7 +303,7 @@ func (b *Bot) GetClientById(id string) *LagrangeClient {
 
 // GetClientByUin 获取指定Uin的bot客户端
 func (b *Bot) GetClientByUin(uin uint32) *LagrangeClient {
-	for _, client := range b.connectedClients {
+	for _, client := range b.getConnectedClients() {
 		if client.Uin == uin {
 			return client
 		}
@@ -218,7 +313,7 @@ func (b *Bot) GetClientByUin(uin uint32) *LagrangeClient {
 
 // GetClientByUid 获取指定Uid的bot客户端
 func (b *Bot) GetClientByUid(uid string) *LagrangeClient {
-	for _, client := range b.connectedClients {
+	for _, client := range b.getConnectedClients() {
 		if client.Uid == uid {
 			return client
 		}
@@ -226,6 +321,26 @@ func (b *Bot) GetClientByUid(uid string) *LagrangeClient {
 	return nil
 }
 
+// selectClient 选择执行动作的Bot客户端，uin 为0时使用Uin最小的客户端
+func (b *Bot) selectClient(uin uint32) (*LagrangeClient, error) {
+	if uin != 0 {
+		if c := b.GetClientByUin(uin); c != nil {
+			return c, nil
+		}
+		return nil, fmt.Errorf("Bot账号 %d 没有连接", uin)
+	}
+	var result *LagrangeClient
+	for _, c := range b.getConnectedClients() {
+		if result == nil || c.Uin < result.Uin {
+			result = c
+		}
+	}
+	if result == nil {
+		return nil, ErrNotOnline
+	}
+	return result, nil
+}
+
 // GetClient 获取指定事件对应的bot客户端
 func (b *Bot) GetClient(event Event) *LagrangeClient {
 	return b.GetClientById(event.GetUniEvent().ClientId)
@@ -247,13 +362,17 @@ func (b *Bot) AddPlugin(plugin ...Plugin) {
 			b.Logger.Successf("[Cryo] 插件 %s 已成功加载", p.GetPluginName())
 			p.Enable()
 		}
+		b.pluginMutex.Lock()
 		b.plugin = append(b.plugin, p)
+		b.pluginMutex.Unlock()
 	}
 }
 
 // GetPlugin 获取插件
 func (b *Bot) GetPlugin(name string) []Plugin {
 	var plugins []Plugin
+	b.pluginMutex.RLock()
+	defer b.pluginMutex.RUnlock()
 	for _, p := range b.plugin {
 		if p.GetPluginName() == name {
 			plugins = append(plugins, p)
@@ -265,6 +384,8 @@ func (b *Bot) GetPlugin(name string) []Plugin {
 // GetEnabledPlugin 获取启用的插件
 func (b *Bot) GetEnabledPlugin() []Plugin {
 	var plugins []Plugin
+	b.pluginMutex.RLock()
+	defer b.pluginMutex.RUnlock()
 	for _, p := range b.plugin {
 		if p.IsEnable() {
 			plugins = append(plugins, p)
@@ -276,6 +397,8 @@ func (b *Bot) GetEnabledPlugin() []Plugin {
 // GetDisabledPlugin 获取禁用的插件
 func (b *Bot) GetDisabledPlugin() []Plugin {
 	var plugins []Plugin
+	b.pluginMutex.RLock()
+	defer b.pluginMutex.RUnlock()
 	for _, p := range b.plugin {
 		if !p.IsEnable() {
 			plugins = append(plugins, p)
@@ -286,11 +409,15 @@ func (b *Bot) GetDisabledPlugin() []Plugin {
 
 // GetAllPlugin 获取所有插件
 func (b *Bot) GetAllPlugin() []Plugin {
-	return b.plugin
+	b.pluginMutex.RLock()
+	defer b.pluginMutex.RUnlock()
+	return append([]Plugin(nil), b.plugin...)
 }
 
 // RemovePlugin 移除插件
 func (b *Bot) RemovePlugin(plugin ...Plugin) {
+	b.pluginMutex.Lock()
+	defer b.pluginMutex.Unlock()
 	for _, p := range plugin {
 		for i, pl := range b.plugin {
 			if pl.GetPluginName() == p.GetPluginName() {
@@ -307,6 +434,16 @@ func (b *Bot) GetLogger() log.CryoLogger {
 	return b.Logger
 }
 
+// AddLogSink 添加一个日志接收器，Bot和所有客户端、插件输出的日志都会交给它，返回移除这个接收器的函数
+//
+// 需要在 Init 之后调用，如果之后替换了 Bot.Logger，新的日志记录器输出的日志不会交给接收器
+func (b *Bot) AddLogSink(sink log.Sink) (remove func()) {
+	if b.logTee == nil {
+		return func() {}
+	}
+	return b.logTee.AddSink(sink)
+}
+
 // GetConfig 获取配置项
 func (b *Bot) GetConfig() Config {
 	return b.conf
@@ -317,7 +454,9 @@ func (b *Bot) AddDelayTask(name string, duration time.Duration, task func() erro
 	st := NewDelayTask(name, duration, task)
 	st.Set(b) // 设置任务
 	// 将任务添加到定时任务列表
+	b.tasksMutex.Lock()
 	b.Tasks = append(b.Tasks, st)
+	b.tasksMutex.Unlock()
 	return st
 }
 
@@ -326,7 +465,9 @@ func (b *Bot) AddIntervalTask(name string, duration time.Duration, isInstantly b
 	st := NewIntervalTask(name, duration, isInstantly, task)
 	st.Set(b) // 设置任务
 	// 将任务添加到定时任务列表
+	b.tasksMutex.Lock()
 	b.Tasks = append(b.Tasks, st)
+	b.tasksMutex.Unlock()
 	return st
 }
 
@@ -335,12 +476,23 @@ func (b *Bot) AddCronTask(name string, cron string, isWithSeconds bool, task fun
 	st := NewCronTask(name, cron, isWithSeconds, task)
 	st.Set(b) // 设置任务
 	// 将任务添加到定时任务列表
+	b.tasksMutex.Lock()
 	b.Tasks = append(b.Tasks, st)
+	b.tasksMutex.Unlock()
 	return st
 }
 
+// GetTasks 获取定时任务列表的快照
+func (b *Bot) GetTasks() []*ScheduledTask {
+	b.tasksMutex.RLock()
+	defer b.tasksMutex.RUnlock()
+	return slices.Clone(b.Tasks)
+}
+
 // GetTaskByName 根据名称获取定时任务
 func (b *Bot) GetTaskByName(name string) []*ScheduledTask {
+	b.tasksMutex.RLock()
+	defer b.tasksMutex.RUnlock()
 	var tasks []*ScheduledTask
 	for _, task := range b.Tasks {
 		if task.name == name {
@@ -352,6 +504,8 @@ func (b *Bot) GetTaskByName(name string) []*ScheduledTask {
 
 // GetTaskById 根据ID获取定时任务
 func (b *Bot) GetTaskById(id string) []*ScheduledTask {
+	b.tasksMutex.RLock()
+	defer b.tasksMutex.RUnlock()
 	var tasks []*ScheduledTask
 	for _, task := range b.Tasks {
 		if task.id == id {
@@ -367,12 +521,16 @@ func (b *Bot) StopTask(st *ScheduledTask) error {
 }
 
 // Send 快速根据事件内容发送消息
+//
+// Deprecated: 请使用 SendTo，它会返回已发送消息的句柄 SentMessage 和发送失败的原因
 func (b *Bot) Send(event MessageEvent, args ...interface{}) (ok bool, messageId uint32) {
 	// 根据事件获取对应的bot客户端
 	return b.GetClient(event).Send(event, args...)
 }
 
 // Reply 快速根据事件内容回复消息
+//
+// Deprecated: 请使用 ReplyTo，它会返回已发送消息的句柄 SentMessage 和发送失败的原因
 func (b *Bot) Reply(event MessageEvent, args ...interface{}) (ok bool, messageId uint32) {
 	// 根据事件获取对应的bot客户端
 	return b.GetClient(event).Reply(event, args...)
@@ -383,3 +541,15 @@ func (b *Bot) Poke(event MessageEvent) (ok bool) {
 	// 根据事件获取对应的bot客户端
 	return b.GetClient(event).Poke(event)
 }
+
+// SendTo 快速根据事件内容发送消息，返回已发送的消息或者发送失败的原因
+func (b *Bot) SendTo(event MessageEvent, args ...interface{}) (*SentMessage, error) {
+	// 根据事件获取对应的bot客户端
+	return b.GetClient(event).SendTo(event, args...)
+}
+
+// ReplyTo 快速根据事件内容回复消息，返回已发送的消息或者发送失败的原因
+func (b *Bot) ReplyTo(event MessageEvent, args ...interface{}) (*SentMessage, error) {
+	// 根据事件获取对应的bot客户端
+	return b.GetClient(event).ReplyTo(event, args...)
+}
@@ -1,7 +1,9 @@
 package cryo
 
 import (
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 )
 
 // MiddlewareOrdering 是中间件的执行顺序类型别名
@@ -21,8 +23,13 @@ type EventBus struct {
 	postMiddleware  []Middleware // 后处理中间件列表
 	syncMiddleware  []Middleware // 中间件列表
 	asyncMiddleware []Middleware // 并发中间件列表
+	panicHandler    PanicHandler // 中间件出现 panic 时的处理函数
+	pending         atomic.Int64 // 正在处理的事件和中间件数量
 }
 
+// PanicHandler 是中间件执行时出现 panic 的处理函数，value 是 panic 的值，stack 是出现 panic 时的调用栈
+type PanicHandler func(event Event, value any, stack []byte)
+
 // NewEventBus 创建一个新的事件总线
 func NewEventBus() *EventBus {
 	return &EventBus{
@@ -94,9 +101,12 @@ func (bus *EventBus) applySyncMiddleware(event Event) Event {
 
 		if middleware.IsGlobal() || middleware.HasType(eventType) {
 			wg.Add(1)
+			bus.pending.Add(1)
 			// 为每个中间件创建一个 goroutine
 			go func(m Middleware) {
 				defer wg.Done()
+				defer bus.pending.Add(-1)
+				defer bus.recoverPanic(eventCopy)
 				m.Do(eventCopy)
 			}(middleware) // 传递中间件实例作为参数
 		}
@@ -160,9 +170,12 @@ func (bus *EventBus) applyAsyncMiddleware(event Event) Event {
 
 		if middleware.IsGlobal() || middleware.HasType(eventType) {
 			wg.Add(1)
+			bus.pending.Add(1)
 			// 为每个中间件创建一个 goroutine
 			go func(m Middleware) {
 				defer wg.Done()
+				defer bus.pending.Add(-1)
+				defer bus.recoverPanic(eventCopy)
 				m.DoAsync(eventCopy)
 			}(middleware) // 传递中间件实例作为参数
 		}
@@ -337,8 +350,81 @@ func (bus *EventBus) ClearAllMiddleware() {
 	bus.postMiddleware = make([]Middleware, 0)
 }
 
+// MiddlewareInfo 是中间件的概要信息，用来查看事件总线当前的状态
+type MiddlewareInfo struct {
+	Id       string   `json:"id"`
+	Stage    string   `json:"stage"` // 中间件所在的阶段，pre、sync、post 或者 async
+	Tags     []string `json:"tags"`
+	Types    []string `json:"types"` // 接收的事件类型名称，为空时是全局中间件
+	Handlers int      `json:"handlers"`
+}
+
+// GetMiddlewareInfo 获取所有中间件的概要信息，按照阶段和执行顺序排列
+func (bus *EventBus) GetMiddlewareInfo() []MiddlewareInfo {
+	bus.middlewareMutex.RLock()
+	defer bus.middlewareMutex.RUnlock()
+	stages := []struct {
+		name        string
+		middlewares []Middleware
+	}{
+		{"pre", bus.preMiddleware},
+		{"sync", bus.syncMiddleware},
+		{"post", bus.postMiddleware},
+		{"async", bus.asyncMiddleware},
+	}
+	var infos []MiddlewareInfo
+	for _, stage := range stages {
+		for _, m := range stage.middlewares {
+			types := make([]string, 0, len(m.GetType()))
+			for _, et := range m.GetType() {
+				types = append(types, et.ToString())
+			}
+			infos = append(infos, MiddlewareInfo{
+				Id:       m.GetId(),
+				Stage:    stage.name,
+				Tags:     append([]string{}, m.GetTag()...),
+				Types:    types,
+				Handlers: m.GetHandlerCount(),
+			})
+		}
+	}
+	return infos
+}
+
+// SetPanicHandler 设置中间件出现 panic 时的处理函数，设置之后 panic 会被恢复并交给它处理
+//
+// 没有设置时 panic 不会被恢复，和普通的 goroutine 一样会导致程序崩溃
+func (bus *EventBus) SetPanicHandler(handler PanicHandler) {
+	bus.middlewareMutex.Lock() // 持有写锁
+	defer bus.middlewareMutex.Unlock()
+	bus.panicHandler = handler
+}
+
+// recoverPanic 恢复中间件中出现的 panic 并交给处理函数，必须直接通过 defer 调用
+func (bus *EventBus) recoverPanic(event Event) {
+	bus.middlewareMutex.RLock()
+	handler := bus.panicHandler
+	bus.middlewareMutex.RUnlock()
+	if handler == nil {
+		return // 没有处理函数时不恢复，让 panic 继续向上抛出
+	}
+	if r := recover(); r != nil {
+		handler(event, r, debug.Stack())
+	}
+}
+
+// Pending 获取正在发布的事件和正在执行的中间件数量，为0时表示所有事件都已经处理完成
+//
+// 中间件自己创建的 goroutine 不会被计算在内
+func (bus *EventBus) Pending() int {
+	return int(bus.pending.Load())
+}
+
 // Publish 发布事件并按顺序执行中间件
 func (bus *EventBus) Publish(event Event) {
+	bus.pending.Add(1)
+	defer bus.pending.Add(-1)
+	defer bus.recoverPanic(event) // 预处理和后处理中间件是在当前 goroutine 中执行的
 	// 先执行预处理中间件
 	event = bus.applyPreMiddleware(event)
 	if event == nil {
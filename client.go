@@ -1,6 +1,7 @@
 package cryo
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"github.com/LagrangeDev/LagrangeGo/client"
@@ -21,10 +22,14 @@ type LagrangeClient struct {
 	Uid       string
 	Nickname  string
 
-	initFlag bool   // 是否初始化完成
-	conf     Config // 配置项
-	bus      *EventBus
-	logger   log.CryoLogger
+	initFlag   bool     // 是否初始化完成
+	conf       Config   // 配置项
+	protocol   Protocol // 协议实现，默认使用 Client
+	bus        *EventBus
+	logger     log.CryoLogger
+	sendQueue  *SendQueue  // 消息发送队列，没有启用时为nil
+	cache      *infoCache  // 群和群成员信息缓存
+	mediaCache *mediaCache // 媒体上传缓存，没有启用时为nil
 }
 
 // NewLagrangeClient 创建一个新的LagrangeClient实例
@@ -34,11 +39,6 @@ func NewLagrangeClient() *LagrangeClient {
 
 // Init 初始化一个新的LagrangeClient客户端
 func (c *LagrangeClient) Init(bus *EventBus, logger log.CryoLogger, conf Config) {
-	c.Id = newUUID() // 给Bot客户端分配一个唯一的UUID
-	c.conf = conf
-	c.bus = bus
-	c.logger = logger
-
 	// 默认平台和版本
 	if c.Platform == "" {
 		c.Platform = "linux"
@@ -49,16 +49,47 @@ func (c *LagrangeClient) Init(bus *EventBus, logger log.CryoLogger, conf Config)
 
 	appInfo := auth.AppList[c.Platform][c.Version]
 	c.Client = client.NewClient(0, "")
-	c.Client.SetLogger(log.NewProtocolLogger(c.logger)) // 替换日志记录器，详见client/protocol_logger.go以及log/Logger.go
+	c.Client.SetLogger(log.NewProtocolLogger(logger)) // 替换日志记录器，详见client/protocol_logger.go以及log/Logger.go
 	c.Client.UseVersion(appInfo)
 	c.Client.AddSignServer(conf.SignServers...)
 	c.DeviceNum = randomDeviceNumber()
 	c.Client.UseDevice(auth.NewDeviceInfo(c.DeviceNum))
+	c.init(bus, logger, conf, &lagrangeProtocol{c.Client})
+}
+
+// InitWithProtocol 使用指定的协议实现初始化客户端，不会创建LagrangeGo客户端
+//
+// 这样初始化的客户端没有登录流程，调用 AfterLogin 后就会视为已经登录，一般配合 MockProtocol 用于测试
+func (c *LagrangeClient) InitWithProtocol(bus *EventBus, logger log.CryoLogger, conf Config, protocol Protocol) {
+	c.init(bus, logger, conf, protocol)
+}
+
+func (c *LagrangeClient) init(bus *EventBus, logger log.CryoLogger, conf Config, protocol Protocol) {
+	c.Id = newUUID() // 给Bot客户端分配一个唯一的UUID
+	c.conf = conf
+	c.bus = bus
+	c.logger = logger
+	c.protocol = protocol
 	c.Nickname = newNickname() // 生成一个默认的编号昵称
+	c.cache = newInfoCache()
+	c.mediaCache = newMediaCache(MediaUploadCacheSize)
+	if conf.EnableSendQueue { // 如果启用了消息发送队列
+		c.sendQueue = NewSendQueue(c, conf.SendQueue)
+	}
 
 	c.initFlag = true
 }
 
+// GetProtocol 获取客户端使用的协议实现
+func (c *LagrangeClient) GetProtocol() Protocol {
+	return c.protocol
+}
+
+// IsOnline 判断客户端是否在线
+func (c *LagrangeClient) IsOnline() bool {
+	return c.protocol != nil && c.protocol.IsOnline()
+}
+
 // Rebuild 重新构建LagrangeClient实例
 func (c *LagrangeClient) Rebuild(clientInfo ClientInfo) bool {
 	if !c.initFlag {
@@ -126,10 +157,10 @@ func (c *LagrangeClient) UseSignature(sig string) {
 // AfterLogin 登录成功后的处理函数，包含保存签名、绑定LagrangeGo的事件、发送登录成功事件及自动保存登录信息
 func (c *LagrangeClient) AfterLogin() {
 	// 登录成功后，保存签名
-	c.Uin = c.Client.Sig().Uin
-	c.Uid = c.Client.Sig().UID
-	SendBotConnectedEvent(c)         // 发送登录成功事件
-	if c.conf.EnableClientAutoSave { // 如果启用了自动保存
+	c.Uin, c.Uid = c.protocol.Self()
+	SendBotConnectedEvent(c) // 发送登录成功事件
+	// 如果启用了自动保存，其他协议实现没有签名可以保存
+	if c.conf.EnableClientAutoSave && c.Client != nil {
 		err := c.Save()
 		if err != nil {
 			c.logger.Error("保存登录信息时出现错误：", err)
@@ -225,107 +256,355 @@ func (c *LagrangeClient) watingForLoginResult() bool {
 	return true
 }
 
-// SendPrivateMessage 发送私聊消息
-func (c *LagrangeClient) SendPrivateMessage(userUin uint32, msg *Message) (ok bool, messageId uint32) {
-	// 发送私聊消息
-	message, err := c.Client.SendPrivateMessage(userUin, msg.ToIMessageElements())
-	if err != nil {
-		c.logger.Errorf("向用户 %d 发送消息时出现错误：%v", userUin, err)
-		return false, 0
+// GetSendQueue 获取客户端的消息发送队列，没有启用时返回nil
+func (c *LagrangeClient) GetSendQueue() *SendQueue {
+	return c.sendQueue
+}
+
+// sendAction 获取向发送目标发送消息时的操作名称，不支持的发送目标返回false
+func sendAction(target SendTarget) (string, bool) {
+	switch target.Type {
+	case PrivateTarget:
+		return "send_private_message", true
+	case GroupTarget:
+		return "send_group_message", true
+	case TempTarget:
+		return "send_temp_message", true
 	}
-	return true, message.ID
+	return "", false
 }
 
-// SendGroupMessage 发送群聊消息
-func (c *LagrangeClient) SendGroupMessage(groupUin uint32, msg *Message) (ok bool, messageId uint32) {
-	// 发送群消息
-	message, err := c.Client.SendGroupMessage(groupUin, msg.ToIMessageElements())
-	if err != nil {
-		c.logger.Errorf("向群 %d 发送消息时出现错误：%v", groupUin, err)
-		return false, 0
+// prepareMessage 在消息进入发送队列之前检查消息内容，并在调用者的goroutine中下载只有链接的图片
+//
+// 这样发送队列的goroutine不会因为下载图片而阻塞，下载也可以通过ctx取消
+func (c *LagrangeClient) prepareMessage(ctx context.Context, target SendTarget, msg *Message) error {
+	action, ok := sendAction(target)
+	if !ok {
+		return newActionError("send_message", target.GroupUin, target.UserUin, ErrUnsupportedEvent, nil)
+	}
+	if err := checkMessage(msg); err != nil {
+		return newActionError(action, target.GroupUin, target.UserUin, err, nil)
+	}
+	for _, e := range *msg {
+		f, ok := e.(*File)
+		if !ok {
+			continue
+		}
+		if target.Type == TempTarget {
+			return newActionError("upload_file", target.GroupUin, target.UserUin, ErrUnsupportedTarget, nil)
+		}
+		if f.FileStream == nil {
+			return newActionError("upload_file", target.GroupUin, target.UserUin, ErrMessageEmpty, nil)
+		}
+	}
+	if err := fetchURLImages(ctx, *msg); err != nil {
+		return newActionError(action, target.GroupUin, target.UserUin, nil, err)
 	}
-	return true, message.ID
+	return nil
 }
 
-// SendTempMessage 发送临时消息
-func (c *LagrangeClient) SendTempMessage(groupUin, userUin uint32, msg *Message) (ok bool, messageId uint32) {
-	// 发送临时消息
-	message, err := c.Client.SendTempMessage(groupUin, userUin, msg.ToIMessageElements())
+// sendDirect 不经过发送队列，直接向指定目标发送消息，消息需要先经过 prepareMessage 处理
+//
+// 文件元素会在其余的消息发送成功之后再依次上传，上传失败时返回已经发送的消息和 ErrPartiallySent 错误
+func (c *LagrangeClient) sendDirect(target SendTarget, msg *Message) (*SentMessage, error) {
+	action, ok := sendAction(target)
+	if !ok {
+		return nil, newActionError("send_message", target.GroupUin, target.UserUin, ErrUnsupportedEvent, nil)
+	}
+
+	// 文件元素不能和普通消息一起发送，需要单独上传
+	elements := *msg
+	var files []*File
+	if msg.HasType(FileType) {
+		rest := Message{}
+		for _, e := range elements {
+			if f, ok := e.(*File); ok {
+				files = append(files, f)
+			} else {
+				rest = append(rest, e)
+			}
+		}
+		msg = &rest
+	}
+
+	var sent *SentMessage
+	if len(*msg) > 0 {
+		var err error
+		if sent, err = c.sendElements(action, target, elements, msg); err != nil {
+			return nil, err
+		}
+	} else { // 只包含文件的消息没有消息回执
+		sent = newSentMessage(c, target, elements, 0, 0, 0, uint32(time.Now().Unix()))
+	}
+	for i, f := range files {
+		if err := c.sendFile(target, f); err != nil {
+			if i == 0 && len(*msg) == 0 { // 还没有发送出任何内容
+				return nil, err
+			}
+			return sent, newActionError(action, target.GroupUin, target.UserUin, ErrPartiallySent, err)
+		}
+	}
+	return sent, nil
+}
+
+// sendElements 发送不包含文件的消息，elements 是用来创建 SentMessage 的完整消息
+func (c *LagrangeClient) sendElements(action string, target SendTarget, elements Message, msg *Message) (*SentMessage, error) {
+	c.useUploadCache(target, *msg)
+	for _, e := range *msg {
+		if f, ok := e.(*ForwardMessage); ok && f.ResID == "" {
+			if err := c.prepareForward(target, &f.ForwardMessage); err != nil {
+				return nil, newActionError(action, target.GroupUin, target.UserUin, nil, err)
+			}
+		}
+	}
+	var sent *SentMessage
+	var err error
+	switch target.Type {
+	case PrivateTarget:
+		message, e := c.protocol.SendPrivateMessage(target.UserUin, msg.ToIMessageElements())
+		if err = e; message != nil {
+			sent = newSentMessage(c, target, elements, message.ID, message.InternalID, message.ClientSeq, message.Time)
+		}
+	case GroupTarget:
+		message, e := c.protocol.SendGroupMessage(target.GroupUin, msg.ToIMessageElements())
+		if err = e; message != nil {
+			sent = newSentMessage(c, target, elements, message.ID, message.InternalID, 0, message.Time)
+		}
+	case TempTarget:
+		message, e := c.protocol.SendTempMessage(target.GroupUin, target.UserUin, msg.ToIMessageElements())
+		if err = e; message != nil {
+			sent = newSentMessage(c, target, elements, message.ID, 0, 0, uint32(time.Now().Unix()))
+		}
+	}
 	if err != nil {
-		c.logger.Errorf("向与用户 %d 的临时会话发送消息时出现错误：%v", groupUin, err)
-		return false, 0
+		return nil, newActionError(action, target.GroupUin, target.UserUin, nil, err)
+	}
+	c.saveUploadCache(target, *msg)
+	if sent == nil {
+		// 服务器没有返回回执，一般是被禁言或者不是好友导致的
+		return nil, newActionError(action, target.GroupUin, target.UserUin, c.guessRejectReason(target), nil)
+	}
+	return sent, nil
+}
+
+// sendFile 向发送目标上传文件，临时会话不支持发送文件
+func (c *LagrangeClient) sendFile(target SendTarget, file *File) error {
+	switch target.Type {
+	case GroupTarget:
+		_, err := c.UploadGroupFile(target.GroupUin, file)
+		return err
+	case PrivateTarget:
+		return c.UploadPrivateFile(target.UserUin, file)
+	default:
+		return newActionError("upload_file", target.GroupUin, target.UserUin, ErrUnsupportedTarget, nil)
 	}
-	return true, message.ID
+}
+
+// sendMessage 发送消息，启用了发送队列时会进入队列排队并等待发送结果
+func (c *LagrangeClient) sendMessage(target SendTarget, msg *Message) (*SentMessage, error) {
+	return c.SendContext(context.Background(), target, msg)
+}
+
+// SendContext 向指定目标发送消息，启用了发送队列时会进入队列排队并等待发送结果
+//
+// 消息中只有链接的图片会在进入发送队列之前下载，可以通过ctx取消下载；
+// 消息中包含文件时，文件会在其余内容发送成功之后上传，上传失败时同时返回已经发送的消息和 ErrPartiallySent 错误
+func (c *LagrangeClient) SendContext(ctx context.Context, target SendTarget, msg *Message) (*SentMessage, error) {
+	if err := c.prepareMessage(ctx, target, msg); err != nil {
+		return nil, err
+	}
+	if c.sendQueue != nil {
+		return c.sendQueue.Push(target, msg).Wait()
+	}
+	return c.sendDirect(target, msg)
+}
+
+// SendPrivateMessage 发送私聊消息，失败时返回的错误是 *ActionError
+func (c *LagrangeClient) SendPrivateMessage(userUin uint32, msg *Message) (*SentMessage, error) {
+	return c.sendMessage(SendTarget{Type: PrivateTarget, UserUin: userUin}, msg)
+}
+
+// SendGroupMessage 发送群聊消息，失败时返回的错误是 *ActionError
+func (c *LagrangeClient) SendGroupMessage(groupUin uint32, msg *Message) (*SentMessage, error) {
+	return c.sendMessage(SendTarget{Type: GroupTarget, GroupUin: groupUin}, msg)
+}
+
+// SendTempMessage 发送临时消息，失败时返回的错误是 *ActionError
+func (c *LagrangeClient) SendTempMessage(groupUin, userUin uint32, msg *Message) (*SentMessage, error) {
+	return c.sendMessage(SendTarget{Type: TempTarget, GroupUin: groupUin, UserUin: userUin}, msg)
+}
+
+// SendAsync 将消息加入发送队列后立即返回，可以通过返回的 SendResult 等待发送结果
+//
+// 没有启用发送队列时会直接发送，返回的 SendResult 已经是完成状态；
+// 消息中只有链接的图片会在加入队列之前下载，下载完成后才会返回
+func (c *LagrangeClient) SendAsync(target SendTarget, msg *Message, priority ...SendPriority) *SendResult {
+	return c.SendAsyncContext(context.Background(), target, msg, priority...)
+}
+
+// SendAsyncContext 和 SendAsync 相同，可以通过ctx取消加入队列之前的图片下载
+func (c *LagrangeClient) SendAsyncContext(ctx context.Context, target SendTarget, msg *Message, priority ...SendPriority) *SendResult {
+	if err := c.prepareMessage(ctx, target, msg); err != nil {
+		result := newSendResult()
+		result.resolve(nil, err)
+		return result
+	}
+	if c.sendQueue != nil {
+		return c.sendQueue.Push(target, msg, priority...)
+	}
+	result := newSendResult()
+	result.resolve(c.sendDirect(target, msg))
+	return result
+}
+
+// SendPrivateMessageAsync 将私聊消息加入发送队列
+func (c *LagrangeClient) SendPrivateMessageAsync(userUin uint32, msg *Message, priority ...SendPriority) *SendResult {
+	return c.SendAsync(SendTarget{Type: PrivateTarget, UserUin: userUin}, msg, priority...)
+}
+
+// SendGroupMessageAsync 将群聊消息加入发送队列
+func (c *LagrangeClient) SendGroupMessageAsync(groupUin uint32, msg *Message, priority ...SendPriority) *SendResult {
+	return c.SendAsync(SendTarget{Type: GroupTarget, GroupUin: groupUin}, msg, priority...)
+}
+
+// SendTempMessageAsync 将临时消息加入发送队列
+func (c *LagrangeClient) SendTempMessageAsync(groupUin, userUin uint32, msg *Message, priority ...SendPriority) *SendResult {
+	return c.SendAsync(SendTarget{Type: TempTarget, GroupUin: groupUin, UserUin: userUin}, msg, priority...)
 }
 
 // SendFriendPoke 发送好友戳一戳
-func (c *LagrangeClient) SendFriendPoke(userUin uint32) (ok bool) {
+func (c *LagrangeClient) SendFriendPoke(userUin uint32) error {
 	// 发送好友戳一戳
-	err := c.Client.FriendPoke(userUin)
+	err := c.protocol.FriendPoke(userUin)
 	if err != nil {
-		c.logger.Errorf("向用户 %d 发送戳一戳时出现错误：%v", userUin, err)
-		return false
+		return newActionError("friend_poke", 0, userUin, nil, err)
 	}
-	return true
+	return nil
 }
 
 // SendGroupPoke 发送群戳一戳
-func (c *LagrangeClient) SendGroupPoke(groupUin, userUin uint32) (ok bool) {
+func (c *LagrangeClient) SendGroupPoke(groupUin, userUin uint32) error {
 	// 发送群戳一戳
-	err := c.Client.GroupPoke(groupUin, userUin)
+	err := c.protocol.GroupPoke(groupUin, userUin)
 	if err != nil {
-		c.logger.Errorf("向群 %d 的用户 %d 发送戳一戳时出现错误：%v", groupUin, userUin, err)
-		return false
+		return newActionError("group_poke", groupUin, userUin, nil, err)
 	}
-	return true
+	return nil
 }
 
-// Send 自动根据事件内容发送信息
-func (c *LagrangeClient) Send(event MessageEvent, args ...interface{}) (ok bool, messageId uint32) {
-	// 处理消息内容
-	m := ProcessMessageContent(args...)
-	// 根据传入的事件来发送消息
+// GetSendTarget 根据消息事件获取回复消息时的发送目标
+func GetSendTarget(event MessageEvent) (target SendTarget, ok bool) {
+	me := event.GetUniMessageEvent()
 	switch event.GetEventType() {
 	case PrivateMessageEventType:
-		return c.SendPrivateMessage(event.GetUniMessageEvent().SenderUin, m)
+		return SendTarget{Type: PrivateTarget, UserUin: me.SenderUin}, true
 	case GroupMessageEventType:
-		return c.SendGroupMessage(event.GetUniMessageEvent().GroupUin, m)
+		return SendTarget{Type: GroupTarget, GroupUin: me.GroupUin}, true
 	case TempMessageEventType:
-		return c.SendTempMessage(event.GetUniMessageEvent().GroupUin, event.GetUniMessageEvent().SenderUin, m)
+		return SendTarget{Type: TempTarget, GroupUin: me.GroupUin, UserUin: me.SenderUin}, true
 	case UniMessageEventType:
-		me := event.GetUniMessageEvent()
 		// 通过tag来判断消息类型
 		if Contains(me.EventTags, "private_message") {
-			return c.SendPrivateMessage(me.SenderUin, m)
+			return SendTarget{Type: PrivateTarget, UserUin: me.SenderUin}, true
 		} else if Contains(me.EventTags, "group_message") {
-			return c.SendGroupMessage(me.GroupUin, m)
+			return SendTarget{Type: GroupTarget, GroupUin: me.GroupUin}, true
 		} else if Contains(me.EventTags, "temp_message") {
-			return c.SendTempMessage(me.GroupUin, me.SenderUin, m)
+			return SendTarget{Type: TempTarget, GroupUin: me.GroupUin, UserUin: me.SenderUin}, true
 		}
-	default:
-		c.logger.Error("发送消息时传入了不支持的消息事件")
 	}
-	return false, 0
+	return SendTarget{}, false
+}
+
+// Send 自动根据事件内容发送信息
+//
+// 发送失败时只会记录日志，返回值中也没有办法撤回或者编辑已发送的消息
+//
+// Deprecated: 请使用 SendTo，它会返回已发送消息的句柄 SentMessage 和发送失败的原因
+func (c *LagrangeClient) Send(event MessageEvent, args ...interface{}) (ok bool, messageId uint32) {
+	sent, err := c.SendTo(event, args...)
+	if err != nil {
+		c.logger.Errorf("发送消息时出现错误：%v", err)
+		return false, 0
+	}
+	return true, sent.MessageId
+}
+
+// SendTo 自动根据事件内容发送信息，返回已发送的消息或者发送失败的原因
+func (c *LagrangeClient) SendTo(event MessageEvent, args ...interface{}) (*SentMessage, error) {
+	// 处理消息内容
+	m := ProcessMessageContent(args...)
+	// 根据传入的事件来发送消息
+	target, ok := GetSendTarget(event)
+	if !ok {
+		return nil, newActionError("send_message", 0, 0, ErrUnsupportedEvent, nil)
+	}
+	return c.sendMessage(target, m)
 }
 
 // Reply 自动根据事件内容回复消息
+//
+// 发送失败时只会记录日志，返回值中也没有办法撤回或者编辑已发送的消息
+//
+// Deprecated: 请使用 ReplyTo，它会返回已发送消息的句柄 SentMessage 和发送失败的原因
 func (c *LagrangeClient) Reply(event MessageEvent, args ...interface{}) (ok bool, messageId uint32) {
+	sent, err := c.ReplyTo(event, args...)
+	if err != nil {
+		c.logger.Errorf("回复消息时出现错误：%v", err)
+		return false, 0
+	}
+	return true, sent.MessageId
+}
+
+// ReplyTo 自动根据事件内容回复消息，返回已发送的消息或者发送失败的原因
+func (c *LagrangeClient) ReplyTo(event MessageEvent, args ...interface{}) (*SentMessage, error) {
 	// 处理消息内容
 	m := Message{}
 	m.AddReply(event).Add(*ProcessMessageContent(args...)...)
-	return c.Send(event, m)
+	return c.SendTo(event, m)
 }
 
 // Poke 自动根据事件内容戳人（笑
 func (c *LagrangeClient) Poke(event MessageEvent) (ok bool) {
 	// 根据传入的事件来发送消息
+	var err error
 	switch event.GetEventType() {
 	case PrivateMessageEventType:
-		return c.SendFriendPoke(event.GetUniMessageEvent().SenderUin)
+		err = c.SendFriendPoke(event.GetUniMessageEvent().SenderUin)
 	case GroupMessageEventType:
-		return c.SendGroupPoke(event.GetUniMessageEvent().GroupUin, event.GetUniMessageEvent().SenderUin)
+		err = c.SendGroupPoke(event.GetUniMessageEvent().GroupUin, event.GetUniMessageEvent().SenderUin)
 	default:
-		c.logger.Error("发送戳一戳时传入了不支持的消息事件")
+		err = newActionError("poke", 0, 0, ErrUnsupportedEvent, nil)
 	}
-	return false
+	if err != nil {
+		c.logger.Errorf("发送戳一戳时出现错误：%v", err)
+		return false
+	}
+	return true
+}
+
+// RecallGroupMessage 撤回群消息，撤回其他成员的消息需要Bot是群管理员
+func (c *LagrangeClient) RecallGroupMessage(groupUin, seq uint32) error {
+	err := c.protocol.RecallGroupMessage(groupUin, seq)
+	if err != nil {
+		return newActionError("recall_group_message", groupUin, 0, nil, err)
+	}
+	return nil
+}
+
+// RecallPrivateMessage 撤回私聊消息，需要传入消息的序号、随机数、客户端序号以及发送时间
+func (c *LagrangeClient) RecallPrivateMessage(userUin, seq, random, clientSeq, timestamp uint32) error {
+	err := c.protocol.RecallFriendMessage(userUin, seq, random, clientSeq, timestamp)
+	if err != nil {
+		return newActionError("recall_private_message", 0, userUin, nil, err)
+	}
+	return nil
+}
+
+// SetGroupReaction 给群消息添加或取消表态，code 是表情的ID
+func (c *LagrangeClient) SetGroupReaction(groupUin, seq uint32, code string, isAdd bool) error {
+	err := c.protocol.SetGroupReaction(groupUin, seq, code, isAdd)
+	if err != nil {
+		return newActionError("set_group_reaction", groupUin, 0, nil, err)
+	}
+	return nil
 }
@@ -0,0 +1,374 @@
+package cryo
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/LagrangeDev/LagrangeGo/client/entity"
+)
+
+// GroupInfo 群信息
+type GroupInfo struct {
+	entity.Group
+}
+
+// MemberInfo 群成员信息
+type MemberInfo struct {
+	entity.GroupMember
+	GroupUin uint32 // 成员所在的群号
+}
+
+// IsOwner 判断成员是否是群主
+func (m *MemberInfo) IsOwner() bool {
+	return m.Permission == entity.Owner
+}
+
+// IsAdmin 判断成员是否是群管理员，群主也视为管理员
+func (m *MemberInfo) IsAdmin() bool {
+	return m.Permission == entity.Admin || m.Permission == entity.Owner
+}
+
+// infoCache 是每个客户端独立的群和群成员信息缓存
+//
+// 缓存中的信息在第一次查询时从LagrangeGo懒加载，之后会根据收到的群事件自动更新，
+// 缓存项在更新时会被整个替换掉，所以查询接口返回的指针可以放心地在其他协程中读取
+type infoCache struct {
+	mutex         sync.RWMutex
+	groups        map[uint32]*GroupInfo
+	members       map[uint32]map[uint32]*MemberInfo
+	loadedMembers map[uint32]bool // 已经加载过完整成员列表的群
+	loading       map[string]bool // 正在后台加载的缓存项
+}
+
+// newInfoCache 创建一个新的信息缓存
+func newInfoCache() *infoCache {
+	return &infoCache{
+		groups:        make(map[uint32]*GroupInfo),
+		members:       make(map[uint32]map[uint32]*MemberInfo),
+		loadedMembers: make(map[uint32]bool),
+		loading:       make(map[string]bool),
+	}
+}
+
+// startLoading 标记一个缓存项开始在后台加载，已经在加载时返回 false
+func (ic *infoCache) startLoading(key string) bool {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	if ic.loading[key] {
+		return false
+	}
+	ic.loading[key] = true
+	return true
+}
+
+// finishLoading 标记一个缓存项的后台加载已经结束
+func (ic *infoCache) finishLoading(key string) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	delete(ic.loading, key)
+}
+
+func (ic *infoCache) getGroup(groupUin uint32) *GroupInfo {
+	ic.mutex.RLock()
+	defer ic.mutex.RUnlock()
+	return ic.groups[groupUin]
+}
+
+func (ic *infoCache) setGroup(g *GroupInfo) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	ic.groups[g.GroupUin] = g
+}
+
+// updateGroup 复制一份缓存中的群信息并修改，群不在缓存中时不做任何事
+func (ic *infoCache) updateGroup(groupUin uint32, f func(g *GroupInfo)) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	if old, ok := ic.groups[groupUin]; ok {
+		g := *old
+		f(&g)
+		ic.groups[groupUin] = &g
+	}
+}
+
+func (ic *infoCache) removeGroup(groupUin uint32) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	delete(ic.groups, groupUin)
+	delete(ic.members, groupUin)
+	delete(ic.loadedMembers, groupUin)
+}
+
+func (ic *infoCache) getMember(groupUin, userUin uint32) *MemberInfo {
+	ic.mutex.RLock()
+	defer ic.mutex.RUnlock()
+	return ic.members[groupUin][userUin]
+}
+
+func (ic *infoCache) setMember(m *MemberInfo) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	if ic.members[m.GroupUin] == nil {
+		ic.members[m.GroupUin] = make(map[uint32]*MemberInfo)
+	}
+	ic.members[m.GroupUin][m.Uin] = m
+}
+
+// setMembers 用完整的成员列表覆盖缓存
+func (ic *infoCache) setMembers(groupUin uint32, members map[uint32]*MemberInfo) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	ic.members[groupUin] = members
+	ic.loadedMembers[groupUin] = true
+}
+
+// updateMember 复制一份缓存中的成员信息并修改，成员不在缓存中时不做任何事
+func (ic *infoCache) updateMember(groupUin, userUin uint32, f func(m *MemberInfo)) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	if old, ok := ic.members[groupUin][userUin]; ok {
+		m := *old
+		f(&m)
+		ic.members[groupUin][userUin] = &m
+	}
+}
+
+func (ic *infoCache) removeMember(groupUin, userUin uint32) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	delete(ic.members[groupUin], userUin)
+}
+
+// listMembers 获取已经完整加载的成员列表，没有加载过时返回false
+func (ic *infoCache) listMembers(groupUin uint32) ([]*MemberInfo, bool) {
+	ic.mutex.RLock()
+	defer ic.mutex.RUnlock()
+	if !ic.loadedMembers[groupUin] {
+		return nil, false
+	}
+	result := make([]*MemberInfo, 0, len(ic.members[groupUin]))
+	for _, m := range ic.members[groupUin] {
+		result = append(result, m)
+	}
+	return result, true
+}
+
+// GetGroup 获取群信息，缓存中没有时会从LagrangeGo加载，Bot不在群内时返回nil
+func (c *LagrangeClient) GetGroup(groupUin uint32) *GroupInfo {
+	if g := c.cache.getGroup(groupUin); g != nil {
+		return g
+	}
+	g, err := c.RefreshGroup(groupUin)
+	if err != nil {
+		return nil
+	}
+	return g
+}
+
+// RefreshGroup 从服务器重新获取群信息并更新缓存
+func (c *LagrangeClient) RefreshGroup(groupUin uint32) (*GroupInfo, error) {
+	var group *entity.Group
+	if cached := c.protocol.GetCachedGroupInfo(groupUin); cached != nil {
+		group = cached
+	} else {
+		g, err := c.protocol.FetchGroupInfo(groupUin, false)
+		if err != nil {
+			return nil, newActionError("get_group_info", groupUin, 0, nil, err)
+		}
+		group = g
+	}
+	if group == nil || group.GroupUin == 0 {
+		return nil, newActionError("get_group_info", groupUin, 0, ErrGroupNotFound, nil)
+	}
+	g := &GroupInfo{*group}
+	c.cache.setGroup(g)
+	return g, nil
+}
+
+// GetGroupList 获取Bot加入的所有群，传入 refresh 为 true 时会重新从服务器拉取
+func (c *LagrangeClient) GetGroupList(refresh ...bool) ([]*GroupInfo, error) {
+	if len(refresh) > 0 && refresh[0] {
+		if err := c.protocol.RefreshAllGroupsInfo(); err != nil {
+			return nil, newActionError("get_group_list", 0, 0, nil, err)
+		}
+	}
+	groups := c.protocol.GetCachedAllGroupsInfo()
+	if groups == nil {
+		return nil, newActionError("get_group_list", 0, 0, ErrActionFailed, nil)
+	}
+	result := make([]*GroupInfo, 0, len(groups))
+	for _, group := range groups {
+		g := &GroupInfo{*group}
+		c.cache.setGroup(g)
+		result = append(result, g)
+	}
+	return result, nil
+}
+
+// GetMember 获取群成员信息，缓存中没有时会从LagrangeGo加载，成员不存在时返回nil
+func (c *LagrangeClient) GetMember(groupUin, userUin uint32) *MemberInfo {
+	if m := c.cache.getMember(groupUin, userUin); m != nil {
+		return m
+	}
+	m, err := c.RefreshMember(groupUin, userUin)
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+// RefreshMember 从服务器重新获取群成员信息并更新缓存
+func (c *LagrangeClient) RefreshMember(groupUin, userUin uint32) (*MemberInfo, error) {
+	member, err := c.protocol.FetchGroupMember(groupUin, userUin)
+	if err != nil {
+		return nil, newActionError("get_group_member_info", groupUin, userUin, nil, err)
+	}
+	if member == nil || member.Uin == 0 {
+		return nil, newActionError("get_group_member_info", groupUin, userUin, ErrMemberNotFound, nil)
+	}
+	m := &MemberInfo{GroupMember: *member, GroupUin: groupUin}
+	c.cache.setMember(m)
+	return m, nil
+}
+
+// GetMemberList 获取群的所有成员，传入 refresh 为 true 时会重新从服务器拉取
+func (c *LagrangeClient) GetMemberList(groupUin uint32, refresh ...bool) ([]*MemberInfo, error) {
+	if len(refresh) == 0 || !refresh[0] {
+		if members, ok := c.cache.listMembers(groupUin); ok {
+			return members, nil
+		}
+	}
+	data, err := c.protocol.GetGroupMembersData(groupUin)
+	if err != nil {
+		return nil, newActionError("get_group_member_list", groupUin, 0, nil, err)
+	}
+	members := make(map[uint32]*MemberInfo, len(data))
+	result := make([]*MemberInfo, 0, len(data))
+	for uin, member := range data {
+		m := &MemberInfo{GroupMember: *member, GroupUin: groupUin}
+		members[uin] = m
+		result = append(result, m)
+	}
+	c.cache.setMembers(groupUin, members)
+	return result, nil
+}
+
+// loadAsync 在后台加载缓存项，同一个缓存项同时只会有一个加载任务
+func (c *LagrangeClient) loadAsync(key string, load func()) {
+	if !c.cache.startLoading(key) {
+		return
+	}
+	go func() {
+		defer c.cache.finishLoading(key)
+		load()
+	}()
+}
+
+// groupName 获取群名称，用于补全事件中的信息
+//
+// 这个方法在事件分发的协程中调用，所以只读取缓存，缓存中没有时返回空字符串，并在后台加载群信息，之后的事件就能带上群名称
+func (c *LagrangeClient) groupName(groupUin uint32) string {
+	if g := c.cache.getGroup(groupUin); g != nil && g.GroupName != "" {
+		return g.GroupName
+	}
+	c.loadAsync("group:"+strconv.FormatUint(uint64(groupUin), 10), func() {
+		_, _ = c.RefreshGroup(groupUin)
+	})
+	return ""
+}
+
+// memberName 获取群成员的显示名称，优先使用群名片，用于补全事件中的信息
+//
+// 和 groupName 一样只读取缓存，缓存中没有时返回空字符串，并在后台加载成员信息
+func (c *LagrangeClient) memberName(groupUin, userUin uint32) string {
+	if userUin == 0 {
+		return ""
+	}
+	if m := c.cache.getMember(groupUin, userUin); m != nil && m.DisplayName() != "" {
+		return m.DisplayName()
+	}
+	c.loadMemberAsync(groupUin, userUin)
+	return ""
+}
+
+// loadMemberAsync 在后台加载群成员信息
+func (c *LagrangeClient) loadMemberAsync(groupUin, userUin uint32) {
+	c.loadAsync(fmt.Sprintf("member:%d:%d", groupUin, userUin), func() {
+		_, _ = c.RefreshMember(groupUin, userUin)
+	})
+}
+
+// refreshGroupsAsync 在后台重新加载Bot加入的群列表
+func (c *LagrangeClient) refreshGroupsAsync() {
+	c.loadAsync("groups", func() {
+		_ = c.protocol.RefreshAllGroupsInfo()
+	})
+}
+
+// onMemberIncrease 在有新成员加入群时更新缓存
+func (c *LagrangeClient) onMemberIncrease(groupUin, userUin uint32) {
+	if userUin == c.Uin { // Bot自己加入了新群
+		c.cache.removeGroup(groupUin)
+		c.refreshGroupsAsync()
+		return
+	}
+	c.cache.updateGroup(groupUin, func(g *GroupInfo) {
+		g.MemberCount++
+	})
+	c.loadMemberAsync(groupUin, userUin)
+}
+
+// onMemberDecrease 在有成员离开群时更新缓存
+func (c *LagrangeClient) onMemberDecrease(groupUin, userUin uint32) {
+	if userUin == c.Uin { // Bot自己离开了群
+		c.cache.removeGroup(groupUin)
+		c.refreshGroupsAsync()
+		return
+	}
+	c.cache.updateGroup(groupUin, func(g *GroupInfo) {
+		if g.MemberCount > 0 {
+			g.MemberCount--
+		}
+	})
+	c.cache.removeMember(groupUin, userUin)
+}
+
+// onGroupNameUpdated 在群名称变更时更新缓存
+func (c *LagrangeClient) onGroupNameUpdated(groupUin uint32, name string) {
+	c.cache.updateGroup(groupUin, func(g *GroupInfo) {
+		g.GroupName = name
+	})
+}
+
+// onMemberPermissionUpdated 在群成员权限变更时更新缓存
+func (c *LagrangeClient) onMemberPermissionUpdated(groupUin, userUin uint32, isAdmin bool) {
+	c.cache.updateMember(groupUin, userUin, func(m *MemberInfo) {
+		if m.Permission == entity.Owner {
+			return
+		}
+		if isAdmin {
+			m.Permission = entity.Admin
+		} else {
+			m.Permission = entity.Member
+		}
+	})
+}
+
+// onMemberSpecialTitleUpdated 在群成员专属头衔变更时更新缓存
+func (c *LagrangeClient) onMemberSpecialTitleUpdated(groupUin, userUin uint32, title string) {
+	c.cache.updateMember(groupUin, userUin, func(m *MemberInfo) {
+		m.SpecialTitle = title
+	})
+}
+
+// onMemberMuted 在群成员被禁言或解除禁言时更新缓存
+func (c *LagrangeClient) onMemberMuted(groupUin, userUin uint32, now, duration uint32) {
+	c.cache.updateMember(groupUin, userUin, func(m *MemberInfo) {
+		if duration == 0 {
+			m.ShutUpTime = 0
+		} else {
+			m.ShutUpTime = now + duration
+		}
+	})
+}
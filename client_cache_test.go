@@ -0,0 +1,42 @@
+package cryo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/machinacanis/cryo"
+	"github.com/machinacanis/cryo/cryotest"
+)
+
+func TestEventNamesOnCacheMiss(t *testing.T) {
+	h := cryotest.New(t)
+	pokes := make(chan *cryo.GroupPokeEvent, 8)
+	h.Bot().OnType(cryo.GroupPokeEventType).Handle(func(e *cryo.GroupPokeEvent) {
+		pokes <- e
+	}).Register()
+	next := func() *cryo.GroupPokeEvent {
+		t.Helper()
+		select {
+		case e := <-pokes:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("没有收到戳一戳事件")
+			return nil
+		}
+	}
+
+	// 连接之后才加入的群不在客户端的缓存中
+	h.Mock().AddGroup(300, "后来的群")
+	h.Mock().AddMember(300, 1001, "成员")
+	h.Mock().ReceiveGroupPoke(300, 1001, 0)
+	if e := next(); e.GroupName != "" || e.SenderNickname != "" {
+		t.Errorf("缓存中没有时名称应该为空，实际是群 %q 成员 %q", e.GroupName, e.SenderNickname)
+	}
+
+	// 后台加载完成之后的事件会带上真实的名称
+	waitFor(t, "后台加载群信息", func() bool {
+		h.Mock().ReceiveGroupPoke(300, 1001, 0)
+		e := next()
+		return e.GroupName == "后来的群" && e.SenderNickname == "成员"
+	})
+}
@@ -0,0 +1,189 @@
+package cryo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/LagrangeDev/LagrangeGo/client"
+)
+
+// MaxMessageTextLength 单条消息中文本内容的最大长度，超出时会在发送前直接返回 ErrMessageTooLong
+var MaxMessageTextLength = 4500
+
+// 以下是Bot客户端操作失败时的错误类型，可以通过 errors.Is 来判断具体的失败原因
+var (
+	ErrNotOnline         = errors.New("Bot客户端不在线")
+	ErrNetwork           = errors.New("网络错误")
+	ErrMuted             = errors.New("Bot在群内被禁言")
+	ErrNotFriend         = errors.New("对方不是Bot的好友")
+	ErrMessageTooLong    = errors.New("消息过长")
+	ErrMessageEmpty      = errors.New("消息内容为空")
+	ErrMessageRejected   = errors.New("消息被服务器拒绝")
+	ErrPermissionDenied  = errors.New("Bot没有执行该操作的权限")
+	ErrGroupNotFound     = errors.New("群不存在或Bot不在群内")
+	ErrMemberNotFound    = errors.New("群成员不存在")
+	ErrUnsupportedEvent  = errors.New("不支持的事件类型")
+	ErrUnsupportedTarget = errors.New("不支持对该目标执行这个操作")
+	ErrInvalidArgument   = errors.New("参数无效")
+	ErrActionFailed      = errors.New("操作失败")
+	ErrPartiallySent     = errors.New("消息只发送了一部分")
+)
+
+// ActionError 是Bot客户端执行操作失败时返回的错误
+//
+// Kind 是上面定义的错误类型之一，Err 是LagrangeGo返回的原始错误（可能为空），两者都可以通过 errors.Is 和 errors.As 匹配
+type ActionError struct {
+	Action   string // 执行的操作，例如 send_group_message
+	GroupUin uint32 // 操作涉及的群号，没有时为0
+	UserUin  uint32 // 操作涉及的用户Uin，没有时为0
+	Kind     error  // 错误类型
+	Err      error  // 原始错误
+}
+
+// Error 输出错误的字符串表示
+func (e *ActionError) Error() string {
+	var target string
+	if e.GroupUin != 0 {
+		target += fmt.Sprintf(" 群 %d", e.GroupUin)
+	}
+	if e.UserUin != 0 {
+		target += fmt.Sprintf(" 用户 %d", e.UserUin)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s%s：%v：%v", e.Action, target, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s%s：%v", e.Action, target, e.Kind)
+}
+
+// Unwrap 同时展开错误类型和原始错误
+func (e *ActionError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// newActionError 创建一个新的操作错误，如果没有指定错误类型，则会根据原始错误自动判断
+func newActionError(action string, groupUin, userUin uint32, kind error, err error) *ActionError {
+	if kind == nil {
+		kind = classifyError(err)
+	}
+	return &ActionError{
+		Action:   action,
+		GroupUin: groupUin,
+		UserUin:  userUin,
+		Kind:     kind,
+		Err:      err,
+	}
+}
+
+// errorKinds 是可以直接从原始错误中匹配出来的错误类型，原始错误已经包装了这些类型时直接使用
+var errorKinds = []error{
+	ErrNotOnline, ErrNetwork, ErrMuted, ErrNotFriend, ErrMessageTooLong, ErrMessageEmpty, ErrMessageRejected,
+	ErrPermissionDenied, ErrGroupNotFound, ErrMemberNotFound, ErrUnsupportedEvent, ErrUnsupportedTarget, ErrInvalidArgument,
+}
+
+// classifyError 根据LagrangeGo返回的原始错误判断错误类型
+//
+// 优先通过 errors.Is 和 errors.As 匹配LagrangeGo导出的错误、网络错误和已经包装过的错误类型；
+// LagrangeGo的大部分服务器错误只是把服务器返回的提示文本包装成错误，没有错误码可以匹配，
+// 这时才会退回到根据错误文本中的关键字判断，匹配不到时返回 ErrActionFailed
+func classifyError(err error) error {
+	if err == nil {
+		return ErrActionFailed
+	}
+	switch {
+	case errors.Is(err, client.ErrNotOnline):
+		return ErrNotOnline
+	case errors.Is(err, client.ErrMemberNotFound):
+		return ErrMemberNotFound
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.Is(err, net.ErrClosed), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE):
+		return ErrNetwork
+	}
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrNetwork
+	}
+	return classifyErrorText(err.Error())
+}
+
+// classifyErrorText 根据错误文本判断错误类型，只用于没有类型可以匹配的错误
+//
+// LagrangeGo的网络层错误在内部包中，只能通过文本匹配，例如 "Packet timed out"；
+// 服务器返回的提示文本可能是中文也可能是英文，所以两种关键字都要检查
+func classifyErrorText(text string) error {
+	msg := strings.ToLower(text)
+	switch {
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "timed out"), strings.Contains(msg, "unreachable"),
+		strings.Contains(msg, "connection closed"), strings.Contains(msg, "packet dropped"):
+		return ErrNetwork
+	case strings.Contains(msg, "not online"), strings.Contains(msg, "session expired"):
+		return ErrNotOnline
+	case strings.Contains(msg, "too long"), strings.Contains(msg, "过长"):
+		return ErrMessageTooLong
+	case strings.Contains(msg, "permission"), strings.Contains(msg, "权限"):
+		return ErrPermissionDenied
+	case strings.Contains(msg, "not friend"), strings.Contains(msg, "非好友"):
+		return ErrNotFriend
+	case strings.Contains(msg, "禁言"):
+		return ErrMuted
+	}
+	return ErrActionFailed
+}
+
+// IsTransientError 判断错误是否是网络波动或掉线之类的临时性错误，这类错误稍后重试通常可以恢复
+func IsTransientError(err error) bool {
+	return errors.Is(err, ErrNetwork) || errors.Is(err, ErrNotOnline)
+}
+
+// checkMessage 在发送前检查消息内容
+func checkMessage(msg *Message) error {
+	if msg == nil || len(*msg) == 0 {
+		return ErrMessageEmpty
+	}
+	length := 0
+	for _, e := range *msg {
+		if t, ok := e.(*Text); ok {
+			length += utf8.RuneCountInString(t.Content)
+		}
+	}
+	if MaxMessageTextLength > 0 && length > MaxMessageTextLength {
+		return ErrMessageTooLong
+	}
+	return nil
+}
+
+// guessRejectReason 在服务器没有返回发送回执时，根据缓存的信息推测消息被拒绝的原因
+func (c *LagrangeClient) guessRejectReason(target SendTarget) error {
+	switch target.Type {
+	case GroupTarget, TempTarget:
+		if c.GetGroup(target.GroupUin) == nil {
+			return ErrGroupNotFound
+		}
+		if target.Type == GroupTarget {
+			if member := c.GetMember(target.GroupUin, c.Uin); member != nil && int64(member.ShutUpTime) > time.Now().Unix() {
+				return ErrMuted
+			}
+		}
+	case PrivateTarget:
+		if c.protocol.GetCachedFriendInfo(target.UserUin) == nil {
+			return ErrNotFriend
+		}
+	}
+	return ErrMessageRejected
+}
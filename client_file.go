@@ -0,0 +1,155 @@
+package cryo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/LagrangeDev/LagrangeGo/client/entity"
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+)
+
+// ErrFileTooLarge 下载的文件超出了大小限制
+var ErrFileTooLarge = errors.New("文件超出大小限制")
+
+// GroupFile 群文件信息
+type GroupFile struct {
+	entity.GroupFile
+}
+
+// GroupFolder 群文件夹信息
+type GroupFolder struct {
+	entity.GroupFolder
+}
+
+// NewFile 使用字节数据创建一个文件元素
+func NewFile(data []byte, fileName string) *File {
+	return &File{FileElement: *lgrmessage.NewFile(data, fileName)}
+}
+
+// NewFileStream 使用io.ReadSeeker创建一个文件元素
+func NewFileStream(r io.ReadSeeker, fileName string) *File {
+	return &File{FileElement: *lgrmessage.NewStreamFile(r, fileName)}
+}
+
+// NewLocalFile 使用本地文件创建一个文件元素，不传入文件名时使用本地文件的文件名
+func NewLocalFile(filePath string, fileName ...string) (*File, error) {
+	f, err := lgrmessage.NewLocalFile(filePath, fileName...)
+	if err != nil {
+		return nil, err
+	}
+	return &File{FileElement: *f}, nil
+}
+
+// UploadGroupFile 上传群文件，上传完成后文件会直接出现在群文件中，folder 是目标文件夹的ID，默认为根目录
+func (c *LagrangeClient) UploadGroupFile(groupUin uint32, file *File, folder ...string) (*File, error) {
+	if file == nil || file.FileStream == nil {
+		return nil, newActionError("upload_group_file", groupUin, 0, ErrMessageEmpty, nil)
+	}
+	dir := "/"
+	if len(folder) > 0 && folder[0] != "" {
+		dir = folder[0]
+	}
+	if _, err := file.FileStream.Seek(0, io.SeekStart); err != nil {
+		return nil, newActionError("upload_group_file", groupUin, 0, nil, err)
+	}
+	f, err := c.protocol.UploadGroupFile(groupUin, &file.FileElement, dir)
+	if err != nil {
+		return nil, newActionError("upload_group_file", groupUin, 0, nil, err)
+	}
+	return &File{FileElement: *f}, nil
+}
+
+// UploadPrivateFile 上传私聊文件并发送给好友
+func (c *LagrangeClient) UploadPrivateFile(userUin uint32, file *File) error {
+	if file == nil || file.FileStream == nil {
+		return newActionError("upload_private_file", 0, userUin, ErrMessageEmpty, nil)
+	}
+	// LagrangeGo只提供了通过本地文件发送私聊文件的接口，所以先把文件内容写入临时文件
+	tmp, err := os.CreateTemp("", "cryo-file-*")
+	if err != nil {
+		return newActionError("upload_private_file", 0, userUin, nil, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = file.FileStream.Seek(0, io.SeekStart); err == nil {
+		_, err = io.Copy(tmp, file.FileStream)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return newActionError("upload_private_file", 0, userUin, nil, err)
+	}
+	if err = c.protocol.SendPrivateFile(userUin, tmp.Name(), file.FileName); err != nil {
+		return newActionError("upload_private_file", 0, userUin, nil, err)
+	}
+	return nil
+}
+
+// ListGroupFiles 获取群文件夹中的文件和子文件夹，folder 是文件夹的ID，默认为根目录
+func (c *LagrangeClient) ListGroupFiles(groupUin uint32, folder ...string) ([]*GroupFile, []*GroupFolder, error) {
+	dir := "/"
+	if len(folder) > 0 && folder[0] != "" {
+		dir = folder[0]
+	}
+	files, folders, err := c.protocol.ListGroupFilesByFolder(groupUin, dir)
+	if err != nil {
+		return nil, nil, newActionError("list_group_files", groupUin, 0, nil, err)
+	}
+	resultFiles := make([]*GroupFile, 0, len(files))
+	for _, f := range files {
+		resultFiles = append(resultFiles, &GroupFile{*f})
+	}
+	resultFolders := make([]*GroupFolder, 0, len(folders))
+	for _, f := range folders {
+		resultFolders = append(resultFolders, &GroupFolder{*f})
+	}
+	return resultFiles, resultFolders, nil
+}
+
+// GetGroupFileURL 获取群文件的下载链接
+func (c *LagrangeClient) GetGroupFileURL(groupUin uint32, fileId string) (string, error) {
+	url, err := c.protocol.GetGroupFileURL(groupUin, fileId)
+	if err != nil {
+		return "", newActionError("get_group_file_url", groupUin, 0, nil, err)
+	}
+	return url, nil
+}
+
+// GetFileURL 获取收到的文件元素的下载链接，groupUin 是文件所在的群号，私聊文件传入0
+func (c *LagrangeClient) GetFileURL(groupUin uint32, file *File) (string, error) {
+	if file.FileURL != "" {
+		return file.FileURL, nil
+	}
+	if groupUin != 0 {
+		return c.GetGroupFileURL(groupUin, file.FileID)
+	}
+	url, err := c.protocol.GetPrivateFileURL(file.FileUUID, file.FileHash)
+	if err != nil {
+		return "", newActionError("get_private_file_url", 0, 0, nil, err)
+	}
+	return url, nil
+}
+
+// DownloadFile 将收到的文件元素下载到 w 中，maxSize 是允许下载的最大字节数，小于等于0时不限制
+//
+// 返回实际写入的字节数，超出大小限制时返回 ErrFileTooLarge
+func (c *LagrangeClient) DownloadFile(ctx context.Context, groupUin uint32, file *File, w io.Writer, maxSize int64) (int64, error) {
+	url, err := c.GetFileURL(groupUin, file)
+	if err != nil {
+		return 0, err
+	}
+	return downloadTo(ctx, http.DefaultClient, url, w, maxSize)
+}
+
+// downloadTo 将链接的内容流式写入 w 中，maxSize 小于等于0时不限制大小
+func downloadTo(ctx context.Context, httpClient *http.Client, url string, w io.Writer, maxSize int64) (int64, error) {
+	body, err := openURL(ctx, httpClient, url, maxSize)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return io.Copy(w, body)
+}
@@ -0,0 +1,89 @@
+package cryo
+
+import "github.com/LagrangeDev/LagrangeGo/client/entity"
+
+// FriendRequestPolicy 好友请求自动处理策略别名
+type FriendRequestPolicy string
+
+const (
+	FriendRequestManual        FriendRequestPolicy = "none"  // 不自动处理，交给插件处理
+	FriendRequestAcceptAll     FriendRequestPolicy = "all"   // 自动同意所有好友请求
+	FriendRequestAcceptMatched FriendRequestPolicy = "regex" // 验证消息匹配正则表达式时自动同意
+)
+
+// UserInfo 用户信息
+type UserInfo struct {
+	entity.User
+}
+
+// GetFriendList 获取好友列表，默认使用缓存，传入 refresh 为 true 时会重新从服务器拉取
+func (c *LagrangeClient) GetFriendList(refresh ...bool) ([]*UserInfo, error) {
+	friends := c.protocol.GetCachedAllFriendsInfo()
+	if len(friends) == 0 || (len(refresh) > 0 && refresh[0]) {
+		if err := c.protocol.RefreshFriendCache(); err != nil {
+			return nil, newActionError("get_friend_list", 0, 0, nil, err)
+		}
+		friends = c.protocol.GetCachedAllFriendsInfo()
+	}
+	result := make([]*UserInfo, 0, len(friends))
+	for _, f := range friends {
+		result = append(result, &UserInfo{*f})
+	}
+	return result, nil
+}
+
+// GetFriend 从缓存中获取好友信息，不是好友时返回nil
+func (c *LagrangeClient) GetFriend(userUin uint32) *UserInfo {
+	f := c.protocol.GetCachedFriendInfo(userUin)
+	if f == nil {
+		return nil
+	}
+	return &UserInfo{*f}
+}
+
+// IsFriend 判断用户是否是Bot的好友
+func (c *LagrangeClient) IsFriend(userUin uint32) bool {
+	return c.protocol.GetCachedFriendInfo(userUin) != nil
+}
+
+// FetchUserInfo 从服务器获取用户的资料
+func (c *LagrangeClient) FetchUserInfo(userUin uint32) (*UserInfo, error) {
+	u, err := c.protocol.FetchUserInfoUin(userUin)
+	if err != nil {
+		return nil, newActionError("fetch_user_info", 0, userUin, nil, err)
+	}
+	return &UserInfo{*u}, nil
+}
+
+// SetFriendRequest 处理好友请求，uid 是请求者的Uid
+func (c *LagrangeClient) SetFriendRequest(uid string, accept bool) error {
+	err := c.protocol.SetFriendRequest(accept, uid)
+	if err != nil {
+		return newActionError("set_friend_request", 0, 0, nil, err)
+	}
+	if accept {
+		// 同意后刷新一下好友缓存，刷新失败也不影响结果
+		_ = c.protocol.RefreshFriendCache()
+	}
+	return nil
+}
+
+// DeleteFriend 删除好友，block 为 true 时会同时拉黑对方
+func (c *LagrangeClient) DeleteFriend(userUin uint32, block bool) error {
+	err := c.protocol.DeleteFriend(userUin, block)
+	if err != nil {
+		return newActionError("delete_friend", 0, userUin, nil, err)
+	}
+	_ = c.protocol.RefreshFriendCache()
+	return nil
+}
+
+// Accept 同意这个好友请求
+func (e *NewFriendRequestEvent) Accept() error {
+	return e.botClient.SetFriendRequest(e.Uid, true)
+}
+
+// Reject 拒绝这个好友请求
+func (e *NewFriendRequestEvent) Reject() error {
+	return e.botClient.SetFriendRequest(e.Uid, false)
+}
@@ -0,0 +1,221 @@
+package cryo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/client/entity"
+)
+
+// MaxMuteDuration 是群成员禁言时长的上限
+const MaxMuteDuration = 30 * 24 * time.Hour
+
+// MuteGroupMember 禁言群成员，时长会被向上取整到秒，为0时表示解除禁言
+//
+// 不足一秒的时长会按照一秒禁言，而不是变成0解除禁言；时长为负数或者超过 MaxMuteDuration 时不会执行操作，直接返回 ErrInvalidArgument
+func (c *LagrangeClient) MuteGroupMember(groupUin, userUin uint32, duration time.Duration) error {
+	if duration < 0 || duration > MaxMuteDuration {
+		return newActionError("mute_group_member", groupUin, userUin, ErrInvalidArgument, fmt.Errorf("禁言时长 %v 不在 0 到 %v 之间", duration, MaxMuteDuration))
+	}
+	seconds := uint32((duration + time.Second - 1) / time.Second) // 不超过 MaxMuteDuration 的秒数不会溢出 uint32
+	err := c.protocol.SetGroupMemberMute(groupUin, userUin, seconds)
+	if err != nil {
+		return newActionError("mute_group_member", groupUin, userUin, nil, err)
+	}
+	return nil
+}
+
+// UnmuteGroupMember 解除群成员的禁言
+func (c *LagrangeClient) UnmuteGroupMember(groupUin, userUin uint32) error {
+	err := c.protocol.SetGroupMemberMute(groupUin, userUin, 0)
+	if err != nil {
+		return newActionError("unmute_group_member", groupUin, userUin, nil, err)
+	}
+	return nil
+}
+
+// MuteGroup 开启全员禁言
+func (c *LagrangeClient) MuteGroup(groupUin uint32) error {
+	err := c.protocol.SetGroupGlobalMute(groupUin, true)
+	if err != nil {
+		return newActionError("mute_group", groupUin, 0, nil, err)
+	}
+	return nil
+}
+
+// UnmuteGroup 关闭全员禁言
+func (c *LagrangeClient) UnmuteGroup(groupUin uint32) error {
+	err := c.protocol.SetGroupGlobalMute(groupUin, false)
+	if err != nil {
+		return newActionError("unmute_group", groupUin, 0, nil, err)
+	}
+	return nil
+}
+
+// KickGroupMember 将成员移出群聊，rejectAddRequest 为 true 时会拒绝该成员之后的加群请求
+func (c *LagrangeClient) KickGroupMember(groupUin, userUin uint32, rejectAddRequest bool) error {
+	err := c.protocol.KickGroupMember(groupUin, userUin, rejectAddRequest)
+	if err != nil {
+		return newActionError("kick_group_member", groupUin, userUin, nil, err)
+	}
+	return nil
+}
+
+// SetGroupMemberCard 设置群成员的群名片，传入空字符串会清除群名片
+func (c *LagrangeClient) SetGroupMemberCard(groupUin, userUin uint32, card string) error {
+	err := c.protocol.SetGroupMemberName(groupUin, userUin, card)
+	if err != nil {
+		return newActionError("set_group_member_card", groupUin, userUin, nil, err)
+	}
+	return nil
+}
+
+// SetGroupMemberSpecialTitle 设置群成员的专属头衔，需要Bot是群主
+func (c *LagrangeClient) SetGroupMemberSpecialTitle(groupUin, userUin uint32, title string) error {
+	err := c.protocol.SetGroupMemberSpecialTitle(groupUin, userUin, title)
+	if err != nil {
+		return newActionError("set_group_member_special_title", groupUin, userUin, nil, err)
+	}
+	return nil
+}
+
+// SetGroupAdmin 设置或取消群管理员，需要Bot是群主
+func (c *LagrangeClient) SetGroupAdmin(groupUin, userUin uint32, isAdmin bool) error {
+	err := c.protocol.SetGroupAdmin(groupUin, userUin, isAdmin)
+	if err != nil {
+		return newActionError("set_group_admin", groupUin, userUin, nil, err)
+	}
+	return nil
+}
+
+// SetGroupName 修改群名称
+func (c *LagrangeClient) SetGroupName(groupUin uint32, name string) error {
+	err := c.protocol.SetGroupName(groupUin, name)
+	if err != nil {
+		return newActionError("set_group_name", groupUin, 0, nil, err)
+	}
+	return nil
+}
+
+// SetEssenceMessage 将群消息设置为精华消息，seq 是消息ID，random 是消息的内部ID
+func (c *LagrangeClient) SetEssenceMessage(groupUin, seq, random uint32) error {
+	err := c.protocol.SetEssenceMessage(groupUin, seq, random, true)
+	if err != nil {
+		return newActionError("set_essence_message", groupUin, 0, nil, err)
+	}
+	return nil
+}
+
+// RemoveEssenceMessage 移除群精华消息，seq 是消息ID，random 是消息的内部ID
+func (c *LagrangeClient) RemoveEssenceMessage(groupUin, seq, random uint32) error {
+	err := c.protocol.SetEssenceMessage(groupUin, seq, random, false)
+	if err != nil {
+		return newActionError("remove_essence_message", groupUin, 0, nil, err)
+	}
+	return nil
+}
+
+// SetGroupJoinRequest 处理加群请求，isInvited 表示这个请求是否是由群成员邀请产生的，reason 是拒绝时的理由
+func (c *LagrangeClient) SetGroupJoinRequest(groupUin uint32, sequence uint64, isInvited, accept bool, reason string) error {
+	typ := entity.UserJoinRequest
+	if isInvited {
+		typ = entity.UserInvited
+	}
+	isFiltered := c.isFilteredGroupRequest(groupUin, sequence)
+	err := c.protocol.SetGroupRequest(isFiltered, accept, sequence, uint32(typ), groupUin, reason)
+	if err != nil {
+		return newActionError("set_group_join_request", groupUin, 0, nil, err)
+	}
+	return nil
+}
+
+// SetGroupInvitation 处理Bot收到的加群邀请
+func (c *LagrangeClient) SetGroupInvitation(groupUin uint32, sequence uint64, accept bool) error {
+	isFiltered := c.isFilteredGroupRequest(groupUin, sequence)
+	err := c.protocol.SetGroupRequest(isFiltered, accept, sequence, uint32(entity.GroupInvited), groupUin, "")
+	if err != nil {
+		return newActionError("set_group_invitation", groupUin, 0, nil, err)
+	}
+	return nil
+}
+
+// isFilteredGroupRequest 查询加群请求是否被放进了过滤列表，被过滤的请求需要单独处理
+func (c *LagrangeClient) isFilteredGroupRequest(groupUin uint32, sequence uint64) bool {
+	msgs, err := c.protocol.GetGroupSystemMessages(true, 20, groupUin)
+	if err != nil || msgs == nil {
+		return false
+	}
+	for _, req := range msgs.JoinRequests {
+		if req.Sequence == sequence {
+			return true
+		}
+	}
+	for _, req := range msgs.InvitedRequests {
+		if req.Sequence == sequence {
+			return true
+		}
+	}
+	return false
+}
+
+// Approve 同意这个加群请求
+func (e *GroupMemberJoinRequestEvent) Approve() error {
+	return e.botClient.SetGroupJoinRequest(e.GroupUin, e.RequestSeqence, e.InviterUin != 0, true, "")
+}
+
+// Reject 拒绝这个加群请求，可以附带拒绝的理由
+func (e *GroupMemberJoinRequestEvent) Reject(reason ...string) error {
+	r := ""
+	if len(reason) > 0 {
+		r = reason[0]
+	}
+	return e.botClient.SetGroupJoinRequest(e.GroupUin, e.RequestSeqence, e.InviterUin != 0, false, r)
+}
+
+// Approve 接受这个加群邀请
+func (e *GroupInviteEvent) Approve() error {
+	return e.botClient.SetGroupInvitation(e.GroupUin, e.RequestSeqence, true)
+}
+
+// Reject 拒绝这个加群邀请
+func (e *GroupInviteEvent) Reject() error {
+	return e.botClient.SetGroupInvitation(e.GroupUin, e.RequestSeqence, false)
+}
+
+// IsMuteAll 是否是全员禁言事件
+func (e *GroupMuteEvent) IsMuteAll() bool {
+	return e.isMuteAll
+}
+
+// Revoke 撤销这次禁言，如果是全员禁言则会关闭全员禁言
+func (e *GroupMuteEvent) Revoke() error {
+	if e.isMuteAll {
+		return e.botClient.UnmuteGroup(e.GroupUin)
+	}
+	return e.botClient.UnmuteGroupMember(e.GroupUin, e.TargetUin)
+}
+
+// MuteSender 禁言这条群消息的发送者
+func (e *GroupMessageEvent) MuteSender(duration time.Duration) error {
+	return e.botClient.MuteGroupMember(e.GroupUin, e.SenderUin, duration)
+}
+
+// KickSender 将这条群消息的发送者移出群聊
+func (e *GroupMessageEvent) KickSender(rejectAddRequest bool) error {
+	return e.botClient.KickGroupMember(e.GroupUin, e.SenderUin, rejectAddRequest)
+}
+
+// SetEssence 将这条群消息设置为精华消息
+func (e *GroupMessageEvent) SetEssence() error {
+	return e.botClient.SetEssenceMessage(e.GroupUin, e.MessageId, e.InternalId)
+}
+
+// RemoveEssence 将这条群消息移出精华消息
+func (e *GroupMessageEvent) RemoveEssence() error {
+	return e.botClient.RemoveEssenceMessage(e.GroupUin, e.MessageId, e.InternalId)
+}
+
+// Remove 移除这条精华消息
+func (e *GroupDigestEvent) Remove() error {
+	return e.botClient.RemoveEssenceMessage(e.GroupUin, e.MessageId, e.InternalId)
+}
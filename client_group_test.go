@@ -0,0 +1,50 @@
+package cryo_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/client/entity"
+	"github.com/machinacanis/cryo"
+	"github.com/machinacanis/cryo/cryotest"
+)
+
+func TestMuteGroupMemberDuration(t *testing.T) {
+	h := cryotest.New(t)
+	h.Group(100).BotIs(entity.Admin).User(1001)
+
+	tests := []struct {
+		duration time.Duration
+		seconds  uint32
+	}{
+		{0, 0},
+		{500 * time.Millisecond, 1}, // 不足一秒不能变成解除禁言
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{time.Minute, 60},
+		{cryo.MaxMuteDuration, uint32(cryo.MaxMuteDuration / time.Second)},
+	}
+	for _, tt := range tests {
+		h.Mock().ResetActions()
+		if err := h.Client().MuteGroupMember(100, 1001, tt.duration); err != nil {
+			t.Fatalf("禁言 %v 失败：%v", tt.duration, err)
+		}
+		actions := h.Mock().ActionsOf("set_group_member_mute")
+		if len(actions) != 1 || actions[0].Args[0] != tt.seconds {
+			t.Errorf("禁言 %v 时传给协议的参数为 %v，期望 %d 秒", tt.duration, actions, tt.seconds)
+		}
+	}
+
+	h.Mock().ResetActions()
+	for _, d := range []time.Duration{-time.Second, cryo.MaxMuteDuration + time.Second, time.Duration(1<<32) * time.Second} {
+		err := h.Client().MuteGroupMember(100, 1001, d)
+		var ae *cryo.ActionError
+		if !errors.As(err, &ae) || !errors.Is(err, cryo.ErrInvalidArgument) {
+			t.Errorf("禁言 %v 返回 %v，期望 ErrInvalidArgument", d, err)
+		}
+	}
+	if n := len(h.Mock().Actions()); n != 0 {
+		t.Errorf("无效的时长不应该调用协议，实际调用了 %d 次", n)
+	}
+}
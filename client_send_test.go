@@ -0,0 +1,98 @@
+package cryo_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/machinacanis/cryo"
+	"github.com/machinacanis/cryo/cryotest"
+)
+
+func TestSendDownloadsImagesBeforeQueue(t *testing.T) {
+	h := cryotest.NewWithConfig(t, cryo.Config{EnableSendQueue: true})
+	h.Group(100)
+
+	requested := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requested)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		msg := cryo.Message{&cryo.Image{ImageElement: lgrmessage.ImageElement{URL: srv.URL}}}
+		_, err := h.Client().SendContext(ctx, cryo.SendTarget{Type: cryo.GroupTarget, GroupUin: 100}, &msg)
+		done <- err
+	}()
+	<-requested
+
+	// 图片还在下载时，发送队列仍然可以发送其它消息
+	msg, _ := cryo.ParseMessage("hi")
+	result := h.Client().SendGroupMessageAsync(100, &msg)
+	if _, err, ok := result.WaitTimeout(time.Second); !ok || err != nil {
+		t.Fatalf("下载图片时发送队列被阻塞：ok=%v err=%v", ok, err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("取消ctx后发送返回 %v，期望 context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("取消ctx后图片下载没有结束")
+	}
+	if n := len(h.Mock().SentMessages()); n != 1 {
+		t.Errorf("发送了 %d 条消息，期望只发送 1 条", n)
+	}
+}
+
+func TestSendFilePartially(t *testing.T) {
+	h := cryotest.New(t)
+	h.Group(100)
+	target := cryo.SendTarget{Type: cryo.GroupTarget, GroupUin: 100}
+	injected := errors.New("injected")
+
+	// 文本发送失败时不会上传文件
+	msg, _ := cryo.ParseMessage("文件")
+	msg.AddFile([]byte("data"), "a.txt")
+	h.Mock().FailNext("send_group_message", injected)
+	if sent, err := h.Client().SendContext(context.Background(), target, &msg); sent != nil || !errors.Is(err, injected) {
+		t.Fatalf("文本发送失败时返回 %v %v", sent, err)
+	}
+	if n := len(h.Mock().ActionsOf("upload_group_file")); n != 0 {
+		t.Fatalf("文本发送失败后仍然上传了 %d 个文件", n)
+	}
+
+	// 文件上传失败时返回已经发送的消息
+	h.Mock().FailNext("upload_group_file", injected)
+	sent, err := h.Client().SendContext(context.Background(), target, &msg)
+	if !errors.Is(err, cryo.ErrPartiallySent) || !errors.Is(err, injected) {
+		t.Fatalf("文件上传失败时返回 %v，期望 ErrPartiallySent", err)
+	}
+	if sent == nil || sent.MessageId == 0 {
+		t.Fatalf("部分发送时没有返回已经发送的消息：%+v", sent)
+	}
+
+	// 临时会话不支持文件，在发送任何内容之前就会失败
+	h.Mock().ResetActions()
+	temp := cryo.SendTarget{Type: cryo.TempTarget, GroupUin: 100, UserUin: 1001}
+	if _, err := h.Client().SendContext(context.Background(), temp, &msg); !errors.Is(err, cryo.ErrUnsupportedTarget) {
+		t.Fatalf("向临时会话发送文件返回 %v，期望 ErrUnsupportedTarget", err)
+	}
+	if n := len(h.Mock().Actions()); n != 0 {
+		t.Errorf("向临时会话发送文件时调用了 %d 次协议", n)
+	}
+}
@@ -18,6 +18,20 @@ type Config struct {
 	EnableMessagePrintMiddleware bool     `json:"enable_message_print_middleware,omitempty,omitzero"` // 是否启用内置的消息打印中间件
 	EnableEventDebugMiddleware   bool     `json:"enable_event_debug_middleware,omitempty,omitzero"`   // 是否启用内置的事件调试中间件
 	EnableCronScheduler          bool     `json:"enable_cron_scheduler,omitempty,omitzero"`           // 是否启用内置的gocron定时任务调度器
+	EnableSendQueue              bool     `json:"enable_send_queue,omitempty,omitzero"`               // 是否启用消息发送队列
+
+	SendQueue SendQueueConfig `json:"send_queue,omitempty,omitzero"` // 消息发送队列的配置项
+
+	FriendRequestPolicy  FriendRequestPolicy `json:"friend_request_policy,omitempty,omitzero"`  // 好友请求的自动处理策略
+	FriendRequestPattern string              `json:"friend_request_pattern,omitempty,omitzero"` // 按验证消息自动同意好友请求时使用的正则表达式
+
+	EnableDedupMiddleware     bool `json:"enable_dedup_middleware,omitempty,omitzero"`      // 是否启用内置的消息去重中间件
+	DedupTTL                  int  `json:"dedup_ttl,omitempty,omitzero"`                    // 消息去重记录的保留时间，单位 秒
+	EnableGroupLeaderElection bool `json:"enable_group_leader_election,omitempty,omitzero"` // 是否只让多个Bot账号共同所在的群中的一个账号处理群事件
+
+	Webhooks  []WebhookConfig `json:"webhooks,omitempty,omitzero"`  // 事件推送的 Webhook 列表
+	Dashboard DashboardConfig `json:"dashboard,omitempty,omitzero"` // Web 管理面板的配置项
+	Recorder  RecorderConfig  `json:"recorder,omitempty,omitzero"`  // 事件录制器的配置项，设置了录制文件时启用
 }
 
 // ReadCryoConfig 从文件读取配置项
@@ -0,0 +1,400 @@
+package cryo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/client/entity"
+)
+
+// 控制台中默认使用的账号
+const (
+	ConsoleSelfUin  uint32 = 10000 // Bot自己的账号
+	ConsoleUserUin  uint32 = 10001 // 默认扮演的用户
+	ConsoleGroupUin uint32 = 20000 // 默认所在的群
+)
+
+// Console 是用于开发插件的本地交互式控制台
+//
+// 它使用 MockProtocol 连接一个不需要登录的Bot客户端，开发者可以在控制台中扮演任意用户在群聊或者私聊中发送消息，
+// Bot发出的消息和执行的操作会实时打印出来，还可以通过 : 开头的命令模拟入群、禁言、戳一戳等通知，输入 :help 查看所有命令
+type Console struct {
+	bot    *Bot
+	mock   *MockProtocol
+	client *LagrangeClient
+	in     io.Reader
+	out    io.Writer
+
+	outMutex sync.Mutex // 保护输出，Bot的回复是在其他 goroutine 中打印的
+	group    uint32     // 当前所在的群，为0时表示私聊
+	user     uint32     // 当前扮演的用户
+}
+
+// NewConsole 创建一个控制台并连接Bot客户端，默认使用标准输入和标准输出
+func NewConsole(b *Bot) *Console {
+	c := &Console{
+		bot:   b,
+		mock:  NewMockProtocol(ConsoleSelfUin),
+		in:    os.Stdin,
+		out:   os.Stdout,
+		group: ConsoleGroupUin,
+		user:  ConsoleUserUin,
+	}
+	c.mock.AddGroup(ConsoleGroupUin, "控制台测试群")
+	c.mock.AddMember(ConsoleGroupUin, ConsoleUserUin, "开发者")
+	c.mock.AddFriend(ConsoleUserUin, "开发者")
+	c.mock.OnAction(c.printAction)
+	c.client = b.ConnectWithProtocol(c.mock)
+	return c
+}
+
+// SetIO 设置控制台的输入和输出
+func (c *Console) SetIO(in io.Reader, out io.Writer) *Console {
+	c.in, c.out = in, out
+	return c
+}
+
+// GetMock 获取控制台使用的模拟协议
+func (c *Console) GetMock() *MockProtocol {
+	return c.mock
+}
+
+// GetClient 获取控制台连接的Bot客户端
+func (c *Console) GetClient() *LagrangeClient {
+	return c.client
+}
+
+// StartConsole 启动cryobot并在当前终端中运行交互式控制台，输入 :quit 或者结束输入时返回
+//
+// 用来代替 AutoConnect 和 Start，开发插件时不需要登录QQ账号
+func (b *Bot) StartConsole() error {
+	if err := b.Run(); err != nil {
+		return err
+	}
+	return NewConsole(b).Run()
+}
+
+// Run 运行控制台，输入 :quit 或者结束输入时返回
+func (c *Console) Run() error {
+	c.println("cryobot 控制台已启动，直接输入消息即可发送，消息中可以使用CQ码，输入 :help 查看所有命令")
+	scanner := bufio.NewScanner(c.in)
+	for {
+		c.prompt()
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "::") { // 以 : 开头的消息需要写成 ::
+			c.say(line[1:])
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			if quit := c.command(strings.Fields(line[1:])); quit {
+				return nil
+			}
+			continue
+		}
+		c.say(line)
+	}
+	return scanner.Err()
+}
+
+// println 打印一行输出
+func (c *Console) println(format string, args ...any) {
+	c.outMutex.Lock()
+	defer c.outMutex.Unlock()
+	_, _ = fmt.Fprintf(c.out, format+"\n", args...)
+}
+
+// prompt 打印输入提示，显示当前的会话和扮演的用户
+func (c *Console) prompt() {
+	c.outMutex.Lock()
+	defer c.outMutex.Unlock()
+	if c.group != 0 {
+		_, _ = fmt.Fprintf(c.out, "[群 %d | %d] > ", c.group, c.user)
+	} else {
+		_, _ = fmt.Fprintf(c.out, "[私聊 | %d] > ", c.user)
+	}
+}
+
+// printAction 打印Bot执行的操作，发送的消息会显示渲染后的内容
+func (c *Console) printAction(a MockAction) {
+	var target string
+	switch {
+	case a.GroupUin != 0 && a.UserUin != 0:
+		target = fmt.Sprintf("群 %d 用户 %d", a.GroupUin, a.UserUin)
+	case a.GroupUin != 0:
+		target = fmt.Sprintf("群 %d", a.GroupUin)
+	case a.UserUin != 0:
+		target = fmt.Sprintf("用户 %d", a.UserUin)
+	}
+	var result string
+	switch {
+	case a.Err != nil:
+		result = "（失败：" + a.Err.Error() + "）"
+	case a.Rejected:
+		result = "（被服务器拒绝）"
+	}
+	if strings.HasPrefix(a.Action, "send_") && strings.HasSuffix(a.Action, "_message") {
+		c.println("\n<< Bot → %s%s：%s", target, result, a.Message.ToString())
+		return
+	}
+	args := make([]string, 0, len(a.Args))
+	for _, arg := range a.Args {
+		args = append(args, fmt.Sprint(arg))
+	}
+	c.println("\n<< Bot 执行 %s %s %s%s", a.Action, target, strings.Join(args, " "), result)
+}
+
+// say 以当前扮演的用户发送一条消息
+func (c *Console) say(text string) {
+	m, err := ParseMessageWithOptions(text, CQParseOptions{AllowLocalFile: true}) // 控制台的输入来自本机的开发者，可以使用本地文件
+	if err != nil {
+		c.println("消息格式错误：%v", err)
+		return
+	}
+	if c.group != 0 {
+		gm := c.mock.ReceiveGroupMessage(c.group, c.user, m)
+		c.println(">> #%d", gm.ID)
+	} else {
+		pm := c.mock.ReceivePrivateMessage(c.user, m)
+		c.println(">> #%d", pm.ID)
+	}
+}
+
+// consoleHelp 是控制台命令的说明
+const consoleHelp = `可用的命令：
+  :group <群号> [群名]          切换到群聊，群不存在时会自动创建
+  :private [QQ号]               切换到和当前用户或者指定用户的私聊
+  :user <QQ号> [昵称]           切换扮演的用户
+  :role <QQ号|bot> <owner|admin|member>  设置群成员的权限
+  :join <QQ号> [邀请人]         模拟有成员加入当前群
+  :leave <QQ号> [操作者]        模拟成员退出当前群，指定操作者时表示被踢出
+  :mute <QQ号|all> <秒数>       当前用户禁言群成员，秒数为0时解除禁言
+  :poke [QQ号]                  当前用户戳一戳，默认戳Bot
+  :recall <消息序号>            当前用户撤回消息，消息序号在发送后显示
+  :request [验证消息]           当前用户发送好友请求
+  :invite <群号> [群名]         当前用户邀请Bot加群
+  :disconnect [原因]            模拟Bot掉线
+  :actions                      查看Bot执行过的所有操作
+  :quit                         退出控制台
+以 : 开头的消息需要写成 ::`
+
+// command 执行一条控制台命令，返回是否需要退出
+func (c *Console) command(fields []string) (quit bool) {
+	if len(fields) == 0 {
+		c.println(consoleHelp)
+		return false
+	}
+	name, args := fields[0], fields[1:]
+	uinArg := func(i int, def uint32) (uint32, bool) {
+		if i >= len(args) {
+			return def, def != 0
+		}
+		if args[i] == "bot" {
+			return ConsoleSelfUin, true
+		}
+		uin, err := strconv.ParseUint(args[i], 10, 32)
+		if err != nil {
+			c.println("%q 不是有效的QQ号或者群号", args[i])
+			return 0, false
+		}
+		return uint32(uin), true
+	}
+	textArg := func(i int) string {
+		if i >= len(args) {
+			return ""
+		}
+		return strings.Join(args[i:], " ")
+	}
+	needGroup := func() bool {
+		if c.group == 0 {
+			c.println("这个命令只能在群聊中使用，请先使用 :group 切换到群聊")
+		}
+		return c.group != 0
+	}
+
+	switch name {
+	case "help", "h", "?":
+		c.println(consoleHelp)
+	case "quit", "exit", "q":
+		return true
+	case "group", "g":
+		uin, ok := uinArg(0, 0)
+		if !ok {
+			c.println("用法：:group <群号> [群名]")
+			return false
+		}
+		if groupName := textArg(1); groupName != "" || c.mock.GetCachedGroupInfo(uin) == nil {
+			c.mock.AddGroup(uin, groupName)
+		}
+		if _, err := c.mock.FetchGroupMember(uin, c.user); err != nil {
+			c.mock.AddMember(uin, c.user, c.nickname())
+		}
+		c.group = uin
+	case "private", "p":
+		uin, ok := uinArg(0, c.user)
+		if !ok {
+			return false
+		}
+		if c.mock.GetCachedFriendInfo(uin) == nil {
+			c.mock.AddFriend(uin, "")
+		}
+		c.group, c.user = 0, uin
+	case "user", "u":
+		uin, ok := uinArg(0, 0)
+		if !ok {
+			c.println("用法：:user <QQ号> [昵称]")
+			return false
+		}
+		c.user = uin
+		nickname := textArg(1)
+		if c.group != 0 {
+			if _, err := c.mock.FetchGroupMember(c.group, uin); err != nil || nickname != "" {
+				c.mock.AddMember(c.group, uin, nickname, c.permission(c.group, uin))
+			}
+		} else if c.mock.GetCachedFriendInfo(uin) == nil || nickname != "" {
+			c.mock.AddFriend(uin, nickname)
+		}
+	case "role":
+		if !needGroup() {
+			return false
+		}
+		uin, ok := uinArg(0, 0)
+		if !ok || len(args) < 2 {
+			c.println("用法：:role <QQ号|bot> <owner|admin|member>")
+			return false
+		}
+		permission, ok := map[string]entity.GroupMemberPermission{
+			"owner": entity.Owner, "admin": entity.Admin, "member": entity.Member,
+		}[args[1]]
+		if !ok {
+			c.println("权限只能是 owner、admin 或者 member")
+			return false
+		}
+		nickname := ""
+		if m, err := c.mock.FetchGroupMember(c.group, uin); err == nil {
+			nickname = m.Nickname
+		}
+		c.mock.AddMember(c.group, uin, nickname, permission)
+		c.println("已将 %d 设置为 %s", uin, args[1])
+	case "join":
+		if !needGroup() {
+			return false
+		}
+		uin, ok := uinArg(0, 0)
+		if !ok {
+			c.println("用法：:join <QQ号> [邀请人]")
+			return false
+		}
+		inviter, ok := uinArg(1, 0)
+		if !ok && len(args) > 1 {
+			return false
+		}
+		c.mock.MemberJoin(c.group, uin, "", inviter)
+	case "leave":
+		if !needGroup() {
+			return false
+		}
+		uin, ok := uinArg(0, 0)
+		if !ok {
+			c.println("用法：:leave <QQ号> [操作者]")
+			return false
+		}
+		operator, ok := uinArg(1, 0)
+		if !ok && len(args) > 1 {
+			return false
+		}
+		c.mock.MemberLeave(c.group, uin, operator)
+	case "mute":
+		if !needGroup() {
+			return false
+		}
+		if len(args) < 2 {
+			c.println("用法：:mute <QQ号|all> <秒数>")
+			return false
+		}
+		seconds, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			c.println("%q 不是有效的秒数", args[1])
+			return false
+		}
+		var uin uint32 // 为0时表示全员禁言
+		if args[0] != "all" {
+			var ok bool
+			if uin, ok = uinArg(0, 0); !ok {
+				return false
+			}
+		}
+		c.mock.MemberMute(c.group, uin, c.user, time.Duration(seconds)*time.Second)
+	case "poke":
+		target, ok := uinArg(0, ConsoleSelfUin)
+		if !ok {
+			return false
+		}
+		if c.group != 0 {
+			c.mock.ReceiveGroupPoke(c.group, c.user, target)
+		} else {
+			c.mock.ReceiveFriendPoke(c.user)
+		}
+	case "recall":
+		seq, ok := uinArg(0, 0)
+		if !ok {
+			c.println("用法：:recall <消息序号>")
+			return false
+		}
+		if c.group != 0 {
+			c.mock.MemberRecall(c.group, c.user, 0, seq)
+		} else {
+			c.mock.ReceiveFriendRecall(c.user, seq)
+		}
+	case "request":
+		c.mock.ReceiveFriendRequest(c.user, c.nickname(), textArg(0))
+	case "invite":
+		uin, ok := uinArg(0, 0)
+		if !ok {
+			c.println("用法：:invite <群号> [群名]")
+			return false
+		}
+		c.mock.ReceiveGroupInvite(uin, textArg(1), c.user)
+	case "disconnect":
+		c.mock.Disconnect(textArg(0))
+	case "actions":
+		for _, a := range c.mock.Actions() {
+			c.printAction(a)
+		}
+	default:
+		c.println("未知的命令 :%s，输入 :help 查看所有命令", name)
+	}
+	return false
+}
+
+// nickname 获取当前扮演的用户的昵称
+func (c *Console) nickname() string {
+	if c.group != 0 {
+		if m, err := c.mock.FetchGroupMember(c.group, c.user); err == nil {
+			return m.Nickname
+		}
+	}
+	if f := c.mock.GetCachedFriendInfo(c.user); f != nil {
+		return f.Nickname
+	}
+	return strconv.FormatUint(uint64(c.user), 10)
+}
+
+// permission 获取群成员当前的权限，不在群中时为普通成员
+func (c *Console) permission(groupUin, uin uint32) entity.GroupMemberPermission {
+	if m, err := c.mock.FetchGroupMember(groupUin, uin); err == nil {
+		return m.Permission
+	}
+	return entity.Member
+}
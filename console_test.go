@@ -0,0 +1,71 @@
+package cryo_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/machinacanis/cryo"
+	"github.com/machinacanis/cryo/cryotest"
+)
+
+// syncBuffer 是可以同时读写的输出缓冲，控制台会在其他 goroutine 中打印Bot的回复
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestConsolePrivateMessage(t *testing.T) {
+	h := cryotest.New(t)
+	received := make(chan *cryo.PrivateMessageEvent, 1)
+	h.Bot().OnType(cryo.PrivateMessageEventType).Handle(func(e *cryo.PrivateMessageEvent) {
+		received <- e
+		_, _ = h.Bot().SendTo(e, "pong")
+	}).Register()
+
+	in, input := io.Pipe()
+	out := &syncBuffer{}
+	done := make(chan error, 1)
+	go func() { done <- cryo.NewConsole(h.Bot()).SetIO(in, out).Run() }()
+
+	_, _ = io.WriteString(input, ":private\nping [CQ:face,id=14]\n")
+	select {
+	case e := <-received:
+		if e.SenderUin != cryo.ConsoleUserUin || e.ClientUin != cryo.ConsoleSelfUin {
+			t.Errorf("私聊消息来自 %d 发给 %d，期望来自 %d 发给 %d", e.SenderUin, e.ClientUin, cryo.ConsoleUserUin, cryo.ConsoleSelfUin)
+		}
+		if !e.MessageElements.HasType(cryo.FaceType) || !strings.HasPrefix(e.MessageElements.ToString(), "ping") {
+			t.Errorf("控制台输入的CQ码没有被解析：%q", e.MessageElements.ToString())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("控制台输入的消息没有产生私聊消息事件")
+	}
+	waitFor(t, "控制台打印回复", func() bool {
+		return strings.Contains(out.String(), "<< Bot → 用户 10001：pong")
+	})
+
+	_, _ = io.WriteString(input, ":quit\n")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("控制台退出时返回了错误：%v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("输入 :quit 后控制台没有退出")
+	}
+}
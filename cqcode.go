@@ -0,0 +1,494 @@
+package cryo
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-json-experiment/json"
+)
+
+// ErrInvalidCQCode CQ码格式错误或者包含无法解析的内容
+var ErrInvalidCQCode = errors.New("CQ码格式错误")
+
+// 文本和参数中需要转义的字符，参数中还需要额外转义逗号
+var (
+	cqTextEscaper  = strings.NewReplacer("&", "&amp;", "[", "&#91;", "]", "&#93;")
+	cqParamEscaper = strings.NewReplacer("&", "&amp;", "[", "&#91;", "]", "&#93;", ",", "&#44;")
+	cqUnescaper    = strings.NewReplacer("&#91;", "[", "&#93;", "]", "&#44;", ",", "&amp;", "&")
+)
+
+const cqParamSeparator = ","
+
+// EscapeCQText 转义文本中的CQ码特殊字符，拼接CQ码字符串时使用
+func EscapeCQText(text string) string {
+	return cqTextEscaper.Replace(text)
+}
+
+// UnescapeCQText 还原被转义的CQ码特殊字符
+func UnescapeCQText(text string) string {
+	return cqUnescaper.Replace(text)
+}
+
+// ToCQCode 将消息转换为兼容CQ码格式的字符串，可以通过 ParseMessage 还原
+//
+// 收到的媒体保留ID和链接，本地构造还没有上传的媒体会被编码为base64数据，
+// 没有ResID的合并转发消息会把节点编码为JSON
+func (m *Message) ToCQCode() string {
+	var sb strings.Builder
+	for _, e := range *m {
+		sb.WriteString(elementToCQCode(e))
+	}
+	return sb.String()
+}
+
+// elementToCQCode 将单个消息元素转换为CQ码
+func elementToCQCode(e MessageElement) string {
+	if t, ok := e.(*Text); ok {
+		return EscapeCQText(t.Content)
+	}
+	typ, params := elementToCQParams(e)
+	if typ == "" {
+		return ""
+	}
+	return buildCQCode(typ, params...)
+}
+
+// elementToCQParams 获取消息元素对应的CQ码类型和参数，params 是交替的键和值，不支持的元素返回空类型
+func elementToCQParams(e MessageElement) (typ string, params []string) {
+	switch v := e.(type) {
+	case *Text:
+		return "text", []string{"text", v.Content}
+	case *At:
+		qq := "all"
+		if v.TargetUin != 0 {
+			qq = strconv.FormatUint(uint64(v.TargetUin), 10)
+		}
+		return "at", []string{"qq", qq, "name", v.Display}
+	case *Face:
+		return "face", []string{"id", strconv.FormatUint(uint64(v.FaceID), 10)}
+	case *Reply:
+		params := []string{
+			"id", strconv.FormatUint(uint64(v.ReplySeq), 10),
+			"qq", strconv.FormatUint(uint64(v.SenderUin), 10),
+			"time", strconv.FormatUint(uint64(v.Time), 10),
+		}
+		if v.GroupUin != 0 {
+			params = append(params, "group", strconv.FormatUint(uint64(v.GroupUin), 10))
+		}
+		return "reply", params
+	case *Image:
+		file := v.ImageID
+		if file == "" && v.URL == "" {
+			file = cqBase64(v.Stream)
+		}
+		return "image", []string{"file", file, "url", v.URL, "summary", v.Summary}
+	case *Voice:
+		file := v.UUID
+		if file == "" && v.URL == "" {
+			file = cqBase64(v.Stream)
+		}
+		return "record", []string{"file", file, "url", v.URL}
+	case *ShortVideo:
+		file := v.UUID
+		if file == "" && v.URL == "" {
+			file = cqBase64(v.Stream)
+		}
+		return "video", []string{"file", file, "url", v.URL}
+	case *File:
+		var file string
+		if v.FileID == "" && v.FileUUID == "" && v.FileURL == "" {
+			file = cqBase64(v.FileStream)
+		}
+		return "file", []string{
+			"file", file, "name", v.FileName, "id", v.FileID, "uuid", v.FileUUID,
+			"size", cqUintParam(v.FileSize), "url", v.FileURL,
+		}
+	case *ForwardMessage:
+		if v.ResID != "" {
+			return "forward", []string{"id", v.ResID}
+		}
+		return "forward", []string{"content", cqForwardContent(v)}
+	case *LightApp:
+		return "json", []string{"data", v.Content}
+	case *XML:
+		return "xml", []string{"id", strconv.Itoa(v.ServiceID), "data", v.Content}
+	case *MarketFace:
+		return "mface", []string{
+			"emoji_id", hex.EncodeToString(v.FaceID),
+			"emoji_package_id", cqUintParam(uint64(v.TabID)),
+			"key", hex.EncodeToString(v.EncryptKey),
+			"summary", v.Summary,
+			"item_type", cqUintParam(uint64(v.ItemType)),
+			"face_info", cqUintParam(uint64(v.FaceInfo)),
+			"sub_type", cqUintParam(uint64(v.SubType)),
+			"media_type", cqUintParam(uint64(v.MediaType)),
+			"magic_value", v.MagicValue,
+		}
+	}
+	return "", nil
+}
+
+// cqBase64 读取本地构造的媒体数据并编码为 base64:// 格式，读取之后会把位置移回开头，不能读取时返回空字符串
+func cqBase64(r io.ReadSeeker) string {
+	if r == nil {
+		return ""
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	data, err := io.ReadAll(r)
+	_, _ = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return ""
+	}
+	return "base64://" + base64.StdEncoding.EncodeToString(data)
+}
+
+// cqForwardContent 把合并转发消息的节点编码为JSON，格式和 Message.MarshalJSON 中的转发节点一致
+func cqForwardContent(f *ForwardMessage) string {
+	nodes := make([]*jsonForwardNode, 0, len(f.Nodes))
+	for _, n := range f.GetNodes() {
+		nodes = append(nodes, &jsonForwardNode{GroupUin: n.GroupUin, SenderUin: n.SenderUin, SenderName: n.SenderName, Time: n.Time, Message: n.Message})
+	}
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// cqUintParam 把数字参数转换为字符串，为0时返回空字符串，这样构造CQ码时会省略这个参数
+func cqUintParam(n uint64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// buildCQCode 构造CQ码，params 是交替的键和值，值为空的参数会被忽略
+func buildCQCode(typ string, params ...string) string {
+	var sb strings.Builder
+	sb.WriteString("[CQ:")
+	sb.WriteString(typ)
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i+1] == "" {
+			continue
+		}
+		sb.WriteString(cqParamSeparator)
+		sb.WriteString(params[i])
+		sb.WriteByte('=')
+		sb.WriteString(cqParamEscaper.Replace(params[i+1]))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+// CQParseOptions 是解析CQ码时的选项
+type CQParseOptions struct {
+	AllowLocalFile bool // 是否允许媒体的 file 参数使用 file:// 开头的本地路径，CQ码来自不可信的来源时不要开启
+}
+
+// ParseMessage 解析CQ码格式的字符串，构造对应的消息，不允许读取本地文件
+//
+// 支持 text、at、face、image、reply、record、video、file、forward、json、xml 和 mface，
+// 图片、语音、视频和文件的 file 参数可以是 base64:// 开头的数据或者 http(s) 链接，图片会在发送时下载，
+// 其他的值会被当作已经上传过的媒体的ID，需要使用本地文件时请使用 ParseMessageWithOptions
+func ParseMessage(s string) (Message, error) {
+	return ParseMessageWithOptions(s, CQParseOptions{})
+}
+
+// ParseMessageWithOptions 使用指定的选项解析CQ码格式的字符串
+func ParseMessageWithOptions(s string, opt CQParseOptions) (Message, error) {
+	m := Message{}
+	for len(s) > 0 {
+		start := strings.Index(s, "[CQ:")
+		if start < 0 {
+			m.AddText(UnescapeCQText(s))
+			break
+		}
+		if start > 0 {
+			m.AddText(UnescapeCQText(s[:start]))
+		}
+		end := strings.IndexByte(s[start:], ']')
+		if end < 0 {
+			return nil, fmt.Errorf("%w：CQ码没有闭合 %q", ErrInvalidCQCode, s[start:])
+		}
+		code := s[start+len("[CQ:") : start+end]
+		e, err := parseCQCode(code, opt)
+		if err != nil {
+			return nil, err
+		}
+		m.Add(e)
+		s = s[start+end+1:]
+	}
+	return m, nil
+}
+
+// parseCQCode 解析去掉了方括号和前缀的CQ码内容
+func parseCQCode(code string, opt CQParseOptions) (MessageElement, error) {
+	parts := strings.Split(code, cqParamSeparator)
+	typ := parts[0]
+	params := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w：[CQ:%s] 的参数 %q 缺少值", ErrInvalidCQCode, typ, p)
+		}
+		params[k] = UnescapeCQText(v)
+	}
+	return cqElement(typ, params, opt)
+}
+
+// cqElement 根据CQ码的类型和已经反转义的参数构造消息元素
+func cqElement(typ string, params map[string]string, opt CQParseOptions) (MessageElement, error) {
+	switch typ {
+	case "text":
+		return &Text{*lgrmessage.NewText(params["text"])}, nil
+	case "at":
+		if params["qq"] == "all" {
+			return &At{*lgrmessage.NewAt(0)}, nil
+		}
+		uin, err := cqUint32(typ, params, "qq", true)
+		if err != nil {
+			return nil, err
+		}
+		if name := params["name"]; name != "" {
+			return &At{*lgrmessage.NewAt(uin, name)}, nil
+		}
+		return &At{*lgrmessage.NewAt(uin)}, nil
+	case "face":
+		id, err := cqUint32(typ, params, "id", true)
+		if err != nil {
+			return nil, err
+		}
+		return &Face{*lgrmessage.NewFace(id)}, nil
+	case "reply":
+		seq, err := cqUint32(typ, params, "id", true)
+		if err != nil {
+			return nil, err
+		}
+		sender, err := cqUint32(typ, params, "qq", false)
+		if err != nil {
+			return nil, err
+		}
+		t, err := cqUint32(typ, params, "time", false)
+		if err != nil {
+			return nil, err
+		}
+		groupUin, err := cqUint32(typ, params, "group", false)
+		if err != nil {
+			return nil, err
+		}
+		return &Reply{lgrmessage.ReplyElement{ReplySeq: seq, SenderUin: sender, Time: t, GroupUin: groupUin}}, nil
+	case "image":
+		src, err := cqMedia(typ, params, opt)
+		if err != nil {
+			return nil, err
+		}
+		summary := params["summary"]
+		if src.data != nil {
+			return &Image{ImageElement: *lgrmessage.NewImage(src.data, summary)}, nil
+		}
+		if src.path != "" {
+			img, err := lgrmessage.NewFileImage(src.path, summary)
+			if err != nil {
+				return nil, err
+			}
+			return &Image{ImageElement: *img}, nil
+		}
+		// 只有链接的图片会在发送时下载并上传
+		return &Image{ImageElement: lgrmessage.ImageElement{ImageID: src.id, URL: src.url, Summary: summary}}, nil
+	case "record":
+		src, err := cqMedia(typ, params, opt)
+		if err != nil {
+			return nil, err
+		}
+		if src.data != nil {
+			return &Voice{VoiceElement: *lgrmessage.NewRecord(src.data)}, nil
+		}
+		if src.path != "" {
+			v, err := lgrmessage.NewFileRecord(src.path)
+			if err != nil {
+				return nil, err
+			}
+			return &Voice{VoiceElement: *v}, nil
+		}
+		return &Voice{VoiceElement: lgrmessage.VoiceElement{UUID: src.id, URL: src.url}}, nil
+	case "video":
+		src, err := cqMedia(typ, params, opt)
+		if err != nil {
+			return nil, err
+		}
+		if src.data != nil {
+			return &ShortVideo{ShortVideoElement: *lgrmessage.NewVideo(src.data, nil)}, nil
+		}
+		if src.path != "" {
+			v, err := lgrmessage.NewFileVideo(src.path, nil)
+			if err != nil {
+				return nil, err
+			}
+			return &ShortVideo{ShortVideoElement: *v}, nil
+		}
+		return &ShortVideo{ShortVideoElement: lgrmessage.ShortVideoElement{UUID: src.id, URL: src.url}}, nil
+	case "file":
+		return parseCQFile(params, opt)
+	case "forward":
+		return parseCQForward(params)
+	case "json":
+		return &LightApp{*lgrmessage.NewLightApp(params["data"])}, nil
+	case "xml":
+		id := 60 // 和LagrangeGo的默认值保持一致
+		if params["id"] != "" {
+			n, err := strconv.Atoi(params["id"])
+			if err != nil {
+				return nil, fmt.Errorf("%w：[CQ:xml] 的参数 id 不是数字", ErrInvalidCQCode)
+			}
+			id = n
+		}
+		return &XML{*lgrmessage.NewXMLWithID(id, params["data"])}, nil
+	case "mface":
+		return parseCQMarketFace(params)
+	}
+	return nil, fmt.Errorf("%w：不支持的CQ码类型 %q", ErrInvalidCQCode, typ)
+}
+
+// cqSource 是媒体CQ码的来源，data 和 path 都为空时是已经上传过的媒体的ID或者链接
+type cqSource struct {
+	data []byte // base64:// 解码后的数据
+	path string // file:// 指向的本地路径
+	id   string // 已经上传过的媒体的ID
+	url  string // 媒体的链接
+}
+
+// cqMedia 解析媒体CQ码的 file 和 url 参数，file 参数是 http(s) 链接时会被当作链接
+func cqMedia(typ string, params map[string]string, opt CQParseOptions) (cqSource, error) {
+	file := params["file"]
+	src := cqSource{url: params["url"]}
+	switch {
+	case strings.HasPrefix(file, "base64://"):
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(file, "base64://"))
+		if err != nil {
+			return src, fmt.Errorf("%w：[CQ:%s] 的base64数据无效", ErrInvalidCQCode, typ)
+		}
+		src.data = data
+	case strings.HasPrefix(file, "file://"):
+		if !opt.AllowLocalFile {
+			return src, fmt.Errorf("%w：[CQ:%s] 不允许使用本地文件 %q", ErrInvalidCQCode, typ, file)
+		}
+		src.path = strings.TrimPrefix(file, "file://")
+	case isHTTPURL(file):
+		src.url = file
+	default:
+		src.id = file
+	}
+	if src.data == nil && src.path == "" && src.id == "" && !isHTTPURL(src.url) {
+		return src, fmt.Errorf("%w：[CQ:%s] 缺少可用的来源", ErrInvalidCQCode, typ)
+	}
+	return src, nil
+}
+
+// parseCQFile 解析文件CQ码，file 参数是上传的数据，没有时使用 id 或 uuid 引用已经上传过的文件
+func parseCQFile(params map[string]string, opt CQParseOptions) (MessageElement, error) {
+	name := params["name"]
+	if params["file"] == "" {
+		size, err := strconv.ParseUint(cmp.Or(params["size"], "0"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w：[CQ:file] 的参数 size 不是数字", ErrInvalidCQCode)
+		}
+		if params["id"] == "" && params["uuid"] == "" && !isHTTPURL(params["url"]) {
+			return nil, fmt.Errorf("%w：[CQ:file] 缺少可用的来源", ErrInvalidCQCode)
+		}
+		return &File{FileElement: lgrmessage.FileElement{
+			FileName: name, FileID: params["id"], FileUUID: params["uuid"], FileSize: size, FileURL: params["url"],
+		}}, nil
+	}
+	src, err := cqMedia("file", params, opt)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case src.data != nil:
+		if name == "" {
+			return nil, fmt.Errorf("%w：[CQ:file] 使用base64数据时需要指定 name", ErrInvalidCQCode)
+		}
+		return NewFile(src.data, name), nil
+	case src.path != "" && name != "":
+		return NewLocalFile(src.path, name)
+	case src.path != "":
+		return NewLocalFile(src.path)
+	}
+	return nil, fmt.Errorf("%w：[CQ:file] 的参数 file 需要是base64数据或者本地路径", ErrInvalidCQCode)
+}
+
+// parseCQForward 解析合并转发CQ码，id 是合并转发的ResID，content 是JSON格式的转发节点
+func parseCQForward(params map[string]string) (MessageElement, error) {
+	if params["id"] != "" {
+		return &ForwardMessage{*lgrmessage.NewForwardWithResID(params["id"])}, nil
+	}
+	if params["content"] == "" {
+		return nil, fmt.Errorf("%w：[CQ:forward] 缺少参数 id 或 content", ErrInvalidCQCode)
+	}
+	var nodes []*jsonForwardNode
+	if err := json.Unmarshal([]byte(params["content"]), &nodes); err != nil {
+		return nil, fmt.Errorf("%w：[CQ:forward] 的参数 content 无效：%w", ErrInvalidCQCode, err)
+	}
+	f := &ForwardMessage{}
+	for _, n := range nodes {
+		f.Nodes = append(f.Nodes, (&ForwardNode{
+			GroupUin: n.GroupUin, SenderUin: n.SenderUin, SenderName: n.SenderName, Time: n.Time, Message: n.Message,
+		}).toLgrNode())
+	}
+	return f, nil
+}
+
+// parseCQMarketFace 解析魔法表情CQ码，emoji_id 和 key 是十六进制编码的数据
+func parseCQMarketFace(params map[string]string) (MessageElement, error) {
+	faceID, err := hex.DecodeString(params["emoji_id"])
+	if err != nil || len(faceID) == 0 {
+		return nil, fmt.Errorf("%w：[CQ:mface] 的参数 emoji_id 无效", ErrInvalidCQCode)
+	}
+	key, err := hex.DecodeString(params["key"])
+	if err != nil {
+		return nil, fmt.Errorf("%w：[CQ:mface] 的参数 key 无效", ErrInvalidCQCode)
+	}
+	v := lgrmessage.MarketFaceElement{FaceID: faceID, EncryptKey: key, Summary: params["summary"], MagicValue: params["magic_value"]}
+	for _, p := range []struct {
+		key string
+		dst *uint32
+	}{
+		{"emoji_package_id", &v.TabID}, {"item_type", &v.ItemType}, {"face_info", &v.FaceInfo},
+		{"sub_type", &v.SubType}, {"media_type", &v.MediaType},
+	} {
+		if *p.dst, err = cqUint32("mface", params, p.key, false); err != nil {
+			return nil, err
+		}
+	}
+	return &MarketFace{v}, nil
+}
+
+// cqUint32 读取CQ码中的数字参数，参数不存在时返回0，required 为true时返回错误
+func cqUint32(typ string, params map[string]string, key string, required bool) (uint32, error) {
+	v, ok := params[key]
+	if !ok || v == "" {
+		if required {
+			return 0, fmt.Errorf("%w：[CQ:%s] 缺少参数 %s", ErrInvalidCQCode, typ, key)
+		}
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w：[CQ:%s] 的参数 %s 不是数字", ErrInvalidCQCode, typ, key)
+	}
+	return uint32(n), nil
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
@@ -0,0 +1,79 @@
+package cryo_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/machinacanis/cryo"
+)
+
+func TestParseMessageCQCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string // 再次转换为CQ码的结果，为空时期望和输入相同
+		text  string // 第一个元素是文本时期望的内容
+		err   error
+	}{
+		{name: "文本转义", input: "a&amp;b&#91;c&#93;,d", text: "a&b[c],d"},
+		{name: "参数中的逗号", input: "[CQ:at,qq=123,name=a&#44;b]"},
+		{name: "全体成员", input: "[CQ:at,qq=all]", want: "[CQ:at,qq=all,name=@全体成员]"},
+		{name: "表情", input: "[CQ:face,id=14]"},
+		{name: "回复", input: "[CQ:reply,id=5,qq=1001,time=100,group=100]"},
+		{name: "图片链接", input: "[CQ:image,file=https://example.com/a.png]", want: "[CQ:image,url=https://example.com/a.png]"},
+		{name: "图片参数转义", input: "[CQ:image,file=abc.image,url=https://example.com/a.png,summary=&#91;动画表情&#93;]"},
+		{name: "base64图片", input: "[CQ:image,file=base64://aGk=]"},
+		{name: "混合", input: "你好[CQ:at,qq=123,name=@小明] &#91;不是CQ码&#93;[CQ:face,id=1]", text: "你好"},
+		{name: "未知类型", input: "[CQ:dice]", err: cryo.ErrInvalidCQCode},
+		{name: "没有闭合", input: "[CQ:face,id=1", err: cryo.ErrInvalidCQCode},
+		{name: "参数缺少值", input: "[CQ:face,id]", err: cryo.ErrInvalidCQCode},
+		{name: "不允许本地文件", input: "[CQ:image,file=file:///etc/passwd]", err: cryo.ErrInvalidCQCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := cryo.ParseMessage(tt.input)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("解析 %q 返回 %v，期望 %v", tt.input, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析 %q 失败：%v", tt.input, err)
+			}
+			if tt.text != "" {
+				if text, ok := m[0].(*cryo.Text); !ok || text.Content != tt.text {
+					t.Errorf("第一个元素为 %#v，期望文本 %q", m[0], tt.text)
+				}
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.input
+			}
+			if got := m.ToCQCode(); got != want {
+				t.Errorf("再次转换为CQ码得到 %q，期望 %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseMessageLocalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code := "[CQ:file,file=file://" + path + ",name=a.txt]"
+
+	if _, err := cryo.ParseMessage(code); !errors.Is(err, cryo.ErrInvalidCQCode) {
+		t.Fatalf("没有开启 AllowLocalFile 时解析本地文件返回 %v，期望 ErrInvalidCQCode", err)
+	}
+	m, err := cryo.ParseMessageWithOptions(code, cryo.CQParseOptions{AllowLocalFile: true})
+	if err != nil {
+		t.Fatalf("开启 AllowLocalFile 后解析本地文件失败：%v", err)
+	}
+	if f, ok := m[0].(*cryo.File); !ok || f.FileName != "a.txt" {
+		t.Errorf("解析出的元素为 %#v，期望文件 a.txt", m[0])
+	}
+}
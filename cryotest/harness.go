@@ -0,0 +1,421 @@
+// Package cryotest 提供了在单元测试中运行cryobot插件的测试工具
+//
+// Harness 会创建一个使用 cryo.MockProtocol 的Bot，不需要登录也不会访问网络，
+// 可以模拟用户发送消息并检查Bot的回复：
+//
+//	func TestPing(t *testing.T) {
+//		h := cryotest.New(t, &PingPlugin{})
+//		h.Group(123).User(456).Says("/ping")
+//		h.ExpectReply("pong")
+//	}
+//
+// 定时任务使用的是假时钟，可以通过 Harness.Advance 推进时间，事件处理函数中出现的 panic 会让测试失败
+package cryotest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/client/entity"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/jonboulle/clockwork"
+	"github.com/machinacanis/cryo"
+)
+
+// SelfUin 是测试中Bot自己的账号
+const SelfUin uint32 = 10000
+
+// DefaultTimeout 是等待Bot回复的默认超时时间
+var DefaultTimeout = time.Second
+
+// DefaultQuietPeriod 是 ExpectNoReply 在事件处理完成之后继续等待的时间，用来等待插件自己创建的 goroutine
+var DefaultQuietPeriod = 20 * time.Millisecond
+
+// Harness 是插件测试的运行环境
+type Harness struct {
+	t         testing.TB
+	bot       *cryo.Bot
+	client    *cryo.LagrangeClient
+	mock      *cryo.MockProtocol
+	clock     *clockwork.FakeClock
+	scheduler gocron.Scheduler
+	logger    *testLogger
+	timeout   time.Duration
+
+	mutex        sync.Mutex
+	sentCursor   int      // 已经检查过的发送消息数量
+	actionCursor int      // 已经检查过的操作数量
+	lastGroup    uint32   // 最近一次模拟发送消息的群，私聊时为0
+	lastUser     uint32   // 最近一次模拟发送消息的用户
+	panics       []string // 事件处理函数中出现的 panic
+	reported     int      // 已经报告过的 panic 数量
+}
+
+// New 创建一个测试环境并加载插件，测试结束时会自动关闭
+func New(t testing.TB, plugins ...cryo.Plugin) *Harness {
+	t.Helper()
+	return NewWithConfig(t, cryo.Config{}, plugins...)
+}
+
+// NewWithConfig 使用指定的配置项创建一个测试环境，定时任务调度器总是会被启用
+func NewWithConfig(t testing.TB, conf cryo.Config, plugins ...cryo.Plugin) *Harness {
+	t.Helper()
+	h := &Harness{
+		t:       t,
+		bot:     cryo.NewBot(),
+		clock:   clockwork.NewFakeClock(),
+		logger:  &testLogger{t: t},
+		timeout: DefaultTimeout,
+	}
+	conf.EnableCronScheduler = true
+	h.bot.Init(h.logger, conf)
+
+	scheduler, err := gocron.NewScheduler(gocron.WithClock(h.clock))
+	if err != nil {
+		t.Fatalf("cryotest: 创建定时任务调度器失败：%v", err)
+	}
+	h.scheduler = scheduler
+	h.bot.SetScheduler(scheduler)
+	h.bot.GetBus().SetPanicHandler(h.recordPanic)
+
+	h.mock = cryo.NewMockProtocol(SelfUin)
+	h.mock.SetClock(h.clock.Now)
+	h.client = h.bot.ConnectWithProtocol(h.mock)
+	h.bot.AddPlugin(plugins...)
+	if err := h.bot.Run(); err != nil {
+		t.Fatalf("cryotest: 启动Bot失败：%v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = h.scheduler.Shutdown()
+		h.failOnPanic()
+		h.logger.close()
+	})
+	return h
+}
+
+// WithTimeout 设置等待Bot回复的超时时间
+func (h *Harness) WithTimeout(timeout time.Duration) *Harness {
+	h.timeout = timeout
+	return h
+}
+
+// Bot 获取测试中的Bot
+func (h *Harness) Bot() *cryo.Bot {
+	return h.bot
+}
+
+// Client 获取测试中的Bot客户端
+func (h *Harness) Client() *cryo.LagrangeClient {
+	return h.client
+}
+
+// Mock 获取测试中使用的模拟协议，可以用来注入错误或者推送其他事件
+func (h *Harness) Mock() *cryo.MockProtocol {
+	return h.mock
+}
+
+// Clock 获取测试中使用的假时钟
+func (h *Harness) Clock() *clockwork.FakeClock {
+	return h.clock
+}
+
+// Now 获取假时钟的当前时间
+func (h *Harness) Now() time.Time {
+	return h.clock.Now()
+}
+
+// Advance 推进假时钟，到期的定时任务会被触发
+//
+// 任务是在其他 goroutine 中执行的，需要检查任务的结果时可以继续使用 ExpectReply 等待
+func (h *Harness) Advance(d time.Duration) {
+	h.t.Helper()
+	// 先等待正在处理的事件完成，再等待调度器为所有任务设置好计时器，否则推进时间时可能错过刚添加的任务
+	h.waitIdle()
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	_ = h.clock.BlockUntilContext(ctx, len(h.scheduler.Jobs()))
+	h.clock.Advance(d)
+	h.failOnPanic()
+}
+
+// waitIdle 等待事件总线处理完所有事件，最多等待 timeout
+func (h *Harness) waitIdle() {
+	deadline := time.Now().Add(h.timeout)
+	for h.bot.GetBus().Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// recordPanic 记录事件处理函数中出现的 panic
+func (h *Harness) recordPanic(event cryo.Event, value any, stack []byte) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.panics = append(h.panics, fmt.Sprintf("处理 %s 时出现 panic：%v\n%s", event.GetEventType().ToString(), value, stack))
+}
+
+// failOnPanic 在出现过 panic 时让测试失败，每个 panic 只会报告一次
+func (h *Harness) failOnPanic() {
+	h.t.Helper()
+	h.mutex.Lock()
+	panics := h.panics[h.reported:]
+	h.reported = len(h.panics)
+	h.mutex.Unlock()
+	for _, p := range panics {
+		h.t.Errorf("cryotest: %s", p)
+	}
+}
+
+// Group 获取一个群的模拟会话，群不存在时会自动创建
+func (h *Harness) Group(groupUin uint32) *Group {
+	if h.mock.GetCachedGroupInfo(groupUin) == nil {
+		h.mock.AddGroup(groupUin, "")
+	}
+	return &Group{h: h, uin: groupUin}
+}
+
+// User 获取一个私聊的模拟用户，用户会自动成为Bot的好友
+func (h *Harness) User(uin uint32) *User {
+	if h.mock.GetCachedFriendInfo(uin) == nil {
+		h.mock.AddFriend(uin, strconv.FormatUint(uint64(uin), 10))
+	}
+	return &User{h: h, uin: uin}
+}
+
+// Group 是一个群的模拟会话
+type Group struct {
+	h   *Harness
+	uin uint32
+}
+
+// Named 设置群名称
+func (g *Group) Named(name string) *Group {
+	g.h.mock.AddGroup(g.uin, name)
+	return g
+}
+
+// BotIs 设置Bot在群中的权限，默认是普通成员，测试管理功能时需要设置为管理员或者群主
+func (g *Group) BotIs(permission entity.GroupMemberPermission) *Group {
+	g.h.mock.AddMember(g.uin, SelfUin, "", permission)
+	return g
+}
+
+// User 获取群中的一个成员，成员不存在时会自动加入群
+func (g *Group) User(uin uint32) *User {
+	if _, err := g.h.mock.FetchGroupMember(g.uin, uin); err != nil {
+		g.h.mock.AddMember(g.uin, uin, "")
+	}
+	return &User{h: g.h, group: g.uin, uin: uin}
+}
+
+// User 是一个模拟用户，在群会话中获取时代表群成员，否则代表私聊的好友
+type User struct {
+	h     *Harness
+	group uint32
+	uin   uint32
+}
+
+// Named 设置用户的昵称
+func (u *User) Named(nickname string) *User {
+	if u.group != 0 {
+		m, err := u.h.mock.FetchGroupMember(u.group, u.uin)
+		permission := entity.Member
+		if err == nil {
+			permission = m.Permission
+		}
+		u.h.mock.AddMember(u.group, u.uin, nickname, permission)
+	} else {
+		u.h.mock.AddFriend(u.uin, nickname)
+	}
+	return u
+}
+
+// Is 设置群成员的权限
+func (u *User) Is(permission entity.GroupMemberPermission) *User {
+	if u.group != 0 {
+		nickname := ""
+		if m, err := u.h.mock.FetchGroupMember(u.group, u.uin); err == nil {
+			nickname = m.Nickname
+		}
+		u.h.mock.AddMember(u.group, u.uin, nickname, permission)
+	}
+	return u
+}
+
+// Says 模拟用户发送一条消息，参数和 Bot.Send 一样
+func (u *User) Says(args ...any) *User {
+	u.h.t.Helper()
+	u.h.mutex.Lock()
+	u.h.lastGroup, u.h.lastUser = u.group, u.uin
+	u.h.mutex.Unlock()
+	if u.group != 0 {
+		u.h.mock.ReceiveGroupMessage(u.group, u.uin, args...)
+	} else {
+		u.h.mock.ReceivePrivateMessage(u.uin, args...)
+	}
+	u.h.failOnPanic()
+	return u
+}
+
+// Joins 模拟用户加入群，inviter 为0时表示主动加群
+func (u *User) Joins(inviter uint32) *User {
+	u.h.t.Helper()
+	u.h.mock.MemberJoin(u.group, u.uin, "", inviter)
+	u.h.failOnPanic()
+	return u
+}
+
+// Leaves 模拟用户退出群
+func (u *User) Leaves() *User {
+	u.h.t.Helper()
+	u.h.mock.MemberLeave(u.group, u.uin, 0)
+	u.h.failOnPanic()
+	return u
+}
+
+// Replay 读取录制文件并立即回放其中的所有事件，事件会绑定到测试中的Bot客户端，回放完成后等待事件处理完成
+//
+// 回放之后的 ExpectReply 不会检查回复的会话，可以通过返回的操作自己检查
+func (h *Harness) Replay(files ...string) {
+	h.t.Helper()
+	records, err := cryo.ReadRecording(files...)
+	if err != nil {
+		h.t.Fatalf("cryotest: 读取录制文件失败：%v", err)
+	}
+	h.mutex.Lock()
+	h.lastGroup, h.lastUser = 0, 0
+	h.mutex.Unlock()
+	if err := h.bot.Replay(context.Background(), records, cryo.ReplayOptions{Client: h.client}); err != nil {
+		h.t.Fatalf("cryotest: 回放失败：%v", err)
+	}
+	h.waitIdle()
+	h.failOnPanic()
+}
+
+// Sent 获取Bot成功发送的所有消息
+func (h *Harness) Sent() []cryo.MockAction {
+	return h.mock.SentMessages()
+}
+
+// Actions 获取Bot执行的所有操作，包括发送消息
+func (h *Harness) Actions() []cryo.MockAction {
+	return h.mock.Actions()
+}
+
+// Text 获取消息中的纯文本内容
+func Text(m cryo.Message) string {
+	var sb strings.Builder
+	for _, e := range m {
+		if t, ok := e.(*cryo.Text); ok {
+			sb.WriteString(t.Content)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// waitSent 等待Bot发出下一条还没有检查过的消息
+func (h *Harness) waitSent(timeout time.Duration) (cryo.MockAction, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		sent := h.mock.SentMessages()
+		h.mutex.Lock()
+		if h.sentCursor < len(sent) {
+			a := sent[h.sentCursor]
+			h.sentCursor++
+			h.mutex.Unlock()
+			return a, true
+		}
+		h.mutex.Unlock()
+		if time.Now().After(deadline) {
+			return cryo.MockAction{}, false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// NextReply 等待并返回Bot发出的下一条消息，超时会让测试失败
+func (h *Harness) NextReply() cryo.MockAction {
+	h.t.Helper()
+	a, ok := h.waitSent(h.timeout)
+	h.failOnPanic()
+	if !ok {
+		h.t.Fatalf("cryotest: 等待 %s 后Bot仍然没有发出消息", h.timeout)
+	}
+	return a
+}
+
+// ExpectReply 等待Bot的下一条消息，并检查它是发给最近一次发言的会话的，而且纯文本内容和 text 相同
+func (h *Harness) ExpectReply(text string) cryo.MockAction {
+	h.t.Helper()
+	a := h.expectReplyTarget()
+	if got := Text(a.Message); got != text {
+		h.t.Fatalf("cryotest: Bot的回复是 %q，期望是 %q", got, text)
+	}
+	return a
+}
+
+// ExpectReplyContains 等待Bot的下一条消息，并检查它的纯文本内容包含 sub
+func (h *Harness) ExpectReplyContains(sub string) cryo.MockAction {
+	h.t.Helper()
+	a := h.expectReplyTarget()
+	if got := Text(a.Message); !strings.Contains(got, sub) {
+		h.t.Fatalf("cryotest: Bot的回复 %q 中没有包含 %q", got, sub)
+	}
+	return a
+}
+
+// expectReplyTarget 等待Bot的下一条消息，并检查它是发给最近一次发言的会话的
+func (h *Harness) expectReplyTarget() cryo.MockAction {
+	h.t.Helper()
+	a := h.NextReply()
+	h.mutex.Lock()
+	group, user := h.lastGroup, h.lastUser
+	h.mutex.Unlock()
+	switch {
+	case group != 0 && (a.Action != "send_group_message" || a.GroupUin != group):
+		h.t.Fatalf("cryotest: 期望回复到群 %d，实际是 %s 群 %d 用户 %d：%s", group, a.Action, a.GroupUin, a.UserUin, a.Message.ToString())
+	case group == 0 && user != 0 && (a.Action != "send_private_message" || a.UserUin != user):
+		h.t.Fatalf("cryotest: 期望回复给用户 %d，实际是 %s 群 %d 用户 %d：%s", user, a.Action, a.GroupUin, a.UserUin, a.Message.ToString())
+	}
+	return a
+}
+
+// ExpectNoReply 等待事件处理完成，并检查Bot在之后的 DefaultQuietPeriod 内没有发出新的消息
+func (h *Harness) ExpectNoReply() {
+	h.t.Helper()
+	h.waitIdle()
+	a, ok := h.waitSent(DefaultQuietPeriod)
+	h.failOnPanic()
+	if ok {
+		h.t.Fatalf("cryotest: 期望Bot不回复，但是Bot发出了消息：%s", a.Message.ToString())
+	}
+}
+
+// ExpectAction 等待Bot执行指定名称的操作，例如 kick_group_member，返回第一个还没有检查过的同名操作
+func (h *Harness) ExpectAction(action string) cryo.MockAction {
+	h.t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for {
+		actions := h.mock.Actions()
+		h.mutex.Lock()
+		for i := h.actionCursor; i < len(actions); i++ {
+			if actions[i].Action == action {
+				h.actionCursor = i + 1
+				h.mutex.Unlock()
+				h.failOnPanic()
+				return actions[i]
+			}
+		}
+		h.mutex.Unlock()
+		if time.Now().After(deadline) {
+			h.failOnPanic()
+			h.t.Fatalf("cryotest: 等待 %s 后Bot仍然没有执行 %s 操作", h.timeout, action)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,193 @@
+package cryotest_test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/machinacanis/cryo"
+	"github.com/machinacanis/cryo/cryotest"
+)
+
+// testPlugin 是测试用的插件，init 会在插件初始化时调用
+type testPlugin struct {
+	init    func(bot *cryo.Bot)
+	enabled bool
+}
+
+func (p *testPlugin) Init(bot *cryo.Bot) error {
+	p.init(bot)
+	return nil
+}
+func (p *testPlugin) GetPluginName() string        { return "test" }
+func (p *testPlugin) GetPluginVersion() string     { return "0.1.0" }
+func (p *testPlugin) GetPluginDescription() string { return "测试插件" }
+func (p *testPlugin) GetPluginAuthor() string      { return "cryotest" }
+func (p *testPlugin) Enable()                      { p.enabled = true }
+func (p *testPlugin) Disable()                     { p.enabled = false }
+func (p *testPlugin) IsEnable() bool               { return p.enabled }
+
+// pingPlugin 收到 /ping 时回复 pong
+func pingPlugin() *testPlugin {
+	return &testPlugin{init: func(bot *cryo.Bot) {
+		bot.OnCommand("/ping").Handle(func(e *cryo.UniMessageEvent) {
+			_, _ = bot.SendTo(e, "pong")
+		}).Register()
+	}}
+}
+
+// fakeT 记录 Harness 报告的失败而不是让测试真正失败，用来检查 Harness 自己的失败报告
+type fakeT struct {
+	testing.TB
+	mutex    sync.Mutex
+	errors   []string
+	cleanups []func()
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+// Fatalf 和 testing.T 一样通过 runtime.Goexit 结束当前的 goroutine
+func (f *fakeT) Fatalf(format string, args ...any) {
+	f.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (f *fakeT) Fail() {
+	f.Errorf("Fail")
+}
+
+func (f *fakeT) Failed() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.errors) > 0
+}
+
+func (f *fakeT) Cleanup(fn func()) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.cleanups = append(f.cleanups, fn)
+}
+
+// report 获取所有的失败信息
+func (f *fakeT) report() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return strings.Join(f.errors, "\n")
+}
+
+// runFake 在新的 goroutine 中使用 fakeT 运行 body，结束后按照注册的相反顺序执行清理函数
+func runFake(t *testing.T, body func(t testing.TB)) *fakeT {
+	f := &fakeT{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		body(f)
+	}()
+	<-done
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+	return f
+}
+
+func TestPingPong(t *testing.T) {
+	h := cryotest.New(t, pingPlugin())
+
+	h.Group(100).User(1001).Says("/ping")
+	h.ExpectReply("pong")
+
+	h.User(1002).Says("/ping")
+	a := h.ExpectReply("pong")
+	if a.Action != "send_private_message" || a.UserUin != 1002 {
+		t.Errorf("私聊的回复发送到了 %s 用户 %d", a.Action, a.UserUin)
+	}
+
+	h.Group(100).User(1001).Says("/pingpong")
+	h.ExpectNoReply()
+}
+
+func TestScheduledTaskAdvance(t *testing.T) {
+	var h *cryotest.Harness
+	h = cryotest.New(t, &testPlugin{init: func(bot *cryo.Bot) {
+		bot.AddIntervalTask("整点报时", time.Hour, false, func() error {
+			msg, err := cryo.ParseMessage("整点报时")
+			if err != nil {
+				return err
+			}
+			_, err = h.Client().SendGroupMessage(100, &msg)
+			return err
+		})
+	}})
+	h.Group(100)
+
+	h.Advance(30 * time.Minute)
+	h.ExpectNoReply()
+
+	h.Advance(30 * time.Minute)
+	a := h.ExpectReply("整点报时")
+	if a.GroupUin != 100 {
+		t.Errorf("报时发送到了群 %d，期望 100", a.GroupUin)
+	}
+
+	h.Advance(time.Hour)
+	h.ExpectReply("整点报时")
+}
+
+func TestPanicFailsTest(t *testing.T) {
+	f := runFake(t, func(t testing.TB) {
+		h := cryotest.New(t, &testPlugin{init: func(bot *cryo.Bot) {
+			bot.OnCommand("/boom").Handle(func(e *cryo.UniMessageEvent) {
+				panic("boom")
+			}).Register()
+		}})
+		h.Group(100).User(1001).Says("/boom")
+		h.ExpectNoReply()
+	})
+	if !f.Failed() {
+		t.Fatal("事件处理函数中出现 panic 时测试没有失败")
+	}
+	if report := f.report(); strings.Count(report, "panic：boom") != 1 {
+		t.Errorf("panic 应该被报告一次，实际的失败信息：\n%s", report)
+	}
+}
+
+func TestExpectReplyTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	var reached bool
+	start := time.Now()
+	f := runFake(t, func(t testing.TB) {
+		h := cryotest.New(t).WithTimeout(timeout)
+		h.Group(100).User(1001).Says("/ping")
+		h.ExpectReply("pong")
+		reached = true
+	})
+	if reached {
+		t.Fatal("ExpectReply 超时后没有结束测试")
+	}
+	if elapsed := time.Since(start); elapsed < timeout {
+		t.Errorf("ExpectReply 只等待了 %v，期望至少等待 %v", elapsed, timeout)
+	}
+	if report := f.report(); !strings.Contains(report, "没有发出消息") {
+		t.Errorf("超时的失败信息不正确：\n%s", report)
+	}
+}
+
+func TestExpectReplyWrongText(t *testing.T) {
+	f := runFake(t, func(t testing.TB) {
+		h := cryotest.New(t, pingPlugin())
+		h.Group(100).User(1001).Says("/ping")
+		h.ExpectReply("ping")
+	})
+	if report := f.report(); !strings.Contains(report, `"pong"`) {
+		t.Errorf("回复内容不一致时的失败信息不正确：\n%s", report)
+	}
+}
@@ -0,0 +1,83 @@
+package cryotest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// testLogger 把Bot的日志输出到测试日志中，测试结束之后的日志会被丢弃
+type testLogger struct {
+	t     testing.TB
+	mutex sync.Mutex
+	done  bool
+}
+
+func (l *testLogger) log(level string, args ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.done {
+		l.t.Logf("[%s] %s", level, fmt.Sprint(args...))
+	}
+}
+
+// close 在测试结束时调用，之后的日志不会再输出
+func (l *testLogger) close() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.done = true
+}
+
+func (l *testLogger) Debug(args ...interface{})   { l.log("DEBUG", args...) }
+func (l *testLogger) Info(args ...interface{})    { l.log("INFO", args...) }
+func (l *testLogger) Success(args ...interface{}) { l.log("SUCCESS", args...) }
+func (l *testLogger) Warn(args ...interface{})    { l.log("WARN", args...) }
+func (l *testLogger) Error(args ...interface{})   { l.log("ERROR", args...) }
+func (l *testLogger) Print(args ...interface{})   { l.log("PRINT", args...) }
+
+// Fatal 不能在其他 goroutine 中结束测试，只会把测试标记为失败
+func (l *testLogger) Fatal(args ...interface{}) {
+	l.log("FATAL", args...)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.done {
+		l.t.Fail()
+	}
+}
+
+func (l *testLogger) Panic(args ...interface{}) {
+	l.log("PANIC", args...)
+	panic(fmt.Sprint(args...))
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) {
+	l.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Infof(format string, args ...interface{}) {
+	l.Info(fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Successf(format string, args ...interface{}) {
+	l.Success(fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Warnf(format string, args ...interface{}) {
+	l.Warn(fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Errorf(format string, args ...interface{}) {
+	l.Error(fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Fatalf(format string, args ...interface{}) {
+	l.Fatal(fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Panicf(format string, args ...interface{}) {
+	l.Panic(fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Printf(format string, args ...interface{}) {
+	l.Print(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,420 @@
+package cryo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/machinacanis/cryo/log"
+)
+
+//go:embed dashboard
+var dashboardAssets embed.FS
+
+// DashboardConfig 网页管理面板的配置
+type DashboardConfig struct {
+	Addr         string `json:"addr,omitempty,omitzero"`          // 监听地址，例如 127.0.0.1:8060
+	Password     string `json:"password,omitempty,omitzero"`      // 登录密码，为空时面板不会启动
+	SessionTTL   int    `json:"session_ttl,omitempty,omitzero"`   // 登录状态的有效期（小时），默认为24
+	RecentErrors int    `json:"recent_errors,omitempty,omitzero"` // 保留的最近错误数量，默认为100
+}
+
+// dashboardCookie 保存登录状态的 Cookie 名称
+const dashboardCookie = "cryo_dashboard"
+
+// dashboardError 是面板中显示的一条错误
+type dashboardError struct {
+	Time    int64  `json:"time"` // 毫秒时间戳
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// sseMessage 是推送给面板的一条服务器发送事件
+type sseMessage struct {
+	event string
+	data  []byte
+}
+
+// Dashboard 是内置的网页管理面板，以插件的形式运行
+//
+// 面板可以查看Bot客户端的状态、扫码登录新的账号、实时查看事件、管理插件和定时任务以及查看最近的错误，
+// 插件、定时任务等接口和 HTTPAPI 一致，只是改为使用密码登录
+type Dashboard struct {
+	conf      DashboardConfig
+	bot       *Bot
+	logger    log.CryoLogger
+	api       *HTTPAPI
+	mux       *http.ServeMux
+	startTime time.Time
+
+	mutex    sync.Mutex
+	enabled  bool
+	server   *http.Server
+	sessions map[string]time.Time         // 登录令牌 -> 过期时间
+	streams  map[chan sseMessage]struct{} // 正在连接的事件流
+	errors   []dashboardError             // 最近的错误，按时间顺序排列
+	logins   map[string]*dashboardLogin   // 扫码登录的会话
+}
+
+// NewDashboard 创建一个新的网页管理面板，需要通过 Bot.AddPlugin 添加到Bot中才会生效
+//
+// 在配置项中设置了 Dashboard.Addr 时，Bot 会在初始化时自动添加面板
+func NewDashboard(conf DashboardConfig) *Dashboard {
+	if conf.SessionTTL <= 0 {
+		conf.SessionTTL = 24
+	}
+	if conf.RecentErrors <= 0 {
+		conf.RecentErrors = 100
+	}
+	d := &Dashboard{
+		conf:     conf,
+		api:      NewHTTPAPI(HTTPAPIConfig{BasePath: "/api"}),
+		mux:      http.NewServeMux(),
+		sessions: make(map[string]time.Time),
+		streams:  make(map[chan sseMessage]struct{}),
+		logins:   make(map[string]*dashboardLogin),
+	}
+	assets, _ := fs.Sub(dashboardAssets, "dashboard")
+	d.mux.Handle("/", http.FileServerFS(assets))
+	d.mux.HandleFunc("POST /api/login", d.login)
+	d.mux.HandleFunc("POST /api/logout", d.logout)
+	d.mux.Handle("/api/", d.auth(d.api))
+	d.mux.Handle("GET /api/status", d.auth(http.HandlerFunc(d.status)))
+	d.mux.Handle("GET /api/errors", d.auth(http.HandlerFunc(d.recentErrors)))
+	d.mux.Handle("GET /api/events", d.auth(http.HandlerFunc(d.eventStream)))
+	d.mux.Handle("POST /api/qrcode", d.auth(http.HandlerFunc(d.startLogin)))
+	d.mux.Handle("GET /api/qrcode/{id}", d.auth(http.HandlerFunc(d.getLogin)))
+	d.mux.Handle("DELETE /api/qrcode/{id}", d.auth(http.HandlerFunc(d.cancelLogin)))
+	// 禁用面板会断开当前的连接，不允许在面板中操作
+	d.mux.Handle("POST /api/plugins/Dashboard/disable", d.auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHTTPAPIError(w, http.StatusConflict, "不能在面板中禁用面板自己")
+	})))
+	return d
+}
+
+// Init 初始化面板，记录Bot日志中的错误并注册推送事件的中间件
+//
+// 面板通过 Bot.AddLogSink 接收日志，会记录所有客户端和插件输出的警告和错误
+func (d *Dashboard) Init(bot *Bot) error {
+	d.bot = bot
+	d.startTime = time.Now()
+	d.logger = bot.GetLogger()
+	bot.AddLogSink(d.handleLog)
+	if err := d.api.Init(bot); err != nil {
+		return err
+	}
+	d.api.Enable()
+
+	mw := NewUniMiddleware()
+	mw.AddTag("dashboard")
+	mw.AddHandler(d.handleEvent)
+	bot.GetBus().AddPostMiddleware(mw)
+	return nil
+}
+
+// GetPluginName 获取插件名称信息
+func (d *Dashboard) GetPluginName() string {
+	return "Dashboard"
+}
+
+// GetPluginVersion 获取插件版本号信息
+func (d *Dashboard) GetPluginVersion() string {
+	return "0.1.0"
+}
+
+// GetPluginDescription 获取插件描述信息
+func (d *Dashboard) GetPluginDescription() string {
+	return "网页管理面板"
+}
+
+// GetPluginAuthor 获取插件作者信息
+func (d *Dashboard) GetPluginAuthor() string {
+	return "machinacanis"
+}
+
+// Enable 启用面板，开始监听
+//
+// 日志会经过面板的日志接收器，所以需要在释放锁之后再输出
+func (d *Dashboard) Enable() {
+	d.mutex.Lock()
+	if d.enabled {
+		d.mutex.Unlock()
+		return
+	}
+	if d.conf.Password == "" {
+		d.mutex.Unlock()
+		d.logger.Error("[Dashboard] 没有设置面板的登录密码，面板不会启动")
+		return
+	}
+	d.enabled = true
+	if d.conf.Addr == "" {
+		d.mutex.Unlock()
+		return
+	}
+	ln, err := net.Listen("tcp", d.conf.Addr)
+	if err != nil {
+		d.mutex.Unlock()
+		d.logger.Errorf("[Dashboard] 监听 %s 失败：%v", d.conf.Addr, err)
+		return
+	}
+	d.server = &http.Server{Handler: d}
+	go func(server *http.Server) {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.logger.Errorf("[Dashboard] HTTP 服务出现错误：%v", err)
+		}
+	}(d.server)
+	d.mutex.Unlock()
+	d.logger.Successf("[Dashboard] 管理面板已启动：http://%s", ln.Addr())
+}
+
+// Disable 禁用面板，断开所有事件流并停止监听
+func (d *Dashboard) Disable() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if !d.enabled {
+		return
+	}
+	d.enabled = false
+	for ch := range d.streams {
+		close(ch)
+		delete(d.streams, ch)
+	}
+	if d.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = d.server.Shutdown(ctx)
+		cancel()
+		d.server = nil
+	}
+}
+
+// IsEnable 是否已启用面板
+func (d *Dashboard) IsEnable() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.enabled
+}
+
+// ServeHTTP 处理面板的请求，可以把面板挂载到其他的 HTTP 服务上
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !d.IsEnable() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	d.mux.ServeHTTP(w, r)
+}
+
+// auth 检查请求是否已经登录
+func (d *Dashboard) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(dashboardCookie)
+		if err != nil || !d.checkSession(cookie.Value) {
+			writeHTTPAPIError(w, http.StatusUnauthorized, "需要登录")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkSession 检查登录令牌是否有效，顺便清理已经过期的令牌
+func (d *Dashboard) checkSession(token string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	now := time.Now()
+	for t, expire := range d.sessions {
+		if now.After(expire) {
+			delete(d.sessions, t)
+		}
+	}
+	_, ok := d.sessions[token]
+	return ok
+}
+
+func (d *Dashboard) login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if !decodeHTTPAPIBody(w, r, &req) {
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Password), []byte(d.conf.Password)) != 1 {
+		time.Sleep(time.Second) // 拖慢暴力破解
+		d.logger.Warnf("[Dashboard] %s 尝试登录面板失败", r.RemoteAddr)
+		writeHTTPAPIError(w, http.StatusUnauthorized, "密码错误")
+		return
+	}
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	token := hex.EncodeToString(buf)
+	ttl := time.Duration(d.conf.SessionTTL) * time.Hour
+	d.mutex.Lock()
+	d.sessions[token] = time.Now().Add(ttl)
+	d.mutex.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     dashboardCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	writeHTTPAPIJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (d *Dashboard) logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(dashboardCookie); err == nil {
+		d.mutex.Lock()
+		delete(d.sessions, cookie.Value)
+		d.mutex.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: dashboardCookie, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	writeHTTPAPIJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (d *Dashboard) status(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+	errorCount := len(d.errors)
+	streams := len(d.streams)
+	d.mutex.Unlock()
+	plugins := d.bot.GetAllPlugin()
+	enabled := 0
+	for _, p := range plugins {
+		if p.IsEnable() {
+			enabled++
+		}
+	}
+	writeHTTPAPIJSON(w, http.StatusOK, map[string]any{
+		"start_time":      d.startTime.UnixMilli(),
+		"uptime":          int64(time.Since(d.startTime).Seconds()),
+		"clients":         len(d.bot.getConnectedClients()),
+		"plugins":         len(plugins),
+		"enabled_plugins": enabled,
+		"tasks":           len(d.bot.GetTasks()),
+		"errors":          errorCount,
+		"streams":         streams,
+	})
+}
+
+func (d *Dashboard) recentErrors(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+	result := append([]dashboardError{}, d.errors...)
+	d.mutex.Unlock()
+	writeHTTPAPIJSON(w, http.StatusOK, result)
+}
+
+// recordError 记录一条错误并推送给面板
+func (d *Dashboard) recordError(level, message string) {
+	e := dashboardError{Time: time.Now().UnixMilli(), Level: level, Message: message}
+	d.mutex.Lock()
+	d.errors = append(d.errors, e)
+	if len(d.errors) > d.conf.RecentErrors {
+		d.errors = d.errors[len(d.errors)-d.conf.RecentErrors:]
+	}
+	d.mutex.Unlock()
+	d.publish("error", e)
+}
+
+// handleEvent 把事件推送给面板，定时任务失败时会记录为错误
+func (d *Dashboard) handleEvent(e Event) Event {
+	if te, ok := e.(*ScheduledTaskFailedEvent); ok && te.task != nil {
+		d.recordError("error", fmt.Sprintf("定时任务 %s 执行失败：%v", te.task.GetTaskName(), te.task.GetError()))
+	}
+	d.mutex.Lock()
+	listening := len(d.streams) > 0
+	d.mutex.Unlock()
+	if !listening {
+		return e
+	}
+	data, err := MarshalEvent(e)
+	if err != nil {
+		return e
+	}
+	d.broadcast(sseMessage{event: "event", data: data})
+	return e
+}
+
+// publish 把数据序列化后推送给面板
+func (d *Dashboard) publish(event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	d.broadcast(sseMessage{event: event, data: data})
+}
+
+// broadcast 推送给所有正在连接的事件流，处理不过来的事件流会丢弃消息
+func (d *Dashboard) broadcast(msg sseMessage) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for ch := range d.streams {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// eventStream 使用 SSE 推送事件、错误和扫码登录的状态
+func (d *Dashboard) eventStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeHTTPAPIError(w, http.StatusInternalServerError, "不支持 SSE")
+		return
+	}
+	ch := make(chan sseMessage, 256)
+	d.mutex.Lock()
+	d.streams[ch] = struct{}{}
+	d.mutex.Unlock()
+	defer func() {
+		d.mutex.Lock()
+		if _, ok := d.streams[ch]; ok {
+			delete(d.streams, ch)
+			close(ch)
+		}
+		d.mutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_, _ = fmt.Fprint(w, ": ping\n\n") // 防止代理断开空闲的连接
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.event, msg.data)
+		}
+		flusher.Flush()
+	}
+}
+
+// handleLog 把警告和错误记录到面板中
+func (d *Dashboard) handleLog(level log.CryoLogLevel, message string) {
+	switch {
+	case level == log.WarnLevel:
+		d.recordError("warn", message)
+	case level >= log.ErrorLevel:
+		d.recordError("error", message)
+	}
+}
@@ -0,0 +1,308 @@
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+const maxLogItems = 500;
+
+let stream = null;
+let paused = false;
+let unreadErrors = 0;
+let currentLogin = null;
+
+// api 调用面板的接口，未登录时回到登录页
+async function api(method, path, body) {
+  const resp = await fetch("api/" + path, {
+    method,
+    headers: body ? { "Content-Type": "application/json" } : {},
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  const data = await resp.json().catch(() => ({}));
+  if (resp.status === 401 && path !== "login") {
+    showLogin();
+    throw new Error("需要登录");
+  }
+  if (!resp.ok) {
+    throw new Error(data.error || resp.statusText);
+  }
+  return data;
+}
+
+// el 创建元素，children 可以是字符串或者元素
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  Object.assign(e, attrs || {});
+  for (const c of children) {
+    e.append(c instanceof Node ? c : String(c ?? ""));
+  }
+  return e;
+}
+
+function formatTime(ms) {
+  return new Date(ms).toLocaleString();
+}
+
+function formatUptime(seconds) {
+  const d = Math.floor(seconds / 86400);
+  const h = Math.floor(seconds % 86400 / 3600);
+  const m = Math.floor(seconds % 3600 / 60);
+  return (d ? d + " 天 " : "") + h + " 小时 " + m + " 分";
+}
+
+function prependLog(list, item) {
+  list.prepend(item);
+  while (list.children.length > maxLogItems) {
+    list.lastChild.remove();
+  }
+}
+
+function showLogin() {
+  if (stream) {
+    stream.close();
+    stream = null;
+  }
+  $("main-view").hidden = true;
+  $("login-view").hidden = false;
+  $("password").focus();
+}
+
+function showMain() {
+  $("login-view").hidden = true;
+  $("main-view").hidden = false;
+  connectStream();
+  refresh();
+  loadErrors();
+}
+
+function switchTab(name) {
+  for (const b of document.querySelectorAll("nav button")) {
+    b.classList.toggle("active", b.dataset.tab === name);
+  }
+  for (const t of document.querySelectorAll(".tab")) {
+    t.hidden = t.id !== "tab-" + name;
+  }
+  if (name === "errors") {
+    unreadErrors = 0;
+    $("error-badge").hidden = true;
+  }
+  refresh();
+}
+
+async function refresh() {
+  try {
+    await Promise.all([loadStatus(), loadClients(), loadPlugins(), loadTasks()]);
+  } catch (e) {
+    console.error(e);
+  }
+}
+
+async function loadStatus() {
+  const s = await api("GET", "status");
+  const items = [
+    ["运行时间", formatUptime(s.uptime)],
+    ["Bot 客户端", s.clients],
+    ["插件", s.enabled_plugins + " / " + s.plugins],
+    ["定时任务", s.tasks],
+    ["最近错误", s.errors],
+  ];
+  $("status").replaceChildren(...items.map(([k, v]) => el("div", {}, el("b", {}, v), el("span", {}, k))));
+}
+
+async function loadClients() {
+  const clients = await api("GET", "clients");
+  $("clients").replaceChildren(...clients.map((c) => el("tr", {},
+    el("td", {}, c.nickname),
+    el("td", {}, c.uin),
+    el("td", {}, c.platform),
+    el("td", { className: c.online ? "ok" : "error" }, c.online ? "在线" : "离线"),
+    el("td", {}, c.id),
+  )));
+  if (clients.length === 0) {
+    $("clients").append(el("tr", {}, el("td", { colSpan: 5 }, "还没有连接的 Bot 客户端，可以在「扫码登录」中登录新的账号")));
+  }
+}
+
+async function loadPlugins() {
+  const plugins = await api("GET", "plugins");
+  $("plugins").replaceChildren(...plugins.map((p) => {
+    const button = el("button", { className: p.enabled ? "secondary" : "" }, p.enabled ? "禁用" : "启用");
+    button.disabled = p.name === "Dashboard";
+    button.onclick = async () => {
+      button.disabled = true;
+      try {
+        await api("POST", "plugins/" + encodeURIComponent(p.name) + (p.enabled ? "/disable" : "/enable"));
+      } catch (e) {
+        alert(e.message);
+      }
+      loadPlugins();
+    };
+    return el("tr", {},
+      el("td", {}, p.name),
+      el("td", {}, p.version),
+      el("td", {}, p.author),
+      el("td", {}, p.description),
+      el("td", { className: p.enabled ? "ok" : "" }, p.enabled ? "已启用" : "已禁用"),
+      el("td", {}, button),
+    );
+  }));
+}
+
+async function loadTasks() {
+  const tasks = await api("GET", "tasks");
+  const types = { delay: "延迟", interval: "间隔", cron: "cron" };
+  $("tasks").replaceChildren(...tasks.map((t) => {
+    const button = el("button", { className: "secondary" }, "停止");
+    button.disabled = t.status === "stopped";
+    button.onclick = async () => {
+      button.disabled = true;
+      try {
+        await api("POST", "tasks/" + encodeURIComponent(t.id) + "/stop");
+      } catch (e) {
+        alert(e.message);
+      }
+      loadTasks();
+    };
+    return el("tr", {},
+      el("td", {}, t.name),
+      el("td", {}, types[t.type] || t.type),
+      el("td", {}, t.cron || (t.duration / 1000 + " 秒")),
+      el("td", {}, t.status),
+      el("td", { className: "error" }, t.error || ""),
+      el("td", {}, button),
+    );
+  }));
+}
+
+function errorItem(e) {
+  return el("li", { className: e.level }, el("time", {}, formatTime(e.time)), e.message);
+}
+
+async function loadErrors() {
+  const errors = await api("GET", "errors");
+  $("errors").replaceChildren(...errors.reverse().map(errorItem));
+}
+
+// connectStream 连接 SSE 事件流，断开后浏览器会自动重连
+function connectStream() {
+  if (stream) {
+    return;
+  }
+  stream = new EventSource("api/events");
+  stream.addEventListener("event", (msg) => {
+    if (paused) {
+      return;
+    }
+    const e = JSON.parse(msg.data);
+    const filter = $("event-filter").value.trim();
+    if (filter && !e.type.includes(filter)) {
+      return;
+    }
+    const summary = Object.assign({}, e);
+    delete summary.type;
+    prependLog($("events"), el("li", {},
+      el("time", {}, formatTime((e.time || Date.now() / 1000) * 1000)),
+      el("span", { className: "type" }, e.type),
+      JSON.stringify(summary),
+    ));
+    if (e.type === "BotConnectedEvent" || e.type === "BotDisconnectedEvent") {
+      refresh();
+    }
+  });
+  stream.addEventListener("error", (msg) => {
+    if (!msg.data) {
+      return; // 连接错误，不是服务端推送的错误
+    }
+    prependLog($("errors"), errorItem(JSON.parse(msg.data)));
+    if ($("tab-errors").hidden) {
+      unreadErrors++;
+      $("error-badge").textContent = unreadErrors;
+      $("error-badge").hidden = false;
+    }
+  });
+  stream.addEventListener("login", (msg) => {
+    const l = JSON.parse(msg.data);
+    if (currentLogin && l.id === currentLogin) {
+      showLoginState(l);
+    }
+  });
+}
+
+const loginStates = {
+  waiting_scan: "请使用手机 QQ 扫描二维码",
+  waiting_confirm: "已扫码，请在手机上确认登录",
+  success: "登录成功",
+  expired: "二维码已过期，请重新获取",
+  canceled: "登录已取消",
+  failed: "登录失败",
+};
+
+function showLoginState(l) {
+  const finished = l.state !== "waiting_scan" && l.state !== "waiting_confirm";
+  $("qrcode-state").textContent = (loginStates[l.state] || l.state) + (l.uin ? "：" + l.uin : "") + (l.error ? "：" + l.error : "");
+  $("qrcode-state").className = l.state === "success" ? "ok" : (finished ? "error" : "");
+  if (l.image) {
+    $("qrcode-image").src = l.image;
+  }
+  $("qrcode-image").hidden = finished;
+  $("qrcode-cancel").hidden = finished;
+  $("qrcode-start").disabled = !finished;
+  if (finished) {
+    currentLogin = null;
+    refresh();
+  }
+}
+
+$("qrcode-start").onclick = async () => {
+  $("qrcode-start").disabled = true;
+  $("qrcode-state").textContent = "正在获取二维码...";
+  try {
+    const l = await api("POST", "qrcode");
+    currentLogin = l.id;
+    showLoginState(l);
+  } catch (e) {
+    $("qrcode-state").textContent = e.message;
+    $("qrcode-state").className = "error";
+    $("qrcode-start").disabled = false;
+  }
+};
+
+$("qrcode-cancel").onclick = () => {
+  if (currentLogin) {
+    api("DELETE", "qrcode/" + currentLogin).catch((e) => alert(e.message));
+  }
+};
+
+$("login-form").onsubmit = async (ev) => {
+  ev.preventDefault();
+  $("login-error").textContent = "";
+  try {
+    await api("POST", "login", { password: $("password").value });
+    $("password").value = "";
+    showMain();
+  } catch (e) {
+    $("login-error").textContent = e.message;
+  }
+};
+
+$("logout").onclick = async () => {
+  await api("POST", "logout").catch(() => {});
+  showLogin();
+};
+
+$("event-pause").onclick = () => {
+  paused = !paused;
+  $("event-pause").textContent = paused ? "继续" : "暂停";
+};
+
+$("event-clear").onclick = () => $("events").replaceChildren();
+
+for (const b of document.querySelectorAll("nav button")) {
+  b.onclick = () => switchTab(b.dataset.tab);
+}
+
+setInterval(() => {
+  if (!$("main-view").hidden) {
+    loadStatus().catch(() => {});
+  }
+}, 10000);
+
+// 已经登录时直接进入面板
+api("GET", "status").then(showMain, () => showLogin());
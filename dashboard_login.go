@@ -0,0 +1,184 @@
+package cryo
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/client/packets/wtlogin/qrcodestate"
+)
+
+// 扫码登录的状态
+const (
+	dashboardLoginWaitingScan    = "waiting_scan"    // 等待扫码
+	dashboardLoginWaitingConfirm = "waiting_confirm" // 已扫码，等待在手机上确认
+	dashboardLoginSuccess        = "success"         // 登录成功
+	dashboardLoginExpired        = "expired"         // 二维码已过期
+	dashboardLoginCanceled       = "canceled"        // 在手机上或者面板中取消了登录
+	dashboardLoginFailed         = "failed"          // 登录失败
+)
+
+// dashboardMaxPendingLogins 同时进行中的扫码登录数量上限
+const dashboardMaxPendingLogins = 3
+
+// dashboardLogin 是面板中发起的一次扫码登录
+type dashboardLogin struct {
+	Id    string `json:"id"`
+	State string `json:"state"`
+	Image string `json:"image,omitzero"` // 二维码图片的 data URL
+	URL   string `json:"url,omitzero"`   // 二维码指向的链接
+	Uin   uint32 `json:"uin,omitzero"`   // 登录成功后的Bot账号
+	Error string `json:"error,omitzero"`
+
+	cancel context.CancelFunc
+}
+
+// finished 登录是否已经结束
+func (l *dashboardLogin) finished() bool {
+	return l.State != dashboardLoginWaitingScan && l.State != dashboardLoginWaitingConfirm
+}
+
+func (d *Dashboard) startLogin(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+	pending := 0
+	for _, l := range d.logins {
+		if !l.finished() {
+			pending++
+		}
+	}
+	d.mutex.Unlock()
+	if pending >= dashboardMaxPendingLogins {
+		writeHTTPAPIError(w, http.StatusTooManyRequests, "进行中的扫码登录太多了，请先完成或者取消之前的登录")
+		return
+	}
+
+	c := NewLagrangeClient()
+	c.Init(d.bot.bus, d.bot.Logger, d.bot.conf)
+	code, url, err := c.GetQRCode()
+	if err != nil {
+		c.Client.Release()
+		writeHTTPAPIError(w, http.StatusBadGateway, "获取二维码失败："+err.Error())
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &dashboardLogin{
+		Id:     c.Id,
+		State:  dashboardLoginWaitingScan,
+		Image:  "data:image/png;base64," + base64.StdEncoding.EncodeToString(code),
+		URL:    url,
+		cancel: cancel,
+	}
+	d.mutex.Lock()
+	d.logins[l.Id] = l
+	snapshot := *l
+	d.mutex.Unlock()
+	d.logger.Infof("[Dashboard] %s 在面板中发起了扫码登录", r.RemoteAddr)
+	go d.watchLogin(ctx, c, l)
+	writeHTTPAPIJSON(w, http.StatusOK, snapshot)
+}
+
+func (d *Dashboard) getLogin(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+	l, ok := d.logins[r.PathValue("id")]
+	var snapshot dashboardLogin
+	if ok {
+		snapshot = *l
+	}
+	d.mutex.Unlock()
+	if !ok {
+		writeHTTPAPIError(w, http.StatusNotFound, "扫码登录不存在或者已经过期")
+		return
+	}
+	writeHTTPAPIJSON(w, http.StatusOK, snapshot)
+}
+
+func (d *Dashboard) cancelLogin(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+	l, ok := d.logins[r.PathValue("id")]
+	d.mutex.Unlock()
+	if !ok {
+		writeHTTPAPIError(w, http.StatusNotFound, "扫码登录不存在或者已经过期")
+		return
+	}
+	l.cancel()
+	writeHTTPAPIJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// setLoginState 更新扫码登录的状态并推送给面板
+func (d *Dashboard) setLoginState(l *dashboardLogin, state, errMsg string) {
+	d.mutex.Lock()
+	if l.State == state && l.Error == errMsg {
+		d.mutex.Unlock()
+		return
+	}
+	l.State, l.Error = state, errMsg
+	if l.finished() {
+		l.Image = "" // 二维码已经没用了，不再返回
+	}
+	snapshot := *l
+	d.mutex.Unlock()
+	d.publish("login", snapshot)
+}
+
+// watchLogin 轮询扫码登录的结果，结束后保留一段时间的记录以便面板查询
+func (d *Dashboard) watchLogin(ctx context.Context, c *LagrangeClient, l *dashboardLogin) {
+	defer l.cancel()
+	defer func() {
+		time.AfterFunc(5*time.Minute, func() {
+			d.mutex.Lock()
+			delete(d.logins, l.Id)
+			d.mutex.Unlock()
+		})
+	}()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			c.Client.Release()
+			d.setLoginState(l, dashboardLoginCanceled, "")
+			return
+		case <-ticker.C:
+		}
+		state, err := c.Client.GetQRCodeResult()
+		if err != nil {
+			c.Client.Release()
+			d.setLoginState(l, dashboardLoginFailed, err.Error())
+			return
+		}
+		switch state {
+		case qrcodestate.WaitingForScan:
+			continue
+		case qrcodestate.WaitingForConfirm:
+			d.setLoginState(l, dashboardLoginWaitingConfirm, "")
+			continue
+		case qrcodestate.Expired:
+			c.Client.Release()
+			d.setLoginState(l, dashboardLoginExpired, "")
+			return
+		case qrcodestate.Canceled:
+			c.Client.Release()
+			d.setLoginState(l, dashboardLoginCanceled, "")
+			return
+		case qrcodestate.Confirmed:
+		default:
+			c.Client.Release()
+			d.setLoginState(l, dashboardLoginFailed, "未知的二维码状态 "+state.Name())
+			return
+		}
+		if _, err := c.Client.QRCodeLogin(); err != nil {
+			c.Client.Release()
+			d.setLoginState(l, dashboardLoginFailed, err.Error())
+			return
+		}
+		c.AfterLogin()
+		d.bot.addConnectedClient(c)
+		d.mutex.Lock()
+		l.Uin = c.Uin
+		d.mutex.Unlock()
+		d.setLoginState(l, dashboardLoginSuccess, "")
+		d.logger.Successf("[Dashboard] %d 已通过面板扫码登录", c.Uin)
+		return
+	}
+}
@@ -0,0 +1,108 @@
+package cryo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// dedupCache 记录最近处理过的事件，用于丢弃重复投递的消息
+type dedupCache struct {
+	mutex     sync.Mutex
+	ttl       time.Duration
+	items     map[string]time.Time // 键 -> 过期时间
+	lastSweep time.Time
+}
+
+// newDedupCache 创建一个新的去重缓存
+func newDedupCache(ttl time.Duration) *dedupCache {
+	return &dedupCache{
+		ttl:   ttl,
+		items: make(map[string]time.Time),
+	}
+}
+
+// seen 判断键是否在有效期内出现过，没有出现过时会记录下来
+func (dc *dedupCache) seen(key string, now time.Time) bool {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	if now.Sub(dc.lastSweep) > dc.ttl { // 定期清理过期的记录
+		for k, expire := range dc.items {
+			if now.After(expire) {
+				delete(dc.items, k)
+			}
+		}
+		dc.lastSweep = now
+	}
+	if expire, ok := dc.items[key]; ok && now.Before(expire) {
+		return true
+	}
+	dc.items[key] = now.Add(dc.ttl)
+	return false
+}
+
+// dedupKey 计算消息事件的去重键，不是消息事件时返回空字符串
+//
+// 群消息的键不包含Bot的Uin，所以多个Bot账号收到的同一条群消息也会被视为重复，
+// 启用了群主控客户端选举时，去重在选举之后进行，只有主控客户端收到的消息会被记录
+func dedupKey(e Event) string {
+	switch v := e.(type) {
+	case *GroupMessageEvent:
+		return fmt.Sprintf("g:%d:%d:%d:%d:%d", v.GroupUin, v.SenderUin, v.MessageId, v.InternalId, v.Time)
+	case *PrivateMessageEvent:
+		return fmt.Sprintf("p:%d:%d:%d:%d:%d", v.ClientUin, v.SenderUin, v.MessageId, v.InternalId, v.Time)
+	case *TempMessageEvent:
+		return fmt.Sprintf("t:%d:%d:%d:%d:%d", v.ClientUin, v.GroupUin, v.SenderUin, v.MessageId, v.Time)
+	}
+	return ""
+}
+
+// eventGroupUin 获取群相关事件所在的群号
+func eventGroupUin(e Event) (uint32, bool) {
+	switch v := e.(type) {
+	case *GroupMessageEvent:
+		return v.GroupUin, true
+	case *GroupMemberPermissionUpdatedEvent:
+		return v.GroupUin, true
+	case *GroupNameUpdatedEvent:
+		return v.GroupUin, true
+	case *GroupMuteEvent:
+		return v.GroupUin, true
+	case *GroupRecallEvent:
+		return v.GroupUin, true
+	case *GroupMemberJoinRequestEvent:
+		return v.GroupUin, true
+	case *GroupMemberIncreaseEvent:
+		return v.GroupUin, true
+	case *GroupMemberDecreaseEvent:
+		return v.GroupUin, true
+	case *GroupDigestEvent:
+		return v.GroupUin, true
+	case *GroupReactionEvent:
+		return v.GroupUin, true
+	case *GroupPokeEvent:
+		return v.GroupUin, true
+	case *GroupMemberSpecialTitleUpdated:
+		return v.GroupUin, true
+	}
+	return 0, false
+}
+
+// inGroup 判断客户端是否在线并且在指定的群中
+func (c *LagrangeClient) inGroup(groupUin uint32) bool {
+	if !c.IsOnline() {
+		return false
+	}
+	return c.cache.getGroup(groupUin) != nil || c.protocol.GetCachedGroupInfo(groupUin) != nil
+}
+
+// groupLeader 在同时加入了这个群的在线客户端中选出Uin最小的一个作为主控客户端，没有可选的客户端时返回0
+func groupLeader(clients []*LagrangeClient, groupUin uint32) uint32 {
+	var leader uint32
+	for _, c := range clients {
+		if c.Uin != 0 && (leader == 0 || c.Uin < leader) && c.inGroup(groupUin) {
+			leader = c.Uin
+		}
+	}
+	return leader
+}
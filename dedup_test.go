@@ -0,0 +1,93 @@
+package cryo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/machinacanis/cryo/log"
+)
+
+func TestDedupCacheTTL(t *testing.T) {
+	dc := newDedupCache(time.Minute)
+	now := time.Unix(1700000000, 0)
+
+	steps := []struct {
+		after time.Duration
+		seen  bool
+	}{
+		{0, false},
+		{30 * time.Second, true},
+		{time.Minute + time.Second, false},
+		{time.Minute + 30*time.Second, true}, // 过期后重新记录，有效期从这次开始计算
+	}
+	for _, s := range steps {
+		if got := dc.seen("key", now.Add(s.after)); got != s.seen {
+			t.Errorf("%v 后 seen 返回 %v，期望 %v", s.after, got, s.seen)
+		}
+	}
+}
+
+func TestDedupCacheSweep(t *testing.T) {
+	dc := newDedupCache(time.Minute)
+	now := time.Unix(1700000000, 0)
+
+	dc.seen("a", now)
+	dc.seen("b", now.Add(30*time.Second))
+	if n := len(dc.items); n != 2 {
+		t.Fatalf("记录了 %d 个键，期望 2 个", n)
+	}
+
+	// 距离上次清理超过ttl时会清理掉过期的记录，还没有过期的记录会保留
+	dc.seen("c", now.Add(80*time.Second))
+	if _, ok := dc.items["a"]; ok {
+		t.Error("过期的记录没有被清理")
+	}
+	if _, ok := dc.items["b"]; !ok {
+		t.Error("还没有过期的记录被清理了")
+	}
+
+	// 距离上次清理不到ttl时不会清理
+	dc.seen("d", now.Add(100*time.Second))
+	if _, ok := dc.items["b"]; !ok {
+		t.Error("距离上次清理不到ttl时清理了记录")
+	}
+	if n := len(dc.items); n != 3 {
+		t.Errorf("记录了 %d 个键，期望 3 个", n)
+	}
+}
+
+func TestLeaderElectionBeforeDedup(t *testing.T) {
+	logger := log.NewLoggerBuilder()
+	bus := NewEventBus()
+	var clients []*LagrangeClient
+	for _, uin := range []uint32{20000, 10000} {
+		p := NewMockProtocol(uin)
+		p.AddGroup(100, "")
+		c := NewLagrangeClient()
+		c.InitWithProtocol(bus, logger, Config{}, p)
+		c.AfterLogin()
+		clients = append(clients, c)
+	}
+	setDefaultMiddleware(bus, logger, Config{EnableDedupMiddleware: true, EnableGroupLeaderElection: true},
+		func() []*LagrangeClient { return clients })
+
+	receive := func(clientUin uint32) Event {
+		return bus.applyPreMiddleware(&GroupMessageEvent{UniMessageEvent: UniMessageEvent{
+			UniEvent:  UniEvent{EventType: GroupMessageEventType, ClientUin: clientUin, Time: 1700000000},
+			GroupUin:  100,
+			SenderUin: 1001,
+			MessageId: 1,
+		}})
+	}
+
+	// 非主控客户端先收到消息，它不能被去重记录下来，否则主控客户端收到的同一条消息会被丢弃
+	if receive(20000) != nil {
+		t.Fatal("非主控客户端收到的群消息没有被拦截")
+	}
+	if receive(10000) == nil {
+		t.Fatal("主控客户端收到的群消息被丢弃了")
+	}
+	if receive(10000) != nil {
+		t.Error("重复的群消息没有被去重")
+	}
+}
@@ -35,6 +35,7 @@ export default defineConfig({
         items: [
           { text: '配置项', link: '/guides/config' },
           { text: '日志', link: '/guides/logger' },
+          { text: '测试插件', link: '/guides/testing' },
         ]
       }
     ],
@@ -56,21 +56,25 @@ type Reply struct {
 // Voice 语音元素
 type Voice struct {
 	lgrmessage.VoiceElement
+	owner mediaOwner // 收到的元素所属的客户端和群，用于获取下载链接
 }
 
 // Image 图片元素
 type Image struct {
 	lgrmessage.ImageElement
+	owner mediaOwner // 收到的元素所属的客户端和群，用于获取下载链接
 }
 
 // File 文件元素
 type File struct {
 	lgrmessage.FileElement
+	owner mediaOwner // 收到的元素所属的客户端和群，用于获取下载链接
 }
 
 // ShortVideo 短视频元素
 type ShortVideo struct {
 	lgrmessage.ShortVideoElement
+	owner mediaOwner // 收到的元素所属的客户端和群，用于获取下载链接
 }
 
 // LightApp 轻应用元素
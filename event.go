@@ -82,23 +82,27 @@ type MessageEvent interface {
 	GetMessage() *Message                                                                                    // 获取消息元素
 	GetIMessageElements() []lgrmessage.IMessageElement                                                       // 获取消息元素的LagrangeGo格式
 	GetMessageId() uint32                                                                                    // 获取消息ID
-	Send(args ...interface{}) (ok bool, messageId uint32)                                                    // 发送消息
-	Reply(args ...interface{}) (ok bool, messageId uint32)                                                   // 回复消息
+	Send(args ...interface{}) (ok bool, messageId uint32)                                                    // 发送消息，已弃用，请使用 SendTo
+	Reply(args ...interface{}) (ok bool, messageId uint32)                                                   // 回复消息，已弃用，请使用 ReplyTo
+	SendTo(args ...interface{}) (*SentMessage, error)                                                        // 发送消息，返回已发送的消息
+	ReplyTo(args ...interface{}) (*SentMessage, error)                                                       // 回复消息，返回已发送的消息
+	Recall() error                                                                                           // 撤回消息
+	React(code string) error                                                                                 // 给消息添加表态
 }
 
 // UniMessageEvent 是消息事件的基础模型，其他消息事件都由这个事件组合而成
 type UniMessageEvent struct {
 	UniEvent              // 事件的基础信息
-	MessageId      uint32 // 消息ID
-	SenderUin      uint32 // 消息发送者的Uin
-	SenderUid      string // 消息发送者的Uid
-	SenderNickname string // 消息发送者的昵称
-	SenderCardname string // 消息发送者的备注名
-	IsSenderFriend bool   // 消息发送者是否是好友
-	GroupUin       uint32 // 群号，如果是私聊消息则为对应的好友Uin
-	GroupName      string // 群名称，如果是私聊消息则为好友名称
+	MessageId      uint32 `json:"message_id,omitzero,omitempty"`       // 消息ID
+	SenderUin      uint32 `json:"sender_uin,omitzero,omitempty"`       // 消息发送者的Uin
+	SenderUid      string `json:"sender_uid,omitzero,omitempty"`       // 消息发送者的Uid
+	SenderNickname string `json:"sender_nickname,omitzero,omitempty"`  // 消息发送者的昵称
+	SenderCardname string `json:"sender_cardname,omitzero,omitempty"`  // 消息发送者的备注名
+	IsSenderFriend bool   `json:"is_sender_friend,omitzero,omitempty"` // 消息发送者是否是好友
+	GroupUin       uint32 `json:"group_uin,omitzero,omitempty"`        // 群号，如果是私聊消息则为对应的好友Uin
+	GroupName      string `json:"group_name,omitzero,omitempty"`       // 群名称，如果是私聊消息则为好友名称
 
-	MessageElements Message // 消息元素
+	MessageElements Message `json:"message,omitzero,omitempty"` // 消息元素
 }
 
 // GetReplyDetail 获取回复用的部分消息内容
@@ -156,29 +160,63 @@ func (e *UniMessageEvent) Clone() Event {
 }
 
 // Send 通过Bot客户端发送消息的一个快捷方式
+//
+// Deprecated: 请使用 SendTo，它会返回已发送消息的句柄 SentMessage 和发送失败的原因
 func (e *UniMessageEvent) Send(args ...interface{}) (ok bool, messageId uint32) {
 	// 发送消息
 	return e.botClient.Send(e, args...)
 }
 
 // Reply 通过Bot客户端回复消息的一个快捷方式
+//
+// Deprecated: 请使用 ReplyTo，它会返回已发送消息的句柄 SentMessage 和发送失败的原因
 func (e *UniMessageEvent) Reply(args ...interface{}) (ok bool, messageId uint32) {
 	// 回复消息
 	return e.botClient.Send(e, args...)
 }
 
+// SendTo 通过Bot客户端发送消息，返回已发送的消息或者发送失败的原因
+func (e *UniMessageEvent) SendTo(args ...interface{}) (*SentMessage, error) {
+	return e.botClient.SendTo(e, args...)
+}
+
+// ReplyTo 通过Bot客户端回复这条消息，返回已发送的消息或者发送失败的原因
+func (e *UniMessageEvent) ReplyTo(args ...interface{}) (*SentMessage, error) {
+	return e.botClient.ReplyTo(e, args...)
+}
+
+// Recall 撤回这条消息，撤回群成员的消息需要Bot是群管理员
+//
+// 私聊消息的撤回需要用到 PrivateMessageEvent 中的额外信息，所以需要在 *PrivateMessageEvent 上调用
+func (e *UniMessageEvent) Recall() error {
+	switch e.EventType {
+	case GroupMessageEventType:
+		return e.botClient.RecallGroupMessage(e.GroupUin, e.MessageId)
+	default:
+		return newActionError("recall_message", e.GroupUin, e.SenderUin, ErrUnsupportedEvent, nil)
+	}
+}
+
+// React 给这条消息添加表态，只支持群消息
+func (e *UniMessageEvent) React(code string) error {
+	if e.EventType != GroupMessageEventType {
+		return newActionError("set_group_reaction", e.GroupUin, e.SenderUin, ErrUnsupportedEvent, nil)
+	}
+	return e.botClient.SetGroupReaction(e.GroupUin, e.MessageId, code, true)
+}
+
 type (
 	// PrivateMessageEvent 私聊消息事件
 	PrivateMessageEvent struct {
 		UniMessageEvent
-		InternalId uint32 // 内部ID
-		ClientSeq  uint32 // 客户端序列号
-		TargetUin  uint32 // 目标Uin
+		InternalId uint32 `json:"internal_id,omitzero,omitempty"` // 内部ID
+		ClientSeq  uint32 `json:"client_seq,omitzero,omitempty"`  // 客户端序列号
+		TargetUin  uint32 `json:"target_uin,omitzero,omitempty"`  // 目标Uin
 	}
 	// GroupMessageEvent 群消息事件
 	GroupMessageEvent struct {
 		UniMessageEvent
-		InternalId uint32 // 内部ID
+		InternalId uint32 `json:"internal_id,omitzero,omitempty"` // 内部ID
 	}
 	// TempMessageEvent 临时消息事件
 	TempMessageEvent struct {
@@ -187,158 +225,191 @@ type (
 	// NewFriendRequestEvent 新好友请求事件
 	NewFriendRequestEvent struct {
 		UniEvent
-		Uin      uint32
-		Uid      string
-		Nickname string
-		Message  string
-		From     string
+		Uin      uint32 `json:"uin,omitzero,omitempty"`
+		Uid      string `json:"uid,omitzero,omitempty"`
+		Nickname string `json:"nickname,omitzero,omitempty"`
+		Message  string `json:"message,omitzero,omitempty"`
+		From     string `json:"from,omitzero,omitempty"`
 	}
 	// NewFriendEvent 新好友事件
 	NewFriendEvent struct {
 		UniEvent
-		Uin      uint32
-		Uid      string
-		Nickname string
-		Message  string
+		Uin      uint32 `json:"uin,omitzero,omitempty"`
+		Uid      string `json:"uid,omitzero,omitempty"`
+		Nickname string `json:"nickname,omitzero,omitempty"`
+		Message  string `json:"message,omitzero,omitempty"`
 	}
 	// FriendRecallEvent 好友撤回事件
 	FriendRecallEvent struct {
 		UniEvent
-		Uin     uint32
-		Uid     string
-		Seqence uint64
-		Random  uint32
+		Uin     uint32 `json:"uin,omitzero,omitempty"`
+		Uid     string `json:"uid,omitzero,omitempty"`
+		Seqence uint64 `json:"sequence,omitzero,omitempty"`
+		Random  uint32 `json:"random,omitzero,omitempty"`
 	}
 	// FriendRenameEvent 好友改名事件
 	FriendRenameEvent struct {
 		UniEvent
-		IsSelf   bool
-		Uin      uint32
-		Uid      string
-		Nickname string
+		IsSelf   bool   `json:"is_self,omitzero,omitempty"`
+		Uin      uint32 `json:"uin,omitzero,omitempty"`
+		Uid      string `json:"uid,omitzero,omitempty"`
+		Nickname string `json:"nickname,omitzero,omitempty"`
 	}
 	// FriendPokeEvent 好友戳一戳事件
 	FriendPokeEvent struct {
 		UniEvent
-		SenderUin uint32
-		TargetUin uint32
-		Suffix    string
-		Action    string
+		SenderUin uint32 `json:"sender_uin,omitzero,omitempty"`
+		TargetUin uint32 `json:"target_uin,omitzero,omitempty"`
+		Suffix    string `json:"suffix,omitzero,omitempty"`
+		Action    string `json:"action,omitzero,omitempty"`
+	}
+	// GroupPokeEvent 群戳一戳事件
+	GroupPokeEvent struct {
+		UniEvent
+		GroupUin       uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName      string `json:"group_name,omitzero,omitempty"`      // 群名称
+		SenderUin      uint32 `json:"sender_uin,omitzero,omitempty"`      // 发起戳一戳的成员
+		SenderNickname string `json:"sender_nickname,omitzero,omitempty"` // 发起者的显示名称
+		TargetUin      uint32 `json:"target_uin,omitzero,omitempty"`      // 被戳的成员
+		TargetNickname string `json:"target_nickname,omitzero,omitempty"` // 被戳成员的显示名称
+		Suffix         string `json:"suffix,omitzero,omitempty"`
+		Action         string `json:"action,omitzero,omitempty"`
 	}
 	// GroupMemberPermissionUpdatedEvent 群成员权限变更事件
 	GroupMemberPermissionUpdatedEvent struct {
 		UniEvent
-		GroupUin uint32
-		Uin      uint32
-		Uid      string
-		IsAdmin  bool
+		GroupUin  uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName string `json:"group_name,omitzero,omitempty"` // 群名称
+		Nickname  string `json:"nickname,omitzero,omitempty"`   // 成员的显示名称
+		Uin       uint32 `json:"uin,omitzero,omitempty"`
+		Uid       string `json:"uid,omitzero,omitempty"`
+		IsAdmin   bool   `json:"is_admin,omitzero,omitempty"`
 	}
 	// GroupNameUpdatedEvent 群名称变更事件
 	GroupNameUpdatedEvent struct {
 		UniEvent
-		GroupUin uint32
-		NewName  string
+		GroupUin uint32 `json:"group_uin,omitzero,omitempty"`
+		OldName  string `json:"old_name,omitzero,omitempty"` // 变更前的群名称
+		NewName  string `json:"new_name,omitzero,omitempty"`
 	}
 	// GroupMuteEvent 群禁言事件
 	GroupMuteEvent struct {
 		UniEvent
-		GroupUin    uint32
-		OperatorUin uint32
-		OperatorUid string
-		TargetUin   uint32
-		TargetUid   string
-		Duration    uint32
-		isMuteAll   bool
+		GroupUin         uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName        string `json:"group_name,omitzero,omitempty"`        // 群名称
+		OperatorNickname string `json:"operator_nickname,omitzero,omitempty"` // 操作者的显示名称
+		TargetNickname   string `json:"target_nickname,omitzero,omitempty"`   // 被禁言成员的显示名称
+		OperatorUin      uint32 `json:"operator_uin,omitzero,omitempty"`
+		OperatorUid      string `json:"operator_uid,omitzero,omitempty"`
+		TargetUin        uint32 `json:"target_uin,omitzero,omitempty"`
+		TargetUid        string `json:"target_uid,omitzero,omitempty"`
+		Duration         uint32 `json:"duration,omitzero,omitempty"`
+		isMuteAll        bool
 	}
 	// GroupRecallEvent 群撤回事件
 	GroupRecallEvent struct {
 		UniEvent
-		GroupUin    uint32
-		OperatorUin uint32
-		OperatorUid string
-		SenderUin   uint32
-		SenderUid   string
-		Seqence     uint64
-		Random      uint32
+		GroupUin         uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName        string `json:"group_name,omitzero,omitempty"`        // 群名称
+		OperatorNickname string `json:"operator_nickname,omitzero,omitempty"` // 操作者的显示名称
+		SenderNickname   string `json:"sender_nickname,omitzero,omitempty"`   // 消息发送者的显示名称
+		OperatorUin      uint32 `json:"operator_uin,omitzero,omitempty"`
+		OperatorUid      string `json:"operator_uid,omitzero,omitempty"`
+		SenderUin        uint32 `json:"sender_uin,omitzero,omitempty"`
+		SenderUid        string `json:"sender_uid,omitzero,omitempty"`
+		Seqence          uint64 `json:"sequence,omitzero,omitempty"`
+		Random           uint32 `json:"random,omitzero,omitempty"`
 	}
 	// GroupMemberJoinRequestEvent 群成员入群请求事件
 	GroupMemberJoinRequestEvent struct {
 		UniEvent
-		GroupUin       uint32
-		SenderUin      uint32
-		SenderUid      string
-		SenderNickname string
-		InviterUin     uint32
-		InviterUid     string
-		Answer         string
-		RequestSeqence uint64
+		GroupUin        uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName       string `json:"group_name,omitzero,omitempty"`       // 群名称
+		InviterNickname string `json:"inviter_nickname,omitzero,omitempty"` // 邀请者的显示名称
+		SenderUin       uint32 `json:"sender_uin,omitzero,omitempty"`
+		SenderUid       string `json:"sender_uid,omitzero,omitempty"`
+		SenderNickname  string `json:"sender_nickname,omitzero,omitempty"`
+		InviterUin      uint32 `json:"inviter_uin,omitzero,omitempty"`
+		InviterUid      string `json:"inviter_uid,omitzero,omitempty"`
+		Answer          string `json:"answer,omitzero,omitempty"`
+		RequestSeqence  uint64 `json:"request_sequence,omitzero,omitempty"`
 	}
 	// GroupMemberIncreaseEvent 群成员增加事件
 	GroupMemberIncreaseEvent struct {
 		UniEvent
-		GroupUin   uint32
-		Uin        uint32
-		Uid        string
-		InviterUin uint32
-		InviterUid string
-		IsSelf     bool
+		GroupUin        uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName       string `json:"group_name,omitzero,omitempty"`       // 群名称
+		Nickname        string `json:"nickname,omitzero,omitempty"`         // 新成员的显示名称
+		InviterNickname string `json:"inviter_nickname,omitzero,omitempty"` // 邀请者的显示名称
+		Uin             uint32 `json:"uin,omitzero,omitempty"`
+		Uid             string `json:"uid,omitzero,omitempty"`
+		InviterUin      uint32 `json:"inviter_uin,omitzero,omitempty"`
+		InviterUid      string `json:"inviter_uid,omitzero,omitempty"`
+		IsSelf          bool   `json:"is_self,omitzero,omitempty"`
 	}
 	// GroupMemberDecreaseEvent 群成员减少事件
 	GroupMemberDecreaseEvent struct {
 		UniEvent
-		GroupUin uint32
-		Uin      uint32
-		Uid      string
-		IsSelf   bool
-		IsKicked bool
+		GroupUin  uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName string `json:"group_name,omitzero,omitempty"` // 群名称
+		Nickname  string `json:"nickname,omitzero,omitempty"`   // 离开的成员的显示名称
+		Uin       uint32 `json:"uin,omitzero,omitempty"`
+		Uid       string `json:"uid,omitzero,omitempty"`
+		IsSelf    bool   `json:"is_self,omitzero,omitempty"`
+		IsKicked  bool   `json:"is_kicked,omitzero,omitempty"`
 	}
 	// GroupDigestEvent 群精华消息事件
 	GroupDigestEvent struct {
 		UniEvent
-		GroupUin         uint32
-		MessageId        uint32
-		InternalId       uint32
-		SenderUin        uint32
-		SenderUid        string
-		SenderNickname   string
-		OperatorUin      uint32
-		OperatorNickname string
-		IsRemove         bool
+		GroupUin         uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName        string `json:"group_name,omitzero,omitempty"` // 群名称
+		MessageId        uint32 `json:"message_id,omitzero,omitempty"`
+		InternalId       uint32 `json:"internal_id,omitzero,omitempty"`
+		SenderUin        uint32 `json:"sender_uin,omitzero,omitempty"`
+		SenderUid        string `json:"sender_uid,omitzero,omitempty"`
+		SenderNickname   string `json:"sender_nickname,omitzero,omitempty"`
+		OperatorUin      uint32 `json:"operator_uin,omitzero,omitempty"`
+		OperatorNickname string `json:"operator_nickname,omitzero,omitempty"`
+		IsRemove         bool   `json:"is_remove,omitzero,omitempty"`
 	}
 	// GroupReactionEvent 群消息表态事件
 	GroupReactionEvent struct {
 		UniEvent
-		GroupUin  uint32
-		Uin       uint32
-		Uid       string
-		TargetSeq uint32
-		IsAdd     bool
-		IsEmoji   bool
-		Code      string
-		Count     uint32
+		GroupUin  uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName string `json:"group_name,omitzero,omitempty"` // 群名称
+		Nickname  string `json:"nickname,omitzero,omitempty"`   // 表态成员的显示名称
+		Uin       uint32 `json:"uin,omitzero,omitempty"`
+		Uid       string `json:"uid,omitzero,omitempty"`
+		TargetSeq uint32 `json:"target_seq,omitzero,omitempty"`
+		IsAdd     bool   `json:"is_add,omitzero,omitempty"`
+		IsEmoji   bool   `json:"is_emoji,omitzero,omitempty"`
+		Code      string `json:"code,omitzero,omitempty"`
+		Count     uint32 `json:"count,omitzero,omitempty"`
 	}
 	// GroupMemberSpecialTitleUpdated 群成员特殊头衔变更事件
 	GroupMemberSpecialTitleUpdated struct {
 		UniEvent
-		GroupUin uint32
-		Uin      uint32
-		Uid      string
-		NewTitle string
+		GroupUin  uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName string `json:"group_name,omitzero,omitempty"` // 群名称
+		Nickname  string `json:"nickname,omitzero,omitempty"`   // 成员的显示名称
+		Uin       uint32 `json:"uin,omitzero,omitempty"`
+		Uid       string `json:"uid,omitzero,omitempty"`
+		NewTitle  string `json:"new_title,omitzero,omitempty"`
 	}
 	// GroupInviteEvent 加群邀请事件
 	GroupInviteEvent struct {
 		UniEvent
-		GroupUin        uint32
-		GroupName       string
-		InviterUin      uint32
-		InviterUid      string
-		InviterNickname string
-		RequestSeqence  uint64
+		GroupUin        uint32 `json:"group_uin,omitzero,omitempty"`
+		GroupName       string `json:"group_name,omitzero,omitempty"`
+		InviterUin      uint32 `json:"inviter_uin,omitzero,omitempty"`
+		InviterUid      string `json:"inviter_uid,omitzero,omitempty"`
+		InviterNickname string `json:"inviter_nickname,omitzero,omitempty"`
+		RequestSeqence  uint64 `json:"request_sequence,omitzero,omitempty"`
 	}
 	// BotConnectedEvent 机器人连接事件
 	BotConnectedEvent struct {
 		UniEvent
-		Version string
+		Version string `json:"version,omitzero,omitempty"`
 	}
 	// BotDisconnectedEvent 机器人断开连接事件
 	BotDisconnectedEvent struct {
@@ -376,6 +447,11 @@ type (
 	}
 )
 
+// Recall 撤回这条私聊消息
+func (e *PrivateMessageEvent) Recall() error {
+	return e.botClient.RecallPrivateMessage(e.SenderUin, e.MessageId, e.InternalId, e.ClientSeq, e.Time)
+}
+
 func (e *PrivateMessageEvent) Clone() Event {
 	// 克隆事件
 	return &PrivateMessageEvent{
@@ -585,6 +661,33 @@ func (e *FriendPokeEvent) Clone() Event {
 	}
 }
 
+func (e *GroupPokeEvent) Clone() Event {
+	// 克隆事件
+	return &GroupPokeEvent{
+		UniEvent: UniEvent{
+			payload:        e.payload,
+			EventType:      e.EventType,
+			EventId:        e.EventId,
+			EventTags:      e.EventTags,
+			Time:           e.Time,
+			botClient:      e.botClient,
+			ClientId:       e.ClientId,
+			ClientNickname: e.ClientNickname,
+			ClientUin:      e.ClientUin,
+			ClientUid:      e.ClientUid,
+			Platform:       e.Platform,
+		},
+		GroupUin:       e.GroupUin,
+		GroupName:      e.GroupName,
+		SenderUin:      e.SenderUin,
+		SenderNickname: e.SenderNickname,
+		TargetUin:      e.TargetUin,
+		TargetNickname: e.TargetNickname,
+		Suffix:         e.Suffix,
+		Action:         e.Action,
+	}
+}
+
 func (e *GroupMemberPermissionUpdatedEvent) Clone() Event {
 	// 克隆事件
 	return &GroupMemberPermissionUpdatedEvent{
@@ -601,10 +704,12 @@ func (e *GroupMemberPermissionUpdatedEvent) Clone() Event {
 			ClientUid:      e.ClientUid,
 			Platform:       e.Platform,
 		},
-		GroupUin: e.GroupUin,
-		Uin:      e.Uin,
-		Uid:      e.Uid,
-		IsAdmin:  e.IsAdmin,
+		GroupUin:  e.GroupUin,
+		GroupName: e.GroupName,
+		Nickname:  e.Nickname,
+		Uin:       e.Uin,
+		Uid:       e.Uid,
+		IsAdmin:   e.IsAdmin,
 	}
 }
 func (e *GroupNameUpdatedEvent) Clone() Event {
@@ -624,6 +729,7 @@ func (e *GroupNameUpdatedEvent) Clone() Event {
 			Platform:       e.Platform,
 		},
 		GroupUin: e.GroupUin,
+		OldName:  e.OldName,
 		NewName:  e.NewName,
 	}
 }
@@ -643,13 +749,16 @@ func (e *GroupMuteEvent) Clone() Event {
 			ClientUid:      e.ClientUid,
 			Platform:       e.Platform,
 		},
-		GroupUin:    e.GroupUin,
-		OperatorUin: e.OperatorUin,
-		OperatorUid: e.OperatorUid,
-		TargetUin:   e.TargetUin,
-		TargetUid:   e.TargetUid,
-		Duration:    e.Duration,
-		isMuteAll:   e.isMuteAll,
+		GroupUin:         e.GroupUin,
+		GroupName:        e.GroupName,
+		OperatorNickname: e.OperatorNickname,
+		TargetNickname:   e.TargetNickname,
+		OperatorUin:      e.OperatorUin,
+		OperatorUid:      e.OperatorUid,
+		TargetUin:        e.TargetUin,
+		TargetUid:        e.TargetUid,
+		Duration:         e.Duration,
+		isMuteAll:        e.isMuteAll,
 	}
 }
 func (e *GroupRecallEvent) Clone() Event {
@@ -668,13 +777,16 @@ func (e *GroupRecallEvent) Clone() Event {
 			ClientUid:      e.ClientUid,
 			Platform:       e.Platform,
 		},
-		GroupUin:    e.GroupUin,
-		OperatorUin: e.OperatorUin,
-		OperatorUid: e.OperatorUid,
-		SenderUin:   e.SenderUin,
-		SenderUid:   e.SenderUid,
-		Seqence:     e.Seqence,
-		Random:      e.Random,
+		GroupUin:         e.GroupUin,
+		GroupName:        e.GroupName,
+		OperatorNickname: e.OperatorNickname,
+		SenderNickname:   e.SenderNickname,
+		OperatorUin:      e.OperatorUin,
+		OperatorUid:      e.OperatorUid,
+		SenderUin:        e.SenderUin,
+		SenderUid:        e.SenderUid,
+		Seqence:          e.Seqence,
+		Random:           e.Random,
 	}
 }
 func (e *GroupMemberJoinRequestEvent) Clone() Event {
@@ -693,13 +805,16 @@ func (e *GroupMemberJoinRequestEvent) Clone() Event {
 			ClientUid:      e.ClientUid,
 			Platform:       e.Platform,
 		},
-		GroupUin:       e.GroupUin,
-		SenderUin:      e.SenderUin,
-		SenderUid:      e.SenderUid,
-		SenderNickname: e.SenderNickname,
-		InviterUin:     e.InviterUin,
-		InviterUid:     e.InviterUid,
-		RequestSeqence: e.RequestSeqence,
+		GroupUin:        e.GroupUin,
+		GroupName:       e.GroupName,
+		InviterNickname: e.InviterNickname,
+		SenderUin:       e.SenderUin,
+		SenderUid:       e.SenderUid,
+		SenderNickname:  e.SenderNickname,
+		InviterUin:      e.InviterUin,
+		InviterUid:      e.InviterUid,
+		Answer:          e.Answer,
+		RequestSeqence:  e.RequestSeqence,
 	}
 }
 func (e *GroupMemberIncreaseEvent) Clone() Event {
@@ -718,12 +833,15 @@ func (e *GroupMemberIncreaseEvent) Clone() Event {
 			ClientUid:      e.ClientUid,
 			Platform:       e.Platform,
 		},
-		GroupUin:   e.GroupUin,
-		Uin:        e.Uin,
-		Uid:        e.Uid,
-		InviterUin: e.InviterUin,
-		InviterUid: e.InviterUid,
-		IsSelf:     e.IsSelf,
+		GroupUin:        e.GroupUin,
+		GroupName:       e.GroupName,
+		Nickname:        e.Nickname,
+		InviterNickname: e.InviterNickname,
+		Uin:             e.Uin,
+		Uid:             e.Uid,
+		InviterUin:      e.InviterUin,
+		InviterUid:      e.InviterUid,
+		IsSelf:          e.IsSelf,
 	}
 }
 func (e *GroupMemberDecreaseEvent) Clone() Event {
@@ -742,10 +860,13 @@ func (e *GroupMemberDecreaseEvent) Clone() Event {
 			ClientUid:      e.ClientUid,
 			Platform:       e.Platform,
 		},
-		GroupUin: e.GroupUin,
-		Uin:      e.Uin,
-		Uid:      e.Uid,
-		IsSelf:   e.IsSelf,
+		GroupUin:  e.GroupUin,
+		GroupName: e.GroupName,
+		Nickname:  e.Nickname,
+		Uin:       e.Uin,
+		Uid:       e.Uid,
+		IsSelf:    e.IsSelf,
+		IsKicked:  e.IsKicked,
 	}
 }
 func (e *GroupDigestEvent) Clone() Event {
@@ -765,6 +886,7 @@ func (e *GroupDigestEvent) Clone() Event {
 			Platform:       e.Platform,
 		},
 		GroupUin:         e.GroupUin,
+		GroupName:        e.GroupName,
 		MessageId:        e.MessageId,
 		InternalId:       e.InternalId,
 		SenderUin:        e.SenderUin,
@@ -792,6 +914,8 @@ func (e *GroupReactionEvent) Clone() Event {
 			Platform:       e.Platform,
 		},
 		GroupUin:  e.GroupUin,
+		GroupName: e.GroupName,
+		Nickname:  e.Nickname,
 		Uin:       e.Uin,
 		Uid:       e.Uid,
 		TargetSeq: e.TargetSeq,
@@ -818,10 +942,12 @@ func (e *GroupMemberSpecialTitleUpdated) Clone() Event {
 			ClientUid:      e.ClientUid,
 			Platform:       e.Platform,
 		},
-		GroupUin: e.GroupUin,
-		Uin:      e.Uin,
-		Uid:      e.Uid,
-		NewTitle: e.NewTitle,
+		GroupUin:  e.GroupUin,
+		GroupName: e.GroupName,
+		Nickname:  e.Nickname,
+		Uin:       e.Uin,
+		Uid:       e.Uid,
+		NewTitle:  e.NewTitle,
 	}
 }
 
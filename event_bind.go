@@ -7,12 +7,18 @@ import (
 	"time"
 )
 
-// EventBind 绑定LagrangeGo的事件到cryobot的事件总线
+// eventBind 订阅协议收到的事件，转换后发布到cryobot的事件总线
 func (c *LagrangeClient) eventBind() {
+	c.logger.Infof("[Cryo] 正在将 %d 的消息事件绑定到事件总线", c.Uin)
+	c.protocol.Subscribe(c.handleProtocolEvent)
+	c.logger.Successf("[Cryo] %d 的消息事件绑定完成", c.Uin)
+}
 
-	c.logger.Infof("[Cryo] 正在将 %d 的消息事件绑定到事件总线", c.Client.Uin)
+// handleProtocolEvent 把协议收到的事件转换为cryobot的事件，不认识的事件会被忽略
+func (c *LagrangeClient) handleProtocolEvent(e any) {
+	switch event := e.(type) {
 	// 断开连接
-	c.Client.DisconnectedEvent.Subscribe(func(client *client.QQClient, event *client.DisconnectedEvent) {
+	case *client.DisconnectedEvent:
 		c.bus.Publish(&BotDisconnectedEvent{UniEvent{
 			payload:        nil,
 			EventType:      BotDisconnectedEventType,
@@ -26,12 +32,12 @@ func (c *LagrangeClient) eventBind() {
 			ClientUid:      c.Uid,
 			Platform:       c.Platform,
 		}})
-	})
 
 	// 私聊消息
-	c.Client.PrivateMessageEvent.Subscribe(func(client *client.QQClient, event *message.PrivateMessage) {
+	case *message.PrivateMessage:
 		m := Message{}
 		m.AddIMessageElement(event.Elements...)
+		m.setMediaOwner(c, 0)
 		c.bus.Publish(&PrivateMessageEvent{
 			UniMessageEvent: UniMessageEvent{
 				UniEvent: UniEvent{
@@ -61,12 +67,12 @@ func (c *LagrangeClient) eventBind() {
 			ClientSeq:  event.ClientSeq,
 			TargetUin:  event.Target,
 		})
-	})
 
 	// 群聊消息
-	c.Client.GroupMessageEvent.Subscribe(func(client *client.QQClient, event *message.GroupMessage) {
+	case *message.GroupMessage:
 		m := Message{}
 		m.AddIMessageElement(event.Elements...)
+		m.setMediaOwner(c, event.GroupUin)
 		c.bus.Publish(&GroupMessageEvent{
 			UniMessageEvent: UniMessageEvent{
 				UniEvent: UniEvent{
@@ -94,12 +100,15 @@ func (c *LagrangeClient) eventBind() {
 			},
 			InternalId: event.InternalID,
 		})
-	})
 
 	// 临时消息
-	c.Client.TempMessageEvent.Subscribe(func(client *client.QQClient, event *message.TempMessage) {
+	case *message.TempMessage:
 		m := Message{}
 		m.AddIMessageElement(event.Elements...)
+		m.setMediaOwner(c, 0)
+		if event.GroupName == "" { // 临时消息通常不带群名称，需要从缓存中补全
+			event.GroupName = c.groupName(event.GroupUin)
+		}
 		c.bus.Publish(&TempMessageEvent{
 			UniMessageEvent: UniMessageEvent{
 				UniEvent: UniEvent{
@@ -126,10 +135,9 @@ func (c *LagrangeClient) eventBind() {
 				GroupName:       event.GroupName,
 			},
 		})
-	})
 
 	// 好友请求
-	c.Client.NewFriendRequestEvent.Subscribe(func(client *client.QQClient, event *event.NewFriendRequest) {
+	case *event.NewFriendRequest:
 		c.bus.Publish(&NewFriendRequestEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -150,10 +158,9 @@ func (c *LagrangeClient) eventBind() {
 			Message:  event.Msg,
 			From:     event.Source,
 		})
-	})
 
 	// 新好友
-	c.Client.NewFriendEvent.Subscribe(func(client *client.QQClient, event *event.NewFriend) {
+	case *event.NewFriend:
 		c.bus.Publish(&NewFriendEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -173,10 +180,9 @@ func (c *LagrangeClient) eventBind() {
 			Nickname: event.FromNick,
 			Message:  event.Msg,
 		})
-	})
 
 	// 好友撤回
-	c.Client.FriendRecallEvent.Subscribe(func(client *client.QQClient, event *event.FriendRecall) {
+	case *event.FriendRecall:
 		c.bus.Publish(&FriendRecallEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -196,10 +202,9 @@ func (c *LagrangeClient) eventBind() {
 			Seqence: event.Sequence,
 			Random:  event.Random,
 		})
-	})
 
 	// 改名
-	c.Client.RenameEvent.Subscribe(func(client *client.QQClient, event *event.Rename) {
+	case *event.Rename:
 		c.bus.Publish(&FriendRenameEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -219,12 +224,12 @@ func (c *LagrangeClient) eventBind() {
 			Uid:      event.UID,
 			Nickname: event.Nickname,
 		})
-	})
 
 	// 好友戳一戳 暂时不支持
 
 	// 群成员权限变动
-	c.Client.GroupMemberPermissionChangedEvent.Subscribe(func(client *client.QQClient, event *event.GroupMemberPermissionChanged) {
+	case *event.GroupMemberPermissionChanged:
+		c.onMemberPermissionUpdated(event.GroupUin, event.UserUin, event.IsAdmin)
 		c.bus.Publish(&GroupMemberPermissionUpdatedEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -239,15 +244,18 @@ func (c *LagrangeClient) eventBind() {
 				ClientUid:      c.Uid,
 				Platform:       c.Platform,
 			},
-			GroupUin: event.GroupUin,
-			Uin:      event.UserUin,
-			Uid:      event.UserUID,
-			IsAdmin:  event.IsAdmin,
+			GroupUin:  event.GroupUin,
+			GroupName: c.groupName(event.GroupUin),
+			Nickname:  c.memberName(event.GroupUin, event.UserUin),
+			Uin:       event.UserUin,
+			Uid:       event.UserUID,
+			IsAdmin:   event.IsAdmin,
 		})
-	})
 
 	// 群改名
-	c.Client.GroupNameUpdatedEvent.Subscribe(func(client *client.QQClient, event *event.GroupNameUpdated) {
+	case *event.GroupNameUpdated:
+		oldName := c.groupName(event.GroupUin)
+		c.onGroupNameUpdated(event.GroupUin, event.NewName)
 		c.bus.Publish(&GroupNameUpdatedEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -263,12 +271,15 @@ func (c *LagrangeClient) eventBind() {
 				Platform:       c.Platform,
 			},
 			GroupUin: event.GroupUin,
+			OldName:  oldName,
 			NewName:  event.NewName,
 		})
-	})
 
 	// 群禁言
-	c.Client.GroupMuteEvent.Subscribe(func(client *client.QQClient, event *event.GroupMute) {
+	case *event.GroupMute:
+		if !event.MuteAll() {
+			c.onMemberMuted(event.GroupUin, event.UserUin, uint32(time.Now().Unix()), event.Duration)
+		}
 		c.bus.Publish(&GroupMuteEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -283,18 +294,20 @@ func (c *LagrangeClient) eventBind() {
 				ClientUid:      c.Uid,
 				Platform:       c.Platform,
 			},
-			GroupUin:    event.GroupUin,
-			OperatorUin: event.OperatorUin,
-			OperatorUid: event.OperatorUID,
-			TargetUin:   event.UserUin,
-			TargetUid:   event.UserUID,
-			Duration:    event.Duration,
-			isMuteAll:   event.MuteAll(),
+			GroupUin:         event.GroupUin,
+			GroupName:        c.groupName(event.GroupUin),
+			OperatorNickname: c.memberName(event.GroupUin, event.OperatorUin),
+			TargetNickname:   c.memberName(event.GroupUin, event.UserUin),
+			OperatorUin:      event.OperatorUin,
+			OperatorUid:      event.OperatorUID,
+			TargetUin:        event.UserUin,
+			TargetUid:        event.UserUID,
+			Duration:         event.Duration,
+			isMuteAll:        event.MuteAll(),
 		})
-	})
 
 	// 群撤回
-	c.Client.GroupRecallEvent.Subscribe(func(client *client.QQClient, event *event.GroupRecall) {
+	case *event.GroupRecall:
 		c.bus.Publish(&GroupRecallEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -309,18 +322,20 @@ func (c *LagrangeClient) eventBind() {
 				ClientUid:      c.Uid,
 				Platform:       c.Platform,
 			},
-			GroupUin:    event.GroupUin,
-			OperatorUin: event.OperatorUin,
-			OperatorUid: event.OperatorUID,
-			SenderUin:   event.UserUin,
-			SenderUid:   event.UserUID,
-			Random:      event.Random,
-			Seqence:     event.Sequence,
+			GroupUin:         event.GroupUin,
+			GroupName:        c.groupName(event.GroupUin),
+			OperatorNickname: c.memberName(event.GroupUin, event.OperatorUin),
+			SenderNickname:   c.memberName(event.GroupUin, event.UserUin),
+			OperatorUin:      event.OperatorUin,
+			OperatorUid:      event.OperatorUID,
+			SenderUin:        event.UserUin,
+			SenderUid:        event.UserUID,
+			Random:           event.Random,
+			Seqence:          event.Sequence,
 		})
-	})
 
 	// 群成员入群请求
-	c.Client.GroupMemberJoinRequestEvent.Subscribe(func(client *client.QQClient, event *event.GroupMemberJoinRequest) {
+	case *event.GroupMemberJoinRequest:
 		c.bus.Publish(&GroupMemberJoinRequestEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -335,19 +350,21 @@ func (c *LagrangeClient) eventBind() {
 				ClientUid:      c.Uid,
 				Platform:       c.Platform,
 			},
-			GroupUin:       event.GroupUin,
-			SenderUin:      event.UserUin,
-			SenderUid:      event.UserUID,
-			SenderNickname: event.TargetNick,
-			InviterUin:     event.UserUin,
-			InviterUid:     event.UserUID,
-			Answer:         event.Answer,
-			RequestSeqence: event.RequestSeq,
+			GroupUin:        event.GroupUin,
+			GroupName:       c.groupName(event.GroupUin),
+			InviterNickname: c.memberName(event.GroupUin, event.InvitorUin),
+			SenderUin:       event.UserUin,
+			SenderUid:       event.UserUID,
+			SenderNickname:  event.TargetNick,
+			InviterUin:      event.InvitorUin,
+			InviterUid:      event.InvitorUID,
+			Answer:          event.Answer,
+			RequestSeqence:  event.RequestSeq,
 		})
-	})
 
 	// 群成员增加
-	c.Client.GroupMemberJoinEvent.Subscribe(func(client *client.QQClient, event *event.GroupMemberIncrease) {
+	case *event.GroupMemberIncrease:
+		c.onMemberIncrease(event.GroupUin, event.UserUin)
 		c.bus.Publish(&GroupMemberIncreaseEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -362,17 +379,22 @@ func (c *LagrangeClient) eventBind() {
 				ClientUid:      c.Uid,
 				Platform:       c.Platform,
 			},
-			GroupUin:   event.GroupUin,
-			Uin:        event.UserUin,
-			Uid:        event.UserUID,
-			InviterUin: event.InvitorUin,
-			InviterUid: event.InvitorUID,
-			IsSelf:     event.UserUin == c.Uin,
+			GroupUin:        event.GroupUin,
+			GroupName:       c.groupName(event.GroupUin),
+			Nickname:        c.memberName(event.GroupUin, event.UserUin),
+			InviterNickname: c.memberName(event.GroupUin, event.InvitorUin),
+			Uin:             event.UserUin,
+			Uid:             event.UserUID,
+			InviterUin:      event.InvitorUin,
+			InviterUid:      event.InvitorUID,
+			IsSelf:          event.UserUin == c.Uin,
 		})
-	})
 
 	// 群成员减少
-	c.Client.GroupMemberLeaveEvent.Subscribe(func(client *client.QQClient, event *event.GroupMemberDecrease) {
+	case *event.GroupMemberDecrease:
+		// 先从缓存中取出名称，再把离开的成员移出缓存
+		groupName, nickname := c.groupName(event.GroupUin), c.memberName(event.GroupUin, event.UserUin)
+		c.onMemberDecrease(event.GroupUin, event.UserUin)
 		c.bus.Publish(&GroupMemberDecreaseEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -387,16 +409,17 @@ func (c *LagrangeClient) eventBind() {
 				ClientUid:      c.Uid,
 				Platform:       c.Platform,
 			},
-			GroupUin: event.GroupUin,
-			Uin:      event.UserUin,
-			Uid:      event.UserUID,
-			IsSelf:   event.UserUin == c.Uin,
-			IsKicked: event.IsKicked(),
+			GroupUin:  event.GroupUin,
+			GroupName: groupName,
+			Nickname:  nickname,
+			Uin:       event.UserUin,
+			Uid:       event.UserUID,
+			IsSelf:    event.UserUin == c.Uin,
+			IsKicked:  event.IsKicked(),
 		})
-	})
 
 	// 群精华消息
-	c.Client.GroupDigestEvent.Subscribe(func(client *client.QQClient, event *event.GroupDigestEvent) {
+	case *event.GroupDigestEvent:
 		c.bus.Publish(&GroupDigestEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -412,6 +435,7 @@ func (c *LagrangeClient) eventBind() {
 				Platform:       c.Platform,
 			},
 			GroupUin:         event.GroupUin,
+			GroupName:        c.groupName(event.GroupUin),
 			MessageId:        event.MessageID,
 			InternalId:       event.InternalMessageID,
 			SenderUin:        event.UserUin,
@@ -421,10 +445,9 @@ func (c *LagrangeClient) eventBind() {
 			OperatorNickname: event.OperatorNick,
 			IsRemove:         event.OperationType == 2,
 		})
-	})
 
 	// 群表态事件
-	c.Client.GroupReactionEvent.Subscribe(func(client *client.QQClient, event *event.GroupReactionEvent) {
+	case *event.GroupReactionEvent:
 		c.bus.Publish(&GroupReactionEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -440,6 +463,8 @@ func (c *LagrangeClient) eventBind() {
 				Platform:       c.Platform,
 			},
 			GroupUin:  event.GroupUin,
+			GroupName: c.groupName(event.GroupUin),
+			Nickname:  c.memberName(event.GroupUin, event.UserUin),
 			Uin:       event.UserUin,
 			Uid:       event.UserUID,
 			TargetSeq: event.TargetSeq,
@@ -448,10 +473,58 @@ func (c *LagrangeClient) eventBind() {
 			Code:      event.Code,
 			Count:     event.Count,
 		})
-	})
+
+	// 好友戳一戳
+	case *event.FriendPokeEvent:
+		c.bus.Publish(&FriendPokeEvent{
+			UniEvent: UniEvent{
+				payload:        nil,
+				EventType:      FriendPokeEventType,
+				EventId:        newUUID(),
+				EventTags:      []string{"notice", "friend_poke"},
+				Time:           uint32(time.Now().Unix()),
+				botClient:      c,
+				ClientId:       c.Id,
+				ClientNickname: c.Nickname,
+				ClientUin:      c.Uin,
+				ClientUid:      c.Uid,
+				Platform:       c.Platform,
+			},
+			SenderUin: event.Sender,
+			TargetUin: event.Receiver,
+			Suffix:    event.Suffix,
+			Action:    event.Action,
+		})
+
+	// 群戳一戳
+	case *event.GroupPokeEvent:
+		c.bus.Publish(&GroupPokeEvent{
+			UniEvent: UniEvent{
+				payload:        nil,
+				EventType:      GroupPokeEventType,
+				EventId:        newUUID(),
+				EventTags:      []string{"notice", "group_poke"},
+				Time:           uint32(time.Now().Unix()),
+				botClient:      c,
+				ClientId:       c.Id,
+				ClientNickname: c.Nickname,
+				ClientUin:      c.Uin,
+				ClientUid:      c.Uid,
+				Platform:       c.Platform,
+			},
+			GroupUin:       event.GroupUin,
+			GroupName:      c.groupName(event.GroupUin),
+			SenderUin:      event.UserUin,
+			SenderNickname: c.memberName(event.GroupUin, event.UserUin),
+			TargetUin:      event.Receiver,
+			TargetNickname: c.memberName(event.GroupUin, event.Receiver),
+			Suffix:         event.Suffix,
+			Action:         event.Action,
+		})
 
 	// 群成员头衔变更
-	c.Client.MemberSpecialTitleUpdatedEvent.Subscribe(func(client *client.QQClient, event *event.MemberSpecialTitleUpdated) {
+	case *event.MemberSpecialTitleUpdated:
+		c.onMemberSpecialTitleUpdated(event.GroupUin, event.UserUin, event.NewTitle)
 		c.bus.Publish(&GroupMemberSpecialTitleUpdated{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -466,15 +539,16 @@ func (c *LagrangeClient) eventBind() {
 				ClientUid:      c.Uid,
 				Platform:       c.Platform,
 			},
-			GroupUin: event.GroupUin,
-			Uin:      event.UserUin,
-			Uid:      event.UserUID,
-			NewTitle: event.NewTitle,
+			GroupUin:  event.GroupUin,
+			GroupName: c.groupName(event.GroupUin),
+			Nickname:  c.memberName(event.GroupUin, event.UserUin),
+			Uin:       event.UserUin,
+			Uid:       event.UserUID,
+			NewTitle:  event.NewTitle,
 		})
-	})
 
 	// 群邀请
-	c.Client.GroupInvitedEvent.Subscribe(func(client *client.QQClient, event *event.GroupInvite) {
+	case *event.GroupInvite:
 		c.bus.Publish(&GroupInviteEvent{
 			UniEvent: UniEvent{
 				payload:        nil,
@@ -496,7 +570,5 @@ func (c *LagrangeClient) eventBind() {
 			InviterNickname: event.InvitorNick,
 			RequestSeqence:  event.RequestSeq,
 		})
-	})
-
-	c.logger.Successf("[Cryo] %d 的消息事件绑定完成", c.Client.Uin)
+	}
 }
@@ -0,0 +1,121 @@
+package cryo
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-json-experiment/json"
+)
+
+// eventFactories 事件类型对应的空事件构造函数，用于反序列化
+var eventFactories = map[EventType]func() Event{
+	UniEventType:                            func() Event { return &UniEvent{} },
+	UniMessageEventType:                     func() Event { return &UniMessageEvent{} },
+	PrivateMessageEventType:                 func() Event { return &PrivateMessageEvent{} },
+	GroupMessageEventType:                   func() Event { return &GroupMessageEvent{} },
+	TempMessageEventType:                    func() Event { return &TempMessageEvent{} },
+	NewFriendRequestEventType:               func() Event { return &NewFriendRequestEvent{} },
+	NewFriendEventType:                      func() Event { return &NewFriendEvent{} },
+	FriendRecallEventType:                   func() Event { return &FriendRecallEvent{} },
+	FriendRenameEventType:                   func() Event { return &FriendRenameEvent{} },
+	FriendPokeEventType:                     func() Event { return &FriendPokeEvent{} },
+	GroupPokeEventType:                      func() Event { return &GroupPokeEvent{} },
+	GroupMemberPermissionUpdatedEventType:   func() Event { return &GroupMemberPermissionUpdatedEvent{} },
+	GroupNameUpdatedEventType:               func() Event { return &GroupNameUpdatedEvent{} },
+	GroupMuteEventType:                      func() Event { return &GroupMuteEvent{} },
+	GroupRecallEventType:                    func() Event { return &GroupRecallEvent{} },
+	GroupMemberJoinRequestEventType:         func() Event { return &GroupMemberJoinRequestEvent{} },
+	GroupMemberIncreaseEventType:            func() Event { return &GroupMemberIncreaseEvent{} },
+	GroupMemberDecreaseEventType:            func() Event { return &GroupMemberDecreaseEvent{} },
+	GroupDigestEventType:                    func() Event { return &GroupDigestEvent{} },
+	GroupReactionEventType:                  func() Event { return &GroupReactionEvent{} },
+	GroupMemberSpecialTitleUpdatedEventType: func() Event { return &GroupMemberSpecialTitleUpdated{} },
+	GroupInviteEventType:                    func() Event { return &GroupInviteEvent{} },
+	BotConnectedEventType:                   func() Event { return &BotConnectedEvent{} },
+	BotDisconnectedEventType:                func() Event { return &BotDisconnectedEvent{} },
+	CustomEventType:                         func() Event { return &CustomEvent{} },
+	ScheduledTaskRegisteredEventType:        func() Event { return &ScheduledTaskRegisteredEvent{} },
+	ScheduledTaskSuccessEventType:           func() Event { return &ScheduledTaskSuccessEvent{} },
+	ScheduledTaskFailedEventType:            func() Event { return &ScheduledTaskFailedEvent{} },
+	ScheduledTaskStoppedEventType:           func() Event { return &ScheduledTaskStoppedEvent{} },
+}
+
+// eventTypeByName 事件类型名称到事件类型的映射
+var eventTypeByName = func() map[string]EventType {
+	m := make(map[string]EventType, len(eventFactories))
+	for _, et := range AllEventTypes() {
+		m[et.ToString()] = et
+	}
+	return m
+}()
+
+// MarshalEvent 将事件序列化为JSON，结果中会额外包含一个 type 字段，值为事件类型的名称，用于反序列化时区分事件类型
+//
+// 事件绑定的Bot客户端、负载和定时任务对象等运行时信息不会被序列化
+func MarshalEvent(e Event) ([]byte, error) {
+	if e == nil {
+		return nil, fmt.Errorf("不能序列化空事件")
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("序列化事件 %s 失败：%w", e.GetEventType().ToString(), err)
+	}
+	typ, err := json.Marshal(e.GetEventType().ToString())
+	if err != nil {
+		return nil, err
+	}
+	// 把 type 字段插入到对象的最前面
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)+len(typ)+9))
+	buf.WriteString(`{"type":`)
+	buf.Write(typ)
+	if len(data) > 2 {
+		buf.WriteByte(',')
+	}
+	buf.Write(data[1:])
+	return buf.Bytes(), nil
+}
+
+// UnmarshalEvent 从 MarshalEvent 生成的JSON还原事件，返回的是对应事件类型的指针
+//
+// 还原的事件没有绑定Bot客户端，不能直接调用 Send、Reply 等需要客户端的方法
+func UnmarshalEvent(data []byte) (Event, error) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, fmt.Errorf("解析事件失败：%w", err)
+	}
+	et, ok := eventTypeByName[head.Type]
+	if !ok {
+		return nil, fmt.Errorf("未知的事件类型 %q", head.Type)
+	}
+	e := eventFactories[et]()
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, fmt.Errorf("解析事件 %s 失败：%w", head.Type, err)
+	}
+	e.GetUniEvent().EventType = et
+	return e, nil
+}
+
+// groupMuteEventJSON 用于序列化 GroupMuteEvent 中没有导出的全员禁言标志
+type groupMuteEventJSON struct {
+	*groupMuteEventAlias
+	IsMuteAll bool `json:"is_mute_all,omitzero,omitempty"`
+}
+
+type groupMuteEventAlias GroupMuteEvent
+
+// MarshalJSON 序列化群禁言事件
+func (e *GroupMuteEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(groupMuteEventJSON{(*groupMuteEventAlias)(e), e.isMuteAll})
+}
+
+// UnmarshalJSON 反序列化群禁言事件
+func (e *GroupMuteEvent) UnmarshalJSON(data []byte) error {
+	v := groupMuteEventJSON{groupMuteEventAlias: (*groupMuteEventAlias)(e)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	e.isMuteAll = v.IsMuteAll
+	return nil
+}
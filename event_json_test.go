@@ -0,0 +1,188 @@
+package cryo
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// 使用 go test -run Golden -update 重新生成 testdata 中的golden文件
+var updateGolden = flag.Bool("update", false, "重新生成golden文件")
+
+// sampleMessage 构造一条包含所有消息元素的消息
+func sampleMessage() Message {
+	node := (&ForwardNode{
+		GroupUin: 100, SenderUin: 10001, SenderName: "node", Time: 1700000000,
+		Message: Message{&Text{*lgrmessage.NewText("转发的消息")}},
+	}).toLgrNode()
+	return Message{
+		&Text{*lgrmessage.NewText("hello [cryo]")},
+		&At{lgrmessage.AtElement{TargetUin: 10001, TargetUID: "u_at", Display: "@someone"}},
+		&Face{lgrmessage.FaceElement{FaceID: 14}},
+		&Reply{lgrmessage.ReplyElement{ReplySeq: 42, SenderUin: 10001, SenderUID: "u_reply", GroupUin: 100, Time: 1700000000}},
+		&Voice{VoiceElement: lgrmessage.VoiceElement{
+			UUID: "voice-uuid", Name: "voice.amr", URL: "https://example.com/voice", Summary: "语音", Size: 1024, Duration: 3,
+			Md5: []byte{1, 2, 3}, Sha1: []byte{4, 5, 6},
+		}},
+		&Image{ImageElement: lgrmessage.ImageElement{
+			ImageID: "image-id.png", URL: "https://example.com/image", Summary: "[图片]", Size: 2048, Width: 640, Height: 480,
+			SubType: 1, Flash: true, IsGroup: true, Md5: []byte{1, 2, 3}, Sha1: []byte{4, 5, 6},
+		}},
+		&File{FileElement: lgrmessage.FileElement{
+			FileID: "/file-id", FileUUID: "file-uuid", FileName: "a.txt", FileSize: 4096, FileURL: "https://example.com/file",
+			FileHash: "hash", FileMd5: []byte{1, 2, 3}, FileSha1: []byte{4, 5, 6},
+		}},
+		&ShortVideo{ShortVideoElement: lgrmessage.ShortVideoElement{
+			UUID: "video-uuid", Name: "video.mp4", URL: "https://example.com/video", Summary: "视频", Size: 8192, Duration: 10,
+			Md5: []byte{1, 2, 3}, Sha1: []byte{4, 5, 6},
+		}},
+		&LightApp{lgrmessage.LightAppElement{AppName: "com.tencent.miniapp", Content: `{"app":"com.tencent.miniapp"}`}},
+		&XML{lgrmessage.XMLElement{ServiceID: 35, Content: `<?xml version="1.0"?><msg/>`}},
+		&ForwardMessage{lgrmessage.ForwardMessage{IsGroup: true, Nodes: []*lgrmessage.ForwardNode{node}}},
+		&ForwardMessage{*lgrmessage.NewForwardWithResID("res-id")},
+		&MarketFace{lgrmessage.MarketFaceElement{
+			Summary: "[魔法表情]", ItemType: 6, FaceInfo: 1, FaceID: []byte{0xab, 0xcd}, TabID: 7, SubType: 3,
+			EncryptKey: []byte{0x12, 0x34}, MediaType: 2, MagicValue: "rand=1",
+		}},
+	}
+}
+
+// sampleEvent 构造指定类型的事件，导出的字段都会被填充为确定的非零值
+func sampleEvent(et EventType) Event {
+	e := eventFactories[et]()
+	fillSample(reflect.ValueOf(e).Elem())
+	if m, ok := e.(*GroupMuteEvent); ok {
+		m.isMuteAll = true // 没有导出的字段需要单独设置
+	}
+	e.GetUniEvent().EventType = et
+	return e
+}
+
+var messageType = reflect.TypeFor[Message]()
+
+// fillSample 递归填充结构体中导出的字段
+func fillSample(v reflect.Value) {
+	if v.Type() == messageType {
+		v.Set(reflect.ValueOf(sampleMessage()))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := range v.NumField() {
+			f := v.Type().Field(i)
+			if !f.IsExported() || f.Tag.Get("json") == "-" {
+				continue
+			}
+			fillSample(v.Field(i))
+		}
+	case reflect.Pointer:
+		if v.Type().Elem().Kind() == reflect.Struct {
+			v.Set(reflect.New(v.Type().Elem()))
+			fillSample(v.Elem())
+		}
+	case reflect.String:
+		v.SetString("sample")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(7)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(7)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			v.Set(reflect.ValueOf([]string{"sample"}).Convert(v.Type()))
+		}
+	}
+}
+
+// indentJSON 格式化JSON，让golden文件便于阅读和比较
+func indentJSON(t *testing.T, data []byte) []byte {
+	t.Helper()
+	v := jsontext.Value(bytes.Clone(data))
+	if err := v.Indent(jsontext.WithIndent("  ")); err != nil {
+		t.Fatalf("格式化JSON失败：%v", err)
+	}
+	return append(v, '\n')
+}
+
+// checkGolden 比较数据和golden文件，-update 时写入golden文件
+func checkGolden(t *testing.T, file string, got []byte) []byte {
+	t.Helper()
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("读取golden文件失败：%v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s 不一致\n得到：\n%s\n期望：\n%s", file, got, want)
+	}
+	return want
+}
+
+func TestMessageJSONGolden(t *testing.T) {
+	m := sampleMessage()
+	data, err := m.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := checkGolden(t, filepath.Join("testdata", "message.json"), indentJSON(t, data))
+
+	var got Message
+	if err := got.UnmarshalJSON(golden); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(m) {
+		t.Fatalf("反序列化得到 %d 个元素，期望 %d 个", len(got), len(m))
+	}
+	for i := range m {
+		if !reflect.DeepEqual(got[i], m[i]) {
+			t.Errorf("第 %d 个元素不一致\n得到：%#v\n期望：%#v", i, got[i], m[i])
+		}
+	}
+}
+
+func TestEventJSONGolden(t *testing.T) {
+	for _, et := range AllEventTypes() {
+		name := et.ToString()
+		t.Run(name, func(t *testing.T) {
+			e := sampleEvent(et)
+			data, err := MarshalEvent(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := checkGolden(t, filepath.Join("testdata", "events", name+".json"), indentJSON(t, data))
+
+			got, err := UnmarshalEvent(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.GetEventType() != et {
+				t.Fatalf("事件类型为 %s，期望 %s", got.GetEventType().ToString(), name)
+			}
+			if !reflect.DeepEqual(got, e) {
+				t.Errorf("反序列化的事件不一致\n得到：%#v\n期望：%#v", got, e)
+			}
+		})
+	}
+}
+
+func TestEventFactoriesCoverAllEventTypes(t *testing.T) {
+	for _, et := range AllEventTypes() {
+		if _, ok := eventFactories[et]; !ok {
+			t.Errorf("事件类型 %s 没有对应的构造函数", et.ToString())
+		}
+	}
+}
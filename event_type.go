@@ -37,6 +37,10 @@ const (
 	ScheduledTaskSuccessEventType    // 定时任务执行成功事件类型
 	ScheduledTaskFailedEventType     // 定时任务执行失败事件类型
 	ScheduledTaskStoppedEventType    // 定时任务被停止事件类型
+
+	// 之后新增的事件类型需要追加在这里，事件类型的数值会被序列化到录制文件、Webhook 和远程插件的消息中，不能改变已有的值
+
+	GroupPokeEventType // 群戳一戳事件类型
 )
 
 // ToString 输出事件类型的字符串表示
@@ -98,6 +102,8 @@ func (et EventType) ToString() string {
 		return "ScheduledTaskFailedEvent"
 	case ScheduledTaskStoppedEventType:
 		return "ScheduledTaskStoppedEvent"
+	case GroupPokeEventType:
+		return "GroupPokeEvent"
 	default:
 		return "UnknownEventType"
 	}
@@ -134,5 +140,6 @@ func AllEventTypes() []EventType {
 		ScheduledTaskSuccessEventType,
 		ScheduledTaskFailedEventType,
 		ScheduledTaskStoppedEventType,
+		GroupPokeEventType,
 	}
 }
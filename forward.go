@@ -0,0 +1,207 @@
+package cryo
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+)
+
+// LongTextThreshold AutoForward 将文本转换为合并转发消息的长度阈值
+var LongTextThreshold = 500
+
+// ForwardNode 合并转发消息中的一条消息
+type ForwardNode struct {
+	GroupUin   uint32  // 消息所在的群号，私聊消息为0
+	SenderUin  uint32  // 发送者的Uin
+	SenderName string  // 显示的发送者名称
+	Time       uint32  // 消息的时间
+	Message    Message // 消息内容
+}
+
+// toLgrNode 转换为LagrangeGo的转发节点
+func (n *ForwardNode) toLgrNode() *lgrmessage.ForwardNode {
+	return &lgrmessage.ForwardNode{
+		GroupID:    n.GroupUin,
+		SenderID:   n.SenderUin,
+		SenderName: n.SenderName,
+		Time:       n.Time,
+		Message:    n.Message.ToIMessageElements(),
+	}
+}
+
+// ForwardBuilder 合并转发消息构造器
+//
+// 每个节点都可以自定义发送者的Uin和名称，节点的内容也可以是另一条合并转发消息
+type ForwardBuilder struct {
+	groupUin uint32
+	nodes    []*ForwardNode
+}
+
+// NewForwardBuilder 创建一个新的合并转发消息构造器
+func NewForwardBuilder() *ForwardBuilder {
+	return &ForwardBuilder{}
+}
+
+// InGroup 将之后添加的节点显示为指定群中的消息
+func (b *ForwardBuilder) InGroup(groupUin uint32) *ForwardBuilder {
+	b.groupUin = groupUin
+	return b
+}
+
+// Add 添加一个节点，args 的处理方式和 Send 方法相同
+func (b *ForwardBuilder) Add(senderUin uint32, senderName string, args ...interface{}) *ForwardBuilder {
+	return b.AddMessage(senderUin, senderName, *ProcessMessageContent(args...))
+}
+
+// AddMessage 添加一个节点，使用已经构造好的消息
+func (b *ForwardBuilder) AddMessage(senderUin uint32, senderName string, msg Message) *ForwardBuilder {
+	b.nodes = append(b.nodes, &ForwardNode{
+		GroupUin:   b.groupUin,
+		SenderUin:  senderUin,
+		SenderName: senderName,
+		Time:       uint32(time.Now().Unix()),
+		Message:    msg,
+	})
+	return b
+}
+
+// AddForward 添加一个内容为另一条合并转发消息的节点
+func (b *ForwardBuilder) AddForward(senderUin uint32, senderName string, inner *ForwardBuilder) *ForwardBuilder {
+	return b.AddMessage(senderUin, senderName, Message{inner.Build()})
+}
+
+// AddNode 直接添加一个节点，例如从收到的合并转发消息中展开的节点
+func (b *ForwardBuilder) AddNode(node *ForwardNode) *ForwardBuilder {
+	b.nodes = append(b.nodes, node)
+	return b
+}
+
+// Len 获取当前的节点数量
+func (b *ForwardBuilder) Len() int {
+	return len(b.nodes)
+}
+
+// Build 构造合并转发消息元素，消息会在发送时自动上传
+func (b *ForwardBuilder) Build() *ForwardMessage {
+	nodes := make([]*lgrmessage.ForwardNode, 0, len(b.nodes))
+	for _, n := range b.nodes {
+		nodes = append(nodes, n.toLgrNode())
+	}
+	return &ForwardMessage{*lgrmessage.NewForwardWithNodes(nodes)}
+}
+
+// AddForward 添加合并转发消息元素
+func (m *Message) AddForward(b *ForwardBuilder) *Message {
+	*m = append(*m, b.Build())
+	return m
+}
+
+// AutoForward 文本长度超过 LongTextThreshold 时，将文本按行切分成多个节点并包装为合并转发消息，否则直接作为文本消息
+func AutoForward(text string, senderUin uint32, senderName string) *Message {
+	m := &Message{}
+	if LongTextThreshold <= 0 || utf8.RuneCountInString(text) <= LongTextThreshold {
+		return m.AddText(text)
+	}
+	b := NewForwardBuilder()
+	for _, chunk := range splitText(text, LongTextThreshold) {
+		b.Add(senderUin, senderName, chunk)
+	}
+	return m.AddForward(b)
+}
+
+// splitText 将文本切分为长度不超过 size 的若干段，尽量在换行处切分
+func splitText(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+	flush := func() {
+		if currentLen > 0 {
+			chunks = append(chunks, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+			currentLen = 0
+		}
+	}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineLen := utf8.RuneCountInString(line)
+		if currentLen+lineLen > size {
+			flush()
+		}
+		// 单行就超出长度时只能硬切分
+		for lineLen > size {
+			runes := []rune(line)
+			chunks = append(chunks, string(runes[:size]))
+			line = string(runes[size:])
+			lineLen -= size
+		}
+		current.WriteString(line)
+		currentLen += lineLen
+	}
+	flush()
+	return chunks
+}
+
+// GetNodes 获取转发消息中已经包含的节点，收到的转发消息通常只有ResID，需要使用 LagrangeClient.ExpandForward 展开
+func (e *ForwardMessage) GetNodes() []*ForwardNode {
+	nodes := make([]*ForwardNode, 0, len(e.Nodes))
+	for _, n := range e.Nodes {
+		m := Message{}
+		m.AddIMessageElement(n.Message...)
+		nodes = append(nodes, &ForwardNode{
+			GroupUin:   n.GroupID,
+			SenderUin:  n.SenderID,
+			SenderName: n.SenderName,
+			Time:       n.Time,
+			Message:    m,
+		})
+	}
+	return nodes
+}
+
+// ExpandForward 展开收到的合并转发消息，获取其中的每一条消息
+func (c *LagrangeClient) ExpandForward(f *ForwardMessage) ([]*ForwardNode, error) {
+	if len(f.Nodes) == 0 && f.ResID != "" {
+		fetched, err := c.protocol.FetchForwardMsg(f.ResID)
+		if err != nil {
+			return nil, newActionError("fetch_forward_message", 0, 0, nil, err)
+		}
+		f.Nodes = fetched.Nodes
+	}
+	nodes := f.GetNodes()
+	for _, n := range nodes {
+		n.Message.setMediaOwner(c, n.GroupUin)
+	}
+	return nodes, nil
+}
+
+// prepareForward 在发送前上传合并转发消息节点中的媒体和嵌套的合并转发消息，LagrangeGo只会处理最外层的元素
+func (c *LagrangeClient) prepareForward(target SendTarget, f *lgrmessage.ForwardMessage) error {
+	source := lgrmessage.Source{SourceType: lgrmessage.SourcePrivate, PrimaryID: int64(target.UserUin)}
+	var groupUin uint32
+	if target.Type == GroupTarget {
+		source = lgrmessage.Source{SourceType: lgrmessage.SourceGroup, PrimaryID: int64(target.GroupUin)}
+		groupUin = target.GroupUin
+	}
+	for _, node := range f.Nodes {
+		for _, e := range node.Message {
+			var err error
+			switch v := e.(type) {
+			case *lgrmessage.ImageElement:
+				if v.MsgInfo == nil && v.Stream != nil {
+					_, err = c.protocol.UploadImage(source, v)
+				}
+			case *lgrmessage.ForwardMessage:
+				if v.ResID == "" && len(v.Nodes) > 0 {
+					if err = c.prepareForward(target, v); err == nil {
+						_, err = c.protocol.UploadForwardMsg(v, groupUin)
+					}
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,427 @@
+package cryo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/machinacanis/cryo/log"
+)
+
+// HTTPAPIConfig HTTP API 的配置
+type HTTPAPIConfig struct {
+	Addr           string // HTTP 服务的监听地址，例如 127.0.0.1:8070，为空时不监听，可以通过 ServeHTTP 挂载到其他服务上
+	AccessToken    string // 访问令牌，为空时不校验，暴露到本机以外时一定要设置
+	BasePath       string // 接口的路径前缀，默认为 /api
+	AllowLocalFile bool   // 是否允许发送的CQ码中的媒体使用 file:// 读取本地文件，默认关闭
+}
+
+// httpAPIMaxBody 请求体的大小上限
+const httpAPIMaxBody = 1 << 20
+
+// HTTPAPI 是内置的 HTTP API，以插件的形式运行
+//
+// 提供了查看Bot客户端、发送消息、管理插件和定时任务以及查看事件总线的接口，
+// 接口的描述可以通过 {BasePath}/openapi.json 获取，这个地址不需要访问令牌
+type HTTPAPI struct {
+	conf   HTTPAPIConfig
+	bot    *Bot
+	logger log.CryoLogger
+	mux    *http.ServeMux
+
+	mutex   sync.Mutex
+	enabled bool
+	server  *http.Server
+}
+
+// NewHTTPAPI 创建一个新的 HTTP API，需要通过 Bot.AddPlugin 添加到Bot中才会生效
+func NewHTTPAPI(conf HTTPAPIConfig) *HTTPAPI {
+	conf.BasePath = "/" + strings.Trim(conf.BasePath, "/")
+	if conf.BasePath == "/" {
+		conf.BasePath = "/api"
+	}
+	a := &HTTPAPI{conf: conf, mux: http.NewServeMux()}
+	base := conf.BasePath
+	a.mux.HandleFunc("GET "+base+"/openapi.json", a.openAPI)
+	a.mux.HandleFunc("GET "+base+"/clients", a.listClients)
+	a.mux.HandleFunc("POST "+base+"/messages", a.sendMessage)
+	a.mux.HandleFunc("GET "+base+"/plugins", a.listPlugins)
+	a.mux.HandleFunc("POST "+base+"/plugins/{name}/enable", a.setPlugin(true))
+	a.mux.HandleFunc("POST "+base+"/plugins/{name}/disable", a.setPlugin(false))
+	a.mux.HandleFunc("GET "+base+"/tasks", a.listTasks)
+	a.mux.HandleFunc("POST "+base+"/tasks/{id}/stop", a.stopTask)
+	a.mux.HandleFunc("GET "+base+"/bus", a.busInfo)
+	return a
+}
+
+// Init 初始化 HTTP API
+func (a *HTTPAPI) Init(bot *Bot) error {
+	a.bot = bot
+	a.logger = bot.GetLogger()
+	return nil
+}
+
+// GetPluginName 获取插件名称信息
+func (a *HTTPAPI) GetPluginName() string {
+	return "HTTPAPI"
+}
+
+// GetPluginVersion 获取插件版本号信息
+func (a *HTTPAPI) GetPluginVersion() string {
+	return "0.1.0"
+}
+
+// GetPluginDescription 获取插件描述信息
+func (a *HTTPAPI) GetPluginDescription() string {
+	return "通过 HTTP 接口发送消息和管理Bot"
+}
+
+// GetPluginAuthor 获取插件作者信息
+func (a *HTTPAPI) GetPluginAuthor() string {
+	return "machinacanis"
+}
+
+// Enable 启用 HTTP API，开始监听
+func (a *HTTPAPI) Enable() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.enabled {
+		return
+	}
+	a.enabled = true
+	if a.conf.Addr == "" {
+		return
+	}
+	if a.conf.AccessToken == "" {
+		a.logger.Warnf("[HTTPAPI] 没有设置访问令牌，任何能访问 %s 的人都可以操作Bot", a.conf.Addr)
+	}
+	ln, err := net.Listen("tcp", a.conf.Addr)
+	if err != nil {
+		a.logger.Errorf("[HTTPAPI] 监听 %s 失败：%v", a.conf.Addr, err)
+		return
+	}
+	a.server = &http.Server{Handler: a}
+	go func(server *http.Server) {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.logger.Errorf("[HTTPAPI] HTTP 服务出现错误：%v", err)
+		}
+	}(a.server)
+	a.logger.Successf("[HTTPAPI] 正在监听 %s%s", a.conf.Addr, a.conf.BasePath)
+}
+
+// Disable 禁用 HTTP API，停止监听
+func (a *HTTPAPI) Disable() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if !a.enabled {
+		return
+	}
+	a.enabled = false
+	if a.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = a.server.Shutdown(ctx)
+		cancel()
+		a.server = nil
+	}
+}
+
+// IsEnable 是否已启用 HTTP API
+func (a *HTTPAPI) IsEnable() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.enabled
+}
+
+// ServeHTTP 处理 HTTP API 的请求，除了接口描述以外都需要访问令牌
+func (a *HTTPAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.IsEnable() {
+		writeHTTPAPIError(w, http.StatusServiceUnavailable, "HTTP API 已被禁用")
+		return
+	}
+	if r.URL.Path != a.conf.BasePath+"/openapi.json" {
+		if status := checkAccessToken(r, a.conf.AccessToken); status != 0 {
+			writeHTTPAPIError(w, status, http.StatusText(status))
+			return
+		}
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+// writeHTTPAPIJSON 写入JSON响应
+func writeHTTPAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.MarshalWrite(w, v)
+}
+
+// writeHTTPAPIError 写入错误响应，格式为 {"error": "..."}
+func writeHTTPAPIError(w http.ResponseWriter, status int, msg string) {
+	writeHTTPAPIJSON(w, status, map[string]string{"error": msg})
+}
+
+// decodeHTTPAPIBody 解析请求体，不允许出现未知的字段
+func decodeHTTPAPIBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		writeHTTPAPIError(w, http.StatusUnsupportedMediaType, "请求体需要是 application/json")
+		return false
+	}
+	body := http.MaxBytesReader(w, r.Body, httpAPIMaxBody)
+	if err := json.UnmarshalRead(body, v, json.RejectUnknownMembers(true)); err != nil {
+		writeHTTPAPIError(w, http.StatusBadRequest, "请求体格式错误："+err.Error())
+		return false
+	}
+	return true
+}
+
+func (a *HTTPAPI) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write([]byte(strings.Replace(httpAPIOpenAPI, "{{base}}", a.conf.BasePath, 1)))
+}
+
+// httpAPIClient 是接口返回的Bot客户端信息
+type httpAPIClient struct {
+	Id       string `json:"id"`
+	Uin      uint32 `json:"uin"`
+	Uid      string `json:"uid"`
+	Nickname string `json:"nickname"`
+	Platform string `json:"platform"`
+	Online   bool   `json:"online"`
+}
+
+func (a *HTTPAPI) listClients(w http.ResponseWriter, r *http.Request) {
+	clients := a.bot.getConnectedClients()
+	result := make([]httpAPIClient, 0, len(clients))
+	for _, c := range clients {
+		result = append(result, httpAPIClient{
+			Id:       c.Id,
+			Uin:      c.Uin,
+			Uid:      c.Uid,
+			Nickname: c.Nickname,
+			Platform: c.Platform,
+			Online:   c.IsOnline(),
+		})
+	}
+	writeHTTPAPIJSON(w, http.StatusOK, result)
+}
+
+// httpAPISendRequest 是发送消息的请求
+type httpAPISendRequest struct {
+	Client  string         `json:"client,omitzero"` // 发送消息的Bot客户端ID或者Uin，为空时使用Uin最小的客户端
+	Type    string         `json:"type"`            // private、group 或者 temp
+	GroupId uint32         `json:"group_id,omitzero"`
+	UserId  uint32         `json:"user_id,omitzero"`
+	Message jsontext.Value `json:"message"` // CQ码字符串或者消息的JSON数组
+}
+
+// validate 检查请求的参数，返回发送目标
+func (req *httpAPISendRequest) validate() (SendTarget, error) {
+	switch req.Type {
+	case "private":
+		if req.UserId == 0 {
+			return SendTarget{}, errors.New("私聊消息需要 user_id")
+		}
+		return SendTarget{Type: PrivateTarget, UserUin: req.UserId}, nil
+	case "group":
+		if req.GroupId == 0 {
+			return SendTarget{}, errors.New("群消息需要 group_id")
+		}
+		return SendTarget{Type: GroupTarget, GroupUin: req.GroupId}, nil
+	case "temp":
+		if req.GroupId == 0 || req.UserId == 0 {
+			return SendTarget{}, errors.New("临时会话消息需要 group_id 和 user_id")
+		}
+		return SendTarget{Type: TempTarget, GroupUin: req.GroupId, UserUin: req.UserId}, nil
+	}
+	return SendTarget{}, errors.New("type 需要是 private、group 或者 temp")
+}
+
+// httpAPISentMessage 是发送消息的结果，撤回消息时需要用到这些信息
+type httpAPISentMessage struct {
+	ClientId   string `json:"client_id"`
+	SelfId     uint32 `json:"self_id"`
+	MessageId  uint32 `json:"message_id"`
+	InternalId uint32 `json:"internal_id"`
+	ClientSeq  uint32 `json:"client_seq"`
+	Time       uint32 `json:"time"`
+}
+
+func (a *HTTPAPI) sendMessage(w http.ResponseWriter, r *http.Request) {
+	var req httpAPISendRequest
+	if !decodeHTTPAPIBody(w, r, &req) {
+		return
+	}
+	target, err := req.validate()
+	if err != nil {
+		writeHTTPAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	m, err := decodeMessageValue(req.Message, CQParseOptions{AllowLocalFile: a.conf.AllowLocalFile})
+	if err != nil {
+		writeHTTPAPIError(w, http.StatusBadRequest, "message 格式错误："+err.Error())
+		return
+	}
+	var c *LagrangeClient
+	if req.Client != "" {
+		c = a.bot.GetClientById(req.Client)
+		if uin, err := strconv.ParseUint(req.Client, 10, 32); c == nil && err == nil {
+			c = a.bot.GetClientByUin(uint32(uin))
+		}
+		if c == nil {
+			writeHTTPAPIError(w, http.StatusNotFound, "Bot客户端 "+req.Client+" 没有连接")
+			return
+		}
+	} else if c, err = a.bot.selectClient(0); err != nil {
+		writeHTTPAPIError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	sent, err := c.SendContext(r.Context(), target, &m)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, ErrNotOnline) {
+			status = http.StatusServiceUnavailable
+		}
+		writeHTTPAPIError(w, status, err.Error())
+		return
+	}
+	writeHTTPAPIJSON(w, http.StatusOK, httpAPISentMessage{
+		ClientId:   c.Id,
+		SelfId:     c.Uin,
+		MessageId:  sent.MessageId,
+		InternalId: sent.InternalId,
+		ClientSeq:  sent.ClientSeq,
+		Time:       sent.Time,
+	})
+}
+
+// httpAPIPlugin 是接口返回的插件信息
+type httpAPIPlugin struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	Author      string `json:"author"`
+	Enabled     bool   `json:"enabled"`
+}
+
+func newHTTPAPIPlugin(p Plugin) httpAPIPlugin {
+	return httpAPIPlugin{
+		Name:        p.GetPluginName(),
+		Version:     p.GetPluginVersion(),
+		Description: p.GetPluginDescription(),
+		Author:      p.GetPluginAuthor(),
+		Enabled:     p.IsEnable(),
+	}
+}
+
+func (a *HTTPAPI) listPlugins(w http.ResponseWriter, r *http.Request) {
+	plugins := a.bot.GetAllPlugin()
+	result := make([]httpAPIPlugin, 0, len(plugins))
+	for _, p := range plugins {
+		result = append(result, newHTTPAPIPlugin(p))
+	}
+	writeHTTPAPIJSON(w, http.StatusOK, result)
+}
+
+// setPlugin 启用或者禁用指定名称的插件，同名的插件会一起处理
+func (a *HTTPAPI) setPlugin(enable bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		plugins := a.bot.GetPlugin(r.PathValue("name"))
+		if len(plugins) == 0 {
+			writeHTTPAPIError(w, http.StatusNotFound, "插件 "+r.PathValue("name")+" 不存在")
+			return
+		}
+		result := make([]httpAPIPlugin, 0, len(plugins))
+		for _, p := range plugins {
+			if p == Plugin(a) && !enable {
+				writeHTTPAPIError(w, http.StatusConflict, "不能通过 HTTP API 禁用它自己")
+				return
+			}
+		}
+		for _, p := range plugins {
+			if enable {
+				p.Enable()
+			} else {
+				p.Disable()
+			}
+			result = append(result, newHTTPAPIPlugin(p))
+		}
+		writeHTTPAPIJSON(w, http.StatusOK, result)
+	}
+}
+
+// httpAPITask 是接口返回的定时任务信息
+type httpAPITask struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`              // delay、interval 或者 cron
+	Status   string `json:"status"`            // pending、running、failed 或者 stopped
+	Duration int64  `json:"duration,omitzero"` // 延迟任务和间隔任务的时间间隔（毫秒）
+	Cron     string `json:"cron,omitzero"`     // 定时任务的cron表达式
+	Error    string `json:"error,omitzero"`    // 任务最近一次执行失败的原因
+}
+
+func newHTTPAPITask(st *ScheduledTask) httpAPITask {
+	t := httpAPITask{
+		Id:       st.GetTaskId(),
+		Name:     st.GetTaskName(),
+		Type:     httpAPITaskType(st.GetTaskType()),
+		Status:   string(st.GetStatus()),
+		Duration: st.GetDuration().Milliseconds(),
+		Cron:     st.GetCron(),
+	}
+	if err := st.GetError(); err != nil {
+		t.Error = err.Error()
+	}
+	return t
+}
+
+// httpAPITaskType 返回定时任务类型在接口中的名称
+func httpAPITaskType(t ScheduledTaskType) string {
+	switch t {
+	case DelayTaskType:
+		return "delay"
+	case IntervalTaskType:
+		return "interval"
+	case CronTaskType:
+		return "cron"
+	default:
+		return "unknown"
+	}
+}
+
+func (a *HTTPAPI) listTasks(w http.ResponseWriter, r *http.Request) {
+	tasks := a.bot.GetTasks()
+	result := make([]httpAPITask, 0, len(tasks))
+	for _, st := range tasks {
+		result = append(result, newHTTPAPITask(st))
+	}
+	writeHTTPAPIJSON(w, http.StatusOK, result)
+}
+
+func (a *HTTPAPI) stopTask(w http.ResponseWriter, r *http.Request) {
+	tasks := a.bot.GetTaskById(r.PathValue("id"))
+	if len(tasks) == 0 {
+		writeHTTPAPIError(w, http.StatusNotFound, "定时任务 "+r.PathValue("id")+" 不存在")
+		return
+	}
+	st := tasks[0]
+	if err := a.bot.StopTask(st); err != nil {
+		writeHTTPAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeHTTPAPIJSON(w, http.StatusOK, newHTTPAPITask(st))
+}
+
+func (a *HTTPAPI) busInfo(w http.ResponseWriter, r *http.Request) {
+	middlewares := a.bot.GetBus().GetMiddlewareInfo()
+	if middlewares == nil {
+		middlewares = []MiddlewareInfo{}
+	}
+	writeHTTPAPIJSON(w, http.StatusOK, map[string]any{"middlewares": middlewares})
+}
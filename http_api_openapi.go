@@ -0,0 +1,207 @@
+package cryo
+
+// httpAPIOpenAPI 是 HTTP API 的 OpenAPI 3.0 描述，{{base}} 会被替换为接口的路径前缀
+const httpAPIOpenAPI = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "cryo HTTP API",
+    "version": "0.1.0",
+    "description": "查看Bot客户端、发送消息、管理插件和定时任务以及查看事件总线。除了本文档以外的接口都需要在 Authorization 请求头中携带 Bearer <token>，或者使用 access_token 查询参数。"
+  },
+  "servers": [{"url": "{{base}}"}],
+  "security": [{"bearer": []}],
+  "paths": {
+    "/clients": {
+      "get": {
+        "summary": "获取已连接的Bot客户端",
+        "operationId": "listClients",
+        "responses": {
+          "200": {"description": "Bot客户端列表", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Client"}}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/messages": {
+      "post": {
+        "summary": "发送消息",
+        "operationId": "sendMessage",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SendRequest"}}}},
+        "responses": {
+          "200": {"description": "已发送的消息", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SentMessage"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "502": {"$ref": "#/components/responses/Error"},
+          "503": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/plugins": {
+      "get": {
+        "summary": "获取所有插件",
+        "operationId": "listPlugins",
+        "responses": {
+          "200": {"description": "插件列表", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Plugin"}}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/plugins/{name}/enable": {
+      "post": {
+        "summary": "启用插件",
+        "operationId": "enablePlugin",
+        "parameters": [{"$ref": "#/components/parameters/PluginName"}],
+        "responses": {
+          "200": {"description": "启用后的插件，同名的插件会一起启用", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Plugin"}}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/plugins/{name}/disable": {
+      "post": {
+        "summary": "禁用插件",
+        "operationId": "disablePlugin",
+        "parameters": [{"$ref": "#/components/parameters/PluginName"}],
+        "responses": {
+          "200": {"description": "禁用后的插件，同名的插件会一起禁用", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Plugin"}}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/tasks": {
+      "get": {
+        "summary": "获取所有定时任务",
+        "operationId": "listTasks",
+        "responses": {
+          "200": {"description": "定时任务列表", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Task"}}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/tasks/{id}/stop": {
+      "post": {
+        "summary": "停止定时任务",
+        "operationId": "stopTask",
+        "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {
+          "200": {"description": "停止后的定时任务", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Task"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/bus": {
+      "get": {
+        "summary": "查看事件总线中的中间件",
+        "operationId": "getBus",
+        "responses": {
+          "200": {"description": "按照阶段和执行顺序排列的中间件", "content": {"application/json": {"schema": {"type": "object", "required": ["middlewares"], "properties": {"middlewares": {"type": "array", "items": {"$ref": "#/components/schemas/Middleware"}}}}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer"}
+    },
+    "parameters": {
+      "PluginName": {"name": "name", "in": "path", "required": true, "schema": {"type": "string"}}
+    },
+    "responses": {
+      "Error": {"description": "错误", "content": {"application/json": {"schema": {"type": "object", "required": ["error"], "properties": {"error": {"type": "string"}}}}}}
+    },
+    "schemas": {
+      "Client": {
+        "type": "object",
+        "required": ["id", "uin", "uid", "nickname", "platform", "online"],
+        "properties": {
+          "id": {"type": "string"},
+          "uin": {"type": "integer", "format": "int64"},
+          "uid": {"type": "string"},
+          "nickname": {"type": "string"},
+          "platform": {"type": "string"},
+          "online": {"type": "boolean"}
+        }
+      },
+      "SendRequest": {
+        "type": "object",
+        "required": ["type", "message"],
+        "additionalProperties": false,
+        "properties": {
+          "client": {"type": "string", "description": "发送消息的Bot客户端ID或者Uin，为空时使用Uin最小的客户端"},
+          "type": {"type": "string", "enum": ["private", "group", "temp"]},
+          "group_id": {"type": "integer", "format": "int64", "minimum": 1, "description": "group 和 temp 需要"},
+          "user_id": {"type": "integer", "format": "int64", "minimum": 1, "description": "private 和 temp 需要"},
+          "message": {
+            "description": "CQ码字符串，或者 [{\"type\": \"text\", \"data\": {\"text\": \"...\"}}] 格式的消息数组",
+            "oneOf": [
+              {"type": "string", "minLength": 1},
+              {"type": "array", "minItems": 1, "items": {"type": "object", "required": ["type"], "properties": {"type": {"type": "string"}, "data": {"type": "object"}}}}
+            ]
+          }
+        }
+      },
+      "SentMessage": {
+        "type": "object",
+        "required": ["client_id", "self_id", "message_id", "internal_id", "client_seq", "time"],
+        "properties": {
+          "client_id": {"type": "string"},
+          "self_id": {"type": "integer", "format": "int64"},
+          "message_id": {"type": "integer", "format": "int64"},
+          "internal_id": {"type": "integer", "format": "int64"},
+          "client_seq": {"type": "integer", "format": "int64"},
+          "time": {"type": "integer", "format": "int64"}
+        }
+      },
+      "Plugin": {
+        "type": "object",
+        "required": ["name", "version", "description", "author", "enabled"],
+        "properties": {
+          "name": {"type": "string"},
+          "version": {"type": "string"},
+          "description": {"type": "string"},
+          "author": {"type": "string"},
+          "enabled": {"type": "boolean"}
+        }
+      },
+      "Task": {
+        "type": "object",
+        "required": ["id", "name", "type", "status"],
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "type": {"type": "string", "enum": ["delay", "interval", "cron"]},
+          "status": {"type": "string", "enum": ["pending", "running", "failed", "stopped"]},
+          "duration": {"type": "integer", "format": "int64", "description": "延迟任务和间隔任务的时间间隔（毫秒）"},
+          "cron": {"type": "string"},
+          "error": {"type": "string", "description": "任务最近一次执行失败的原因"}
+        }
+      },
+      "Middleware": {
+        "type": "object",
+        "required": ["id", "stage", "tags", "types", "handlers"],
+        "properties": {
+          "id": {"type": "string"},
+          "stage": {"type": "string", "enum": ["pre", "sync", "post", "async"]},
+          "tags": {"type": "array", "items": {"type": "string"}},
+          "types": {"type": "array", "items": {"type": "string"}, "description": "接收的事件类型名称，为空时是全局中间件"},
+          "handlers": {"type": "integer"}
+        }
+      }
+    }
+  }
+}
+`
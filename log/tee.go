@@ -0,0 +1,146 @@
+package log
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Sink 是日志接收器，会收到日志记录器输出的每一条日志
+type Sink func(level CryoLogLevel, message string)
+
+// sinkEntry 包装一个日志接收器，移除时通过指针比较
+type sinkEntry struct {
+	sink Sink
+}
+
+// TeeLogger 是可以添加日志接收器的日志记录器，日志会同时写入原有的日志记录器和所有的接收器
+//
+// 接收器在写入原有的日志记录器之前调用，这样 Fatal 和 Panic 的日志也能被接收器收到
+type TeeLogger struct {
+	CryoLogger
+	mutex sync.RWMutex
+	sinks []*sinkEntry
+}
+
+// NewTeeLogger 包装一个日志记录器，使其可以添加日志接收器
+func NewTeeLogger(logger CryoLogger) *TeeLogger {
+	return &TeeLogger{CryoLogger: logger}
+}
+
+// AddSink 添加一个日志接收器，返回移除这个接收器的函数
+//
+// 接收器会在输出日志的协程中同步调用，不应该在其中进行耗时的操作或者再次输出日志
+func (l *TeeLogger) AddSink(sink Sink) (remove func()) {
+	entry := &sinkEntry{sink: sink}
+	l.mutex.Lock()
+	l.sinks = append(l.sinks, entry)
+	l.mutex.Unlock()
+	return func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		l.sinks = slices.DeleteFunc(l.sinks, func(e *sinkEntry) bool { return e == entry })
+	}
+}
+
+// emit 把日志交给所有的接收器，没有接收器时不会格式化日志
+func (l *TeeLogger) emit(level CryoLogLevel, message func() string) {
+	l.mutex.RLock()
+	sinks := l.sinks
+	l.mutex.RUnlock()
+	if len(sinks) == 0 {
+		return
+	}
+	msg := message()
+	for _, e := range sinks {
+		e.sink(level, msg)
+	}
+}
+
+func sprint(args []interface{}) func() string {
+	return func() string { return fmt.Sprint(args...) }
+}
+
+func sprintf(format string, args []interface{}) func() string {
+	return func() string { return fmt.Sprintf(format, args...) }
+}
+
+func (l *TeeLogger) Debug(args ...interface{}) {
+	l.emit(DebugLevel, sprint(args))
+	l.CryoLogger.Debug(args...)
+}
+
+func (l *TeeLogger) Info(args ...interface{}) {
+	l.emit(InfoLevel, sprint(args))
+	l.CryoLogger.Info(args...)
+}
+
+func (l *TeeLogger) Success(args ...interface{}) {
+	l.emit(SuccessLevel, sprint(args))
+	l.CryoLogger.Success(args...)
+}
+
+func (l *TeeLogger) Warn(args ...interface{}) {
+	l.emit(WarnLevel, sprint(args))
+	l.CryoLogger.Warn(args...)
+}
+
+func (l *TeeLogger) Error(args ...interface{}) {
+	l.emit(ErrorLevel, sprint(args))
+	l.CryoLogger.Error(args...)
+}
+
+func (l *TeeLogger) Fatal(args ...interface{}) {
+	l.emit(FatalLevel, sprint(args))
+	l.CryoLogger.Fatal(args...)
+}
+
+func (l *TeeLogger) Panic(args ...interface{}) {
+	l.emit(PanicLevel, sprint(args))
+	l.CryoLogger.Panic(args...)
+}
+
+func (l *TeeLogger) Debugf(format string, args ...interface{}) {
+	l.emit(DebugLevel, sprintf(format, args))
+	l.CryoLogger.Debugf(format, args...)
+}
+
+func (l *TeeLogger) Infof(format string, args ...interface{}) {
+	l.emit(InfoLevel, sprintf(format, args))
+	l.CryoLogger.Infof(format, args...)
+}
+
+func (l *TeeLogger) Successf(format string, args ...interface{}) {
+	l.emit(SuccessLevel, sprintf(format, args))
+	l.CryoLogger.Successf(format, args...)
+}
+
+func (l *TeeLogger) Warnf(format string, args ...interface{}) {
+	l.emit(WarnLevel, sprintf(format, args))
+	l.CryoLogger.Warnf(format, args...)
+}
+
+func (l *TeeLogger) Errorf(format string, args ...interface{}) {
+	l.emit(ErrorLevel, sprintf(format, args))
+	l.CryoLogger.Errorf(format, args...)
+}
+
+func (l *TeeLogger) Fatalf(format string, args ...interface{}) {
+	l.emit(FatalLevel, sprintf(format, args))
+	l.CryoLogger.Fatalf(format, args...)
+}
+
+func (l *TeeLogger) Panicf(format string, args ...interface{}) {
+	l.emit(PanicLevel, sprintf(format, args))
+	l.CryoLogger.Panicf(format, args...)
+}
+
+func (l *TeeLogger) Print(args ...interface{}) {
+	l.emit(InfoLevel, sprint(args))
+	l.CryoLogger.Print(args...)
+}
+
+func (l *TeeLogger) Printf(format string, args ...interface{}) {
+	l.emit(InfoLevel, sprintf(format, args))
+	l.CryoLogger.Printf(format, args...)
+}
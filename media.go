@@ -0,0 +1,201 @@
+package cryo
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+)
+
+var (
+	// MediaHTTPClient 从链接获取图片等媒体文件时使用的HTTP客户端，可以替换成自定义的客户端来设置代理等
+	MediaHTTPClient = &http.Client{Timeout: 30 * time.Second}
+	// MaxMediaSize 从链接获取的媒体文件的最大字节数，小于等于0时不限制
+	MaxMediaSize int64 = 30 << 20
+	// MediaUploadCacheSize 每个客户端最多缓存的媒体上传记录数，小于等于0时不缓存
+	MediaUploadCacheSize = 512
+)
+
+// ErrNotImage 获取到的内容不是图片
+var ErrNotImage = errors.New("内容不是图片")
+
+// fetchImage 从链接获取图片数据，并检查内容是否真的是图片
+func fetchImage(ctx context.Context, url string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if _, err := downloadTo(ctx, MediaHTTPClient, url, buf, MaxMediaSize); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	if !strings.HasPrefix(http.DetectContentType(data), "image/") {
+		return nil, ErrNotImage
+	}
+	return data, nil
+}
+
+// fetchURLImages 下载消息中只有链接还没有数据的图片，例如通过 ParseMessage 构造的图片
+func fetchURLImages(ctx context.Context, msg Message) error {
+	for _, e := range msg {
+		img, ok := e.(*Image)
+		if !ok || img.MsgInfo != nil || img.Stream != nil || img.URL == "" {
+			continue
+		}
+		data, err := fetchImage(ctx, img.URL)
+		if err != nil {
+			return err
+		}
+		img.ImageElement = *lgrmessage.NewImage(data, img.Summary)
+	}
+	return nil
+}
+
+// mediaCache 记录已经上传过的媒体元素，再次发送相同内容时直接复用上传结果
+type mediaCache struct {
+	mutex    sync.Mutex
+	capacity int
+	order    *list.List // 最近使用的记录在最前面
+	items    map[string]*list.Element
+}
+
+type mediaCacheEntry struct {
+	key     string
+	element lgrmessage.IMessageElement
+}
+
+// newMediaCache 创建一个新的媒体上传缓存，capacity 小于等于0时返回nil
+func newMediaCache(capacity int) *mediaCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &mediaCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (mc *mediaCache) get(key string) lgrmessage.IMessageElement {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	if e, ok := mc.items[key]; ok {
+		mc.order.MoveToFront(e)
+		return e.Value.(*mediaCacheEntry).element
+	}
+	return nil
+}
+
+func (mc *mediaCache) put(key string, element lgrmessage.IMessageElement) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	if e, ok := mc.items[key]; ok {
+		e.Value.(*mediaCacheEntry).element = element
+		mc.order.MoveToFront(e)
+		return
+	}
+	mc.items[key] = mc.order.PushFront(&mediaCacheEntry{key: key, element: element})
+	for mc.order.Len() > mc.capacity {
+		last := mc.order.Back()
+		mc.order.Remove(last)
+		delete(mc.items, last.Value.(*mediaCacheEntry).key)
+	}
+}
+
+// mediaCacheKey 根据发送目标和媒体内容的哈希计算缓存的键，不能缓存的元素返回空字符串
+func mediaCacheKey(target SendTarget, e MessageElement) string {
+	var sha1 []byte
+	switch v := e.(type) {
+	case *Image:
+		sha1 = v.Sha1
+	case *Voice:
+		sha1 = v.Sha1
+	case *ShortVideo:
+		sha1 = v.Sha1
+	}
+	if len(sha1) == 0 {
+		return ""
+	}
+	return hex.EncodeToString(sha1) + ":" + strconv.FormatUint(target.key(), 16)
+}
+
+// useUploadCache 用缓存中的上传结果替换消息中还没有上传的媒体元素
+func (c *LagrangeClient) useUploadCache(target SendTarget, msg Message) {
+	if c.mediaCache == nil || target.Type == TempTarget {
+		return
+	}
+	for _, e := range msg {
+		key := mediaCacheKey(target, e)
+		if key == "" {
+			continue
+		}
+		cached := c.mediaCache.get(key)
+		if cached == nil {
+			continue
+		}
+		switch v := e.(type) {
+		case *Image:
+			if u, ok := cached.(*lgrmessage.ImageElement); ok && v.MsgInfo == nil {
+				summary := v.Summary
+				v.ImageElement = *u
+				v.Summary = summary
+			}
+		case *Voice:
+			if u, ok := cached.(*lgrmessage.VoiceElement); ok && v.MsgInfo == nil {
+				summary := v.Summary
+				v.VoiceElement = *u
+				v.Summary = summary
+			}
+		case *ShortVideo:
+			if u, ok := cached.(*lgrmessage.ShortVideoElement); ok && v.MsgInfo == nil {
+				summary := v.Summary
+				v.ShortVideoElement = *u
+				v.Summary = summary
+			}
+		}
+	}
+}
+
+// saveUploadCache 记录消息中已经上传完成的媒体元素
+func (c *LagrangeClient) saveUploadCache(target SendTarget, msg Message) {
+	if c.mediaCache == nil || target.Type == TempTarget {
+		return
+	}
+	for _, e := range msg {
+		key := mediaCacheKey(target, e)
+		if key == "" {
+			continue
+		}
+		// 缓存的是元素的副本，不保留原始的数据流
+		switch v := e.(type) {
+		case *Image:
+			if v.MsgInfo != nil {
+				u := v.ImageElement
+				u.Stream = nil
+				c.mediaCache.put(key, &u)
+			}
+		case *Voice:
+			if v.MsgInfo != nil {
+				u := v.VoiceElement
+				u.Stream = nil
+				c.mediaCache.put(key, &u)
+			}
+		case *ShortVideo:
+			if v.MsgInfo != nil {
+				u := v.ShortVideoElement
+				u.Stream = nil
+				if u.Thumb != nil {
+					thumb := *u.Thumb
+					thumb.Stream = nil
+					u.Thumb = &thumb
+				}
+				c.mediaCache.put(key, &u)
+			}
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package cryo
+
+import (
+	"container/list"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// MaxDownloadSize 下载收到的图片、语音、视频和文件时允许的最大字节数，小于等于0时不限制
+	MaxDownloadSize int64 = 100 << 20
+	// DownloadTimeout 下载收到的媒体文件时的超时时间，小于等于0时不限制，也可以通过ctx控制
+	DownloadTimeout = 60 * time.Second
+)
+
+// ErrMediaURLUnavailable 无法获取媒体元素的下载链接，一般是因为元素不是从事件中收到的
+var ErrMediaURLUnavailable = errors.New("无法获取媒体文件的下载链接")
+
+// downloadCache 全局的下载磁盘缓存，没有启用时为nil
+var downloadCache atomic.Pointer[diskCache]
+
+// mediaOwner 记录收到的媒体元素属于哪个客户端和哪个群，用于在需要时获取下载链接
+type mediaOwner struct {
+	client   *LagrangeClient
+	groupUin uint32 // 私聊和临时会话为0
+}
+
+// setMediaOwner 给消息中的媒体元素设置所属的客户端和群
+func (m *Message) setMediaOwner(c *LagrangeClient, groupUin uint32) {
+	owner := mediaOwner{client: c, groupUin: groupUin}
+	for _, e := range *m {
+		switch v := e.(type) {
+		case *Image:
+			v.owner = owner
+		case *Voice:
+			v.owner = owner
+		case *ShortVideo:
+			v.owner = owner
+		case *File:
+			v.owner = owner
+		}
+	}
+}
+
+// GetDownloadURL 获取图片的下载链接
+func (e *Image) GetDownloadURL() (string, error) {
+	if e.URL != "" {
+		return e.URL, nil
+	}
+	c := e.owner.client
+	if c == nil || e.MsgInfo == nil || len(e.MsgInfo.MsgInfoBody) == 0 {
+		return "", ErrMediaURLUnavailable
+	}
+	node := e.MsgInfo.MsgInfoBody[0].Index
+	var url string
+	var err error
+	if e.owner.groupUin != 0 {
+		url, err = c.protocol.GetGroupImageURL(e.owner.groupUin, node)
+	} else {
+		url, err = c.protocol.GetPrivateImageURL(node)
+	}
+	if err != nil {
+		return "", newActionError("get_image_url", e.owner.groupUin, 0, nil, err)
+	}
+	e.URL = url
+	return url, nil
+}
+
+// GetDownloadURL 获取语音的下载链接
+func (e *Voice) GetDownloadURL() (string, error) {
+	if e.URL != "" {
+		return e.URL, nil
+	}
+	c := e.owner.client
+	if c == nil || e.Node == nil {
+		return "", ErrMediaURLUnavailable
+	}
+	var url string
+	var err error
+	if e.owner.groupUin != 0 {
+		url, err = c.protocol.GetGroupRecordURL(e.owner.groupUin, e.Node)
+	} else {
+		url, err = c.protocol.GetPrivateRecordURL(e.Node)
+	}
+	if err != nil {
+		return "", newActionError("get_record_url", e.owner.groupUin, 0, nil, err)
+	}
+	e.URL = url
+	return url, nil
+}
+
+// GetDownloadURL 获取短视频的下载链接
+func (e *ShortVideo) GetDownloadURL() (string, error) {
+	if e.URL != "" {
+		return e.URL, nil
+	}
+	c := e.owner.client
+	if c == nil || e.Node == nil {
+		return "", ErrMediaURLUnavailable
+	}
+	var url string
+	var err error
+	if e.owner.groupUin != 0 {
+		url, err = c.protocol.GetGroupVideoURL(e.owner.groupUin, e.Node)
+	} else {
+		url, err = c.protocol.GetPrivateVideoURL(e.Node)
+	}
+	if err != nil {
+		return "", newActionError("get_video_url", e.owner.groupUin, 0, nil, err)
+	}
+	e.URL = url
+	return url, nil
+}
+
+// GetDownloadURL 获取文件的下载链接
+func (e *File) GetDownloadURL() (string, error) {
+	if e.FileURL != "" {
+		return e.FileURL, nil
+	}
+	if e.owner.client == nil {
+		return "", ErrMediaURLUnavailable
+	}
+	url, err := e.owner.client.GetFileURL(e.owner.groupUin, e)
+	if err != nil {
+		return "", err
+	}
+	e.FileURL = url
+	return url, nil
+}
+
+// Open 打开图片的数据流，使用完毕后需要关闭
+func (e *Image) Open(ctx context.Context) (io.ReadCloser, error) {
+	return openMedia(ctx, mediaKey("image", e.Sha1, e.Md5, e.ImageID), e.GetDownloadURL)
+}
+
+// Download 下载图片的全部数据
+func (e *Image) Download(ctx context.Context) ([]byte, error) {
+	return readMedia(e.Open(ctx))
+}
+
+// Open 打开语音的数据流，使用完毕后需要关闭
+func (e *Voice) Open(ctx context.Context) (io.ReadCloser, error) {
+	return openMedia(ctx, mediaKey("voice", e.Sha1, e.Md5, e.UUID), e.GetDownloadURL)
+}
+
+// Download 下载语音的全部数据
+func (e *Voice) Download(ctx context.Context) ([]byte, error) {
+	return readMedia(e.Open(ctx))
+}
+
+// Open 打开短视频的数据流，使用完毕后需要关闭
+func (e *ShortVideo) Open(ctx context.Context) (io.ReadCloser, error) {
+	return openMedia(ctx, mediaKey("video", e.Sha1, e.Md5, e.UUID), e.GetDownloadURL)
+}
+
+// Download 下载短视频的全部数据
+func (e *ShortVideo) Download(ctx context.Context) ([]byte, error) {
+	return readMedia(e.Open(ctx))
+}
+
+// Open 打开文件的数据流，使用完毕后需要关闭
+func (e *File) Open(ctx context.Context) (io.ReadCloser, error) {
+	id := e.FileID
+	if id == "" {
+		id = e.FileUUID
+	}
+	return openMedia(ctx, mediaKey("file", e.FileSha1, e.FileMd5, id), e.GetDownloadURL)
+}
+
+// Download 下载文件的全部数据
+func (e *File) Download(ctx context.Context) ([]byte, error) {
+	return readMedia(e.Open(ctx))
+}
+
+// mediaKey 计算媒体在磁盘缓存中的键，优先使用内容的哈希，没有可用的标识时返回空字符串
+func mediaKey(kind string, sha1, md5 []byte, id string) string {
+	switch {
+	case len(sha1) > 0:
+		return kind + "-" + hex.EncodeToString(sha1)
+	case len(md5) > 0:
+		return kind + "-" + hex.EncodeToString(md5)
+	case id != "":
+		return kind + "-" + hex.EncodeToString([]byte(id))
+	}
+	return ""
+}
+
+// readMedia 读取并关闭数据流
+func readMedia(rc io.ReadCloser, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// openMedia 打开媒体文件的数据流，启用了磁盘缓存时会先下载到缓存中
+func openMedia(ctx context.Context, key string, resolve func() (string, error)) (io.ReadCloser, error) {
+	dc := downloadCache.Load()
+	if dc != nil && key != "" {
+		if f := dc.open(key); f != nil {
+			return f, nil
+		}
+	}
+	url, err := resolve()
+	if err != nil {
+		return nil, err
+	}
+	var cancel context.CancelFunc
+	if DownloadTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, DownloadTimeout)
+	}
+	body, err := openURL(ctx, MediaHTTPClient, url, MaxDownloadSize)
+	if err != nil {
+		if cancel != nil {
+			cancel()
+		}
+		return nil, err
+	}
+	body.cancel = cancel
+	if dc == nil || key == "" {
+		return body, nil
+	}
+	defer body.Close()
+	if err = dc.store(key, body); err != nil {
+		return nil, err
+	}
+	if f := dc.open(key); f != nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("读取下载缓存 %s 失败", key)
+}
+
+// limitedBody 是带有大小限制的HTTP响应体
+type limitedBody struct {
+	body    io.ReadCloser
+	maxSize int64
+	read    int64
+	cancel  context.CancelFunc
+}
+
+// Read 读取响应体，超出大小限制时返回 ErrFileTooLarge
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.maxSize > 0 && b.read > b.maxSize {
+		return 0, ErrFileTooLarge
+	}
+	n, err := b.body.Read(p)
+	b.read += int64(n)
+	if b.maxSize > 0 && b.read > b.maxSize {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
+
+// Close 关闭响应体
+func (b *limitedBody) Close() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	return b.body.Close()
+}
+
+// openURL 请求链接并返回带有大小限制的响应体，maxSize 小于等于0时不限制大小
+func openURL(ctx context.Context, httpClient *http.Client, url string, maxSize int64) (*limitedBody, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("下载 %s 失败：%s", url, resp.Status)
+	}
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		resp.Body.Close()
+		return nil, ErrFileTooLarge
+	}
+	return &limitedBody{body: resp.Body, maxSize: maxSize}, nil
+}
+
+// SetDownloadCache 启用下载的磁盘缓存，缓存总大小超出 maxSize 时会删除最久没有使用的文件
+//
+// dir 为空或 maxSize 小于等于0时关闭磁盘缓存，已经缓存的文件不会被删除
+func SetDownloadCache(dir string, maxSize int64) error {
+	if dir == "" || maxSize <= 0 {
+		downloadCache.Store(nil)
+		return nil
+	}
+	dc, err := newDiskCache(dir, maxSize)
+	if err != nil {
+		return err
+	}
+	downloadCache.Store(dc)
+	return nil
+}
+
+// diskCache 按最近使用时间淘汰的磁盘缓存
+type diskCache struct {
+	mutex   sync.Mutex
+	dir     string
+	maxSize int64
+	size    int64
+	order   *list.List // 最近使用的文件在最前面
+	items   map[string]*list.Element
+}
+
+type diskCacheEntry struct {
+	key  string
+	size int64
+}
+
+// newDiskCache 创建磁盘缓存，并按修改时间载入目录中已有的缓存文件
+func newDiskCache(dir string, maxSize int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	dc := &diskCache{
+		dir:     dir,
+		maxSize: maxSize,
+		order:   list.New(),
+		items:   make(map[string]*list.Element),
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type existing struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var files []existing
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || filepath.Ext(entry.Name()) == ".tmp" {
+			continue
+		}
+		files = append(files, existing{entry.Name(), info.Size(), info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		dc.items[f.key] = dc.order.PushFront(&diskCacheEntry{key: f.key, size: f.size})
+		dc.size += f.size
+	}
+	dc.mutex.Lock()
+	dc.evict()
+	dc.mutex.Unlock()
+	return dc, nil
+}
+
+// open 打开缓存的文件，不存在时返回nil
+func (dc *diskCache) open(key string) *os.File {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	e, ok := dc.items[key]
+	if !ok {
+		return nil
+	}
+	p := filepath.Join(dc.dir, key)
+	f, err := os.Open(p)
+	if err != nil {
+		dc.remove(e)
+		return nil
+	}
+	dc.order.MoveToFront(e)
+	now := time.Now()
+	_ = os.Chtimes(p, now, now) // 更新修改时间，重启后仍然可以按使用时间淘汰
+	return f
+}
+
+// store 将数据写入缓存
+func (dc *diskCache) store(key string, r io.Reader) error {
+	tmp, err := os.CreateTemp(dc.dir, "download-*.tmp")
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dc.dir, key))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	if e, ok := dc.items[key]; ok {
+		dc.size -= e.Value.(*diskCacheEntry).size
+		dc.order.Remove(e)
+	}
+	dc.items[key] = dc.order.PushFront(&diskCacheEntry{key: key, size: n})
+	dc.size += n
+	dc.evict()
+	return nil
+}
+
+// evict 删除最久没有使用的文件直到总大小不超过限制，最新的文件总是会被保留，调用时需要持有锁
+func (dc *diskCache) evict() {
+	for dc.size > dc.maxSize && dc.order.Len() > 1 {
+		dc.remove(dc.order.Back())
+	}
+}
+
+// remove 删除一个缓存文件，调用时需要持有锁
+func (dc *diskCache) remove(e *list.Element) {
+	entry := e.Value.(*diskCacheEntry)
+	dc.order.Remove(e)
+	delete(dc.items, entry.key)
+	dc.size -= entry.size
+	_ = os.Remove(filepath.Join(dc.dir, entry.key))
+}
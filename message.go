@@ -1,6 +1,7 @@
 package cryo
 
 import (
+	"context"
 	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
 	"io"
 )
@@ -57,13 +58,13 @@ func (m *Message) AddIMessageElement(e ...lgrmessage.IMessageElement) *Message {
 		case *lgrmessage.ReplyElement:
 			*m = append(*m, &Reply{*element.(*lgrmessage.ReplyElement)})
 		case *lgrmessage.VoiceElement:
-			*m = append(*m, &Voice{*element.(*lgrmessage.VoiceElement)})
+			*m = append(*m, &Voice{VoiceElement: *element.(*lgrmessage.VoiceElement)})
 		case *lgrmessage.ImageElement:
-			*m = append(*m, &Image{*element.(*lgrmessage.ImageElement)})
+			*m = append(*m, &Image{ImageElement: *element.(*lgrmessage.ImageElement)})
 		case *lgrmessage.FileElement:
-			*m = append(*m, &File{*element.(*lgrmessage.FileElement)})
+			*m = append(*m, &File{FileElement: *element.(*lgrmessage.FileElement)})
 		case *lgrmessage.ShortVideoElement:
-			*m = append(*m, &ShortVideo{*element.(*lgrmessage.ShortVideoElement)})
+			*m = append(*m, &ShortVideo{ShortVideoElement: *element.(*lgrmessage.ShortVideoElement)})
 		case *lgrmessage.LightAppElement:
 			*m = append(*m, &LightApp{*element.(*lgrmessage.LightAppElement)})
 		case *lgrmessage.XMLElement:
@@ -124,13 +125,13 @@ func (m *Message) AddReplyDirect(replySeq uint32, senderUin uint32, time uint32,
 
 // AddImage 添加图片消息元素
 func (m *Message) AddImage(imgData []byte, summary ...string) *Message {
-	*m = append(*m, &Image{*lgrmessage.NewImage(imgData, summary...)})
+	*m = append(*m, &Image{ImageElement: *lgrmessage.NewImage(imgData, summary...)})
 	return m
 }
 
 // AddImageStream 添加图片消息元素，使用io.ReadSeeker
 func (m *Message) AddImageStream(r io.ReadSeeker, summary ...string) *Message {
-	*m = append(*m, &Image{*lgrmessage.NewStreamImage(r, summary...)})
+	*m = append(*m, &Image{ImageElement: *lgrmessage.NewStreamImage(r, summary...)})
 	return m
 }
 
@@ -140,6 +141,88 @@ func (m *Message) AddImageFile(filePath string, summary ...string) (*Message, er
 	if err != nil {
 		return nil, err
 	}
-	*m = append(*m, &Image{*imgElement})
+	*m = append(*m, &Image{ImageElement: *imgElement})
+	return m, nil
+}
+
+// AddImageURL 添加图片消息元素，图片会立即从链接下载，使用 MediaHTTPClient 和 MaxMediaSize 的设置
+//
+// 下载到的内容不是图片时返回 ErrNotImage
+func (m *Message) AddImageURL(url string, summary ...string) (*Message, error) {
+	return m.AddImageURLContext(context.Background(), url, summary...)
+}
+
+// AddImageURLContext 添加图片消息元素，可以通过ctx取消下载
+func (m *Message) AddImageURLContext(ctx context.Context, url string, summary ...string) (*Message, error) {
+	data, err := fetchImage(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return m.AddImage(data, summary...), nil
+}
+
+// AddVoice 添加语音消息元素，语音数据需要是silk或amr格式
+func (m *Message) AddVoice(voiceData []byte, summary ...string) *Message {
+	*m = append(*m, &Voice{VoiceElement: *lgrmessage.NewRecord(voiceData, summary...)})
+	return m
+}
+
+// AddVoiceStream 添加语音消息元素，使用io.ReadSeeker
+func (m *Message) AddVoiceStream(r io.ReadSeeker, summary ...string) *Message {
+	*m = append(*m, &Voice{VoiceElement: *lgrmessage.NewStreamRecord(r, summary...)})
+	return m
+}
+
+// AddVoiceFile 添加语音消息元素，使用文件路径
+func (m *Message) AddVoiceFile(filePath string, summary ...string) (*Message, error) {
+	voiceElement, err := lgrmessage.NewFileRecord(filePath, summary...)
+	if err != nil {
+		return nil, err
+	}
+	*m = append(*m, &Voice{VoiceElement: *voiceElement})
+	return m, nil
+}
+
+// AddVideo 添加短视频消息元素，thumb 是视频的封面图片
+func (m *Message) AddVideo(videoData, thumb []byte, summary ...string) *Message {
+	*m = append(*m, &ShortVideo{ShortVideoElement: *lgrmessage.NewVideo(videoData, thumb, summary...)})
+	return m
+}
+
+// AddVideoStream 添加短视频消息元素，使用io.ReadSeeker
+func (m *Message) AddVideoStream(r io.ReadSeeker, thumb io.ReadSeeker, summary ...string) *Message {
+	*m = append(*m, &ShortVideo{ShortVideoElement: *lgrmessage.NewStreamVideo(r, thumb, summary...)})
+	return m
+}
+
+// AddVideoFile 添加短视频消息元素，使用文件路径
+func (m *Message) AddVideoFile(filePath string, thumb []byte, summary ...string) (*Message, error) {
+	videoElement, err := lgrmessage.NewFileVideo(filePath, thumb, summary...)
+	if err != nil {
+		return nil, err
+	}
+	*m = append(*m, &ShortVideo{ShortVideoElement: *videoElement})
+	return m, nil
+}
+
+// AddFile 添加文件消息元素，文件会在发送时单独上传，临时会话中不能发送文件
+func (m *Message) AddFile(data []byte, fileName string) *Message {
+	*m = append(*m, NewFile(data, fileName))
+	return m
+}
+
+// AddFileStream 添加文件消息元素，使用io.ReadSeeker
+func (m *Message) AddFileStream(r io.ReadSeeker, fileName string) *Message {
+	*m = append(*m, NewFileStream(r, fileName))
+	return m
+}
+
+// AddLocalFile 添加文件消息元素，使用本地文件路径，不传入文件名时使用本地文件的文件名
+func (m *Message) AddLocalFile(filePath string, fileName ...string) (*Message, error) {
+	f, err := NewLocalFile(filePath, fileName...)
+	if err != nil {
+		return nil, err
+	}
+	*m = append(*m, f)
 	return m, nil
 }
@@ -0,0 +1,328 @@
+package cryo
+
+import (
+	"errors"
+	"fmt"
+
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// jsonElement 消息元素的JSON格式，type 和 CQ码的类型名称保持一致
+type jsonElement struct {
+	Type string         `json:"type"`
+	Data jsontext.Value `json:"data,omitzero,omitempty"`
+}
+
+type (
+	jsonText struct {
+		Text string `json:"text"`
+	}
+	jsonAt struct {
+		Uin  uint32 `json:"qq,omitzero,omitempty"` // 0表示全体成员
+		Uid  string `json:"uid,omitzero,omitempty"`
+		Name string `json:"name,omitzero,omitempty"`
+	}
+	jsonFace struct {
+		Id       uint32 `json:"id"`
+		ResultId uint32 `json:"result_id,omitzero,omitempty"`
+	}
+	jsonReply struct {
+		Seq       uint32  `json:"id"`
+		SenderUin uint32  `json:"qq,omitzero,omitempty"`
+		SenderUid string  `json:"uid,omitzero,omitempty"`
+		GroupUin  uint32  `json:"group,omitzero,omitempty"`
+		Time      uint32  `json:"time,omitzero,omitempty"`
+		Message   Message `json:"message,omitzero,omitempty"`
+	}
+	jsonImage struct {
+		Id      string `json:"file,omitzero,omitempty"`
+		URL     string `json:"url,omitzero,omitempty"`
+		Summary string `json:"summary,omitzero,omitempty"`
+		Size    uint32 `json:"size,omitzero,omitempty"`
+		Width   uint32 `json:"width,omitzero,omitempty"`
+		Height  uint32 `json:"height,omitzero,omitempty"`
+		SubType int32  `json:"sub_type,omitzero,omitempty"`
+		Flash   bool   `json:"flash,omitzero,omitempty"`
+		IsGroup bool   `json:"is_group,omitzero,omitempty"`
+		Md5     []byte `json:"md5,omitzero,omitempty"`
+		Sha1    []byte `json:"sha1,omitzero,omitempty"`
+	}
+	jsonMedia struct {
+		UUID     string `json:"file,omitzero,omitempty"`
+		Name     string `json:"name,omitzero,omitempty"`
+		URL      string `json:"url,omitzero,omitempty"`
+		Summary  string `json:"summary,omitzero,omitempty"`
+		Size     uint32 `json:"size,omitzero,omitempty"`
+		Duration uint32 `json:"duration,omitzero,omitempty"`
+		Md5      []byte `json:"md5,omitzero,omitempty"`
+		Sha1     []byte `json:"sha1,omitzero,omitempty"`
+	}
+	jsonFile struct {
+		Id   string `json:"id,omitzero,omitempty"`
+		UUID string `json:"uuid,omitzero,omitempty"`
+		Name string `json:"name,omitzero,omitempty"`
+		Size uint64 `json:"size,omitzero,omitempty"`
+		URL  string `json:"url,omitzero,omitempty"`
+		Hash string `json:"hash,omitzero,omitempty"`
+		Md5  []byte `json:"md5,omitzero,omitempty"`
+		Sha1 []byte `json:"sha1,omitzero,omitempty"`
+	}
+	jsonForward struct {
+		ResId   string             `json:"id,omitzero,omitempty"`
+		IsGroup bool               `json:"is_group,omitzero,omitempty"`
+		Nodes   []*jsonForwardNode `json:"nodes,omitzero,omitempty"`
+	}
+	jsonForwardNode struct {
+		GroupUin   uint32  `json:"group,omitzero,omitempty"`
+		SenderUin  uint32  `json:"qq,omitzero,omitempty"`
+		SenderName string  `json:"name,omitzero,omitempty"`
+		Time       uint32  `json:"time,omitzero,omitempty"`
+		Message    Message `json:"message,omitzero,omitempty"`
+	}
+	jsonLightApp struct {
+		AppName string `json:"app,omitzero,omitempty"`
+		Content string `json:"data"`
+	}
+	jsonXML struct {
+		ServiceId int    `json:"id,omitzero,omitempty"`
+		Content   string `json:"data"`
+	}
+	jsonMarketFace struct {
+		Summary    string `json:"summary,omitzero,omitempty"`
+		ItemType   uint32 `json:"item_type,omitzero,omitempty"`
+		FaceInfo   uint32 `json:"face_info,omitzero,omitempty"`
+		FaceId     []byte `json:"face_id,omitzero,omitempty"`
+		TabId      uint32 `json:"tab_id,omitzero,omitempty"`
+		SubType    uint32 `json:"sub_type,omitzero,omitempty"`
+		EncryptKey []byte `json:"encrypt_key,omitzero,omitempty"`
+		MediaType  uint32 `json:"media_type,omitzero,omitempty"`
+		MagicValue string `json:"magic_value,omitzero,omitempty"`
+	}
+)
+
+// MarshalJSON 将消息序列化为JSON数组，每个元素的格式为 {"type": "...", "data": {...}}
+//
+// 媒体元素只保留元数据和链接，不包含原始数据
+func (m Message) MarshalJSON() ([]byte, error) {
+	elements := make([]jsonElement, 0, len(m))
+	for _, e := range m {
+		typ, data := elementToJSON(e)
+		if typ == "" {
+			continue
+		}
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, jsonElement{Type: typ, Data: raw})
+	}
+	return json.Marshal(elements)
+}
+
+// UnmarshalJSON 从 MarshalJSON 生成的JSON数组还原消息
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var elements []jsonElement
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return err
+	}
+	result := make(Message, 0, len(elements))
+	for _, je := range elements {
+		e, err := elementFromJSON(je)
+		if err != nil {
+			return err
+		}
+		result = append(result, e)
+	}
+	*m = result
+	return nil
+}
+
+// elementToJSON 获取消息元素的类型名称和对应的JSON结构
+func elementToJSON(e MessageElement) (string, any) {
+	switch v := e.(type) {
+	case *Text:
+		return "text", &jsonText{Text: v.Content}
+	case *At:
+		return "at", &jsonAt{Uin: v.TargetUin, Uid: v.TargetUID, Name: v.Display}
+	case *Face:
+		return "face", &jsonFace{Id: v.FaceID, ResultId: v.ResultID}
+	case *Reply:
+		m := Message{}
+		m.AddIMessageElement(v.Elements...)
+		return "reply", &jsonReply{Seq: v.ReplySeq, SenderUin: v.SenderUin, SenderUid: v.SenderUID, GroupUin: v.GroupUin, Time: v.Time, Message: m}
+	case *Image:
+		return "image", &jsonImage{
+			Id: v.ImageID, URL: v.URL, Summary: v.Summary, Size: v.Size, Width: v.Width, Height: v.Height,
+			SubType: v.SubType, Flash: v.Flash, IsGroup: v.IsGroup, Md5: v.Md5, Sha1: v.Sha1,
+		}
+	case *Voice:
+		return "record", &jsonMedia{
+			UUID: v.UUID, Name: v.Name, URL: v.URL, Summary: v.Summary, Size: v.Size, Duration: v.Duration, Md5: v.Md5, Sha1: v.Sha1,
+		}
+	case *ShortVideo:
+		return "video", &jsonMedia{
+			UUID: v.UUID, Name: v.Name, URL: v.URL, Summary: v.Summary, Size: v.Size, Duration: v.Duration, Md5: v.Md5, Sha1: v.Sha1,
+		}
+	case *File:
+		return "file", &jsonFile{
+			Id: v.FileID, UUID: v.FileUUID, Name: v.FileName, Size: v.FileSize, URL: v.FileURL, Hash: v.FileHash, Md5: v.FileMd5, Sha1: v.FileSha1,
+		}
+	case *ForwardMessage:
+		f := &jsonForward{ResId: v.ResID, IsGroup: v.IsGroup}
+		for _, n := range v.GetNodes() {
+			f.Nodes = append(f.Nodes, &jsonForwardNode{GroupUin: n.GroupUin, SenderUin: n.SenderUin, SenderName: n.SenderName, Time: n.Time, Message: n.Message})
+		}
+		return "forward", f
+	case *LightApp:
+		return "json", &jsonLightApp{AppName: v.AppName, Content: v.Content}
+	case *XML:
+		return "xml", &jsonXML{ServiceId: v.ServiceID, Content: v.Content}
+	case *MarketFace:
+		return "mface", &jsonMarketFace{
+			Summary: v.Summary, ItemType: v.ItemType, FaceInfo: v.FaceInfo, FaceId: v.FaceID, TabId: v.TabID,
+			SubType: v.SubType, EncryptKey: v.EncryptKey, MediaType: v.MediaType, MagicValue: v.MagicValue,
+		}
+	}
+	return "", nil
+}
+
+// elementFromJSON 根据类型名称还原消息元素
+func elementFromJSON(je jsonElement) (MessageElement, error) {
+	unmarshal := func(v any) error {
+		if len(je.Data) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(je.Data, v); err != nil {
+			return fmt.Errorf("解析 %s 消息元素失败：%w", je.Type, err)
+		}
+		return nil
+	}
+	switch je.Type {
+	case "text":
+		var v jsonText
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		return &Text{lgrmessage.TextElement{Content: v.Text}}, nil
+	case "at":
+		var v jsonAt
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		return &At{lgrmessage.AtElement{TargetUin: v.Uin, TargetUID: v.Uid, Display: v.Name}}, nil
+	case "face":
+		var v jsonFace
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		// 超级表情等标志位没有导出，这里只还原表情ID和结果值
+		face := lgrmessage.NewFace(v.Id)
+		face.ResultID = v.ResultId
+		return &Face{*face}, nil
+	case "reply":
+		var v jsonReply
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		return &Reply{lgrmessage.ReplyElement{
+			ReplySeq: v.Seq, SenderUin: v.SenderUin, SenderUID: v.SenderUid, GroupUin: v.GroupUin, Time: v.Time,
+			Elements: v.Message.ToIMessageElements(),
+		}}, nil
+	case "image":
+		var v jsonImage
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		return &Image{ImageElement: lgrmessage.ImageElement{
+			ImageID: v.Id, URL: v.URL, Summary: v.Summary, Size: v.Size, Width: v.Width, Height: v.Height,
+			SubType: v.SubType, Flash: v.Flash, IsGroup: v.IsGroup, Md5: v.Md5, Sha1: v.Sha1,
+		}}, nil
+	case "record":
+		var v jsonMedia
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		return &Voice{VoiceElement: lgrmessage.VoiceElement{
+			UUID: v.UUID, Name: v.Name, URL: v.URL, Summary: v.Summary, Size: v.Size, Duration: v.Duration, Md5: v.Md5, Sha1: v.Sha1,
+		}}, nil
+	case "video":
+		var v jsonMedia
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		return &ShortVideo{ShortVideoElement: lgrmessage.ShortVideoElement{
+			UUID: v.UUID, Name: v.Name, URL: v.URL, Summary: v.Summary, Size: v.Size, Duration: v.Duration, Md5: v.Md5, Sha1: v.Sha1,
+		}}, nil
+	case "file":
+		var v jsonFile
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		return &File{FileElement: lgrmessage.FileElement{
+			FileID: v.Id, FileUUID: v.UUID, FileName: v.Name, FileSize: v.Size, FileURL: v.URL, FileHash: v.Hash, FileMd5: v.Md5, FileSha1: v.Sha1,
+		}}, nil
+	case "forward":
+		var v jsonForward
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		f := &ForwardMessage{lgrmessage.ForwardMessage{ResID: v.ResId, IsGroup: v.IsGroup}}
+		for _, n := range v.Nodes {
+			f.Nodes = append(f.Nodes, (&ForwardNode{
+				GroupUin: n.GroupUin, SenderUin: n.SenderUin, SenderName: n.SenderName, Time: n.Time, Message: n.Message,
+			}).toLgrNode())
+		}
+		return f, nil
+	case "json":
+		var v jsonLightApp
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		return &LightApp{lgrmessage.LightAppElement{AppName: v.AppName, Content: v.Content}}, nil
+	case "xml":
+		var v jsonXML
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		return &XML{lgrmessage.XMLElement{ServiceID: v.ServiceId, Content: v.Content}}, nil
+	case "mface":
+		var v jsonMarketFace
+		if err := unmarshal(&v); err != nil {
+			return nil, err
+		}
+		return &MarketFace{lgrmessage.MarketFaceElement{
+			Summary: v.Summary, ItemType: v.ItemType, FaceInfo: v.FaceInfo, FaceID: v.FaceId, TabID: v.TabId,
+			SubType: v.SubType, EncryptKey: v.EncryptKey, MediaType: v.MediaType, MagicValue: v.MagicValue,
+		}}, nil
+	}
+	return nil, fmt.Errorf("未知的消息元素类型 %q", je.Type)
+}
+
+// decodeMessageValue 解析JSON中的消息内容，可以是CQ码字符串，也可以是 MarshalJSON 格式的数组
+func decodeMessageValue(v jsontext.Value, opt CQParseOptions) (Message, error) {
+	m := Message{}
+	switch v.Kind() {
+	case '"':
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return nil, err
+		}
+		parsed, err := ParseMessageWithOptions(s, opt)
+		if err != nil {
+			return nil, err
+		}
+		m = parsed
+	case '[':
+		if err := m.UnmarshalJSON(v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("消息内容需要是字符串或者数组")
+	}
+	if len(m) == 0 {
+		return nil, errors.New("消息内容为空")
+	}
+	return m, nil
+}
@@ -17,7 +17,7 @@ type Middleware interface {
 	RemoveType(eventType ...EventType) *UniMiddleware // 删除中间件接收的事件类型
 	AddHandler(handlers ...EventHandler[Event])       // 添加事件处理器
 	Do(event Event) bool                              // 执行中间件
-	DoAsync(event Event)                              // 并发执行中间件，会破坏中间件的顺序性，谨慎使用
+	DoAsync(event Event)                              // 用事件的副本执行所有处理器，不会被截断，由调用方在 goroutine 中调用
 	GetHandlerCount() int                             // 获取事件处理器的数量
 }
 
@@ -133,16 +133,17 @@ func (m *UniMiddleware) Do(event Event) bool {
 	return true
 }
 
-// DoAsync 并发执行中间件，谨慎使用
+// DoAsync 用事件的副本依次执行所有事件处理器，处理器返回 nil 也不会截断后续的处理器
+//
+// 这个方法本身不会创建 goroutine，会在当前 goroutine 中执行完毕后返回；
+// 事件总线会为每个并发中间件创建 goroutine 并在其中调用它，这样出现的 panic 也能被事件总线恢复。
+// 在事件总线之外调用时，需要调用方自己决定是否并发执行以及如何恢复 panic
 func (m *UniMiddleware) DoAsync(event Event) {
-	// 使用 goroutine 并发执行中间件
-	go func() {
-		for _, handler := range m.Handlers {
-			h := handler // 避免闭包陷阱
-			e := event.Clone()
-			h(e)
-		}
-	}()
+	for _, handler := range m.Handlers {
+		h := handler // 避免闭包陷阱
+		e := event.Clone()
+		h(e)
+	}
 }
 
 // NewUniMiddleware 创建一个新的中间件实例
@@ -1,17 +1,65 @@
 package cryo
 
-import "github.com/machinacanis/cryo/log"
+import (
+	"regexp"
+	"time"
+
+	"github.com/machinacanis/cryo/log"
+)
 
 // setDefaultMiddleware 设置默认的中间件
 //
 // 目前提供了以下中间件：
 //
-// 1. Bot连接状态打印中间件
+// 1. 群主控客户端选举中间件
+//
+// 2. 消息去重中间件
+//
+// 3. Bot连接状态打印中间件
+//
+// 4. 消息打印中间件
 //
-// 2. 消息打印中间件
+// 5. 事件调试中间件
 //
-// 3. 事件调试中间件
-func setDefaultMiddleware(bus *EventBus, logger log.CryoLogger, conf Config) {
+// 6. 好友请求自动处理中间件
+func setDefaultMiddleware(bus *EventBus, logger log.CryoLogger, conf Config, clients func() []*LagrangeClient) {
+	// 选举需要在去重之前进行，否则非主控客户端先收到的消息会被记录下来，主控客户端收到的同一条消息会被当作重复丢弃
+	if conf.EnableGroupLeaderElection { // 是否启用群主控客户端选举中间件
+		logger.Debug("[Cryo] 启用内置的群主控客户端选举中间件")
+		mw := NewUniMiddleware()
+		mw.AddHandler(func(e Event) Event {
+			groupUin, ok := eventGroupUin(e)
+			if !ok {
+				return e
+			}
+			// 只有同一个群中Uin最小的在线客户端会继续处理事件，选不出来时不做拦截
+			if leader := groupLeader(clients(), groupUin); leader != 0 && leader != e.GetUniEvent().ClientUin {
+				return nil
+			}
+			return e
+		})
+		bus.AddPreMiddleware(mw)
+	}
+
+	if conf.EnableDedupMiddleware { // 是否启用消息去重中间件
+		logger.Debug("[Cryo] 启用内置的消息去重中间件")
+		ttl := time.Duration(conf.DedupTTL) * time.Second
+		if ttl <= 0 {
+			ttl = 5 * time.Minute
+		}
+		cache := newDedupCache(ttl)
+		mw := NewUniMiddleware(PrivateMessageEventType, GroupMessageEventType, TempMessageEventType)
+		mw.AddHandler(func(e Event) Event {
+			if key := dedupKey(e); key != "" && cache.seen(key, time.Now()) {
+				u := e.GetUniEvent()
+				logger.Debugf("[Cryo] %s 收到的重复消息 %s 已被丢弃", u.ClientNickname, key)
+				return nil
+			}
+			return e
+		})
+		bus.AddPreMiddleware(mw)
+	}
+
 	if conf.EnableConnectPrintMiddleware { // 是否启用连接状态打印中间件
 		logger.Debug("[Cryo] 启用内置的Bot连接状态打印中间件")
 		mw1 := NewUniMiddleware(BotConnectedEventType)
@@ -70,4 +118,31 @@ func setDefaultMiddleware(bus *EventBus, logger log.CryoLogger, conf Config) {
 		})
 		bus.AddPreMiddleware(mw)
 	}
+
+	if conf.FriendRequestPolicy == FriendRequestAcceptAll || conf.FriendRequestPolicy == FriendRequestAcceptMatched { // 是否启用好友请求自动处理中间件
+		var pattern *regexp.Regexp
+		if conf.FriendRequestPolicy == FriendRequestAcceptMatched {
+			p, err := regexp.Compile(conf.FriendRequestPattern)
+			if err != nil {
+				logger.Errorf("[Cryo] 好友请求的验证消息匹配规则 %s 无效，将不会自动同意好友请求：%v", conf.FriendRequestPattern, err)
+			}
+			pattern = p
+		}
+		logger.Debug("[Cryo] 启用内置的好友请求自动处理中间件")
+		mw := NewUniMiddleware(NewFriendRequestEventType)
+		mw.AddHandler(func(e Event) Event {
+			if typedEvent, ok := e.(*NewFriendRequestEvent); ok {
+				if conf.FriendRequestPolicy == FriendRequestAcceptMatched && (pattern == nil || !pattern.MatchString(typedEvent.Message)) {
+					return e // 验证消息不匹配的请求交给插件处理
+				}
+				if err := typedEvent.Accept(); err != nil {
+					logger.Errorf("[Cryo] 自动同意 %s(%d) 的好友请求时出现错误：%v", typedEvent.Nickname, typedEvent.Uin, err)
+				} else {
+					logger.Infof("[Cryo] 已自动同意 %s(%d) 的好友请求", typedEvent.Nickname, typedEvent.Uin)
+				}
+			}
+			return e
+		})
+		bus.AddPreMiddleware(mw)
+	}
 }
@@ -0,0 +1,1057 @@
+package cryo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/client"
+	"github.com/LagrangeDev/LagrangeGo/client/entity"
+	"github.com/LagrangeDev/LagrangeGo/client/event"
+	"github.com/LagrangeDev/LagrangeGo/client/packets/pb/service/oidb"
+	"github.com/LagrangeDev/LagrangeGo/message"
+)
+
+// 以下是 MockProtocol 模拟服务器拒绝操作时返回的原始错误
+var (
+	ErrMockPermissionDenied = fmt.Errorf("mock: %w", ErrPermissionDenied)
+	ErrMockGroupNotFound    = fmt.Errorf("mock: %w", ErrGroupNotFound)
+	ErrMockForwardNotFound  = errors.New("mock: forward message not found")
+)
+
+// MockAction 是 MockProtocol 记录下来的一次操作
+type MockAction struct {
+	Action   string    // 操作名称，例如 send_group_message、set_group_member_mute
+	GroupUin uint32    // 操作涉及的群号，没有时为0
+	UserUin  uint32    // 操作涉及的用户Uin，没有时为0
+	Message  Message   // 发送的消息内容，只有发送消息时才有
+	Args     []any     // 操作的其他参数，和调用协议时传入的顺序一致
+	Err      error     // 操作返回的错误，包括注入的错误
+	Rejected bool      // 消息被服务器拒绝，没有返回回执
+	Time     time.Time // 执行操作的时间
+}
+
+// mockFailure 是注入的一个错误
+type mockFailure struct {
+	err   error
+	times int // 剩余的次数，小于等于0时一直生效
+}
+
+// MockProtocol 是完全在内存中运行的协议实现，用于在没有网络的环境下测试插件
+//
+// 它可以模拟好友、群和群成员，注入收到的消息和通知，记录Bot发出的每一条消息和每一个操作，
+// 还可以让指定的操作失败；发送消息、禁言、踢人等操作会像真实的服务器一样检查好友关系、禁言状态和管理权限，
+// 修改群信息的操作也会推送对应的通知事件。
+// 通过 Bot.ConnectWithProtocol 连接后就可以像真实的客户端一样使用
+type MockProtocol struct {
+	mutex    sync.Mutex
+	uin      uint32
+	online   bool
+	now      func() time.Time
+	seq      uint32
+	handlers []func(event any)
+	hooks    []func(action MockAction)
+	friends  map[uint32]*entity.User
+	groups   map[uint32]*entity.Group
+	members  map[uint32]map[uint32]*entity.GroupMember
+	forwards map[string]*message.ForwardMessage
+	actions  []MockAction
+	failures map[string]*mockFailure
+}
+
+// NewMockProtocol 创建一个模拟协议，uin 是Bot自己的账号
+func NewMockProtocol(uin uint32) *MockProtocol {
+	return &MockProtocol{
+		uin:      uin,
+		online:   true,
+		now:      time.Now,
+		seq:      1000,
+		friends:  make(map[uint32]*entity.User),
+		groups:   make(map[uint32]*entity.Group),
+		members:  make(map[uint32]map[uint32]*entity.GroupMember),
+		forwards: make(map[string]*message.ForwardMessage),
+		failures: make(map[string]*mockFailure),
+	}
+}
+
+// mockUid 生成模拟用户的Uid
+func mockUid(uin uint32) string {
+	return "u_mock_" + strconv.FormatUint(uint64(uin), 10)
+}
+
+// SetClock 设置模拟协议使用的时钟，消息时间和禁言到期时间都会使用它，默认为 time.Now
+func (p *MockProtocol) SetClock(now func() time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.now = now
+}
+
+// AddFriend 添加一个好友，昵称为空时使用QQ号
+func (p *MockProtocol) AddFriend(uin uint32, nickname string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if nickname == "" {
+		nickname = strconv.FormatUint(uint64(uin), 10)
+	}
+	p.friends[uin] = &entity.User{Uin: uin, UID: mockUid(uin), Nickname: nickname}
+}
+
+// RemoveFriend 删除一个好友
+func (p *MockProtocol) RemoveFriend(uin uint32) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.friends, uin)
+}
+
+// AddGroup 添加一个Bot所在的群，Bot会作为普通成员加入
+func (p *MockProtocol) AddGroup(groupUin uint32, name string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.addGroup(groupUin, name)
+}
+
+func (p *MockProtocol) addGroup(groupUin uint32, name string) {
+	if name == "" {
+		name = strconv.FormatUint(uint64(groupUin), 10)
+	}
+	if g, ok := p.groups[groupUin]; ok {
+		g.GroupName = name
+		return
+	}
+	p.groups[groupUin] = &entity.Group{GroupUin: groupUin, GroupName: name, MaxMember: 500}
+	p.members[groupUin] = make(map[uint32]*entity.GroupMember)
+	p.addMember(groupUin, p.uin, "", entity.Member)
+}
+
+// AddMember 添加一个群成员，群不存在时会自动创建，也可以用来修改Bot自己在群中的权限
+func (p *MockProtocol) AddMember(groupUin, uin uint32, nickname string, permission ...entity.GroupMemberPermission) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.groups[groupUin]; !ok {
+		p.addGroup(groupUin, "")
+	}
+	perm := entity.Member
+	if len(permission) > 0 {
+		perm = permission[0]
+	}
+	p.addMember(groupUin, uin, nickname, perm)
+}
+
+func (p *MockProtocol) addMember(groupUin, uin uint32, nickname string, permission entity.GroupMemberPermission) {
+	if nickname == "" {
+		nickname = strconv.FormatUint(uint64(uin), 10)
+	}
+	members := p.members[groupUin]
+	if _, ok := members[uin]; !ok {
+		p.groups[groupUin].MemberCount++
+	}
+	members[uin] = &entity.GroupMember{
+		User:       entity.User{Uin: uin, UID: mockUid(uin), Nickname: nickname},
+		Permission: permission,
+		JoinTime:   uint32(p.now().Unix()),
+	}
+	if permission == entity.Owner {
+		p.groups[groupUin].GroupOwner = uin
+	}
+}
+
+func (p *MockProtocol) removeMember(groupUin, uin uint32) {
+	if _, ok := p.members[groupUin][uin]; !ok {
+		return
+	}
+	delete(p.members[groupUin], uin)
+	p.groups[groupUin].MemberCount--
+	if uin == p.uin { // Bot自己离开了群
+		delete(p.groups, groupUin)
+		delete(p.members, groupUin)
+	}
+}
+
+// learn 根据回放的事件补充还没有添加的好友、群和群成员，让Bot可以像录制时一样回复
+func (p *MockProtocol) learn(e Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	learnMember := func(groupUin uint32, groupName string, uin uint32, nickname string) {
+		if _, ok := p.groups[groupUin]; !ok {
+			p.addGroup(groupUin, groupName)
+		}
+		if _, ok := p.members[groupUin][uin]; !ok && uin != 0 {
+			p.addMember(groupUin, uin, nickname, entity.Member)
+		}
+	}
+	learnFriend := func(uin uint32, nickname string) {
+		if _, ok := p.friends[uin]; !ok && uin != 0 {
+			if nickname == "" {
+				nickname = strconv.FormatUint(uint64(uin), 10)
+			}
+			p.friends[uin] = &entity.User{Uin: uin, UID: mockUid(uin), Nickname: nickname}
+		}
+	}
+	switch v := e.(type) {
+	case *PrivateMessageEvent:
+		learnFriend(v.SenderUin, v.SenderNickname)
+	case *GroupMessageEvent:
+		learnMember(v.GroupUin, v.GroupName, v.SenderUin, v.SenderNickname)
+	case *TempMessageEvent:
+		learnMember(v.GroupUin, v.GroupName, v.SenderUin, v.SenderNickname)
+	case *FriendPokeEvent:
+		learnFriend(v.SenderUin, "")
+	case *GroupPokeEvent:
+		learnMember(v.GroupUin, v.GroupName, v.SenderUin, v.SenderNickname)
+	default:
+		if groupUin, ok := eventGroupUin(e); ok {
+			learnMember(groupUin, "", 0, "")
+		}
+	}
+}
+
+// Fail 让之后的 times 次 action 操作返回 err，times 小于等于0时会一直失败，action 为 * 时对所有操作生效
+//
+// 操作名称和 MockAction.Action 一致，查询类的操作例如 fetch_group_member 也可以注入错误
+func (p *MockProtocol) Fail(action string, err error, times int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.failures[action] = &mockFailure{err: err, times: times}
+}
+
+// FailNext 让下一次 action 操作返回 err
+func (p *MockProtocol) FailNext(action string, err error) {
+	p.Fail(action, err, 1)
+}
+
+// ClearFailures 清除所有注入的错误
+func (p *MockProtocol) ClearFailures() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	clear(p.failures)
+}
+
+// SetOnline 设置是否在线，离线时所有操作都会返回 client.ErrNotOnline
+func (p *MockProtocol) SetOnline(online bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.online = online
+}
+
+// Disconnect 模拟掉线，会推送断开连接事件
+func (p *MockProtocol) Disconnect(reason string) {
+	p.SetOnline(false)
+	p.Emit(&client.DisconnectedEvent{Message: reason})
+}
+
+// Actions 获取记录的所有操作
+func (p *MockProtocol) Actions() []MockAction {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]MockAction{}, p.actions...)
+}
+
+// ActionsOf 获取记录的指定名称的操作
+func (p *MockProtocol) ActionsOf(action string) []MockAction {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var result []MockAction
+	for _, a := range p.actions {
+		if a.Action == action {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// SentMessages 获取成功发送的所有消息，不包括被拒绝的消息
+func (p *MockProtocol) SentMessages() []MockAction {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var result []MockAction
+	for _, a := range p.actions {
+		if strings.HasPrefix(a.Action, "send_") && strings.HasSuffix(a.Action, "_message") && a.Err == nil && !a.Rejected {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// ResetActions 清空记录的操作
+func (p *MockProtocol) ResetActions() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.actions = nil
+}
+
+// Emit 推送一个 LagrangeGo 的事件，例如 *event.GroupPokeEvent，事件会同步地交给订阅者处理
+func (p *MockProtocol) Emit(event any) {
+	p.mutex.Lock()
+	handlers := append([]func(any){}, p.handlers...)
+	p.mutex.Unlock()
+	for _, h := range handlers {
+		h(event)
+	}
+}
+
+// nextSeq 生成一个新的消息序号
+func (p *MockProtocol) nextSeq() uint32 {
+	p.seq++
+	return p.seq
+}
+
+// sender 根据群成员或者好友信息生成消息的发送者
+func (p *MockProtocol) sender(groupUin, uin uint32) *message.Sender {
+	s := &message.Sender{Uin: uin, UID: mockUid(uin), Nickname: strconv.FormatUint(uint64(uin), 10)}
+	if f, ok := p.friends[uin]; ok {
+		s.Nickname, s.IsFriend = f.Nickname, true
+	}
+	if m, ok := p.members[groupUin][uin]; ok {
+		s.Nickname, s.CardName = m.Nickname, m.MemberCard
+	}
+	return s
+}
+
+// ReceivePrivateMessage 模拟收到一条私聊消息，args 和 Bot.Send 的参数一样
+func (p *MockProtocol) ReceivePrivateMessage(userUin uint32, args ...any) *message.PrivateMessage {
+	p.mutex.Lock()
+	m := &message.PrivateMessage{
+		ID:         p.nextSeq(),
+		InternalID: p.nextSeq(),
+		Self:       p.uin,
+		Target:     p.uin,
+		Time:       uint32(p.now().Unix()),
+		Sender:     p.sender(0, userUin),
+		Elements:   ProcessMessageContent(args...).ToIMessageElements(),
+	}
+	p.mutex.Unlock()
+	p.Emit(m)
+	return m
+}
+
+// ReceiveGroupMessage 模拟收到一条群消息，发送者不在群中时会自动加入
+func (p *MockProtocol) ReceiveGroupMessage(groupUin, userUin uint32, args ...any) *message.GroupMessage {
+	p.mutex.Lock()
+	if _, ok := p.groups[groupUin]; !ok {
+		p.addGroup(groupUin, "")
+	}
+	if _, ok := p.members[groupUin][userUin]; !ok {
+		p.addMember(groupUin, userUin, "", entity.Member)
+	}
+	m := &message.GroupMessage{
+		ID:         p.nextSeq(),
+		InternalID: p.nextSeq(),
+		GroupUin:   groupUin,
+		GroupName:  p.groups[groupUin].GroupName,
+		Sender:     p.sender(groupUin, userUin),
+		Time:       uint32(p.now().Unix()),
+		Elements:   ProcessMessageContent(args...).ToIMessageElements(),
+	}
+	p.mutex.Unlock()
+	p.Emit(m)
+	return m
+}
+
+// ReceiveTempMessage 模拟收到一条群临时会话消息
+func (p *MockProtocol) ReceiveTempMessage(groupUin, userUin uint32, args ...any) *message.TempMessage {
+	p.mutex.Lock()
+	m := &message.TempMessage{
+		ID:       p.nextSeq(),
+		GroupUin: groupUin,
+		Self:     p.uin,
+		Sender:   p.sender(groupUin, userUin),
+		Elements: ProcessMessageContent(args...).ToIMessageElements(),
+	}
+	if g, ok := p.groups[groupUin]; ok {
+		m.GroupName = g.GroupName
+	}
+	p.mutex.Unlock()
+	p.Emit(m)
+	return m
+}
+
+// ReceiveFriendRequest 模拟收到好友请求
+func (p *MockProtocol) ReceiveFriendRequest(userUin uint32, nickname, msg string) {
+	p.Emit(&event.NewFriendRequest{SourceUin: userUin, SourceUID: mockUid(userUin), SourceNick: nickname, Msg: msg, Source: "mock"})
+}
+
+// ReceiveGroupInvite 模拟收到加群邀请
+func (p *MockProtocol) ReceiveGroupInvite(groupUin uint32, groupName string, inviterUin uint32) {
+	p.mutex.Lock()
+	p.seq++
+	seq := uint64(p.seq)
+	p.mutex.Unlock()
+	p.Emit(&event.GroupInvite{
+		GroupUin:    groupUin,
+		GroupName:   groupName,
+		InvitorUin:  inviterUin,
+		InvitorUID:  mockUid(inviterUin),
+		InvitorNick: strconv.FormatUint(uint64(inviterUin), 10),
+		RequestSeq:  seq,
+	})
+}
+
+// ReceiveFriendPoke 模拟好友戳了戳Bot
+func (p *MockProtocol) ReceiveFriendPoke(userUin uint32) {
+	p.Emit(&event.FriendPokeEvent{Sender: userUin, Receiver: p.uin, Action: "戳了戳"})
+}
+
+// ReceiveGroupPoke 模拟群成员戳了戳另一个成员，targetUin 为0时表示戳了戳Bot
+func (p *MockProtocol) ReceiveGroupPoke(groupUin, userUin, targetUin uint32) {
+	if targetUin == 0 {
+		targetUin = p.uin
+	}
+	p.Emit(&event.GroupPokeEvent{
+		GroupEvent: event.GroupEvent{GroupUin: groupUin, UserUin: userUin, UserUID: mockUid(userUin)},
+		Receiver:   targetUin,
+		Action:     "戳了戳",
+	})
+}
+
+// MemberJoin 模拟有新成员加入群，inviterUin 为0时表示主动加群
+func (p *MockProtocol) MemberJoin(groupUin, userUin uint32, nickname string, inviterUin uint32) {
+	p.mutex.Lock()
+	if _, ok := p.groups[groupUin]; !ok {
+		p.addGroup(groupUin, "")
+	}
+	p.addMember(groupUin, userUin, nickname, entity.Member)
+	p.mutex.Unlock()
+	e := &event.GroupMemberIncrease{GroupEvent: event.GroupEvent{GroupUin: groupUin, UserUin: userUin, UserUID: mockUid(userUin)}}
+	if inviterUin != 0 {
+		e.InvitorUin, e.InvitorUID = inviterUin, mockUid(inviterUin)
+	}
+	p.Emit(e)
+}
+
+// MemberLeave 模拟成员离开群，operatorUin 不为0时表示被踢出
+func (p *MockProtocol) MemberLeave(groupUin, userUin, operatorUin uint32) {
+	p.mutex.Lock()
+	p.removeMember(groupUin, userUin)
+	p.mutex.Unlock()
+	p.Emit(p.memberDecrease(groupUin, userUin, operatorUin))
+}
+
+func (p *MockProtocol) memberDecrease(groupUin, userUin, operatorUin uint32) *event.GroupMemberDecrease {
+	e := &event.GroupMemberDecrease{GroupEvent: event.GroupEvent{GroupUin: groupUin, UserUin: userUin, UserUID: mockUid(userUin)}}
+	if operatorUin != 0 {
+		e.OperatorUin, e.OperatorUID, e.ExitType = operatorUin, mockUid(operatorUin), 3
+	}
+	return e
+}
+
+// MemberMute 模拟群成员被禁言，duration 为0时表示解除禁言，userUin 为0时表示全员禁言
+func (p *MockProtocol) MemberMute(groupUin, userUin, operatorUin uint32, duration time.Duration) {
+	p.mutex.Lock()
+	seconds := uint32(duration / time.Second)
+	if m, ok := p.members[groupUin][userUin]; ok {
+		m.ShutUpTime = 0
+		if seconds > 0 {
+			m.ShutUpTime = uint32(p.now().Unix()) + seconds
+		}
+	}
+	p.mutex.Unlock()
+	p.Emit(p.groupMute(groupUin, userUin, operatorUin, seconds))
+}
+
+func (p *MockProtocol) groupMute(groupUin, userUin, operatorUin, seconds uint32) *event.GroupMute {
+	e := &event.GroupMute{GroupEvent: event.GroupEvent{GroupUin: groupUin}, OperatorUin: operatorUin, Duration: seconds}
+	if userUin != 0 {
+		// LagrangeGo 通过 OperatorUID 是否为空判断是不是全员禁言
+		e.UserUin, e.UserUID, e.OperatorUID = userUin, mockUid(userUin), mockUid(operatorUin)
+	} else if seconds > 0 {
+		e.Duration = math.MaxUint32
+	}
+	return e
+}
+
+// MemberRecall 模拟群成员撤回消息，operatorUin 为0时表示发送者自己撤回
+func (p *MockProtocol) MemberRecall(groupUin, userUin, operatorUin uint32, seq uint32) {
+	if operatorUin == 0 {
+		operatorUin = userUin
+	}
+	p.Emit(&event.GroupRecall{
+		GroupEvent:  event.GroupEvent{GroupUin: groupUin, UserUin: userUin, UserUID: mockUid(userUin)},
+		OperatorUin: operatorUin,
+		OperatorUID: mockUid(operatorUin),
+		Sequence:    uint64(seq),
+		Time:        uint32(p.currentTime().Unix()),
+	})
+}
+
+// ReceiveFriendRecall 模拟好友撤回了一条私聊消息
+func (p *MockProtocol) ReceiveFriendRecall(userUin, seq uint32) {
+	p.Emit(&event.FriendRecall{
+		FromUin:  userUin,
+		FromUID:  mockUid(userUin),
+		Sequence: uint64(seq),
+		Time:     uint32(p.currentTime().Unix()),
+	})
+}
+
+func (p *MockProtocol) currentTime() time.Time {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.now()
+}
+
+// check 检查操作是否在线以及是否被注入了错误，需要持有锁
+func (p *MockProtocol) check(action string) error {
+	if !p.online {
+		return client.ErrNotOnline
+	}
+	for _, key := range []string{action, "*"} {
+		f, ok := p.failures[key]
+		if !ok {
+			continue
+		}
+		if f.times > 0 {
+			if f.times--; f.times == 0 {
+				delete(p.failures, key)
+			}
+		}
+		return f.err
+	}
+	return nil
+}
+
+// record 记录一次操作，需要持有锁
+func (p *MockProtocol) record(a MockAction) MockAction {
+	a.Time = p.now()
+	p.actions = append(p.actions, a)
+	return a
+}
+
+// OnAction 添加一个在Bot执行操作之后调用的函数，可以用来实时输出Bot发出的消息
+func (p *MockProtocol) OnAction(hook func(action MockAction)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.hooks = append(p.hooks, hook)
+}
+
+// do 检查并记录一次操作，f 会在持有锁的情况下执行并可以修改记录的操作，返回的事件会在释放锁之后推送
+func (p *MockProtocol) do(a MockAction, f func(a *MockAction) (any, error)) error {
+	p.mutex.Lock()
+	err := p.check(a.Action)
+	var e any
+	if err == nil && f != nil {
+		e, err = f(&a)
+	}
+	a.Err = err
+	a = p.record(a)
+	hooks := append([]func(MockAction){}, p.hooks...)
+	p.mutex.Unlock()
+	for _, hook := range hooks {
+		hook(a)
+	}
+	if e != nil {
+		p.Emit(e)
+	}
+	return err
+}
+
+// query 检查一次查询操作，查询操作不会被记录
+func (p *MockProtocol) query(action string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.check(action)
+}
+
+// requireAdmin 检查Bot是否有权限管理群成员，owner 为 true 时需要Bot是群主，需要持有锁
+func (p *MockProtocol) requireAdmin(groupUin, userUin uint32, owner bool) error {
+	members, ok := p.members[groupUin]
+	if !ok {
+		return ErrMockGroupNotFound
+	}
+	self := members[p.uin]
+	if self == nil || self.Permission == entity.Member || (owner && self.Permission != entity.Owner) {
+		return ErrMockPermissionDenied
+	}
+	if userUin == 0 || userUin == p.uin {
+		return nil
+	}
+	target, ok := members[userUin]
+	if !ok {
+		return client.ErrMemberNotFound
+	}
+	if target.Permission == entity.Owner || (target.Permission == entity.Admin && self.Permission != entity.Owner) {
+		return ErrMockPermissionDenied
+	}
+	return nil
+}
+
+// Self 获取Bot自己的账号
+func (p *MockProtocol) Self() (uint32, string) {
+	return p.uin, mockUid(p.uin)
+}
+
+// IsOnline 判断是否在线
+func (p *MockProtocol) IsOnline() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.online
+}
+
+// Subscribe 订阅模拟协议推送的事件
+func (p *MockProtocol) Subscribe(handler func(event any)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.handlers = append(p.handlers, handler)
+}
+
+// toMessage 把发送的元素转换回cryo的消息，方便在测试中检查
+func toMessage(elements []message.IMessageElement) Message {
+	m := Message{}
+	m.AddIMessageElement(elements...)
+	return m
+}
+
+// SendPrivateMessage 发送私聊消息，对方不是好友时和真实的服务器一样不会返回回执
+func (p *MockProtocol) SendPrivateMessage(userUin uint32, elements []message.IMessageElement) (*message.PrivateMessage, error) {
+	var sent *message.PrivateMessage
+	err := p.do(MockAction{Action: "send_private_message", UserUin: userUin, Message: toMessage(elements)}, func(a *MockAction) (any, error) {
+		if _, ok := p.friends[userUin]; !ok {
+			a.Rejected = true
+			return nil, nil
+		}
+		sent = &message.PrivateMessage{
+			ID:         p.nextSeq(),
+			InternalID: p.nextSeq(),
+			ClientSeq:  p.nextSeq(),
+			Self:       p.uin,
+			Target:     userUin,
+			Time:       uint32(p.now().Unix()),
+			Elements:   elements,
+		}
+		return nil, nil
+	})
+	return sent, err
+}
+
+// SendGroupMessage 发送群消息，Bot不在群中或者被禁言时和真实的服务器一样不会返回回执
+func (p *MockProtocol) SendGroupMessage(groupUin uint32, elements []message.IMessageElement) (*message.GroupMessage, error) {
+	var sent *message.GroupMessage
+	err := p.do(MockAction{Action: "send_group_message", GroupUin: groupUin, Message: toMessage(elements)}, func(a *MockAction) (any, error) {
+		self, ok := p.members[groupUin][p.uin]
+		if !ok || int64(self.ShutUpTime) > p.now().Unix() {
+			a.Rejected = true
+			return nil, nil
+		}
+		sent = &message.GroupMessage{
+			ID:         p.nextSeq(),
+			InternalID: p.nextSeq(),
+			GroupUin:   groupUin,
+			GroupName:  p.groups[groupUin].GroupName,
+			Sender:     p.sender(groupUin, p.uin),
+			Time:       uint32(p.now().Unix()),
+			Elements:   elements,
+		}
+		return nil, nil
+	})
+	return sent, err
+}
+
+// SendTempMessage 发送临时会话消息，Bot不在群中时不会返回回执
+func (p *MockProtocol) SendTempMessage(groupUin, userUin uint32, elements []message.IMessageElement) (*message.TempMessage, error) {
+	var sent *message.TempMessage
+	err := p.do(MockAction{Action: "send_temp_message", GroupUin: groupUin, UserUin: userUin, Message: toMessage(elements)}, func(a *MockAction) (any, error) {
+		if _, ok := p.members[groupUin][p.uin]; !ok {
+			a.Rejected = true
+			return nil, nil
+		}
+		sent = &message.TempMessage{ID: p.nextSeq(), GroupUin: groupUin, Self: p.uin, Elements: elements}
+		return nil, nil
+	})
+	return sent, err
+}
+
+// RecallFriendMessage 撤回私聊消息
+func (p *MockProtocol) RecallFriendMessage(userUin, seq, random, clientSeq, timestamp uint32) error {
+	return p.do(MockAction{Action: "recall_friend_message", UserUin: userUin, Args: []any{seq, random, clientSeq, timestamp}}, nil)
+}
+
+// RecallGroupMessage 撤回群消息
+func (p *MockProtocol) RecallGroupMessage(groupUin, seq uint32) error {
+	return p.do(MockAction{Action: "recall_group_message", GroupUin: groupUin, Args: []any{seq}}, nil)
+}
+
+// SetGroupReaction 给群消息添加或取消表态
+func (p *MockProtocol) SetGroupReaction(groupUin, seq uint32, code string, isAdd bool) error {
+	return p.do(MockAction{Action: "set_group_reaction", GroupUin: groupUin, Args: []any{seq, code, isAdd}}, nil)
+}
+
+// FriendPoke 戳一戳好友
+func (p *MockProtocol) FriendPoke(userUin uint32) error {
+	return p.do(MockAction{Action: "friend_poke", UserUin: userUin}, nil)
+}
+
+// GroupPoke 戳一戳群成员
+func (p *MockProtocol) GroupPoke(groupUin, userUin uint32) error {
+	return p.do(MockAction{Action: "group_poke", GroupUin: groupUin, UserUin: userUin}, nil)
+}
+
+// UploadImage 上传图片，模拟协议不会真的上传，直接返回原来的图片
+func (p *MockProtocol) UploadImage(target message.Source, image *message.ImageElement) (*message.ImageElement, error) {
+	err := p.do(MockAction{Action: "upload_image", Args: []any{target}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// UploadForwardMsg 上传合并转发消息，上传后可以通过 FetchForwardMsg 取回
+func (p *MockProtocol) UploadForwardMsg(forward *message.ForwardMessage, groupUin uint32) (*message.ForwardMessage, error) {
+	err := p.do(MockAction{Action: "upload_forward_msg", GroupUin: groupUin}, func(a *MockAction) (any, error) {
+		forward.ResID = fmt.Sprintf("mock-forward-%d", p.nextSeq())
+		p.forwards[forward.ResID] = forward
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return forward, nil
+}
+
+// FetchForwardMsg 获取上传过的合并转发消息
+func (p *MockProtocol) FetchForwardMsg(resId string) (*message.ForwardMessage, error) {
+	if err := p.query("fetch_forward_msg"); err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	f, ok := p.forwards[resId]
+	if !ok {
+		return nil, ErrMockForwardNotFound
+	}
+	return f, nil
+}
+
+// UploadGroupFile 上传群文件
+func (p *MockProtocol) UploadGroupFile(groupUin uint32, file *message.FileElement, targetDirectory string) (*message.FileElement, error) {
+	var uploaded message.FileElement
+	err := p.do(MockAction{Action: "upload_group_file", GroupUin: groupUin, Args: []any{file.FileName, targetDirectory}}, func(a *MockAction) (any, error) {
+		if _, ok := p.groups[groupUin]; !ok {
+			return nil, ErrMockGroupNotFound
+		}
+		uploaded = *file
+		uploaded.FileID = fmt.Sprintf("mock-file-%d", p.nextSeq())
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &uploaded, nil
+}
+
+// SendPrivateFile 发送私聊文件
+func (p *MockProtocol) SendPrivateFile(userUin uint32, localFilePath, filename string) error {
+	return p.do(MockAction{Action: "send_private_file", UserUin: userUin, Args: []any{filename}}, nil)
+}
+
+// ListGroupFilesByFolder 列出群文件，模拟协议不保存文件，总是返回空列表
+func (p *MockProtocol) ListGroupFilesByFolder(groupUin uint32, targetDirectory string) ([]*entity.GroupFile, []*entity.GroupFolder, error) {
+	return nil, nil, p.query("list_group_files")
+}
+
+// mockURL 生成一个模拟的下载链接
+func (p *MockProtocol) mockURL(action, kind string) (string, error) {
+	if err := p.query(action); err != nil {
+		return "", err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return fmt.Sprintf("https://mock.invalid/%s/%d", kind, p.nextSeq()), nil
+}
+
+// GetGroupFileURL 获取群文件的下载链接
+func (p *MockProtocol) GetGroupFileURL(groupUin uint32, fileId string) (string, error) {
+	return p.mockURL("get_group_file_url", "file")
+}
+
+// GetPrivateFileURL 获取私聊文件的下载链接
+func (p *MockProtocol) GetPrivateFileURL(fileUUID string, fileHash string) (string, error) {
+	return p.mockURL("get_private_file_url", "file")
+}
+
+// GetGroupImageURL 获取群图片的下载链接
+func (p *MockProtocol) GetGroupImageURL(groupUin uint32, node *oidb.IndexNode) (string, error) {
+	return p.mockURL("get_group_image_url", "image")
+}
+
+// GetPrivateImageURL 获取私聊图片的下载链接
+func (p *MockProtocol) GetPrivateImageURL(node *oidb.IndexNode) (string, error) {
+	return p.mockURL("get_private_image_url", "image")
+}
+
+// GetGroupRecordURL 获取群语音的下载链接
+func (p *MockProtocol) GetGroupRecordURL(groupUin uint32, node *oidb.IndexNode) (string, error) {
+	return p.mockURL("get_group_record_url", "record")
+}
+
+// GetPrivateRecordURL 获取私聊语音的下载链接
+func (p *MockProtocol) GetPrivateRecordURL(node *oidb.IndexNode) (string, error) {
+	return p.mockURL("get_private_record_url", "record")
+}
+
+// GetGroupVideoURL 获取群视频的下载链接
+func (p *MockProtocol) GetGroupVideoURL(groupUin uint32, node *oidb.IndexNode) (string, error) {
+	return p.mockURL("get_group_video_url", "video")
+}
+
+// GetPrivateVideoURL 获取私聊视频的下载链接
+func (p *MockProtocol) GetPrivateVideoURL(node *oidb.IndexNode) (string, error) {
+	return p.mockURL("get_private_video_url", "video")
+}
+
+// GetCachedFriendInfo 获取好友信息，不是好友时返回nil
+func (p *MockProtocol) GetCachedFriendInfo(userUin uint32) *entity.User {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if f, ok := p.friends[userUin]; ok {
+		u := *f
+		return &u
+	}
+	return nil
+}
+
+// GetCachedAllFriendsInfo 获取所有好友
+func (p *MockProtocol) GetCachedAllFriendsInfo() map[uint32]*entity.User {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	result := make(map[uint32]*entity.User, len(p.friends))
+	for uin, f := range p.friends {
+		u := *f
+		result[uin] = &u
+	}
+	return result
+}
+
+// RefreshFriendCache 刷新好友列表，模拟协议中的数据总是最新的
+func (p *MockProtocol) RefreshFriendCache() error {
+	return p.query("refresh_friend_cache")
+}
+
+// FetchUserInfoUin 获取用户资料
+func (p *MockProtocol) FetchUserInfoUin(userUin uint32) (*entity.User, error) {
+	if err := p.query("fetch_user_info"); err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	s := p.sender(0, userUin)
+	for _, members := range p.members {
+		if m, ok := members[userUin]; ok && s.Nickname == strconv.FormatUint(uint64(userUin), 10) {
+			s.Nickname = m.Nickname
+		}
+	}
+	return &entity.User{Uin: userUin, UID: s.UID, Nickname: s.Nickname}, nil
+}
+
+// SetFriendRequest 处理好友请求
+func (p *MockProtocol) SetFriendRequest(accept bool, targetUid string) error {
+	return p.do(MockAction{Action: "set_friend_request", Args: []any{accept, targetUid}}, func(a *MockAction) (any, error) {
+		uin, err := strconv.ParseUint(strings.TrimPrefix(targetUid, "u_mock_"), 10, 32)
+		if accept && err == nil {
+			if _, ok := p.friends[uint32(uin)]; !ok {
+				p.friends[uint32(uin)] = &entity.User{Uin: uint32(uin), UID: targetUid, Nickname: strconv.FormatUint(uin, 10)}
+			}
+		}
+		return nil, nil
+	})
+}
+
+// DeleteFriend 删除好友
+func (p *MockProtocol) DeleteFriend(userUin uint32, block bool) error {
+	return p.do(MockAction{Action: "delete_friend", UserUin: userUin, Args: []any{block}}, func(a *MockAction) (any, error) {
+		delete(p.friends, userUin)
+		return nil, nil
+	})
+}
+
+// GetCachedGroupInfo 获取群信息，Bot不在群中时返回nil
+func (p *MockProtocol) GetCachedGroupInfo(groupUin uint32) *entity.Group {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if g, ok := p.groups[groupUin]; ok {
+		group := *g
+		return &group
+	}
+	return nil
+}
+
+// GetCachedAllGroupsInfo 获取Bot加入的所有群
+func (p *MockProtocol) GetCachedAllGroupsInfo() map[uint32]*entity.Group {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	result := make(map[uint32]*entity.Group, len(p.groups))
+	for uin, g := range p.groups {
+		group := *g
+		result[uin] = &group
+	}
+	return result
+}
+
+// RefreshAllGroupsInfo 刷新群列表，模拟协议中的数据总是最新的
+func (p *MockProtocol) RefreshAllGroupsInfo() error {
+	return p.query("refresh_group_list")
+}
+
+// FetchGroupInfo 获取群信息
+func (p *MockProtocol) FetchGroupInfo(groupUin uint32, isStrange bool) (*entity.Group, error) {
+	if err := p.query("fetch_group_info"); err != nil {
+		return nil, err
+	}
+	if g := p.GetCachedGroupInfo(groupUin); g != nil {
+		return g, nil
+	}
+	return nil, ErrMockGroupNotFound
+}
+
+// FetchGroupMember 获取群成员信息
+func (p *MockProtocol) FetchGroupMember(groupUin, userUin uint32) (*entity.GroupMember, error) {
+	if err := p.query("fetch_group_member"); err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if m, ok := p.members[groupUin][userUin]; ok {
+		member := *m
+		return &member, nil
+	}
+	return nil, client.ErrMemberNotFound
+}
+
+// GetGroupMembersData 获取群的所有成员
+func (p *MockProtocol) GetGroupMembersData(groupUin uint32) (map[uint32]*entity.GroupMember, error) {
+	if err := p.query("fetch_group_member_list"); err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	members, ok := p.members[groupUin]
+	if !ok {
+		return nil, ErrMockGroupNotFound
+	}
+	result := make(map[uint32]*entity.GroupMember, len(members))
+	for uin, m := range members {
+		member := *m
+		result[uin] = &member
+	}
+	return result, nil
+}
+
+// GetGroupSystemMessages 获取群系统消息，模拟协议中总是为空
+func (p *MockProtocol) GetGroupSystemMessages(isFiltered bool, count uint32, groupUin ...uint32) (*entity.GroupSystemMessages, error) {
+	if err := p.query("get_group_system_messages"); err != nil {
+		return nil, err
+	}
+	return &entity.GroupSystemMessages{}, nil
+}
+
+// SetGroupRequest 处理加群请求或者邀请
+func (p *MockProtocol) SetGroupRequest(isFiltered bool, accept bool, sequence uint64, typ uint32, groupUin uint32, message string) error {
+	return p.do(MockAction{Action: "set_group_request", GroupUin: groupUin, Args: []any{accept, sequence, typ, message}}, nil)
+}
+
+// SetGroupName 修改群名称，会推送群名称变更事件
+func (p *MockProtocol) SetGroupName(groupUin uint32, name string) error {
+	return p.do(MockAction{Action: "set_group_name", GroupUin: groupUin, Args: []any{name}}, func(a *MockAction) (any, error) {
+		if err := p.requireAdmin(groupUin, 0, false); err != nil {
+			return nil, err
+		}
+		p.groups[groupUin].GroupName = name
+		return &event.GroupNameUpdated{GroupEvent: event.GroupEvent{GroupUin: groupUin, UserUin: p.uin, UserUID: mockUid(p.uin)}, NewName: name}, nil
+	})
+}
+
+// SetGroupGlobalMute 开启或关闭全员禁言
+func (p *MockProtocol) SetGroupGlobalMute(groupUin uint32, isMute bool) error {
+	return p.do(MockAction{Action: "set_group_global_mute", GroupUin: groupUin, Args: []any{isMute}}, func(a *MockAction) (any, error) {
+		if err := p.requireAdmin(groupUin, 0, false); err != nil {
+			return nil, err
+		}
+		var seconds uint32
+		if isMute {
+			seconds = 1
+		}
+		return p.groupMute(groupUin, 0, p.uin, seconds), nil
+	})
+}
+
+// SetGroupMemberMute 禁言群成员，duration 的单位是秒，为0时解除禁言，会推送禁言事件
+func (p *MockProtocol) SetGroupMemberMute(groupUin, userUin, duration uint32) error {
+	return p.do(MockAction{Action: "set_group_member_mute", GroupUin: groupUin, UserUin: userUin, Args: []any{duration}}, func(a *MockAction) (any, error) {
+		if err := p.requireAdmin(groupUin, userUin, false); err != nil {
+			return nil, err
+		}
+		m := p.members[groupUin][userUin]
+		m.ShutUpTime = 0
+		if duration > 0 {
+			m.ShutUpTime = uint32(p.now().Unix()) + duration
+		}
+		return p.groupMute(groupUin, userUin, p.uin, duration), nil
+	})
+}
+
+// SetGroupAdmin 设置或取消群管理员，需要Bot是群主，会推送权限变更事件
+func (p *MockProtocol) SetGroupAdmin(groupUin, userUin uint32, isAdmin bool) error {
+	return p.do(MockAction{Action: "set_group_admin", GroupUin: groupUin, UserUin: userUin, Args: []any{isAdmin}}, func(a *MockAction) (any, error) {
+		if err := p.requireAdmin(groupUin, userUin, true); err != nil {
+			return nil, err
+		}
+		m := p.members[groupUin][userUin]
+		m.Permission = entity.Member
+		if isAdmin {
+			m.Permission = entity.Admin
+		}
+		return &event.GroupMemberPermissionChanged{GroupEvent: event.GroupEvent{GroupUin: groupUin, UserUin: userUin, UserUID: m.UID}, IsAdmin: isAdmin}, nil
+	})
+}
+
+// SetGroupMemberName 设置群成员的群名片，修改自己的群名片不需要管理权限
+func (p *MockProtocol) SetGroupMemberName(groupUin, userUin uint32, name string) error {
+	return p.do(MockAction{Action: "set_group_member_name", GroupUin: groupUin, UserUin: userUin, Args: []any{name}}, func(a *MockAction) (any, error) {
+		if userUin != p.uin {
+			if err := p.requireAdmin(groupUin, userUin, false); err != nil {
+				return nil, err
+			}
+		}
+		m, ok := p.members[groupUin][userUin]
+		if !ok {
+			return nil, client.ErrMemberNotFound
+		}
+		m.MemberCard = name
+		return nil, nil
+	})
+}
+
+// SetGroupMemberSpecialTitle 设置群成员的专属头衔，需要Bot是群主，会推送头衔变更事件
+func (p *MockProtocol) SetGroupMemberSpecialTitle(groupUin, userUin uint32, title string) error {
+	return p.do(MockAction{Action: "set_group_member_special_title", GroupUin: groupUin, UserUin: userUin, Args: []any{title}}, func(a *MockAction) (any, error) {
+		if err := p.requireAdmin(groupUin, 0, true); err != nil {
+			return nil, err
+		}
+		m, ok := p.members[groupUin][userUin]
+		if !ok {
+			return nil, client.ErrMemberNotFound
+		}
+		m.SpecialTitle = title
+		return &event.MemberSpecialTitleUpdated{GroupEvent: event.GroupEvent{GroupUin: groupUin, UserUin: userUin, UserUID: m.UID}, NewTitle: title}, nil
+	})
+}
+
+// KickGroupMember 将成员移出群，会推送成员减少事件
+func (p *MockProtocol) KickGroupMember(groupUin, userUin uint32, rejectAddRequest bool) error {
+	return p.do(MockAction{Action: "kick_group_member", GroupUin: groupUin, UserUin: userUin, Args: []any{rejectAddRequest}}, func(a *MockAction) (any, error) {
+		if err := p.requireAdmin(groupUin, userUin, false); err != nil {
+			return nil, err
+		}
+		p.removeMember(groupUin, userUin)
+		return p.memberDecrease(groupUin, userUin, p.uin), nil
+	})
+}
+
+// SetEssenceMessage 设置或移除精华消息
+func (p *MockProtocol) SetEssenceMessage(groupUin, seq, random uint32, isSet bool) error {
+	return p.do(MockAction{Action: "set_essence_message", GroupUin: groupUin, Args: []any{seq, random, isSet}}, func(a *MockAction) (any, error) {
+		return nil, p.requireAdmin(groupUin, 0, false)
+	})
+}
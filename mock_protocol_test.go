@@ -0,0 +1,102 @@
+package cryo_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/LagrangeDev/LagrangeGo/client"
+	"github.com/LagrangeDev/LagrangeGo/client/entity"
+	"github.com/LagrangeDev/LagrangeGo/client/event"
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/machinacanis/cryo"
+)
+
+func mockText(s string) []lgrmessage.IMessageElement {
+	return []lgrmessage.IMessageElement{lgrmessage.NewText(s)}
+}
+
+func TestMockProtocolSend(t *testing.T) {
+	p := cryo.NewMockProtocol(10000)
+
+	// Bot不在群中时和真实的服务器一样没有回执
+	if sent, err := p.SendGroupMessage(100, mockText("hi")); err != nil || sent != nil {
+		t.Fatalf("发送到不在的群返回 %v %v，期望没有回执也没有错误", sent, err)
+	}
+	if a := p.Actions(); len(a) != 1 || !a[0].Rejected {
+		t.Fatalf("被拒绝的消息没有被记录：%+v", a)
+	}
+
+	p.AddGroup(100, "测试群")
+	sent, err := p.SendGroupMessage(100, mockText("hi"))
+	if err != nil || sent == nil || sent.GroupName != "测试群" {
+		t.Fatalf("发送群消息返回 %v %v", sent, err)
+	}
+
+	injected := errors.New("injected")
+	p.FailNext("send_group_message", injected)
+	if _, err := p.SendGroupMessage(100, mockText("fail")); !errors.Is(err, injected) {
+		t.Fatalf("注入的错误没有生效：%v", err)
+	}
+	if _, err := p.SendGroupMessage(100, mockText("again")); err != nil {
+		t.Fatalf("FailNext 只应该生效一次：%v", err)
+	}
+
+	p.SetOnline(false)
+	if _, err := p.SendGroupMessage(100, mockText("offline")); !errors.Is(err, client.ErrNotOnline) {
+		t.Fatalf("离线时发送消息返回 %v，期望 ErrNotOnline", err)
+	}
+
+	sentMessages := p.SentMessages()
+	if len(sentMessages) != 2 {
+		t.Fatalf("成功发送了 %d 条消息，期望 2 条：%+v", len(sentMessages), sentMessages)
+	}
+	for i, text := range []string{"hi", "again"} {
+		if got := sentMessages[i].Message.ToString(); got != text {
+			t.Errorf("第 %d 条消息为 %q，期望 %q", i, got, text)
+		}
+	}
+	if n := len(p.Actions()); n != 5 {
+		t.Errorf("记录了 %d 个操作，期望 5 个", n)
+	}
+}
+
+func TestMockProtocolPermission(t *testing.T) {
+	p := cryo.NewMockProtocol(10000)
+	var events []any
+	p.Subscribe(func(e any) { events = append(events, e) })
+	p.AddGroup(100, "")
+	p.AddMember(100, 1001, "成员")
+	p.AddMember(100, 1002, "群主", entity.Owner)
+
+	if err := p.KickGroupMember(100, 1001, false); !errors.Is(err, cryo.ErrPermissionDenied) {
+		t.Fatalf("普通成员踢人返回 %v，期望 ErrPermissionDenied", err)
+	}
+	if err := p.KickGroupMember(200, 1001, false); !errors.Is(err, cryo.ErrGroupNotFound) {
+		t.Fatalf("在不存在的群中踢人返回 %v，期望 ErrGroupNotFound", err)
+	}
+
+	p.AddMember(100, 10000, "", entity.Admin)
+	if err := p.KickGroupMember(100, 1002, false); !errors.Is(err, cryo.ErrPermissionDenied) {
+		t.Fatalf("管理员踢群主返回 %v，期望 ErrPermissionDenied", err)
+	}
+	if err := p.KickGroupMember(100, 1001, true); err != nil {
+		t.Fatalf("管理员踢人失败：%v", err)
+	}
+	if _, err := p.FetchGroupMember(100, 1001); !errors.Is(err, client.ErrMemberNotFound) {
+		t.Errorf("被踢出的成员仍然在群中：%v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("推送了 %d 个事件，期望 1 个：%v", len(events), events)
+	}
+	if e, ok := events[0].(*event.GroupMemberDecrease); !ok || e.UserUin != 1001 || e.OperatorUin != 10000 {
+		t.Errorf("推送的事件不正确：%#v", events[0])
+	}
+
+	kicks := p.ActionsOf("kick_group_member")
+	if len(kicks) != 4 {
+		t.Fatalf("记录了 %d 次踢人操作，期望 4 次", len(kicks))
+	}
+	if last := kicks[3]; last.Err != nil || last.UserUin != 1001 || len(last.Args) != 1 || last.Args[0] != true {
+		t.Errorf("最后一次踢人操作记录不正确：%+v", last)
+	}
+}
@@ -0,0 +1,301 @@
+package cryo
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/gorilla/websocket"
+)
+
+// OneBotMessageFormat OneBot 上报事件中消息的格式
+type OneBotMessageFormat string
+
+const (
+	OneBotStringFormat OneBotMessageFormat = "string" // CQ码字符串
+	OneBotArrayFormat  OneBotMessageFormat = "array"  // 消息段数组
+)
+
+// OneBotConfig OneBot v11 适配器的配置
+type OneBotConfig struct {
+	Addr              string              `json:"addr,omitempty,omitzero"`               // HTTP API 和正向 WebSocket 的监听地址，例如 127.0.0.1:5700，为空时不监听
+	AccessToken       string              `json:"access_token,omitempty,omitzero"`       // 访问令牌，为空时不校验
+	MessageFormat     OneBotMessageFormat `json:"message_format,omitempty,omitzero"`     // 上报消息的格式，默认为 string
+	ReverseURLs       []string            `json:"reverse_urls,omitempty,omitzero"`       // 反向 WebSocket 的地址，每个Bot账号会分别建立一条 Universal 连接
+	ReconnectInterval time.Duration       `json:"reconnect_interval,omitempty,omitzero"` // 反向 WebSocket 断开后的重连间隔，例如 "5s"，默认为5秒
+	HTTPPostURLs      []string            `json:"http_post_urls,omitempty,omitzero"`     // HTTP POST 上报地址
+	HTTPPostSecret    string              `json:"http_post_secret,omitempty,omitzero"`   // HTTP POST 上报的签名密钥，为空时不签名
+	HTTPPostTimeout   time.Duration       `json:"http_post_timeout,omitempty,omitzero"`  // HTTP POST 上报的超时时间，默认为5秒
+	HeartbeatInterval time.Duration       `json:"heartbeat_interval,omitempty,omitzero"` // WebSocket 心跳事件的间隔，为0时不发送心跳
+	AllowLocalFile    bool                `json:"allow_local_file,omitempty,omitzero"`   // 是否允许消息中的媒体使用 file:// 读取本地文件，默认关闭，只在连接的应用完全可信时开启
+}
+
+// WebSocket 连接的角色
+const (
+	oneBotUniversalRole = "Universal"
+	oneBotAPIRole       = "API"
+	oneBotEventRole     = "Event"
+)
+
+const oneBotUserAgent = "cryo/OneBot v11"
+
+// OneBotAdapter 是 OneBot v11 协议的适配器，以插件的形式运行
+//
+// 适配器把cryo的事件转换为 OneBot 事件，通过正向 WebSocket、反向 WebSocket 和 HTTP POST 上报，
+// 同时把收到的 OneBot 动作映射到对应Bot客户端的方法上，这样就可以直接对接现有的 OneBot 应用
+type OneBotAdapter struct {
+	*adapterBase
+	conf       OneBotConfig
+	httpClient *http.Client
+}
+
+// NewOneBotAdapter 创建一个新的 OneBot v11 适配器，需要通过 Bot.AddPlugin 添加到Bot中才会生效
+func NewOneBotAdapter(conf OneBotConfig) *OneBotAdapter {
+	if conf.MessageFormat == "" {
+		conf.MessageFormat = OneBotStringFormat
+	}
+	if conf.HTTPPostTimeout <= 0 {
+		conf.HTTPPostTimeout = 5 * time.Second
+	}
+	a := &OneBotAdapter{
+		adapterBase: newAdapterBase("OneBot v11", "0.1.0", "OneBot v11 HTTP 和 WebSocket 适配器"),
+		conf:        conf,
+		httpClient:  &http.Client{Timeout: conf.HTTPPostTimeout},
+	}
+	a.addr = conf.Addr
+	a.accessToken = conf.AccessToken
+	a.handler = a
+	a.report = a.reportEvent
+	a.open = a.openConn
+	a.receive = a.handleRequest
+	a.reverseURLs = conf.ReverseURLs
+	a.reversePerBot = true
+	if conf.ReconnectInterval > 0 {
+		a.reverseInterval = conf.ReconnectInterval
+	}
+	a.reverseHeader = a.reverseRequestHeader
+	a.heartbeatInterval = conf.HeartbeatInterval
+	a.heartbeat = a.sendHeartbeat
+	return a
+}
+
+// ServeHTTP 处理 HTTP API 和正向 WebSocket 请求，可以把适配器挂载到其他的 HTTP 服务上
+//
+// WebSocket 连接的路径为 / 时同时收发事件和动作，为 /api 时只处理动作，为 /event 时只上报事件，
+// 其他请求按照 /<action> 的格式作为 HTTP API 处理
+func (a *OneBotAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if status := checkAccessToken(r, a.conf.AccessToken); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	path := strings.Trim(r.URL.Path, "/")
+	if websocket.IsWebSocketUpgrade(r) {
+		var role string
+		switch path {
+		case "":
+			role = oneBotUniversalRole
+		case "api":
+			role = oneBotAPIRole
+		case "event":
+			role = oneBotEventRole
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		ws, err := a.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return // Upgrade 已经写入了错误响应
+		}
+		a.serveConn(&adapterConn{ws: ws, role: role})
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	params, err := oneBotRequestParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	selfId, _ := strconv.ParseUint(r.Header.Get("X-Self-ID"), 10, 32)
+	resp := a.callAction(uint32(selfId), path, params)
+	if resp.Retcode == oneBotRetUnsupported {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(data)
+}
+
+// oneBotRequestParams 读取 HTTP API 请求的参数，支持查询参数、JSON 和表单
+func oneBotRequestParams(r *http.Request) (adapterParams, error) {
+	params := adapterParams{}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			params[k] = v[0]
+		}
+	}
+	if r.Method != http.MethodPost {
+		return params, nil
+	}
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		body, err := io.ReadAll(io.LimitReader(r.Body, 16<<20))
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(body)) == 0 {
+			return params, nil
+		}
+		var p adapterParams
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, errors.New("请求体不是有效的JSON对象")
+		}
+		for k, v := range p {
+			params[k] = v
+		}
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		for k, v := range r.PostForm {
+			if len(v) > 0 {
+				params[k] = v[0]
+			}
+		}
+	}
+	return params, nil
+}
+
+// oneBotRequest 是通过 WebSocket 收到的动作请求
+type oneBotRequest struct {
+	Action string         `json:"action"`
+	Params adapterParams  `json:"params"`
+	Echo   jsontext.Value `json:"echo,omitzero"`
+}
+
+// openConn 在连接建立后发送生命周期事件
+func (a *OneBotAdapter) openConn(oc *adapterConn) {
+	if oc.role == "" {
+		oc.role = oneBotUniversalRole // 反向连接
+	}
+	if oc.role == oneBotAPIRole {
+		return
+	}
+	oc.ready.Store(true)
+	selfId := oc.selfId
+	if selfId == 0 {
+		if c, err := a.client(0); err == nil {
+			selfId = c.Uin
+		}
+	}
+	if data, err := marshalOneBot(oneBotLifecycle(selfId, "connect")); err == nil {
+		_ = oc.write(data)
+	}
+}
+
+// handleRequest 执行 WebSocket 连接收到的动作请求并返回结果
+func (a *OneBotAdapter) handleRequest(oc *adapterConn, data []byte) {
+	if oc.role == oneBotEventRole {
+		return // 只上报事件的连接不处理动作
+	}
+	var req oneBotRequest
+	var resp *oneBotResponse
+	if err := json.Unmarshal(data, &req); err != nil {
+		resp = oneBotFailed(oneBotRetBadParams, errors.New("请求不是有效的JSON对象"))
+	} else {
+		resp = a.callAction(oc.selfId, req.Action, req.Params)
+		resp.Echo = req.Echo
+	}
+	out, err := marshalOneBot(resp)
+	if err != nil {
+		a.logger.Errorf("[OneBot] 序列化动作 %s 的结果失败：%v", req.Action, err)
+		return
+	}
+	_ = oc.write(out)
+}
+
+// reportEvent 把事件上报给所有的连接和 HTTP POST 地址
+func (a *OneBotAdapter) reportEvent(e Event, ae *adapterEvent) {
+	data, err := marshalOneBot(a.convertEvent(ae))
+	if err != nil {
+		a.logger.Errorf("[OneBot] 序列化事件 %s 失败：%v", e.GetEventType().ToString(), err)
+		return
+	}
+	a.broadcast(ae.SelfId, data)
+	if len(a.conf.HTTPPostURLs) > 0 {
+		go a.post(e, ae.SelfId, data)
+	}
+}
+
+// post 通过 HTTP POST 上报事件，并执行响应中的快速操作
+func (a *OneBotAdapter) post(e Event, selfId uint32, data []byte) {
+	for _, url := range a.conf.HTTPPostURLs {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+		if err != nil {
+			a.logger.Errorf("[OneBot] HTTP POST 地址 %s 无效：%v", url, err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", oneBotUserAgent)
+		req.Header.Set("X-Self-ID", strconv.FormatUint(uint64(selfId), 10))
+		if a.conf.HTTPPostSecret != "" {
+			mac := hmac.New(sha1.New, []byte(a.conf.HTTPPostSecret))
+			mac.Write(data)
+			req.Header.Set("X-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		resp, err := a.httpClient.Do(req)
+		if err != nil {
+			a.logger.Warnf("[OneBot] 上报事件到 %s 失败：%v", url, err)
+			continue
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && len(bytes.TrimSpace(body)) > 0 {
+			a.quickOperation(e, body)
+		}
+	}
+}
+
+// reverseRequestHeader 构造反向 WebSocket 连接的请求头
+func (a *OneBotAdapter) reverseRequestHeader(selfId uint32) http.Header {
+	header := http.Header{}
+	header.Set("X-Self-ID", strconv.FormatUint(uint64(selfId), 10))
+	header.Set("X-Client-Role", oneBotUniversalRole)
+	header.Set("User-Agent", oneBotUserAgent)
+	if a.conf.AccessToken != "" {
+		header.Set("Authorization", "Bearer "+a.conf.AccessToken)
+	}
+	return header
+}
+
+// sendHeartbeat 向 WebSocket 连接发送心跳事件
+func (a *OneBotAdapter) sendHeartbeat(clients []*LagrangeClient) {
+	for _, c := range clients {
+		if data, err := marshalOneBot(oneBotHeartbeat(c, a.conf.HeartbeatInterval)); err == nil {
+			a.broadcast(c.Uin, data)
+		}
+	}
+}
+
+// marshalOneBot 序列化要发送给 OneBot 应用的数据，对象的键按字典序排列
+func marshalOneBot(v any) ([]byte, error) {
+	return json.Marshal(v, json.Deterministic(true))
+}
@@ -0,0 +1,386 @@
+package cryo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/gorilla/websocket"
+)
+
+// OneBot12Config OneBot v12 适配器的配置
+type OneBot12Config struct {
+	Addr              string        `json:"addr,omitempty,omitzero"`               // HTTP 和正向 WebSocket 的监听地址，例如 127.0.0.1:6700，为空时不监听
+	AccessToken       string        `json:"access_token,omitempty,omitzero"`       // 访问令牌，为空时不校验
+	ReverseURLs       []string      `json:"reverse_urls,omitempty,omitzero"`       // 反向 WebSocket 的地址，所有Bot账号共用一条连接
+	ReconnectInterval time.Duration `json:"reconnect_interval,omitempty,omitzero"` // 反向 WebSocket 断开后的重连间隔，默认为5秒
+	WebhookURLs       []string      `json:"webhook_urls,omitempty,omitzero"`       // HTTP Webhook 上报地址
+	WebhookTimeout    time.Duration `json:"webhook_timeout,omitempty,omitzero"`    // HTTP Webhook 上报的超时时间，默认为5秒
+	HeartbeatInterval time.Duration `json:"heartbeat_interval,omitempty,omitzero"` // 心跳事件的间隔，为0时不发送心跳
+	AllowLocalFile    bool          `json:"allow_local_file,omitempty,omitzero"`   // 是否允许消息中的媒体使用 file:// 读取本地文件，默认关闭，只在连接的应用完全可信时开启
+}
+
+const (
+	oneBot12Platform  = "qq"
+	oneBot12Impl      = "cryo"
+	oneBot12Version   = "0.1.0"
+	oneBot12UserAgent = "OneBot/12 (" + oneBot12Platform + ") " + oneBot12Impl + "/" + oneBot12Version
+)
+
+// OneBot12Adapter 是 OneBot v12 协议的适配器，以插件的形式运行
+//
+// 一个适配器同时为所有已连接的Bot账号提供服务，事件和动作中通过 self 字段区分账号，
+// 支持 HTTP、HTTP Webhook、正向 WebSocket 和反向 WebSocket 四种通信方式
+type OneBot12Adapter struct {
+	*adapterBase
+	conf       OneBot12Config
+	httpClient *http.Client
+}
+
+// NewOneBot12Adapter 创建一个新的 OneBot v12 适配器，需要通过 Bot.AddPlugin 添加到Bot中才会生效
+func NewOneBot12Adapter(conf OneBot12Config) *OneBot12Adapter {
+	if conf.WebhookTimeout <= 0 {
+		conf.WebhookTimeout = 5 * time.Second
+	}
+	a := &OneBot12Adapter{
+		adapterBase: newAdapterBase("OneBot v12", oneBot12Version, "OneBot v12 HTTP 和 WebSocket 适配器"),
+		conf:        conf,
+		httpClient:  &http.Client{Timeout: conf.WebhookTimeout},
+	}
+	a.addr = conf.Addr
+	a.accessToken = conf.AccessToken
+	a.handler = a
+	a.report = a.reportEvent
+	a.open = a.openConn
+	a.receive = a.handleRequest
+	a.reverseURLs = conf.ReverseURLs
+	if conf.ReconnectInterval > 0 {
+		a.reverseInterval = conf.ReconnectInterval
+	}
+	a.reverseHeader = a.reverseRequestHeader
+	a.heartbeatInterval = conf.HeartbeatInterval
+	a.heartbeat = a.sendHeartbeat
+	return a
+}
+
+// oneBot12Self 是 OneBot v12 中的机器人自身标识
+type oneBot12Self struct {
+	Platform string `json:"platform"`
+	UserId   string `json:"user_id"`
+}
+
+// newOneBot12Self 构造Bot账号的自身标识
+func newOneBot12Self(uin uint32) oneBot12Self {
+	return oneBot12Self{Platform: oneBot12Platform, UserId: strconv.FormatUint(uint64(uin), 10)}
+}
+
+// oneBot12Request 是 OneBot v12 的动作请求
+type oneBot12Request struct {
+	Action string         `json:"action"`
+	Params adapterParams  `json:"params"`
+	Echo   jsontext.Value `json:"echo,omitzero"`
+	Self   *oneBot12Self  `json:"self,omitzero"`
+}
+
+// ServeHTTP 处理 HTTP 动作请求和正向 WebSocket 连接，可以把适配器挂载到其他的 HTTP 服务上
+func (a *OneBot12Adapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if status := checkAccessToken(r, a.conf.AccessToken); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		ws, err := a.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return // Upgrade 已经写入了错误响应
+		}
+		a.serveConn(&adapterConn{ws: ws})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 16<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := marshalOneBot(a.handleRaw(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// handleRaw 解析并执行一个动作请求
+func (a *OneBot12Adapter) handleRaw(data []byte) *oneBot12Response {
+	var req oneBot12Request
+	if err := json.Unmarshal(data, &req); err != nil || req.Action == "" {
+		return oneBot12Failed(oneBot12RetBadRequest, errors.New("请求不是有效的动作请求"))
+	}
+	var selfId uint32
+	if req.Self != nil {
+		if req.Self.Platform != oneBot12Platform {
+			resp := oneBot12Failed(oneBot12RetUnknownSelf, errors.New("未知的平台 "+req.Self.Platform))
+			resp.Echo = req.Echo
+			return resp
+		}
+		id, err := strconv.ParseUint(req.Self.UserId, 10, 32)
+		if err != nil || id == 0 {
+			resp := oneBot12Failed(oneBot12RetUnknownSelf, errors.New("未知的机器人 "+req.Self.UserId))
+			resp.Echo = req.Echo
+			return resp
+		}
+		selfId = uint32(id)
+	}
+	resp := a.callAction(selfId, req.Action, req.Params)
+	resp.Echo = req.Echo
+	return resp
+}
+
+// openConn 在连接建立后发送 connect 和 status_update 元事件
+func (a *OneBot12Adapter) openConn(oc *adapterConn) {
+	oc.ready.Store(true)
+	if data, err := marshalOneBot(a.metaEvent("connect", map[string]any{"version": oneBot12VersionInfo()})); err == nil {
+		_ = oc.write(data)
+	}
+	if data, err := marshalOneBot(a.statusUpdate(nil)); err == nil {
+		_ = oc.write(data)
+	}
+}
+
+// handleRequest 执行 WebSocket 连接收到的动作请求并返回结果
+func (a *OneBot12Adapter) handleRequest(oc *adapterConn, data []byte) {
+	out, err := marshalOneBot(a.handleRaw(data))
+	if err != nil {
+		a.logger.Errorf("[OneBot v12] 序列化动作结果失败：%v", err)
+		return
+	}
+	_ = oc.write(out)
+}
+
+// reportEvent 把事件上报给所有的连接和 Webhook 地址
+func (a *OneBot12Adapter) reportEvent(e Event, ae *adapterEvent) {
+	var payload map[string]any
+	if ae.Type == "meta_event" {
+		payload = a.statusUpdate(ae) // 账号上线和下线都通过状态更新事件通知
+	} else if payload = a.convertEvent(ae); payload == nil {
+		return
+	}
+	data, err := marshalOneBot(payload)
+	if err != nil {
+		a.logger.Errorf("[OneBot v12] 序列化事件 %s 失败：%v", e.GetEventType().ToString(), err)
+		return
+	}
+	a.broadcast(ae.SelfId, data)
+	if len(a.conf.WebhookURLs) > 0 {
+		go a.post(data)
+	}
+}
+
+// post 通过 HTTP Webhook 上报事件
+func (a *OneBot12Adapter) post(data []byte) {
+	for _, url := range a.conf.WebhookURLs {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+		if err != nil {
+			a.logger.Errorf("[OneBot v12] Webhook 地址 %s 无效：%v", url, err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", oneBot12UserAgent)
+		req.Header.Set("X-OneBot-Version", "12")
+		req.Header.Set("X-Impl", oneBot12Impl)
+		if a.conf.AccessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+a.conf.AccessToken)
+		}
+		resp, err := a.httpClient.Do(req)
+		if err != nil {
+			a.logger.Warnf("[OneBot v12] 上报事件到 %s 失败：%v", url, err)
+			continue
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		_ = resp.Body.Close()
+	}
+}
+
+// reverseRequestHeader 构造反向 WebSocket 连接的请求头
+func (a *OneBot12Adapter) reverseRequestHeader(uint32) http.Header {
+	header := http.Header{}
+	header.Set("User-Agent", oneBot12UserAgent)
+	header.Set("Sec-WebSocket-Protocol", "12."+oneBot12Impl)
+	if a.conf.AccessToken != "" {
+		header.Set("Authorization", "Bearer "+a.conf.AccessToken)
+	}
+	return header
+}
+
+// sendHeartbeat 向 WebSocket 连接发送心跳事件
+func (a *OneBot12Adapter) sendHeartbeat([]*LagrangeClient) {
+	payload := a.metaEvent("heartbeat", map[string]any{"interval": a.conf.HeartbeatInterval.Milliseconds()})
+	if data, err := marshalOneBot(payload); err == nil {
+		a.broadcast(0, data)
+	}
+}
+
+// oneBot12VersionInfo 获取实现的版本信息
+func oneBot12VersionInfo() map[string]any {
+	return map[string]any{"impl": oneBot12Impl, "version": oneBot12Version, "onebot_version": "12"}
+}
+
+// metaEvent 构造元事件，元事件没有 self 字段
+func (a *OneBot12Adapter) metaEvent(detailType string, fields map[string]any) map[string]any {
+	p := map[string]any{
+		"id":          newUUID(),
+		"time":        float64(time.Now().UnixMilli()) / 1000,
+		"type":        "meta",
+		"detail_type": detailType,
+		"sub_type":    "",
+	}
+	for k, v := range fields {
+		p[k] = v
+	}
+	return p
+}
+
+// statusUpdate 构造包含所有Bot账号状态的 status_update 元事件，lifecycle 不为nil时使用其中的账号状态
+func (a *OneBot12Adapter) statusUpdate(lifecycle *adapterEvent) map[string]any {
+	status := a.status()
+	if lifecycle != nil {
+		// 上线事件触发时账号还不在已连接的列表中，下线后又已经被移除，所以需要单独更新
+		bots := slices.DeleteFunc(status["bots"].([]map[string]any), func(b map[string]any) bool {
+			return b["self"] == newOneBot12Self(lifecycle.SelfId)
+		})
+		status["bots"] = append(bots, map[string]any{"self": newOneBot12Self(lifecycle.SelfId), "online": lifecycle.SubType == "enable"})
+	}
+	return a.metaEvent("status_update", map[string]any{"status": status})
+}
+
+// status 获取所有Bot账号的运行状态
+func (a *OneBot12Adapter) status() map[string]any {
+	bots := make([]map[string]any, 0)
+	if a.bot != nil {
+		for _, c := range a.bot.getConnectedClients() {
+			online := c.IsOnline()
+			bots = append(bots, map[string]any{"self": newOneBot12Self(c.Uin), "online": online})
+		}
+	}
+	return map[string]any{"good": true, "bots": bots}
+}
+
+// oneBot12Id 把QQ号、群号或者消息ID格式化为 OneBot v12 中的字符串ID
+func oneBot12Id[T uint32 | int32](id T) string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// oneBot12Notices OneBot v11 命名的通知到 OneBot v12 的类型映射，qq. 开头的是扩展的类型
+var oneBot12Notices = map[string]string{
+	"friend_recall":  "private_message_delete",
+	"friend_add":     "friend_increase",
+	"group_recall":   "group_message_delete",
+	"group_increase": "group_member_increase",
+	"group_decrease": "group_member_decrease",
+	"group_admin":    "qq.group_admin",
+	"group_ban":      "qq.group_ban",
+	"essence":        "qq.group_essence",
+}
+
+// convertEvent 把适配器的中间事件转换为 OneBot v12 事件，没有对应的事件时返回nil
+func (a *OneBot12Adapter) convertEvent(ae *adapterEvent) map[string]any {
+	p := map[string]any{
+		"id":       newUUID(),
+		"time":     float64(ae.Time),
+		"type":     ae.Type,
+		"sub_type": "",
+		"self":     newOneBot12Self(ae.SelfId),
+		"user_id":  oneBot12Id(ae.UserId),
+	}
+	if ae.GroupId != 0 && ae.Target.Type != TempTarget {
+		p["group_id"] = oneBot12Id(ae.GroupId)
+	}
+
+	switch ae.Type {
+	case "message":
+		p["message_id"] = oneBot12Id(ae.MessageId)
+		p["message"] = a.formatMessage(ae.SelfId, ae.Target, ae.Message)
+		p["alt_message"] = ae.Message.ToString()
+		p["qq.sender_name"] = ae.UserName
+		switch ae.Target.Type {
+		case GroupTarget:
+			p["detail_type"] = "group"
+			p["qq.sender_card"] = ae.Card
+			p["qq.sender_role"] = ae.Role
+		case TempTarget:
+			p["detail_type"] = "private"
+			p["sub_type"] = "qq.temp"
+			p["qq.group_id"] = oneBot12Id(ae.GroupId)
+		default:
+			p["detail_type"] = "private"
+		}
+	case "notice":
+		detail, ok := oneBot12Notices[ae.Detail]
+		switch {
+		case ok:
+			p["detail_type"] = detail
+		case ae.Detail == "notify" && ae.SubType == "poke":
+			p["detail_type"] = "qq.poke"
+			p["qq.target_id"] = oneBot12Id(ae.TargetId)
+		case ae.Detail == "notify" && ae.SubType == "title":
+			p["detail_type"] = "qq.group_title"
+			p["qq.title"] = ae.Title
+		default:
+			return nil
+		}
+		switch ae.Detail {
+		case "friend_recall":
+			p["message_id"] = oneBot12Id(ae.MessageId)
+		case "group_recall":
+			p["message_id"] = oneBot12Id(ae.MessageId)
+			p["operator_id"] = oneBot12Id(ae.OperatorId)
+			p["sub_type"] = "delete"
+			if ae.OperatorId == ae.UserId {
+				p["sub_type"] = "recall"
+			}
+		case "group_increase":
+			p["operator_id"] = oneBot12Id(ae.OperatorId)
+			p["sub_type"] = "join"
+			if ae.SubType == "invite" {
+				p["sub_type"] = "invite"
+			}
+		case "group_decrease":
+			p["operator_id"] = oneBot12Id(ae.OperatorId)
+			p["sub_type"] = "leave"
+			if ae.SubType != "leave" {
+				p["sub_type"] = "kick"
+			}
+		case "group_admin":
+			p["sub_type"] = ae.SubType
+		case "group_ban":
+			p["operator_id"] = oneBot12Id(ae.OperatorId)
+			p["sub_type"] = ae.SubType
+			p["qq.duration"] = ae.Duration
+		case "essence":
+			p["message_id"] = oneBot12Id(ae.MessageId)
+			p["operator_id"] = oneBot12Id(ae.OperatorId)
+			p["sub_type"] = ae.SubType
+		}
+	case "request":
+		p["detail_type"] = "qq.friend_request"
+		if ae.Detail == "group" {
+			p["detail_type"] = "qq.group_request"
+			p["sub_type"] = ae.SubType
+		}
+		p["qq.comment"] = ae.Comment
+		p["qq.flag"] = ae.Flag
+	default:
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,473 @@
+package cryo
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// OneBot v12 动作的返回码
+const (
+	oneBot12RetOK                 = 0     // 成功
+	oneBot12RetBadRequest         = 10001 // 无效的动作请求
+	oneBot12RetUnsupportedAction  = 10002 // 不支持的动作
+	oneBot12RetBadParam           = 10003 // 参数错误
+	oneBot12RetUnsupportedSegment = 10005 // 不支持的消息段类型
+	oneBot12RetWhoAmI             = 10101 // 有多个机器人时没有指定 self
+	oneBot12RetUnknownSelf        = 10102 // 未知的机器人
+	oneBot12RetFailed             = 34000 // 执行失败
+)
+
+// errOneBot12Segment 是消息段类型不受支持时返回的错误
+var errOneBot12Segment = errors.New("不支持的消息段")
+
+// oneBot12Response 是 OneBot v12 动作的响应
+type oneBot12Response struct {
+	Status  string         `json:"status"`
+	Retcode int            `json:"retcode"`
+	Data    any            `json:"data"`
+	Message string         `json:"message"`
+	Echo    jsontext.Value `json:"echo,omitzero"`
+}
+
+// oneBot12Failed 构造失败的响应
+func oneBot12Failed(retcode int, err error) *oneBot12Response {
+	return &oneBot12Response{Status: "failed", Retcode: retcode, Message: err.Error()}
+}
+
+// oneBot12Action 是 OneBot v12 动作的处理函数，不需要Bot账号的动作 c 为nil
+type oneBot12Action func(a *OneBot12Adapter, c *LagrangeClient, p adapterParams) (any, error)
+
+// oneBot12Actions 支持的 OneBot v12 动作，qq. 开头的是扩展动作
+var oneBot12Actions = map[string]oneBot12Action{
+	"send_message":          (*OneBot12Adapter).sendMessageAction,
+	"delete_message":        (*OneBot12Adapter).deleteMessage,
+	"get_self_info":         (*OneBot12Adapter).getSelfInfo,
+	"get_user_info":         (*OneBot12Adapter).getUserInfo,
+	"get_friend_list":       (*OneBot12Adapter).getFriendList,
+	"get_group_info":        (*OneBot12Adapter).getGroupInfo,
+	"get_group_list":        (*OneBot12Adapter).getGroupList,
+	"get_group_member_info": (*OneBot12Adapter).getGroupMemberInfo,
+	"get_group_member_list": (*OneBot12Adapter).getGroupMemberList,
+	"set_group_name":        (*OneBot12Adapter).setGroupName,
+	"get_status":            (*OneBot12Adapter).getStatus,
+	"get_version":           (*OneBot12Adapter).getVersion,
+	"qq.set_group_ban":      (*OneBot12Adapter).setGroupBan,
+	"qq.kick_group_member":  (*OneBot12Adapter).kickGroupMember,
+	"qq.set_group_admin":    (*OneBot12Adapter).setGroupAdmin,
+	"qq.set_group_card":     (*OneBot12Adapter).setGroupCard,
+	"qq.set_friend_request": (*OneBot12Adapter).setFriendRequest,
+	"qq.set_group_request":  (*OneBot12Adapter).setGroupRequest,
+}
+
+// oneBot12MetaActions 与Bot账号无关的动作
+var oneBot12MetaActions = map[string]bool{
+	"get_status":  true,
+	"get_version": true,
+}
+
+// callAction 执行 OneBot v12 动作，selfId 为0且只有一个在线账号时使用这个账号
+func (a *OneBot12Adapter) callAction(selfId uint32, action string, p adapterParams) *oneBot12Response {
+	if p == nil {
+		p = adapterParams{}
+	}
+	if action == "get_supported_actions" {
+		// 这个动作需要遍历动作表，不能放在表中
+		actions := append(slices.Sorted(maps.Keys(oneBot12Actions)), action)
+		return &oneBot12Response{Status: "ok", Retcode: oneBot12RetOK, Data: actions}
+	}
+	handler, ok := oneBot12Actions[action]
+	if !ok {
+		return oneBot12Failed(oneBot12RetUnsupportedAction, fmt.Errorf("不支持的动作 %q", action))
+	}
+	var c *LagrangeClient
+	if !oneBot12MetaActions[action] {
+		if selfId == 0 && a.bot != nil && len(a.bot.getConnectedClients()) > 1 {
+			return oneBot12Failed(oneBot12RetWhoAmI, errors.New("有多个Bot账号在线，需要通过 self 指定账号"))
+		}
+		var err error
+		if c, err = a.client(selfId); err != nil {
+			return oneBot12Failed(oneBot12RetUnknownSelf, err)
+		}
+	}
+	data, err := handler(a, c, p)
+	if err != nil {
+		switch {
+		case errors.Is(err, errOneBot12Segment):
+			return oneBot12Failed(oneBot12RetUnsupportedSegment, err)
+		case errors.Is(err, errAdapterParams):
+			return oneBot12Failed(oneBot12RetBadParam, err)
+		}
+		return oneBot12Failed(oneBot12RetFailed, err)
+	}
+	return &oneBot12Response{Status: "ok", Retcode: oneBot12RetOK, Data: data}
+}
+
+// oneBot12Segment 是 OneBot v12 的消息段
+type oneBot12Segment struct {
+	Type string         `json:"type"`
+	Data map[string]any `json:"data"`
+}
+
+// formatMessage 把消息转换为 OneBot v12 的消息段，回复元素中的消息序号会被替换为消息ID
+func (a *OneBot12Adapter) formatMessage(selfId uint32, target SendTarget, m Message) []oneBot12Segment {
+	segments := make([]oneBot12Segment, 0, len(m))
+	for _, e := range m {
+		var seg oneBot12Segment
+		switch v := e.(type) {
+		case *Text:
+			seg = oneBot12Segment{"text", map[string]any{"text": v.Content}}
+		case *At:
+			if v.TargetUin == 0 {
+				seg = oneBot12Segment{"mention_all", map[string]any{}}
+			} else {
+				seg = oneBot12Segment{"mention", map[string]any{"user_id": oneBot12Id(v.TargetUin)}}
+			}
+		case *Reply:
+			seg = oneBot12Segment{"reply", map[string]any{
+				"message_id": oneBot12Id(a.replyId(selfId, target, v)),
+				"user_id":    oneBot12Id(v.SenderUin),
+			}}
+		case *Image:
+			seg = oneBot12Segment{"image", map[string]any{"file_id": v.ImageID, "url": v.URL}}
+		case *Voice:
+			seg = oneBot12Segment{"voice", map[string]any{"file_id": v.UUID, "url": v.URL}}
+		case *ShortVideo:
+			seg = oneBot12Segment{"video", map[string]any{"file_id": v.UUID, "url": v.URL}}
+		case *File:
+			seg = oneBot12Segment{"file", map[string]any{"file_id": v.FileID, "name": v.FileName, "url": v.FileURL}}
+		default:
+			// 其他元素使用和CQ码相同的参数，类型加上 qq. 前缀
+			typ, params := elementToCQParams(e)
+			if typ == "" {
+				continue
+			}
+			data := make(map[string]any, len(params)/2)
+			for i := 0; i+1 < len(params); i += 2 {
+				data[params[i]] = params[i+1]
+			}
+			seg = oneBot12Segment{"qq." + typ, data}
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+// oneBot12SegmentTypes OneBot v12 消息段类型到CQ码类型的映射
+var oneBot12SegmentTypes = map[string]string{
+	"text":        "text",
+	"mention":     "at",
+	"mention_all": "at",
+	"reply":       "reply",
+	"image":       "image",
+	"voice":       "record",
+	"audio":       "record",
+	"video":       "video",
+	"file":        "file",
+	"qq.face":     "face",
+	"qq.forward":  "forward",
+	"qq.json":     "json",
+	"qq.xml":      "xml",
+}
+
+// parseMessage 读取参数中的消息段数组，回复消息段的 message_id 会被还原为被回复的消息
+//
+// 媒体消息段的 file_id 和CQ码的 file 参数一致，可以是 base64:// 开头的数据、已经上传过的媒体ID或者链接，
+// 开启 AllowLocalFile 时还可以是 file:// 开头的本地路径
+func (a *OneBot12Adapter) parseMessage(p adapterParams) (Message, error) {
+	var segments []any
+	switch v := p["message"].(type) {
+	case []any:
+		segments = v
+	case map[string]any:
+		segments = []any{v}
+	case string:
+		segments = []any{map[string]any{"type": "text", "data": map[string]any{"text": v}}}
+	default:
+		return nil, fmt.Errorf("%w：缺少 message", errAdapterParams)
+	}
+	m := make(Message, 0, len(segments))
+	for _, s := range segments {
+		seg, ok := s.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w：消息段不是对象", errAdapterParams)
+		}
+		typ, _ := seg["type"].(string)
+		cqType, ok := oneBot12SegmentTypes[typ]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", errOneBot12Segment, typ)
+		}
+		data, _ := seg["data"].(map[string]any)
+		dp := adapterParams(data)
+		params := make(map[string]string, len(data))
+		for k := range data {
+			params[k] = dp.getString(k)
+		}
+		switch typ {
+		case "mention":
+			params["qq"] = params["user_id"]
+		case "mention_all":
+			params["qq"] = "all"
+		case "reply":
+			params["id"] = params["message_id"]
+		case "image", "voice", "audio", "video", "file":
+			params["file"] = params["file_id"]
+			if params["file"] == "" {
+				params["file"] = params["url"]
+			}
+		}
+		e, err := cqElement(cqType, params, CQParseOptions{AllowLocalFile: a.conf.AllowLocalFile})
+		if err != nil {
+			return nil, fmt.Errorf("%w：%w", errAdapterParams, err)
+		}
+		m = append(m, e)
+	}
+	a.resolveReplies(m)
+	return m, nil
+}
+
+func (a *OneBot12Adapter) sendMessageAction(c *LagrangeClient, p adapterParams) (any, error) {
+	var target SendTarget
+	switch p.getString("detail_type") {
+	case "private":
+		userUin, err := p.requireUint32("user_id")
+		if err != nil {
+			return nil, err
+		}
+		target = SendTarget{Type: PrivateTarget, UserUin: userUin}
+	case "group":
+		groupUin, err := p.requireUint32("group_id")
+		if err != nil {
+			return nil, err
+		}
+		target = SendTarget{Type: GroupTarget, GroupUin: groupUin}
+	case "qq.temp":
+		groupUin, userUin, err := p.groupMember()
+		if err != nil {
+			return nil, err
+		}
+		target = SendTarget{Type: TempTarget, GroupUin: groupUin, UserUin: userUin}
+	default:
+		return nil, fmt.Errorf("%w：未知的 detail_type", errAdapterParams)
+	}
+	m, err := a.parseMessage(p)
+	if err != nil {
+		return nil, err
+	}
+	id, err := a.sendMessage(c, target, m)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"message_id": oneBot12Id(id), "time": float64(time.Now().UnixMilli()) / 1000}, nil
+}
+
+func (a *OneBot12Adapter) deleteMessage(c *LagrangeClient, p adapterParams) (any, error) {
+	id, ok, err := p.getInt64("message_id")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w：缺少 message_id", errAdapterParams)
+	}
+	rec := a.store.get(int32(id))
+	if rec == nil {
+		return nil, fmt.Errorf("%w：消息 %d 不存在或者已经过期", errAdapterParams, id)
+	}
+	return nil, a.recallMessage(c, rec)
+}
+
+func (a *OneBot12Adapter) getSelfInfo(c *LagrangeClient, p adapterParams) (any, error) {
+	return map[string]any{"user_id": oneBot12Id(c.Uin), "user_name": c.Nickname, "user_displayname": ""}, nil
+}
+
+// oneBot12User 构造 OneBot v12 格式的用户信息
+func oneBot12User(u *UserInfo) map[string]any {
+	return map[string]any{
+		"user_id":          oneBot12Id(u.Uin),
+		"user_name":        u.Nickname,
+		"user_displayname": "",
+		"user_remark":      u.Remarks,
+	}
+}
+
+func (a *OneBot12Adapter) getUserInfo(c *LagrangeClient, p adapterParams) (any, error) {
+	userUin, err := p.requireUint32("user_id")
+	if err != nil {
+		return nil, err
+	}
+	u, err := c.FetchUserInfo(userUin)
+	if err != nil {
+		return nil, err
+	}
+	return oneBot12User(u), nil
+}
+
+func (a *OneBot12Adapter) getFriendList(c *LagrangeClient, p adapterParams) (any, error) {
+	friends, err := c.GetFriendList()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0, len(friends))
+	for _, f := range friends {
+		result = append(result, oneBot12User(f))
+	}
+	return result, nil
+}
+
+// oneBot12Group 构造 OneBot v12 格式的群信息
+func oneBot12Group(g *GroupInfo) map[string]any {
+	return map[string]any{
+		"group_id":            oneBot12Id(g.GroupUin),
+		"group_name":          g.GroupName,
+		"qq.member_count":     g.MemberCount,
+		"qq.max_member_count": g.MaxMember,
+	}
+}
+
+// oneBot12Member 构造 OneBot v12 格式的群成员信息
+func oneBot12Member(m *MemberInfo) map[string]any {
+	return map[string]any{
+		"user_id":          oneBot12Id(m.Uin),
+		"user_name":        m.Nickname,
+		"user_displayname": m.MemberCard,
+		"qq.role":          adapterRole(m),
+		"qq.title":         m.SpecialTitle,
+		"qq.level":         m.GroupLevel,
+		"qq.join_time":     m.JoinTime,
+	}
+}
+
+func (a *OneBot12Adapter) getGroupInfo(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("group_id")
+	if err != nil {
+		return nil, err
+	}
+	g := c.GetGroup(groupUin)
+	if g == nil {
+		return nil, newActionError("get_group_info", groupUin, 0, ErrGroupNotFound, nil)
+	}
+	return oneBot12Group(g), nil
+}
+
+func (a *OneBot12Adapter) getGroupList(c *LagrangeClient, p adapterParams) (any, error) {
+	groups, err := c.GetGroupList()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, oneBot12Group(g))
+	}
+	return result, nil
+}
+
+func (a *OneBot12Adapter) getGroupMemberInfo(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	m := c.GetMember(groupUin, userUin)
+	if m == nil {
+		return nil, newActionError("get_group_member_info", groupUin, userUin, ErrMemberNotFound, nil)
+	}
+	return oneBot12Member(m), nil
+}
+
+func (a *OneBot12Adapter) getGroupMemberList(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("group_id")
+	if err != nil {
+		return nil, err
+	}
+	members, err := c.GetMemberList(groupUin)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0, len(members))
+	for _, m := range members {
+		result = append(result, oneBot12Member(m))
+	}
+	return result, nil
+}
+
+func (a *OneBot12Adapter) setGroupName(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("group_id")
+	if err != nil {
+		return nil, err
+	}
+	name := p.getString("group_name")
+	if name == "" {
+		return nil, fmt.Errorf("%w：缺少 group_name", errAdapterParams)
+	}
+	return nil, c.SetGroupName(groupUin, name)
+}
+
+func (a *OneBot12Adapter) getStatus(c *LagrangeClient, p adapterParams) (any, error) {
+	return a.status(), nil
+}
+
+func (a *OneBot12Adapter) getVersion(c *LagrangeClient, p adapterParams) (any, error) {
+	return oneBot12VersionInfo(), nil
+}
+
+func (a *OneBot12Adapter) setGroupBan(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	duration, ok, err := p.getInt64("duration")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		duration = 30 * 60
+	}
+	if duration <= 0 {
+		return nil, c.UnmuteGroupMember(groupUin, userUin)
+	}
+	return nil, c.MuteGroupMember(groupUin, userUin, durationOf(duration, time.Second))
+}
+
+func (a *OneBot12Adapter) kickGroupMember(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.KickGroupMember(groupUin, userUin, p.getBool("reject_add_request", false))
+}
+
+func (a *OneBot12Adapter) setGroupAdmin(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.SetGroupAdmin(groupUin, userUin, p.getBool("enable", true))
+}
+
+func (a *OneBot12Adapter) setGroupCard(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.SetGroupMemberCard(groupUin, userUin, p.getString("card"))
+}
+
+func (a *OneBot12Adapter) setFriendRequest(c *LagrangeClient, p adapterParams) (any, error) {
+	flag := p.getString("flag")
+	if flag == "" {
+		return nil, fmt.Errorf("%w：缺少 flag", errAdapterParams)
+	}
+	return nil, setAdapterRequest(c, flag, p.getBool("approve", true), "")
+}
+
+func (a *OneBot12Adapter) setGroupRequest(c *LagrangeClient, p adapterParams) (any, error) {
+	flag := p.getString("flag")
+	if strings.Count(flag, ":") != 2 {
+		return nil, fmt.Errorf("%w：flag 无效", errAdapterParams)
+	}
+	return nil, setAdapterRequest(c, flag, p.getBool("approve", true), p.getString("reason"))
+}
@@ -0,0 +1,180 @@
+package cryo_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/gorilla/websocket"
+	"github.com/machinacanis/cryo"
+	"github.com/machinacanis/cryo/cryotest"
+)
+
+const secondUin uint32 = 20000
+
+// newAdapterServer 创建一个连接了两个Bot账号的测试环境，并通过 httptest 挂载适配器
+func newAdapterServer(t *testing.T, adapter interface {
+	cryo.Plugin
+	http.Handler
+}) (*cryotest.Harness, *cryo.MockProtocol, *httptest.Server) {
+	t.Helper()
+	h := cryotest.New(t, adapter)
+	second := cryo.NewMockProtocol(secondUin)
+	h.Bot().ConnectWithProtocol(second)
+	srv := httptest.NewServer(adapter)
+	t.Cleanup(srv.Close)
+	return h, second, srv
+}
+
+// dialWebSocket 连接测试服务器上的 WebSocket
+func dialWebSocket(t *testing.T, srv *httptest.Server, path string) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+path, nil)
+	if err != nil {
+		t.Fatalf("连接 WebSocket 失败：%v", err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+// readUntil 读取 WebSocket 上的JSON消息，直到 match 返回true，超时时测试失败
+func readUntil(t *testing.T, ws *websocket.Conn, match func(map[string]any) bool) map[string]any {
+	t.Helper()
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("没有收到期望的消息：%v", err)
+		}
+		var v map[string]any
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Fatalf("消息不是JSON对象：%s", data)
+		}
+		if match(v) {
+			return v
+		}
+	}
+}
+
+// postJSON 发送JSON请求，响应成功时把JSON响应解析到 out 中，返回响应的状态码
+func postJSON(t *testing.T, url string, header http.Header, body, out any) int {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusOK && out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			t.Fatalf("响应不是有效的JSON：%s", raw)
+		}
+	}
+	return resp.StatusCode
+}
+
+func oneBot12SelfOf(v map[string]any) string {
+	self, _ := v["self"].(map[string]any)
+	id, _ := self["user_id"].(string)
+	return id
+}
+
+func TestOneBot12EventSelf(t *testing.T) {
+	h, second, srv := newAdapterServer(t, cryo.NewOneBot12Adapter(cryo.OneBot12Config{}))
+	ws := dialWebSocket(t, srv, "/")
+
+	status := readUntil(t, ws, func(v map[string]any) bool { return v["detail_type"] == "status_update" })
+	bots := status["status"].(map[string]any)["bots"].([]any)
+	if len(bots) != 2 {
+		t.Fatalf("status_update 中有 %d 个账号，期望 2 个：%v", len(bots), bots)
+	}
+
+	h.Mock().ReceiveGroupMessage(100, 1001, "来自第一个账号")
+	e := readUntil(t, ws, func(v map[string]any) bool { return v["type"] == "message" })
+	if got := oneBot12SelfOf(e); got != "10000" {
+		t.Errorf("第一个账号的事件 self 为 %q", got)
+	}
+	if e["group_id"] != "100" || e["alt_message"] != "来自第一个账号" {
+		t.Errorf("事件内容不正确：%v", e)
+	}
+
+	second.ReceiveGroupMessage(200, 2001, "来自第二个账号")
+	e = readUntil(t, ws, func(v map[string]any) bool { return v["type"] == "message" })
+	if got := oneBot12SelfOf(e); got != "20000" {
+		t.Errorf("第二个账号的事件 self 为 %q", got)
+	}
+	if e["group_id"] != "200" {
+		t.Errorf("事件内容不正确：%v", e)
+	}
+}
+
+func TestOneBot12ActionSelf(t *testing.T) {
+	h, second, srv := newAdapterServer(t, cryo.NewOneBot12Adapter(cryo.OneBot12Config{}))
+	h.Mock().AddGroup(100, "第一个账号的群")
+	second.AddGroup(200, "第二个账号的群")
+	send := map[string]any{
+		"action": "send_message",
+		"params": map[string]any{
+			"detail_type": "group", "group_id": "200",
+			"message": []any{map[string]any{"type": "text", "data": map[string]any{"text": "hi"}}},
+		},
+	}
+
+	// 有多个账号在线时必须指定 self
+	var resp map[string]any
+	postJSON(t, srv.URL, nil, send, &resp)
+	if resp["retcode"] != float64(10101) {
+		t.Fatalf("没有指定 self 时返回 %v，期望 10101", resp)
+	}
+
+	send["self"] = map[string]any{"platform": "qq", "user_id": "30000"}
+	postJSON(t, srv.URL, nil, send, &resp)
+	if resp["retcode"] != float64(10102) {
+		t.Fatalf("未知的 self 返回 %v，期望 10102", resp)
+	}
+
+	send["self"] = map[string]any{"platform": "qq", "user_id": "20000"}
+	postJSON(t, srv.URL, nil, send, &resp)
+	if resp["retcode"] != float64(0) {
+		t.Fatalf("发送消息失败：%v", resp)
+	}
+	if sent := second.SentMessages(); len(sent) != 1 || sent[0].GroupUin != 200 || sent[0].Message.ToString() != "hi" {
+		t.Errorf("第二个账号发送的消息不正确：%v", sent)
+	}
+	if sent := h.Mock().SentMessages(); len(sent) != 0 {
+		t.Errorf("第一个账号不应该发送消息：%v", sent)
+	}
+
+	// WebSocket 上的动作同样通过 self 选择账号
+	ws := dialWebSocket(t, srv, "/")
+	send["self"] = map[string]any{"platform": "qq", "user_id": "10000"}
+	send["params"].(map[string]any)["group_id"] = "100"
+	send["echo"] = "ws"
+	if err := ws.WriteJSON(send); err != nil {
+		t.Fatal(err)
+	}
+	resp = readUntil(t, ws, func(v map[string]any) bool { return v["echo"] == "ws" })
+	if resp["retcode"] != float64(0) {
+		t.Fatalf("通过 WebSocket 发送消息失败：%v", resp)
+	}
+	if sent := h.Mock().SentMessages(); len(sent) != 1 || sent[0].GroupUin != 100 {
+		t.Errorf("第一个账号发送的消息不正确：%v", sent)
+	}
+}
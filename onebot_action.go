@@ -0,0 +1,562 @@
+package cryo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// OneBot 动作的返回码
+const (
+	oneBotRetOK          = 0    // 成功
+	oneBotRetAsync       = 1    // 已提交异步处理
+	oneBotRetBadParams   = 100  // 参数缺失或者无效
+	oneBotRetFailed      = 103  // 执行失败
+	oneBotRetUnsupported = 1404 // 不支持的动作
+)
+
+// oneBotResponse 是 OneBot 动作的响应
+type oneBotResponse struct {
+	Status  string         `json:"status"`
+	Retcode int            `json:"retcode"`
+	Data    any            `json:"data"`
+	Message string         `json:"message,omitempty"`
+	Wording string         `json:"wording,omitempty"`
+	Echo    jsontext.Value `json:"echo,omitzero"`
+}
+
+// oneBotFailed 构造失败的响应
+func oneBotFailed(retcode int, err error) *oneBotResponse {
+	return &oneBotResponse{Status: "failed", Retcode: retcode, Message: err.Error(), Wording: err.Error()}
+}
+
+// oneBotAction 是 OneBot 动作的处理函数
+type oneBotAction func(a *OneBotAdapter, c *LagrangeClient, p adapterParams) (any, error)
+
+// oneBotActions 支持的 OneBot 动作
+var oneBotActions = map[string]oneBotAction{
+	"send_private_msg":        (*OneBotAdapter).sendPrivateMsg,
+	"send_group_msg":          (*OneBotAdapter).sendGroupMsg,
+	"send_msg":                (*OneBotAdapter).sendMsg,
+	"delete_msg":              (*OneBotAdapter).deleteMsg,
+	"get_msg":                 (*OneBotAdapter).getMsg,
+	"set_group_kick":          (*OneBotAdapter).setGroupKick,
+	"set_group_ban":           (*OneBotAdapter).setGroupBan,
+	"set_group_whole_ban":     (*OneBotAdapter).setGroupWholeBan,
+	"set_group_admin":         (*OneBotAdapter).setGroupAdmin,
+	"set_group_card":          (*OneBotAdapter).setGroupCard,
+	"set_group_name":          (*OneBotAdapter).setGroupName,
+	"set_group_special_title": (*OneBotAdapter).setGroupSpecialTitle,
+	"set_friend_add_request":  (*OneBotAdapter).setFriendAddRequest,
+	"set_group_add_request":   (*OneBotAdapter).setGroupAddRequest,
+	"get_login_info":          (*OneBotAdapter).getLoginInfo,
+	"get_stranger_info":       (*OneBotAdapter).getStrangerInfo,
+	"get_friend_list":         (*OneBotAdapter).getFriendList,
+	"get_group_info":          (*OneBotAdapter).getGroupInfo,
+	"get_group_list":          (*OneBotAdapter).getGroupList,
+	"get_group_member_info":   (*OneBotAdapter).getGroupMemberInfo,
+	"get_group_member_list":   (*OneBotAdapter).getGroupMemberList,
+	"get_status":              (*OneBotAdapter).getStatus,
+	"get_version_info":        (*OneBotAdapter).getVersionInfo,
+	"can_send_image":          (*OneBotAdapter).canSend,
+	"can_send_record":         (*OneBotAdapter).canSend,
+}
+
+// callAction 执行 OneBot 动作，selfId 为0时使用参数中的 self_id 或者Uin最小的在线客户端
+//
+// 动作名称以 _async 结尾时会在后台执行并立即返回
+func (a *OneBotAdapter) callAction(selfId uint32, action string, p adapterParams) *oneBotResponse {
+	if p == nil {
+		p = adapterParams{}
+	}
+	name, async := strings.CutSuffix(action, "_async")
+	handler, ok := oneBotActions[name]
+	if !ok {
+		return oneBotFailed(oneBotRetUnsupported, fmt.Errorf("不支持的动作 %q", action))
+	}
+	if id, ok, _ := p.getUint32("self_id"); ok {
+		selfId = id
+	}
+	c, err := a.client(selfId)
+	if err != nil {
+		return oneBotFailed(oneBotRetFailed, err)
+	}
+	if async {
+		go func() {
+			if _, err := handler(a, c, p); err != nil {
+				a.logger.Warnf("[OneBot] 异步动作 %s 执行失败：%v", action, err)
+			}
+		}()
+		return &oneBotResponse{Status: "async", Retcode: oneBotRetAsync}
+	}
+	data, err := handler(a, c, p)
+	if err != nil {
+		if errors.Is(err, errAdapterParams) {
+			return oneBotFailed(oneBotRetBadParams, err)
+		}
+		return oneBotFailed(oneBotRetFailed, err)
+	}
+	return &oneBotResponse{Status: "ok", Retcode: oneBotRetOK, Data: data}
+}
+
+// parseMessage 读取参数中的消息，支持CQ码字符串、消息段数组和单个消息段
+//
+// 回复消息段的 id 是 OneBot 消息ID，会被还原为被回复的消息
+func (a *OneBotAdapter) parseMessage(p adapterParams, key string) (Message, error) {
+	var m Message
+	switch v := p[key].(type) {
+	case string:
+		if p.getBool("auto_escape", false) {
+			m = Message{}
+			m.AddText(v)
+			break
+		}
+		var err error
+		if m, err = ParseMessageWithOptions(v, a.cqOptions()); err != nil {
+			return nil, fmt.Errorf("%w：%w", errAdapterParams, err)
+		}
+	case []any:
+		m = make(Message, 0, len(v))
+		for _, s := range v {
+			e, err := oneBotSegmentElement(s, a.cqOptions())
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, e)
+		}
+	case map[string]any:
+		e, err := oneBotSegmentElement(v, a.cqOptions())
+		if err != nil {
+			return nil, err
+		}
+		m = Message{e}
+	default:
+		return nil, fmt.Errorf("%w：缺少 %s", errAdapterParams, key)
+	}
+	a.resolveReplies(m)
+	return m, nil
+}
+
+// cqOptions 获取解析动作中的消息使用的选项
+func (a *OneBotAdapter) cqOptions() CQParseOptions {
+	return CQParseOptions{AllowLocalFile: a.conf.AllowLocalFile}
+}
+
+// oneBotSegmentElement 把消息段转换为消息元素，消息段的参数和CQ码的参数一致
+func oneBotSegmentElement(s any, opt CQParseOptions) (MessageElement, error) {
+	seg, ok := s.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w：消息段不是对象", errAdapterParams)
+	}
+	typ, _ := seg["type"].(string)
+	data, _ := seg["data"].(map[string]any)
+	params := make(map[string]string, len(data))
+	for k := range data {
+		params[k] = adapterParams(data).getString(k)
+	}
+	e, err := cqElement(typ, params, opt)
+	if err != nil {
+		return nil, fmt.Errorf("%w：%w", errAdapterParams, err)
+	}
+	return e, nil
+}
+
+// oneBotMessageId 是发送消息动作的返回值
+type oneBotMessageId struct {
+	MessageId int32 `json:"message_id"`
+}
+
+// send 发送消息并保存消息ID
+func (a *OneBotAdapter) send(c *LagrangeClient, target SendTarget, p adapterParams) (any, error) {
+	m, err := a.parseMessage(p, "message")
+	if err != nil {
+		return nil, err
+	}
+	id, err := a.sendMessage(c, target, m)
+	if err != nil {
+		return nil, err
+	}
+	return oneBotMessageId{id}, nil
+}
+
+func (a *OneBotAdapter) sendPrivateMsg(c *LagrangeClient, p adapterParams) (any, error) {
+	userUin, err := p.requireUint32("user_id")
+	if err != nil {
+		return nil, err
+	}
+	// 带有 group_id 时通过临时会话发送
+	if groupUin, ok, err := p.getUint32("group_id"); err != nil {
+		return nil, err
+	} else if ok && groupUin != 0 {
+		return a.send(c, SendTarget{Type: TempTarget, GroupUin: groupUin, UserUin: userUin}, p)
+	}
+	return a.send(c, SendTarget{Type: PrivateTarget, UserUin: userUin}, p)
+}
+
+func (a *OneBotAdapter) sendGroupMsg(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("group_id")
+	if err != nil {
+		return nil, err
+	}
+	return a.send(c, SendTarget{Type: GroupTarget, GroupUin: groupUin}, p)
+}
+
+func (a *OneBotAdapter) sendMsg(c *LagrangeClient, p adapterParams) (any, error) {
+	switch p.getString("message_type") {
+	case "private":
+		return a.sendPrivateMsg(c, p)
+	case "group":
+		return a.sendGroupMsg(c, p)
+	case "":
+		if _, ok := p["group_id"]; ok {
+			return a.sendGroupMsg(c, p)
+		}
+		return a.sendPrivateMsg(c, p)
+	}
+	return nil, fmt.Errorf("%w：未知的 message_type", errAdapterParams)
+}
+
+// message 根据参数中的 message_id 获取保存的消息
+func (a *OneBotAdapter) message(p adapterParams) (*adapterMessageRecord, error) {
+	id, ok, err := p.getInt64("message_id")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w：缺少 message_id", errAdapterParams)
+	}
+	rec := a.store.get(int32(id))
+	if rec == nil {
+		return nil, fmt.Errorf("%w：消息 %d 不存在或者已经过期", errAdapterParams, id)
+	}
+	return rec, nil
+}
+
+func (a *OneBotAdapter) deleteMsg(c *LagrangeClient, p adapterParams) (any, error) {
+	rec, err := a.message(p)
+	if err != nil {
+		return nil, err
+	}
+	return nil, a.recallMessage(c, rec)
+}
+
+func (a *OneBotAdapter) getMsg(c *LagrangeClient, p adapterParams) (any, error) {
+	rec, err := a.message(p)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Message == nil {
+		return nil, fmt.Errorf("%w：没有保存消息 %s 的内容", errAdapterParams, p.getString("message_id"))
+	}
+	message, _ := a.formatMessage(rec.SelfId, rec.Target, rec.Message)
+	messageType := "private"
+	if rec.Target.Type == GroupTarget {
+		messageType = "group"
+	}
+	id, _, _ := p.getInt64("message_id")
+	return map[string]any{
+		"time":         rec.Time,
+		"message_type": messageType,
+		"message_id":   int32(id),
+		"real_id":      rec.Seq,
+		"sender":       map[string]any{"user_id": rec.SenderUin, "nickname": rec.SenderNickname},
+		"message":      message,
+	}, nil
+}
+
+func (a *OneBotAdapter) setGroupKick(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.KickGroupMember(groupUin, userUin, p.getBool("reject_add_request", false))
+}
+
+func (a *OneBotAdapter) setGroupBan(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	duration, ok, err := p.getInt64("duration")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		duration = 30 * 60
+	}
+	if duration <= 0 {
+		return nil, c.UnmuteGroupMember(groupUin, userUin)
+	}
+	return nil, c.MuteGroupMember(groupUin, userUin, durationOf(duration, time.Second))
+}
+
+func (a *OneBotAdapter) setGroupWholeBan(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("group_id")
+	if err != nil {
+		return nil, err
+	}
+	if p.getBool("enable", true) {
+		return nil, c.MuteGroup(groupUin)
+	}
+	return nil, c.UnmuteGroup(groupUin)
+}
+
+func (a *OneBotAdapter) setGroupAdmin(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.SetGroupAdmin(groupUin, userUin, p.getBool("enable", true))
+}
+
+func (a *OneBotAdapter) setGroupCard(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.SetGroupMemberCard(groupUin, userUin, p.getString("card"))
+}
+
+func (a *OneBotAdapter) setGroupName(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("group_id")
+	if err != nil {
+		return nil, err
+	}
+	name := p.getString("group_name")
+	if name == "" {
+		return nil, fmt.Errorf("%w：缺少 group_name", errAdapterParams)
+	}
+	return nil, c.SetGroupName(groupUin, name)
+}
+
+func (a *OneBotAdapter) setGroupSpecialTitle(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.SetGroupMemberSpecialTitle(groupUin, userUin, p.getString("special_title"))
+}
+
+func (a *OneBotAdapter) setFriendAddRequest(c *LagrangeClient, p adapterParams) (any, error) {
+	flag := p.getString("flag")
+	if flag == "" {
+		return nil, fmt.Errorf("%w：缺少 flag", errAdapterParams)
+	}
+	return nil, setAdapterRequest(c, flag, p.getBool("approve", true), "")
+}
+
+func (a *OneBotAdapter) setGroupAddRequest(c *LagrangeClient, p adapterParams) (any, error) {
+	flag := p.getString("flag")
+	if strings.Count(flag, ":") != 2 {
+		return nil, fmt.Errorf("%w：flag 无效", errAdapterParams)
+	}
+	return nil, setAdapterRequest(c, flag, p.getBool("approve", true), p.getString("reason"))
+}
+
+func (a *OneBotAdapter) getLoginInfo(c *LagrangeClient, p adapterParams) (any, error) {
+	return map[string]any{"user_id": c.Uin, "nickname": c.Nickname}, nil
+}
+
+func (a *OneBotAdapter) getStrangerInfo(c *LagrangeClient, p adapterParams) (any, error) {
+	userUin, err := p.requireUint32("user_id")
+	if err != nil {
+		return nil, err
+	}
+	u, err := c.FetchUserInfo(userUin)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"user_id": u.Uin, "nickname": u.Nickname, "sex": adapterSex(u.Sex), "age": u.Age}, nil
+}
+
+func (a *OneBotAdapter) getFriendList(c *LagrangeClient, p adapterParams) (any, error) {
+	friends, err := c.GetFriendList(p.getBool("no_cache", false))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0, len(friends))
+	for _, f := range friends {
+		result = append(result, map[string]any{"user_id": f.Uin, "nickname": f.Nickname, "remark": f.Remarks})
+	}
+	return result, nil
+}
+
+// oneBotGroup 构造 OneBot 格式的群信息
+func oneBotGroup(g *GroupInfo) map[string]any {
+	return map[string]any{
+		"group_id":         g.GroupUin,
+		"group_name":       g.GroupName,
+		"member_count":     g.MemberCount,
+		"max_member_count": g.MaxMember,
+	}
+}
+
+// oneBotMember 构造 OneBot 格式的群成员信息
+func oneBotMember(m *MemberInfo) map[string]any {
+	return map[string]any{
+		"group_id":          m.GroupUin,
+		"user_id":           m.Uin,
+		"nickname":          m.Nickname,
+		"card":              m.MemberCard,
+		"sex":               adapterSex(m.Sex),
+		"age":               m.Age,
+		"join_time":         m.JoinTime,
+		"last_sent_time":    m.LastMsgTime,
+		"level":             strconv.FormatUint(uint64(m.GroupLevel), 10),
+		"role":              adapterRole(m),
+		"unfriendly":        false,
+		"title":             m.SpecialTitle,
+		"title_expire_time": 0,
+		"card_changeable":   false,
+	}
+}
+
+func (a *OneBotAdapter) getGroupInfo(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("group_id")
+	if err != nil {
+		return nil, err
+	}
+	var g *GroupInfo
+	if p.getBool("no_cache", false) {
+		if g, err = c.RefreshGroup(groupUin); err != nil {
+			return nil, err
+		}
+	} else if g = c.GetGroup(groupUin); g == nil {
+		return nil, newActionError("get_group_info", groupUin, 0, ErrGroupNotFound, nil)
+	}
+	return oneBotGroup(g), nil
+}
+
+func (a *OneBotAdapter) getGroupList(c *LagrangeClient, p adapterParams) (any, error) {
+	groups, err := c.GetGroupList(p.getBool("no_cache", false))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, oneBotGroup(g))
+	}
+	return result, nil
+}
+
+func (a *OneBotAdapter) getGroupMemberInfo(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, userUin, err := p.groupMember()
+	if err != nil {
+		return nil, err
+	}
+	var m *MemberInfo
+	if p.getBool("no_cache", false) {
+		if m, err = c.RefreshMember(groupUin, userUin); err != nil {
+			return nil, err
+		}
+	} else if m = c.GetMember(groupUin, userUin); m == nil {
+		return nil, newActionError("get_group_member_info", groupUin, userUin, ErrMemberNotFound, nil)
+	}
+	return oneBotMember(m), nil
+}
+
+func (a *OneBotAdapter) getGroupMemberList(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("group_id")
+	if err != nil {
+		return nil, err
+	}
+	members, err := c.GetMemberList(groupUin, p.getBool("no_cache", false))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0, len(members))
+	for _, m := range members {
+		result = append(result, oneBotMember(m))
+	}
+	return result, nil
+}
+
+func (a *OneBotAdapter) getStatus(c *LagrangeClient, p adapterParams) (any, error) {
+	return oneBotStatus(c), nil
+}
+
+func (a *OneBotAdapter) getVersionInfo(c *LagrangeClient, p adapterParams) (any, error) {
+	return map[string]any{"app_name": "cryo", "app_version": a.GetPluginVersion(), "protocol_version": "v11"}, nil
+}
+
+func (a *OneBotAdapter) canSend(c *LagrangeClient, p adapterParams) (any, error) {
+	return map[string]any{"yes": true}, nil
+}
+
+// quickOperation 执行 HTTP POST 上报响应中的快速操作
+func (a *OneBotAdapter) quickOperation(e Event, body []byte) {
+	var op adapterParams
+	if err := json.Unmarshal(body, &op); err != nil || len(op) == 0 {
+		return
+	}
+	c := e.GetClient()
+	if c == nil {
+		return
+	}
+	var err error
+	switch v := e.(type) {
+	case MessageEvent:
+		err = a.quickReply(c, v, op)
+	case *NewFriendRequestEvent:
+		if _, ok := op["approve"]; ok {
+			err = c.SetFriendRequest(v.Uid, op.getBool("approve", true))
+		}
+	case *GroupMemberJoinRequestEvent:
+		if _, ok := op["approve"]; ok {
+			err = c.SetGroupJoinRequest(v.GroupUin, v.RequestSeqence, v.InviterUin != 0, op.getBool("approve", true), op.getString("reason"))
+		}
+	case *GroupInviteEvent:
+		if _, ok := op["approve"]; ok {
+			err = c.SetGroupInvitation(v.GroupUin, v.RequestSeqence, op.getBool("approve", true))
+		}
+	}
+	if err != nil {
+		a.logger.Warnf("[OneBot] 执行快速操作失败：%v", err)
+	}
+}
+
+// quickReply 执行消息事件的快速操作：回复、撤回、踢出和禁言
+func (a *OneBotAdapter) quickReply(c *LagrangeClient, e MessageEvent, op adapterParams) error {
+	target, ok := GetSendTarget(e)
+	if !ok {
+		return nil
+	}
+	me := e.GetUniMessageEvent()
+	if _, ok := op["reply"]; ok {
+		m, err := a.parseMessage(op, "reply")
+		if err != nil {
+			return err
+		}
+		if target.Type == GroupTarget && op.getBool("at_sender", true) {
+			m = append(Message{&At{*lgrmessage.NewAt(me.SenderUin)}, &Text{*lgrmessage.NewText(" ")}}, m...)
+		}
+		if _, err := a.sendMessage(c, target, m); err != nil {
+			return err
+		}
+	}
+	if target.Type != GroupTarget {
+		return nil
+	}
+	if op.getBool("delete", false) {
+		if err := e.Recall(); err != nil {
+			return err
+		}
+	}
+	if op.getBool("kick", false) {
+		return c.KickGroupMember(me.GroupUin, me.SenderUin, false)
+	}
+	if op.getBool("ban", false) {
+		duration, ok, _ := op.getInt64("ban_duration")
+		if !ok || duration <= 0 {
+			duration = 30 * 60
+		}
+		return c.MuteGroupMember(me.GroupUin, me.SenderUin, durationOf(duration, time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package cryo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// oneBotSegment 是 OneBot 的消息段
+type oneBotSegment struct {
+	Type string            `json:"type"`
+	Data map[string]string `json:"data"`
+}
+
+// formatMessage 把消息转换为 OneBot 格式，返回配置的上报格式和CQ码格式的原始消息
+//
+// 回复元素中的消息序号会被替换为 OneBot 消息ID
+func (a *OneBotAdapter) formatMessage(selfId uint32, target SendTarget, m Message) (message any, raw string) {
+	segments := make([]oneBotSegment, 0, len(m))
+	var sb strings.Builder
+	for _, e := range m {
+		typ, params := elementToCQParams(e)
+		if typ == "" {
+			continue
+		}
+		if r, ok := e.(*Reply); ok {
+			params = []string{"id", fmt.Sprint(a.replyId(selfId, target, r))}
+		}
+		if t, ok := e.(*Text); ok {
+			sb.WriteString(EscapeCQText(t.Content))
+		} else {
+			sb.WriteString(buildCQCode(typ, params...))
+		}
+		data := make(map[string]string, len(params)/2)
+		for i := 0; i+1 < len(params); i += 2 {
+			if params[i+1] != "" {
+				data[params[i]] = params[i+1]
+			}
+		}
+		segments = append(segments, oneBotSegment{Type: typ, Data: data})
+	}
+	if a.conf.MessageFormat == OneBotArrayFormat {
+		return segments, sb.String()
+	}
+	return sb.String(), sb.String()
+}
+
+// oneBotLifecycle 构造生命周期元事件
+func oneBotLifecycle(selfId uint32, subType string) map[string]any {
+	return map[string]any{
+		"time":            time.Now().Unix(),
+		"self_id":         selfId,
+		"post_type":       "meta_event",
+		"meta_event_type": "lifecycle",
+		"sub_type":        subType,
+	}
+}
+
+// oneBotHeartbeat 构造心跳元事件
+func oneBotHeartbeat(c *LagrangeClient, interval time.Duration) map[string]any {
+	return map[string]any{
+		"time":            time.Now().Unix(),
+		"self_id":         c.Uin,
+		"post_type":       "meta_event",
+		"meta_event_type": "heartbeat",
+		"status":          oneBotStatus(c),
+		"interval":        interval.Milliseconds(),
+	}
+}
+
+// oneBotStatus 获取Bot客户端的运行状态
+func oneBotStatus(c *LagrangeClient) map[string]any {
+	online := c.IsOnline()
+	return map[string]any{"online": online, "good": online}
+}
+
+// convertEvent 把适配器的中间事件转换为 OneBot v11 事件
+func (a *OneBotAdapter) convertEvent(ae *adapterEvent) map[string]any {
+	if ae.Type == "meta_event" {
+		return oneBotLifecycle(ae.SelfId, ae.SubType)
+	}
+	p := map[string]any{
+		"time":            ae.Time,
+		"self_id":         ae.SelfId,
+		"post_type":       ae.Type,
+		ae.Type + "_type": ae.Detail,
+		"user_id":         ae.UserId,
+	}
+	if ae.SubType != "" {
+		p["sub_type"] = ae.SubType
+	}
+	if ae.GroupId != 0 && ae.Detail != "private" {
+		p["group_id"] = ae.GroupId
+	}
+
+	switch ae.Type {
+	case "message":
+		message, raw := a.formatMessage(ae.SelfId, ae.Target, ae.Message)
+		p["message_id"] = ae.MessageId
+		p["message"] = message
+		p["raw_message"] = raw
+		p["font"] = 0
+		sender := map[string]any{"user_id": ae.UserId, "nickname": ae.UserName, "sex": "unknown", "age": 0}
+		switch ae.Target.Type {
+		case GroupTarget:
+			p["anonymous"] = nil
+			sender["card"] = ae.Card
+			sender["role"] = ae.Role
+			if ae.Title != "" {
+				sender["title"] = ae.Title
+			}
+		case TempTarget:
+			sender["group_id"] = ae.GroupId
+		}
+		p["sender"] = sender
+	case "notice":
+		switch ae.Detail {
+		case "friend_recall", "group_recall":
+			p["message_id"] = ae.MessageId
+		case "essence":
+			p["message_id"] = ae.MessageId
+			p["sender_id"] = ae.UserId
+		case "group_ban":
+			p["duration"] = ae.Duration
+		case "notify":
+			if ae.SubType == "poke" {
+				p["target_id"] = ae.TargetId
+			} else {
+				p["title"] = ae.Title
+			}
+		}
+		if strings.HasPrefix(ae.Detail, "group_") || ae.Detail == "essence" {
+			p["operator_id"] = ae.OperatorId
+		}
+	case "request":
+		p["comment"] = ae.Comment
+		p["flag"] = ae.Flag
+	}
+	return p
+}
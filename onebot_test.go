@@ -0,0 +1,183 @@
+package cryo_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/gorilla/websocket"
+	"github.com/machinacanis/cryo"
+)
+
+func TestOneBotHTTPAction(t *testing.T) {
+	h, second, srv := newAdapterServer(t, cryo.NewOneBotAdapter(cryo.OneBotConfig{}))
+	h.Mock().AddGroup(100, "")
+	second.AddGroup(200, "")
+
+	var resp struct {
+		Status  string         `json:"status"`
+		Retcode int            `json:"retcode"`
+		Data    map[string]any `json:"data"`
+	}
+	// 字符串格式的消息按CQ码解析
+	body := map[string]any{"group_id": 100, "message": "hi[CQ:face,id=14]"}
+	if status := postJSON(t, srv.URL+"/send_group_msg", nil, body, &resp); status != http.StatusOK || resp.Retcode != 0 {
+		t.Fatalf("send_group_msg 返回 %d %+v", status, resp)
+	}
+	if _, ok := resp.Data["message_id"]; !ok {
+		t.Errorf("send_group_msg 的结果中没有 message_id：%v", resp.Data)
+	}
+	sent := h.Mock().SentMessages()
+	if len(sent) != 1 || sent[0].GroupUin != 100 || !sent[0].Message.HasType(cryo.FaceType) {
+		t.Fatalf("发送的消息不正确：%+v", sent)
+	}
+
+	// 消息段数组格式的消息，X-Self-ID 选择发送的账号
+	body = map[string]any{"group_id": 200, "message": []any{
+		map[string]any{"type": "text", "data": map[string]any{"text": "[CQ:face,id=14]"}},
+	}}
+	header := http.Header{"X-Self-Id": {"20000"}}
+	if status := postJSON(t, srv.URL+"/send_group_msg", header, body, &resp); status != http.StatusOK || resp.Retcode != 0 {
+		t.Fatalf("使用消息段数组的 send_group_msg 返回 %d %+v", status, resp)
+	}
+	if sent := second.SentMessages(); len(sent) != 1 || sent[0].Message.ToString() != "[CQ:face,id=14]" {
+		t.Errorf("消息段中的文本应该原样发送：%+v", sent)
+	}
+
+	if status := postJSON(t, srv.URL+"/no_such_action", nil, map[string]any{}, nil); status != http.StatusNotFound {
+		t.Errorf("不支持的动作返回 %d，期望 404", status)
+	}
+}
+
+func TestOneBotWebSocketAction(t *testing.T) {
+	h, _, srv := newAdapterServer(t, cryo.NewOneBotAdapter(cryo.OneBotConfig{}))
+	h.Mock().AddGroup(100, "测试群")
+	ws := dialWebSocket(t, srv, "/api")
+
+	if err := ws.WriteJSON(map[string]any{"action": "get_group_info", "params": map[string]any{"group_id": 100}, "echo": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	resp := readUntil(t, ws, func(v map[string]any) bool { return v["echo"] == "1" })
+	data, _ := resp["data"].(map[string]any)
+	if resp["retcode"] != float64(0) || data["group_name"] != "测试群" {
+		t.Errorf("get_group_info 的结果不正确：%v", resp)
+	}
+}
+
+func TestOneBotMessageFormat(t *testing.T) {
+	for _, format := range []cryo.OneBotMessageFormat{cryo.OneBotStringFormat, cryo.OneBotArrayFormat} {
+		t.Run(string(format), func(t *testing.T) {
+			h, _, srv := newAdapterServer(t, cryo.NewOneBotAdapter(cryo.OneBotConfig{MessageFormat: format}))
+			ws := dialWebSocket(t, srv, "/event")
+			readUntil(t, ws, func(v map[string]any) bool { return v["meta_event_type"] == "lifecycle" })
+
+			h.Mock().ReceiveGroupMessage(100, 1001, "a&b", &cryo.Face{})
+			e := readUntil(t, ws, func(v map[string]any) bool { return v["post_type"] == "message" })
+			if e["raw_message"] != "a&amp;b[CQ:face,id=0]" {
+				t.Errorf("raw_message 为 %v", e["raw_message"])
+			}
+			switch format {
+			case cryo.OneBotStringFormat:
+				if e["message"] != e["raw_message"] {
+					t.Errorf("字符串格式的 message 为 %v，期望和 raw_message 相同", e["message"])
+				}
+			case cryo.OneBotArrayFormat:
+				segments, ok := e["message"].([]any)
+				if !ok || len(segments) != 2 {
+					t.Fatalf("数组格式的 message 为 %v", e["message"])
+				}
+				text, _ := segments[0].(map[string]any)
+				data, _ := text["data"].(map[string]any)
+				if text["type"] != "text" || data["text"] != "a&b" {
+					t.Errorf("第一个消息段为 %v，期望不转义的文本", text)
+				}
+				if face, _ := segments[1].(map[string]any); face["type"] != "face" {
+					t.Errorf("第二个消息段为 %v，期望表情", face)
+				}
+			}
+		})
+	}
+}
+
+func TestOneBotHTTPPostSignature(t *testing.T) {
+	type post struct {
+		header http.Header
+		body   []byte
+	}
+	posts := make(chan post, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		posts <- post{r.Header.Clone(), body}
+		var e map[string]any
+		if json.Unmarshal(body, &e) == nil && e["post_type"] == "message" {
+			_, _ = io.WriteString(w, `{"reply":"pong"}`) // 快速操作
+		}
+	}))
+	defer receiver.Close()
+
+	h, _, _ := newAdapterServer(t, cryo.NewOneBotAdapter(cryo.OneBotConfig{
+		HTTPPostURLs:   []string{receiver.URL},
+		HTTPPostSecret: "secret",
+	}))
+	h.Group(100).User(1001).Says("ping")
+
+	var p post
+	for p.header == nil || p.header.Get("X-Self-ID") != "10000" {
+		select {
+		case p = <-posts:
+		case <-time.After(2 * time.Second):
+			t.Fatal("没有收到 HTTP POST 上报")
+		}
+	}
+	mac := hmac.New(sha1.New, []byte("secret"))
+	mac.Write(p.body)
+	if want, got := "sha1="+hex.EncodeToString(mac.Sum(nil)), p.header.Get("X-Signature"); got != want {
+		t.Errorf("X-Signature 为 %q，期望 %q", got, want)
+	}
+	h.ExpectReply("pong")
+}
+
+func TestOneBotConfigJSON(t *testing.T) {
+	var conf cryo.OneBotConfig
+	data := `{"addr":"127.0.0.1:5700","access_token":"token","message_format":"array","reconnect_interval":"3s","http_post_urls":["http://127.0.0.1:8080"]}`
+	if err := json.Unmarshal([]byte(data), &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Addr != "127.0.0.1:5700" || conf.AccessToken != "token" || conf.MessageFormat != cryo.OneBotArrayFormat ||
+		conf.ReconnectInterval != 3*time.Second || len(conf.HTTPPostURLs) != 1 {
+		t.Errorf("解析出的配置不正确：%+v", conf)
+	}
+}
+
+func TestOneBotWebSocketOrigin(t *testing.T) {
+	dial := func(srv *httptest.Server, query string) (*http.Response, error) {
+		header := http.Header{"Origin": {"http://evil.example"}}
+		ws, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/event"+query, header)
+		if err == nil {
+			_ = ws.Close()
+		}
+		return resp, err
+	}
+
+	// 没有设置访问令牌时拒绝跨域的连接
+	_, _, srv := newAdapterServer(t, cryo.NewOneBotAdapter(cryo.OneBotConfig{}))
+	if resp, err := dial(srv, ""); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("没有访问令牌时跨域连接没有被拒绝：%v", err)
+	}
+
+	// 设置了访问令牌时通过令牌限制来源
+	_, _, srv = newAdapterServer(t, cryo.NewOneBotAdapter(cryo.OneBotConfig{AccessToken: "token"}))
+	if resp, err := dial(srv, ""); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("没有携带访问令牌的连接没有被拒绝：%v", err)
+	}
+	if _, err := dial(srv, "?access_token=token"); err != nil {
+		t.Errorf("携带访问令牌的跨域连接失败：%v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package cryo
+
+import (
+	"github.com/LagrangeDev/LagrangeGo/client"
+	"github.com/LagrangeDev/LagrangeGo/client/entity"
+	"github.com/LagrangeDev/LagrangeGo/client/packets/pb/service/oidb"
+	"github.com/LagrangeDev/LagrangeGo/message"
+)
+
+// Protocol 是Bot客户端和QQ服务器之间的协议实现，LagrangeClient 的所有操作和事件都会经过它
+//
+// 默认使用 LagrangeGo 实现，测试插件时可以换成完全在内存中运行的 MockProtocol，
+// 方法的签名和 LagrangeGo 的 client.QQClient 保持一致，返回的原始错误会被包装成 *ActionError
+type Protocol interface {
+	// Self 获取当前登录的账号
+	Self() (uin uint32, uid string)
+	// IsOnline 判断是否在线
+	IsOnline() bool
+	// Subscribe 订阅协议收到的事件，handler 收到的是 LagrangeGo 的事件指针，
+	// 例如 *message.GroupMessage、*event.GroupMute 和 *client.DisconnectedEvent
+	Subscribe(handler func(event any))
+
+	SendPrivateMessage(userUin uint32, elements []message.IMessageElement) (*message.PrivateMessage, error)
+	SendGroupMessage(groupUin uint32, elements []message.IMessageElement) (*message.GroupMessage, error)
+	SendTempMessage(groupUin, userUin uint32, elements []message.IMessageElement) (*message.TempMessage, error)
+	RecallFriendMessage(userUin, seq, random, clientSeq, timestamp uint32) error
+	RecallGroupMessage(groupUin, seq uint32) error
+	SetGroupReaction(groupUin, seq uint32, code string, isAdd bool) error
+	FriendPoke(userUin uint32) error
+	GroupPoke(groupUin, userUin uint32) error
+
+	UploadImage(target message.Source, image *message.ImageElement) (*message.ImageElement, error)
+	UploadForwardMsg(forward *message.ForwardMessage, groupUin uint32) (*message.ForwardMessage, error)
+	FetchForwardMsg(resId string) (*message.ForwardMessage, error)
+	UploadGroupFile(groupUin uint32, file *message.FileElement, targetDirectory string) (*message.FileElement, error)
+	SendPrivateFile(userUin uint32, localFilePath, filename string) error
+	ListGroupFilesByFolder(groupUin uint32, targetDirectory string) ([]*entity.GroupFile, []*entity.GroupFolder, error)
+	GetGroupFileURL(groupUin uint32, fileId string) (string, error)
+	GetPrivateFileURL(fileUUID string, fileHash string) (string, error)
+	GetGroupImageURL(groupUin uint32, node *oidb.IndexNode) (string, error)
+	GetPrivateImageURL(node *oidb.IndexNode) (string, error)
+	GetGroupRecordURL(groupUin uint32, node *oidb.IndexNode) (string, error)
+	GetPrivateRecordURL(node *oidb.IndexNode) (string, error)
+	GetGroupVideoURL(groupUin uint32, node *oidb.IndexNode) (string, error)
+	GetPrivateVideoURL(node *oidb.IndexNode) (string, error)
+
+	GetCachedFriendInfo(userUin uint32) *entity.User
+	GetCachedAllFriendsInfo() map[uint32]*entity.User
+	RefreshFriendCache() error
+	FetchUserInfoUin(userUin uint32) (*entity.User, error)
+	SetFriendRequest(accept bool, targetUid string) error
+	DeleteFriend(userUin uint32, block bool) error
+
+	GetCachedGroupInfo(groupUin uint32) *entity.Group
+	GetCachedAllGroupsInfo() map[uint32]*entity.Group
+	RefreshAllGroupsInfo() error
+	FetchGroupInfo(groupUin uint32, isStrange bool) (*entity.Group, error)
+	FetchGroupMember(groupUin, userUin uint32) (*entity.GroupMember, error)
+	GetGroupMembersData(groupUin uint32) (map[uint32]*entity.GroupMember, error)
+	GetGroupSystemMessages(isFiltered bool, count uint32, groupUin ...uint32) (*entity.GroupSystemMessages, error)
+	SetGroupRequest(isFiltered bool, accept bool, sequence uint64, typ uint32, groupUin uint32, message string) error
+	SetGroupName(groupUin uint32, name string) error
+	SetGroupGlobalMute(groupUin uint32, isMute bool) error
+	SetGroupMemberMute(groupUin, userUin, duration uint32) error
+	SetGroupAdmin(groupUin, userUin uint32, isAdmin bool) error
+	SetGroupMemberName(groupUin, userUin uint32, name string) error
+	SetGroupMemberSpecialTitle(groupUin, userUin uint32, title string) error
+	KickGroupMember(groupUin, userUin uint32, rejectAddRequest bool) error
+	SetEssenceMessage(groupUin, seq, random uint32, isSet bool) error
+}
+
+// lagrangeProtocol 是基于 LagrangeGo 的默认协议实现
+type lagrangeProtocol struct {
+	*client.QQClient
+}
+
+// Self 获取当前登录的账号
+func (p *lagrangeProtocol) Self() (uint32, string) {
+	sig := p.Sig()
+	if sig == nil {
+		return 0, ""
+	}
+	return sig.Uin, sig.UID
+}
+
+// IsOnline 判断是否在线
+func (p *lagrangeProtocol) IsOnline() bool {
+	return p.Online.Load()
+}
+
+// SendPrivateMessage 发送私聊消息
+func (p *lagrangeProtocol) SendPrivateMessage(userUin uint32, elements []message.IMessageElement) (*message.PrivateMessage, error) {
+	return p.QQClient.SendPrivateMessage(userUin, elements)
+}
+
+// SendGroupMessage 发送群聊消息
+func (p *lagrangeProtocol) SendGroupMessage(groupUin uint32, elements []message.IMessageElement) (*message.GroupMessage, error) {
+	return p.QQClient.SendGroupMessage(groupUin, elements)
+}
+
+// Subscribe 订阅 LagrangeGo 的所有事件
+func (p *lagrangeProtocol) Subscribe(handler func(event any)) {
+	subscribeLagrange(&p.DisconnectedEvent, handler)
+	subscribeLagrange(&p.PrivateMessageEvent, handler)
+	subscribeLagrange(&p.GroupMessageEvent, handler)
+	subscribeLagrange(&p.TempMessageEvent, handler)
+	subscribeLagrange(&p.NewFriendRequestEvent, handler)
+	subscribeLagrange(&p.NewFriendEvent, handler)
+	subscribeLagrange(&p.FriendRecallEvent, handler)
+	subscribeLagrange(&p.RenameEvent, handler)
+	subscribeLagrange(&p.GroupMemberPermissionChangedEvent, handler)
+	subscribeLagrange(&p.GroupNameUpdatedEvent, handler)
+	subscribeLagrange(&p.GroupMuteEvent, handler)
+	subscribeLagrange(&p.GroupRecallEvent, handler)
+	subscribeLagrange(&p.GroupMemberJoinRequestEvent, handler)
+	subscribeLagrange(&p.GroupMemberJoinEvent, handler)
+	subscribeLagrange(&p.GroupMemberLeaveEvent, handler)
+	subscribeLagrange(&p.GroupDigestEvent, handler)
+	subscribeLagrange(&p.GroupReactionEvent, handler)
+	subscribeLagrange(&p.MemberSpecialTitleUpdatedEvent, handler)
+	subscribeLagrange(&p.GroupInvitedEvent, handler)
+	// 戳一戳只会通过这两个通知事件推送，具体的类型是 *event.FriendPokeEvent 和 *event.GroupPokeEvent，
+	// 不订阅的话真实的客户端永远不会发布 FriendPokeEvent 和 GroupPokeEvent
+	subscribeLagrange(&p.FriendNotifyEvent, handler)
+	subscribeLagrange(&p.GroupNotifyEvent, handler)
+}
+
+func subscribeLagrange[T any](handle *client.EventHandle[T], handler func(event any)) {
+	handle.Subscribe(func(_ *client.QQClient, event T) {
+		handler(event)
+	})
+}
@@ -0,0 +1,178 @@
+package cryo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/machinacanis/cryo/log"
+)
+
+// RecorderConfig 是事件录制器的配置项，事件会被序列化后逐行追加到文件中，可以通过 Bot.Replay 回放
+type RecorderConfig struct {
+	File       string   `json:"file"`                           // 录制文件的路径，为空时不录制
+	EventTypes []string `json:"event_types,omitempty,omitzero"` // 录制的事件类型名称，为空时录制所有事件
+	MaxSize    int      `json:"max_size,omitempty,omitzero"`    // 单个录制文件的最大大小（MB），超过后会轮转，默认为10
+	MaxBackups int      `json:"max_backups,omitempty,omitzero"` // 保留的旧录制文件数量，默认为5
+}
+
+// recordLine 是录制文件中一行记录的JSON格式
+type recordLine struct {
+	Time  time.Time      `json:"time"`
+	Event jsontext.Value `json:"event"`
+}
+
+// EventRecorder 是事件录制器，文件超过大小限制后会被重命名为 <文件名>.1，之前的旧文件依次后移
+type EventRecorder struct {
+	conf    RecorderConfig
+	logger  log.CryoLogger
+	types   []EventType
+	id      string // 注册的中间件ID
+	mutex   sync.Mutex
+	file    *os.File
+	size    int64
+	maxSize int64
+	closed  bool
+}
+
+// NewEventRecorder 检查配置并创建事件录制器，录制文件会在录制第一个事件时打开
+func NewEventRecorder(conf RecorderConfig, logger log.CryoLogger) (*EventRecorder, error) {
+	if conf.File == "" {
+		return nil, errors.New("录制文件的路径不能为空")
+	}
+	if conf.MaxSize <= 0 {
+		conf.MaxSize = 10
+	}
+	if conf.MaxBackups <= 0 {
+		conf.MaxBackups = 5
+	}
+	types := make([]EventType, 0, len(conf.EventTypes))
+	for _, name := range conf.EventTypes {
+		et, ok := eventTypeByName[name]
+		if !ok {
+			return nil, fmt.Errorf("未知的事件类型 %q", name)
+		}
+		types = append(types, et)
+	}
+	return &EventRecorder{
+		conf:    conf,
+		logger:  logger,
+		types:   types,
+		maxSize: int64(conf.MaxSize) << 20,
+	}, nil
+}
+
+// GetId 获取录制器注册的中间件ID，没有通过 Bot.AddRecorder 注册时为空
+func (r *EventRecorder) GetId() string {
+	return r.id
+}
+
+// Record 录制一个事件，录制器关闭之后不做任何事
+func (r *EventRecorder) Record(e Event) error {
+	data, err := MarshalEvent(e)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(recordLine{Time: time.Now(), Event: data})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return nil
+	}
+	if r.file != nil && r.size+int64(len(line)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+// Close 关闭录制文件，之后录制的事件会被丢弃
+func (r *EventRecorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.closed = true
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// open 以追加模式打开录制文件
+func (r *EventRecorder) open() error {
+	f, err := os.OpenFile(r.conf.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+// rotate 关闭当前的录制文件并依次重命名旧文件，超出保留数量的旧文件会被删除
+func (r *EventRecorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+	_ = os.Remove(r.conf.File + "." + strconv.Itoa(r.conf.MaxBackups))
+	for i := r.conf.MaxBackups - 1; i > 0; i-- {
+		old := r.conf.File + "." + strconv.Itoa(i)
+		if err := os.Rename(old, r.conf.File+"."+strconv.Itoa(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(r.conf.File, r.conf.File+".1")
+}
+
+// handle 录制经过中间件的事件，回放的事件不会被录制
+func (r *EventRecorder) handle(e Event) Event {
+	if slices.Contains(e.GetEventTag(), ReplayTag) {
+		return e
+	}
+	if err := r.Record(e); err != nil {
+		r.logger.Errorf("[Recorder] 录制事件 %s 失败：%v", e.GetEventId(), err)
+	}
+	return e
+}
+
+// AddRecorder 添加一个事件录制器，录制器作为预处理中间件运行，会按照事件到达的顺序录制
+//
+// 在它之前注册的预处理中间件截断的事件不会被录制，配置文件中的录制器会在内置中间件之前注册，可以录制到所有事件；
+// 可以通过 EventBus.RemoveMiddlewareById 移除录制器的中间件后调用 EventRecorder.Close 停止录制
+func (b *Bot) AddRecorder(conf RecorderConfig) (*EventRecorder, error) {
+	r, err := NewEventRecorder(conf, b.Logger)
+	if err != nil {
+		return nil, err
+	}
+	mw := NewUniMiddleware(r.types...)
+	mw.AddTag("recorder")
+	mw.AddHandler(r.handle)
+	r.id = mw.GetId()
+	b.bus.AddPreMiddleware(mw)
+	return r, nil
+}
@@ -0,0 +1,54 @@
+package cryo_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/machinacanis/cryo"
+	"github.com/machinacanis/cryo/cryotest"
+)
+
+// onPing 让Bot收到 /ping 时回复 pong
+func onPing(bot *cryo.Bot) {
+	bot.OnCommand("/ping").Handle(func(e *cryo.UniMessageEvent) {
+		_, _ = bot.SendTo(e, "pong")
+	}).Register()
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+
+	recording := cryotest.New(t)
+	onPing(recording.Bot())
+	r, err := recording.Bot().AddRecorder(cryo.RecorderConfig{File: file, EventTypes: []string{"GroupMessageEvent"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording.Group(100).User(1001).Says("/ping")
+	recording.ExpectReply("pong")
+	recording.Bot().GetBus().RemoveMiddlewareById(r.GetId())
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := cryo.ReadRecording(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Event.GetEventType() != cryo.GroupMessageEventType {
+		t.Fatalf("录制了 %d 个事件，期望 1 条群消息：%+v", len(records), records)
+	}
+
+	// 回放到一个新的Bot中，回复应该发送到新的模拟协议里
+	replaying := cryotest.New(t)
+	onPing(replaying.Bot())
+	replaying.Group(100)
+	replaying.Replay(file)
+	a := replaying.ExpectReply("pong")
+	if a.Action != "send_group_message" || a.GroupUin != 100 {
+		t.Errorf("回放的消息的回复发送到了 %s 群 %d，期望群 100", a.Action, a.GroupUin)
+	}
+	if n := len(recording.Sent()); n != 1 {
+		t.Errorf("回放时录制用的Bot又发送了消息，共发送 %d 条", n)
+	}
+}
@@ -0,0 +1,551 @@
+package cryo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/gorilla/websocket"
+	"github.com/machinacanis/cryo/log"
+)
+
+// RemotePluginConfig 远程插件服务的配置
+type RemotePluginConfig struct {
+	Addr              string        // WebSocket 的监听地址，例如 127.0.0.1:8090，为空时不监听
+	AccessToken       string        // 访问令牌，为空时不校验
+	HeartbeatInterval time.Duration // 远程插件发送心跳的间隔，默认为15秒
+	HeartbeatTimeout  time.Duration // 超过这个时间没有收到任何数据时断开连接，默认为心跳间隔的3倍
+	AllowLocalFile    bool          // 是否允许远程插件发送的CQ码中的媒体使用 file:// 读取本地文件，默认关闭
+}
+
+// 远程插件协议的操作类型
+const (
+	remoteOpHello       = "hello"       // 远程插件 -> cryo：声明插件信息，连接后必须首先发送
+	remoteOpReady       = "ready"       // cryo -> 远程插件：插件已加载
+	remoteOpSubscribe   = "subscribe"   // 远程插件 -> cryo：注册响应器
+	remoteOpUnsubscribe = "unsubscribe" // 远程插件 -> cryo：移除响应器
+	remoteOpCall        = "call"        // 远程插件 -> cryo：执行动作
+	remoteOpResult      = "result"      // cryo -> 远程插件：请求的结果
+	remoteOpEvent       = "event"       // cryo -> 远程插件：响应器匹配到的事件
+	remoteOpPing        = "ping"        // 远程插件 -> cryo：心跳
+	remoteOpPong        = "pong"        // cryo -> 远程插件：心跳的回应
+)
+
+// remoteEventCacheSize 每个远程插件最多保存的最近事件数量，回复和撤回时通过事件ID查找
+const remoteEventCacheSize = 1000
+
+// remoteFrame 是远程插件协议中的一帧数据，请求的结果会带上请求的 id
+type remoteFrame struct {
+	Op    string         `json:"op"`
+	Id    string         `json:"id,omitzero"`
+	Data  jsontext.Value `json:"data,omitzero"`
+	Error string         `json:"error,omitzero"`
+}
+
+// RemotePluginInfo 是远程插件在 hello 中声明的插件信息
+type RemotePluginInfo struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	Author      string `json:"author"`
+}
+
+// RemoteSubscription 是远程插件注册的响应器，Key 由远程插件指定，推送事件时会带上这个 Key
+type RemoteSubscription struct {
+	Key      string       `json:"key"`
+	Types    []string     `json:"types,omitzero"`    // 事件类型的名称，为空时响应所有事件
+	Rules    []RuleConfig `json:"rules,omitzero"`    // 所有规则都通过时才会推送事件
+	Commands []string     `json:"commands,omitzero"` // 命令，需要包含前缀，匹配任意一个即可
+}
+
+// remoteEventPush 是推送给远程插件的事件
+type remoteEventPush struct {
+	Key   string         `json:"key"`
+	Event jsontext.Value `json:"event"`
+}
+
+// RemotePluginServer 是远程插件服务，以插件的形式运行
+//
+// 独立部署的进程可以通过 WebSocket 连接到这个服务，声明插件信息后注册响应器、接收匹配的事件并调用动作，
+// 每个连接都会作为一个插件加入到Bot中，连接断开或者心跳超时后它注册的中间件会被自动移除，
+// 远程插件崩溃也不会影响Bot本身
+type RemotePluginServer struct {
+	conf     RemotePluginConfig
+	bot      *Bot
+	logger   log.CryoLogger
+	upgrader websocket.Upgrader
+
+	mutex    sync.Mutex
+	enabled  bool
+	server   *http.Server
+	sessions map[string]*remotePlugin // 插件名称 -> 远程插件
+}
+
+// NewRemotePluginServer 创建一个新的远程插件服务，需要通过 Bot.AddPlugin 添加到Bot中才会生效
+func NewRemotePluginServer(conf RemotePluginConfig) *RemotePluginServer {
+	if conf.HeartbeatInterval <= 0 {
+		conf.HeartbeatInterval = 15 * time.Second
+	}
+	if conf.HeartbeatTimeout <= 0 {
+		conf.HeartbeatTimeout = 3 * conf.HeartbeatInterval
+	}
+	return &RemotePluginServer{
+		conf: conf,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		sessions: make(map[string]*remotePlugin),
+	}
+}
+
+// Init 初始化远程插件服务
+func (s *RemotePluginServer) Init(bot *Bot) error {
+	s.bot = bot
+	s.logger = bot.GetLogger()
+	return nil
+}
+
+// GetPluginName 获取插件名称信息
+func (s *RemotePluginServer) GetPluginName() string {
+	return "RemotePlugin"
+}
+
+// GetPluginVersion 获取插件版本号信息
+func (s *RemotePluginServer) GetPluginVersion() string {
+	return "0.1.0"
+}
+
+// GetPluginDescription 获取插件描述信息
+func (s *RemotePluginServer) GetPluginDescription() string {
+	return "通过 WebSocket 接入独立进程中的远程插件"
+}
+
+// GetPluginAuthor 获取插件作者信息
+func (s *RemotePluginServer) GetPluginAuthor() string {
+	return "machinacanis"
+}
+
+// Enable 启用远程插件服务，开始监听
+func (s *RemotePluginServer) Enable() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.enabled {
+		return
+	}
+	s.enabled = true
+	if s.conf.Addr == "" {
+		return
+	}
+	ln, err := net.Listen("tcp", s.conf.Addr)
+	if err != nil {
+		s.logger.Errorf("[RemotePlugin] 监听 %s 失败：%v", s.conf.Addr, err)
+		return
+	}
+	s.server = &http.Server{Handler: s}
+	go func(server *http.Server) {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorf("[RemotePlugin] HTTP 服务出现错误：%v", err)
+		}
+	}(s.server)
+	s.logger.Successf("[RemotePlugin] 正在监听 %s", s.conf.Addr)
+}
+
+// Disable 禁用远程插件服务，断开所有远程插件并停止监听
+func (s *RemotePluginServer) Disable() {
+	s.mutex.Lock()
+	if !s.enabled {
+		s.mutex.Unlock()
+		return
+	}
+	s.enabled = false
+	if s.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = s.server.Shutdown(ctx)
+		cancel()
+		s.server = nil
+	}
+	sessions := make([]*remotePlugin, 0, len(s.sessions))
+	for _, rp := range s.sessions {
+		sessions = append(sessions, rp)
+	}
+	s.mutex.Unlock()
+	// 关闭连接后读取协程会移除远程插件
+	for _, rp := range sessions {
+		_ = rp.ws.Close()
+	}
+}
+
+// IsEnable 是否已启用远程插件服务
+func (s *RemotePluginServer) IsEnable() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.enabled
+}
+
+// GetRemotePlugins 获取当前已连接的远程插件
+func (s *RemotePluginServer) GetRemotePlugins() []Plugin {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	plugins := make([]Plugin, 0, len(s.sessions))
+	for _, rp := range s.sessions {
+		plugins = append(plugins, rp)
+	}
+	return plugins
+}
+
+// ServeHTTP 处理远程插件的 WebSocket 连接，可以把服务挂载到其他的 HTTP 服务上
+func (s *RemotePluginServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.IsEnable() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	if status := checkAccessToken(r, s.conf.AccessToken); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if !websocket.IsWebSocketUpgrade(r) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade 已经写入了错误响应
+	}
+	s.serve(ws)
+}
+
+// serve 处理一个远程插件的连接，直到连接断开
+func (s *RemotePluginServer) serve(ws *websocket.Conn) {
+	defer func() { _ = ws.Close() }()
+	rp := &remotePlugin{server: s, ws: ws, subscriptions: make(map[string]*remoteSubscriptionState), events: make(map[string]Event)}
+
+	// 第一帧必须是 hello
+	_ = ws.SetReadDeadline(time.Now().Add(s.conf.HeartbeatTimeout))
+	var hello remoteFrame
+	if err := ws.ReadJSON(&hello); err != nil || hello.Op != remoteOpHello {
+		rp.writeClose("第一条消息需要是 hello")
+		return
+	}
+	if err := json.Unmarshal(hello.Data, &rp.info); err != nil || rp.info.Name == "" {
+		rp.writeClose("hello 中缺少插件名称")
+		return
+	}
+	if err := s.register(rp); err != nil {
+		rp.writeClose(err.Error())
+		return
+	}
+	defer s.unregister(rp)
+	rp.reply(hello.Id, map[string]any{"heartbeat": s.conf.HeartbeatInterval.Milliseconds()}, nil, remoteOpReady)
+
+	for {
+		_ = ws.SetReadDeadline(time.Now().Add(s.conf.HeartbeatTimeout))
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				s.logger.Warnf("[RemotePlugin] 远程插件 %s 心跳超时", rp.info.Name)
+			}
+			return
+		}
+		var f remoteFrame
+		if err := json.Unmarshal(data, &f); err != nil {
+			rp.reply("", nil, errors.New("消息不是有效的JSON对象"), remoteOpResult)
+			continue
+		}
+		switch f.Op {
+		case remoteOpPing:
+			rp.reply(f.Id, nil, nil, remoteOpPong)
+		case remoteOpSubscribe:
+			var sub RemoteSubscription
+			err := json.Unmarshal(f.Data, &sub)
+			if err == nil {
+				err = rp.subscribe(sub)
+			}
+			rp.reply(f.Id, nil, err, remoteOpResult)
+		case remoteOpUnsubscribe:
+			var sub RemoteSubscription
+			if err := json.Unmarshal(f.Data, &sub); err != nil {
+				rp.reply(f.Id, nil, err, remoteOpResult)
+				continue
+			}
+			rp.unsubscribe(sub.Key)
+			rp.reply(f.Id, nil, nil, remoteOpResult)
+		case remoteOpCall:
+			// 动作可能需要等待网络请求，不能阻塞读取
+			go func(f remoteFrame) {
+				data, err := rp.call(f.Data)
+				rp.reply(f.Id, data, err, remoteOpResult)
+			}(f)
+		default:
+			rp.reply(f.Id, nil, fmt.Errorf("未知的操作 %q", f.Op), remoteOpResult)
+		}
+	}
+}
+
+// register 把远程插件加入到Bot中，插件名称不能和已有的插件重复
+func (s *RemotePluginServer) register(rp *remotePlugin) error {
+	s.mutex.Lock()
+	if _, ok := s.sessions[rp.info.Name]; ok || len(s.bot.GetPlugin(rp.info.Name)) > 0 {
+		s.mutex.Unlock()
+		return fmt.Errorf("插件 %s 已经存在", rp.info.Name)
+	}
+	s.sessions[rp.info.Name] = rp
+	s.mutex.Unlock()
+
+	s.bot.AddPlugin(rp)
+	rp.Enable() // 远程插件连接上来就是为了接收事件，不受自动加载配置的影响
+	s.logger.Successf("[RemotePlugin] 远程插件 %s %s 已连接", rp.info.Name, rp.info.Version)
+	return nil
+}
+
+// unregister 移除远程插件注册的所有中间件，并把它从Bot中移除
+func (s *RemotePluginServer) unregister(rp *remotePlugin) {
+	rp.Disable()
+	s.bot.RemovePlugin(rp)
+	s.mutex.Lock()
+	delete(s.sessions, rp.info.Name)
+	s.mutex.Unlock()
+	s.logger.Infof("[RemotePlugin] 远程插件 %s 已断开", rp.info.Name)
+}
+
+// remoteSubscriptionState 是远程插件注册的响应器，启用时才会注册到事件总线
+type remoteSubscriptionState struct {
+	sub       RemoteSubscription
+	responser *OnResponser
+}
+
+// remotePlugin 是一个已连接的远程插件，实现了 Plugin 接口
+//
+// 禁用插件时会移除它注册的中间件，但是连接会保留，重新启用后会继续推送事件
+type remotePlugin struct {
+	server     *RemotePluginServer
+	ws         *websocket.Conn
+	writeMutex sync.Mutex
+	info       RemotePluginInfo
+	enabled    atomic.Bool
+
+	mutex         sync.Mutex
+	subscriptions map[string]*remoteSubscriptionState
+	events        map[string]Event // 最近推送的事件，事件ID -> 事件
+	eventOrder    []string
+}
+
+// Init 远程插件在连接时已经完成了初始化
+func (rp *remotePlugin) Init(bot *Bot) error {
+	return nil
+}
+
+// GetPluginName 获取插件名称信息
+func (rp *remotePlugin) GetPluginName() string {
+	return rp.info.Name
+}
+
+// GetPluginVersion 获取插件版本号信息
+func (rp *remotePlugin) GetPluginVersion() string {
+	return rp.info.Version
+}
+
+// GetPluginDescription 获取插件描述信息
+func (rp *remotePlugin) GetPluginDescription() string {
+	return rp.info.Description
+}
+
+// GetPluginAuthor 获取插件作者信息
+func (rp *remotePlugin) GetPluginAuthor() string {
+	return rp.info.Author
+}
+
+// Enable 启用远程插件，注册所有的响应器
+func (rp *remotePlugin) Enable() {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	if rp.enabled.Swap(true) {
+		return
+	}
+	for _, state := range rp.subscriptions {
+		rp.registerLocked(state)
+	}
+}
+
+// Disable 禁用远程插件，移除所有响应器注册的中间件
+func (rp *remotePlugin) Disable() {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	if !rp.enabled.Swap(false) {
+		return
+	}
+	for _, state := range rp.subscriptions {
+		if state.responser != nil {
+			state.responser.Remove()
+			state.responser = nil
+		}
+	}
+}
+
+// IsEnable 是否已启用远程插件
+func (rp *remotePlugin) IsEnable() bool {
+	return rp.enabled.Load()
+}
+
+// subscribe 注册响应器，Key 相同的响应器会被替换
+func (rp *remotePlugin) subscribe(sub RemoteSubscription) error {
+	if sub.Key == "" {
+		return fmt.Errorf("%w：缺少 key", errAdapterParams)
+	}
+	// 先检查参数，避免注册了一半的响应器
+	if _, err := remoteEventTypes(sub.Types); err != nil {
+		return err
+	}
+	if _, err := remoteRules(sub); err != nil {
+		return err
+	}
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	if old, ok := rp.subscriptions[sub.Key]; ok && old.responser != nil {
+		old.responser.Remove()
+	}
+	state := &remoteSubscriptionState{sub: sub}
+	rp.subscriptions[sub.Key] = state
+	if rp.enabled.Load() {
+		rp.registerLocked(state)
+	}
+	return nil
+}
+
+// unsubscribe 移除响应器
+func (rp *remotePlugin) unsubscribe(key string) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	if state, ok := rp.subscriptions[key]; ok {
+		if state.responser != nil {
+			state.responser.Remove()
+		}
+		delete(rp.subscriptions, key)
+	}
+}
+
+// registerLocked 根据响应器的声明创建并注册响应器，调用时需要持有锁
+func (rp *remotePlugin) registerLocked(state *remoteSubscriptionState) {
+	types, _ := remoteEventTypes(state.sub.Types)
+	rules, _ := remoteRules(state.sub)
+	r := NewOnResponser(rp.server.bot.GetBus(), types...)
+	for _, rule := range rules {
+		r.AddRule(rule)
+	}
+	key := state.sub.Key
+	r.Handle(func(e Event) {
+		rp.push(key, e)
+	})
+	r.Register()
+	state.responser = r
+}
+
+// push 把事件推送给远程插件
+func (rp *remotePlugin) push(key string, e Event) {
+	event, err := MarshalEvent(e)
+	if err != nil {
+		rp.server.logger.Errorf("[RemotePlugin] 序列化事件失败：%v", err)
+		return
+	}
+	rp.cacheEvent(e)
+	data, err := json.Marshal(remoteEventPush{Key: key, Event: event})
+	if err != nil {
+		return
+	}
+	if err := rp.write(remoteFrame{Op: remoteOpEvent, Data: data}); err != nil {
+		_ = rp.ws.Close() // 关闭后读取协程会移除远程插件
+	}
+}
+
+// cacheEvent 保存最近推送的事件，超出数量时淘汰最早的事件
+func (rp *remotePlugin) cacheEvent(e Event) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	id := e.GetEventId()
+	if _, ok := rp.events[id]; ok {
+		return
+	}
+	rp.events[id] = e
+	rp.eventOrder = append(rp.eventOrder, id)
+	if len(rp.eventOrder) > remoteEventCacheSize {
+		delete(rp.events, rp.eventOrder[0])
+		rp.eventOrder = rp.eventOrder[1:]
+	}
+}
+
+// event 根据事件ID获取最近推送的事件
+func (rp *remotePlugin) event(id string) (Event, error) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	if e, ok := rp.events[id]; ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("%w：事件 %s 不存在或者已经过期", errAdapterParams, id)
+}
+
+// write 发送一帧数据，gorilla/websocket 不支持并发写入
+func (rp *remotePlugin) write(f remoteFrame) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	rp.writeMutex.Lock()
+	defer rp.writeMutex.Unlock()
+	_ = rp.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return rp.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// reply 发送请求的结果
+func (rp *remotePlugin) reply(id string, data any, err error, op string) {
+	f := remoteFrame{Op: op, Id: id}
+	if err != nil {
+		f.Error = err.Error()
+	} else if data != nil {
+		raw, merr := json.Marshal(data)
+		if merr != nil {
+			f.Error = merr.Error()
+		} else {
+			f.Data = raw
+		}
+	}
+	_ = rp.write(f)
+}
+
+// writeClose 发送错误并关闭连接
+func (rp *remotePlugin) writeClose(reason string) {
+	_ = rp.write(remoteFrame{Op: remoteOpResult, Error: reason})
+	_ = rp.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason), time.Now().Add(time.Second))
+}
+
+// remoteEventTypes 把事件类型的名称转换为事件类型
+func remoteEventTypes(names []string) ([]EventType, error) {
+	types := make([]EventType, 0, len(names))
+	for _, name := range names {
+		et, ok := eventTypeByName[name]
+		if !ok {
+			return nil, fmt.Errorf("%w：未知的事件类型 %q", errAdapterParams, name)
+		}
+		types = append(types, et)
+	}
+	return types, nil
+}
+
+// remoteRules 把远程插件声明的规则转换为内置的规则
+func remoteRules(sub RemoteSubscription) ([]Rule[Event], error) {
+	rules, err := BuildRules(sub.Rules...)
+	if err != nil {
+		return nil, fmt.Errorf("%w：%w", errAdapterParams, err)
+	}
+	if len(sub.Commands) > 0 {
+		rules = append(rules, CommandRule(sub.Commands...))
+	}
+	return rules, nil
+}
@@ -0,0 +1,258 @@
+package cryo
+
+import (
+	"fmt"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// RemoteCall 是远程插件调用动作的请求
+type RemoteCall struct {
+	Action string         `json:"action"`
+	Params jsontext.Value `json:"params,omitzero"`
+}
+
+// RemoteTarget 是远程插件使用的发送目标，Type 为 private、group 或者 temp
+type RemoteTarget struct {
+	Type    string `json:"type"`
+	GroupId uint32 `json:"group_id,omitzero"`
+	UserId  uint32 `json:"user_id,omitzero"`
+}
+
+// RemoteSentMessage 是远程插件发送的消息，撤回时原样传回即可
+type RemoteSentMessage struct {
+	SelfId     uint32       `json:"self_id"`
+	Target     RemoteTarget `json:"target"`
+	MessageId  uint32       `json:"message_id"`
+	InternalId uint32       `json:"internal_id,omitzero"`
+	ClientSeq  uint32       `json:"client_seq,omitzero"`
+	Time       uint32       `json:"time"`
+}
+
+// RemoteClientInfo 是远程插件获取到的Bot客户端信息
+type RemoteClientInfo struct {
+	Id       string `json:"id"`
+	Uin      uint32 `json:"uin"`
+	Nickname string `json:"nickname"`
+	Online   bool   `json:"online"`
+}
+
+// remoteSendParams 是 send 和 reply 动作的参数
+type remoteSendParams struct {
+	SelfId  uint32         `json:"self_id,omitzero"`
+	EventId string         `json:"event_id,omitzero"` // 发送到事件所在的会话，或者回复这个事件
+	Target  *RemoteTarget  `json:"target,omitzero"`
+	Message jsontext.Value `json:"message,omitzero"` // CQ码字符串或者消息的JSON数组
+}
+
+// remoteLogParams 是 log 动作的参数
+type remoteLogParams struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// remoteActions 远程插件支持的动作
+var remoteActions = map[string]func(rp *remotePlugin, params jsontext.Value) (any, error){
+	"send":        (*remotePlugin).send,
+	"reply":       (*remotePlugin).replyEvent,
+	"recall":      (*remotePlugin).recall,
+	"get_clients": (*remotePlugin).getClients,
+	"log":         (*remotePlugin).log,
+}
+
+// call 执行远程插件调用的动作
+func (rp *remotePlugin) call(data jsontext.Value) (any, error) {
+	var req RemoteCall
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("%w：%w", errAdapterParams, err)
+	}
+	action, ok := remoteActions[req.Action]
+	if !ok {
+		return nil, fmt.Errorf("未知的动作 %q", req.Action)
+	}
+	if !rp.IsEnable() {
+		return nil, fmt.Errorf("插件 %s 已被禁用", rp.info.Name)
+	}
+	if len(req.Params) == 0 {
+		req.Params = jsontext.Value("{}")
+	}
+	return action(rp, req.Params)
+}
+
+// unmarshalRemoteParams 解析动作的参数
+func unmarshalRemoteParams(params jsontext.Value, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return fmt.Errorf("%w：%w", errAdapterParams, err)
+	}
+	return nil
+}
+
+// remoteSendTarget 把远程插件的发送目标转换为发送目标
+func remoteSendTarget(t RemoteTarget) (SendTarget, error) {
+	switch t.Type {
+	case "private":
+		return SendTarget{Type: PrivateTarget, UserUin: t.UserId}, nil
+	case "group":
+		return SendTarget{Type: GroupTarget, GroupUin: t.GroupId}, nil
+	case "temp":
+		return SendTarget{Type: TempTarget, GroupUin: t.GroupId, UserUin: t.UserId}, nil
+	}
+	return SendTarget{}, fmt.Errorf("%w：未知的目标类型 %q", errAdapterParams, t.Type)
+}
+
+// newRemoteSentMessage 把已发送的消息转换为远程插件使用的格式
+func newRemoteSentMessage(c *LagrangeClient, sent *SentMessage) RemoteSentMessage {
+	t := RemoteTarget{GroupId: sent.Target.GroupUin, UserId: sent.Target.UserUin}
+	t.Type = [...]string{"private", "group", "temp"}[sent.Target.Type]
+	return RemoteSentMessage{
+		SelfId:     c.Uin,
+		Target:     t,
+		MessageId:  sent.MessageId,
+		InternalId: sent.InternalId,
+		ClientSeq:  sent.ClientSeq,
+		Time:       sent.Time,
+	}
+}
+
+// message 解析参数中的消息内容
+func (p *remoteSendParams) message(opt CQParseOptions) (*Message, error) {
+	m, err := decodeMessageValue(p.Message, opt)
+	if err != nil {
+		return nil, fmt.Errorf("%w：%w", errAdapterParams, err)
+	}
+	return &m, nil
+}
+
+// messageEvent 根据事件ID获取消息事件和收到这个事件的Bot客户端
+func (rp *remotePlugin) messageEvent(id string) (MessageEvent, *LagrangeClient, error) {
+	e, err := rp.event(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	me, ok := e.(MessageEvent)
+	if !ok {
+		return nil, nil, newActionError("send_message", 0, 0, ErrUnsupportedEvent, nil)
+	}
+	c := rp.server.bot.GetClient(e)
+	if c == nil {
+		return nil, nil, ErrNotOnline
+	}
+	return me, c, nil
+}
+
+func (rp *remotePlugin) send(params jsontext.Value) (any, error) {
+	var p remoteSendParams
+	if err := unmarshalRemoteParams(params, &p); err != nil {
+		return nil, err
+	}
+	m, err := p.message(CQParseOptions{AllowLocalFile: rp.server.conf.AllowLocalFile})
+	if err != nil {
+		return nil, err
+	}
+	if p.EventId != "" {
+		e, c, err := rp.messageEvent(p.EventId)
+		if err != nil {
+			return nil, err
+		}
+		sent, err := c.SendTo(e, m)
+		if err != nil {
+			return nil, err
+		}
+		return newRemoteSentMessage(c, sent), nil
+	}
+	if p.Target == nil {
+		return nil, fmt.Errorf("%w：缺少 target 或者 event_id", errAdapterParams)
+	}
+	target, err := remoteSendTarget(*p.Target)
+	if err != nil {
+		return nil, err
+	}
+	c, err := rp.server.bot.selectClient(p.SelfId)
+	if err != nil {
+		return nil, err
+	}
+	sent, err := c.sendMessage(target, m)
+	if err != nil {
+		return nil, err
+	}
+	return newRemoteSentMessage(c, sent), nil
+}
+
+func (rp *remotePlugin) replyEvent(params jsontext.Value) (any, error) {
+	var p remoteSendParams
+	if err := unmarshalRemoteParams(params, &p); err != nil {
+		return nil, err
+	}
+	m, err := p.message(CQParseOptions{AllowLocalFile: rp.server.conf.AllowLocalFile})
+	if err != nil {
+		return nil, err
+	}
+	if p.EventId == "" {
+		return nil, fmt.Errorf("%w：缺少 event_id", errAdapterParams)
+	}
+	e, c, err := rp.messageEvent(p.EventId)
+	if err != nil {
+		return nil, err
+	}
+	sent, err := c.ReplyTo(e, m)
+	if err != nil {
+		return nil, err
+	}
+	return newRemoteSentMessage(c, sent), nil
+}
+
+// recall 撤回消息，参数是 send 或者 reply 返回的消息
+func (rp *remotePlugin) recall(params jsontext.Value) (any, error) {
+	var p RemoteSentMessage
+	if err := unmarshalRemoteParams(params, &p); err != nil {
+		return nil, err
+	}
+	target, err := remoteSendTarget(p.Target)
+	if err != nil {
+		return nil, err
+	}
+	c, err := rp.server.bot.selectClient(p.SelfId)
+	if err != nil {
+		return nil, err
+	}
+	sent := newSentMessage(c, target, nil, p.MessageId, p.InternalId, p.ClientSeq, p.Time)
+	return nil, sent.Recall()
+}
+
+func (rp *remotePlugin) getClients(_ jsontext.Value) (any, error) {
+	clients := rp.server.bot.getConnectedClients()
+	result := make([]RemoteClientInfo, 0, len(clients))
+	for _, c := range clients {
+		result = append(result, RemoteClientInfo{
+			Id:       c.Id,
+			Uin:      c.Uin,
+			Nickname: c.Nickname,
+			Online:   c.IsOnline(),
+		})
+	}
+	return result, nil
+}
+
+// log 把远程插件的日志输出到Bot的日志中
+func (rp *remotePlugin) log(params jsontext.Value) (any, error) {
+	var p remoteLogParams
+	if err := unmarshalRemoteParams(params, &p); err != nil {
+		return nil, err
+	}
+	logger := rp.server.logger
+	prefix := "[" + rp.info.Name + "] "
+	switch p.Level {
+	case "debug":
+		logger.Debug(prefix + p.Message)
+	case "warn":
+		logger.Warn(prefix + p.Message)
+	case "error":
+		logger.Error(prefix + p.Message)
+	case "success":
+		logger.Success(prefix + p.Message)
+	default:
+		logger.Info(prefix + p.Message)
+	}
+	return nil, nil
+}
@@ -0,0 +1,350 @@
+package cryo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/gorilla/websocket"
+)
+
+// ErrRemoteDisconnected 远程插件没有连接到cryo
+var ErrRemoteDisconnected = errors.New("远程插件没有连接")
+
+// RemoteEventHandler 是远程插件处理事件的函数
+type RemoteEventHandler func(e *RemoteEvent)
+
+// RemoteEvent 是远程插件收到的事件
+type RemoteEvent struct {
+	Event  Event  // 反序列化后的事件，只有元数据，不能直接用来调用客户端的方法
+	Key    string // 匹配到的响应器的 Key
+	client *RemotePluginClient
+}
+
+// Send 发送消息到事件所在的会话，args 和 SendTo 的参数一致
+func (e *RemoteEvent) Send(ctx context.Context, args ...interface{}) (*RemoteSentMessage, error) {
+	return e.client.sendMessage(ctx, "send", map[string]any{"event_id": e.Event.GetEventId(), "message": ProcessMessageContent(args...)})
+}
+
+// Reply 回复这个事件，args 和 ReplyTo 的参数一致
+func (e *RemoteEvent) Reply(ctx context.Context, args ...interface{}) (*RemoteSentMessage, error) {
+	return e.client.sendMessage(ctx, "reply", map[string]any{"event_id": e.Event.GetEventId(), "message": ProcessMessageContent(args...)})
+}
+
+// remoteClientSubscription 是远程插件注册的响应器和对应的处理函数
+type remoteClientSubscription struct {
+	sub     RemoteSubscription
+	handler RemoteEventHandler
+}
+
+// RemotePluginClient 是远程插件的客户端，运行在独立的进程中
+//
+// 连接断开后会自动重连，并重新声明插件信息和注册所有的响应器
+type RemotePluginClient struct {
+	URL               string        // 远程插件服务的地址，例如 ws://127.0.0.1:8090
+	AccessToken       string        // 访问令牌
+	ReconnectInterval time.Duration // 重连间隔，默认为3秒
+	CallTimeout       time.Duration // 没有设置 context 超时时的请求超时时间，默认为30秒
+	Info              RemotePluginInfo
+
+	mutex         sync.Mutex
+	ws            *websocket.Conn
+	writeMutex    sync.Mutex
+	subscriptions map[string]*remoteClientSubscription
+	pending       map[string]chan remoteFrame
+	seq           atomic.Uint64
+}
+
+// NewRemotePluginClient 创建一个新的远程插件客户端，调用 Run 后开始连接
+func NewRemotePluginClient(url, accessToken string, info RemotePluginInfo) *RemotePluginClient {
+	return &RemotePluginClient{
+		URL:               url,
+		AccessToken:       accessToken,
+		ReconnectInterval: 3 * time.Second,
+		CallTimeout:       30 * time.Second,
+		Info:              info,
+		subscriptions:     make(map[string]*remoteClientSubscription),
+		pending:           make(map[string]chan remoteFrame),
+	}
+}
+
+// Subscribe 注册响应器，Key 相同的响应器会被替换，连接断开时会在重连后重新注册
+func (rc *RemotePluginClient) Subscribe(sub RemoteSubscription, handler RemoteEventHandler) error {
+	if sub.Key == "" {
+		return errors.New("响应器的 Key 不能为空")
+	}
+	rc.mutex.Lock()
+	rc.subscriptions[sub.Key] = &remoteClientSubscription{sub: sub, handler: handler}
+	rc.mutex.Unlock()
+	// 没有连接时会在连接后注册
+	_, err := rc.request(context.Background(), remoteOpSubscribe, sub)
+	if errors.Is(err, ErrRemoteDisconnected) {
+		return nil
+	}
+	return err
+}
+
+// Unsubscribe 移除响应器
+func (rc *RemotePluginClient) Unsubscribe(key string) error {
+	rc.mutex.Lock()
+	delete(rc.subscriptions, key)
+	rc.mutex.Unlock()
+	_, err := rc.request(context.Background(), remoteOpUnsubscribe, RemoteSubscription{Key: key})
+	if errors.Is(err, ErrRemoteDisconnected) {
+		return nil
+	}
+	return err
+}
+
+// Call 调用动作，返回动作结果的原始JSON
+func (rc *RemotePluginClient) Call(ctx context.Context, action string, params any) (jsontext.Value, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return rc.request(ctx, remoteOpCall, RemoteCall{Action: action, Params: raw})
+}
+
+// Send 发送消息，args 和 SendTo 的参数一致
+func (rc *RemotePluginClient) Send(ctx context.Context, selfId uint32, target RemoteTarget, args ...interface{}) (*RemoteSentMessage, error) {
+	return rc.sendMessage(ctx, "send", map[string]any{"self_id": selfId, "target": target, "message": ProcessMessageContent(args...)})
+}
+
+// Recall 撤回 Send 或者 Reply 发送的消息
+func (rc *RemotePluginClient) Recall(ctx context.Context, sent *RemoteSentMessage) error {
+	_, err := rc.Call(ctx, "recall", sent)
+	return err
+}
+
+// GetClients 获取已连接的Bot客户端
+func (rc *RemotePluginClient) GetClients(ctx context.Context) ([]RemoteClientInfo, error) {
+	raw, err := rc.Call(ctx, "get_clients", nil)
+	if err != nil {
+		return nil, err
+	}
+	var clients []RemoteClientInfo
+	return clients, json.Unmarshal(raw, &clients)
+}
+
+// Log 把日志输出到cryo的日志中，level 为 debug、info、success、warn 或者 error
+func (rc *RemotePluginClient) Log(ctx context.Context, level, message string) error {
+	_, err := rc.Call(ctx, "log", remoteLogParams{Level: level, Message: message})
+	return err
+}
+
+// sendMessage 调用发送消息的动作
+func (rc *RemotePluginClient) sendMessage(ctx context.Context, action string, params map[string]any) (*RemoteSentMessage, error) {
+	raw, err := rc.Call(ctx, action, params)
+	if err != nil {
+		return nil, err
+	}
+	sent := &RemoteSentMessage{}
+	return sent, json.Unmarshal(raw, sent)
+}
+
+// Run 连接到远程插件服务并处理事件，连接断开后会自动重连，直到 ctx 被取消
+func (rc *RemotePluginClient) Run(ctx context.Context) error {
+	for {
+		err := rc.runOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, websocket.ErrBadHandshake) {
+			return err // 令牌错误或者地址错误，重连也不会成功
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rc.ReconnectInterval):
+		}
+	}
+}
+
+// runOnce 建立一次连接并处理消息，直到连接断开
+func (rc *RemotePluginClient) runOnce(ctx context.Context) error {
+	header := http.Header{}
+	if rc.AccessToken != "" {
+		header.Set("Authorization", "Bearer "+rc.AccessToken)
+	}
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, rc.URL, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("%w：%s", err, resp.Status)
+		}
+		return err
+	}
+	defer func() { _ = ws.Close() }()
+	stop := context.AfterFunc(ctx, func() { _ = ws.Close() })
+	defer stop()
+
+	// 声明插件信息，等待服务端返回心跳间隔
+	info, _ := json.Marshal(rc.Info)
+	if err := ws.WriteJSON(remoteFrame{Op: remoteOpHello, Id: "hello", Data: info}); err != nil {
+		return err
+	}
+	var ready remoteFrame
+	if err := ws.ReadJSON(&ready); err != nil {
+		return err
+	}
+	if ready.Op != remoteOpReady {
+		return fmt.Errorf("远程插件被拒绝：%s", ready.Error)
+	}
+	var hello struct {
+		Heartbeat int64 `json:"heartbeat"`
+	}
+	_ = json.Unmarshal(ready.Data, &hello)
+
+	rc.mutex.Lock()
+	rc.ws = ws
+	subs := make([]RemoteSubscription, 0, len(rc.subscriptions))
+	for _, s := range rc.subscriptions {
+		subs = append(subs, s.sub)
+	}
+	rc.mutex.Unlock()
+	defer rc.disconnect(ws)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for _, sub := range subs {
+			_, _ = rc.request(ctx, remoteOpSubscribe, sub)
+		}
+	}()
+	if hello.Heartbeat > 0 {
+		go rc.heartbeatLoop(ws, time.Duration(hello.Heartbeat)*time.Millisecond, done)
+	}
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var f remoteFrame
+		if err := json.Unmarshal(data, &f); err != nil {
+			continue
+		}
+		switch f.Op {
+		case remoteOpEvent:
+			rc.dispatch(f.Data)
+		default:
+			rc.mutex.Lock()
+			ch, ok := rc.pending[f.Id]
+			delete(rc.pending, f.Id)
+			rc.mutex.Unlock()
+			if ok {
+				ch <- f
+			}
+		}
+	}
+}
+
+// disconnect 清除连接，并让所有等待中的请求失败
+func (rc *RemotePluginClient) disconnect(ws *websocket.Conn) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	if rc.ws == ws {
+		rc.ws = nil
+	}
+	for id, ch := range rc.pending {
+		close(ch)
+		delete(rc.pending, id)
+	}
+}
+
+// heartbeatLoop 定时发送心跳
+func (rc *RemotePluginClient) heartbeatLoop(ws *websocket.Conn, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := rc.write(ws, remoteFrame{Op: remoteOpPing}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// dispatch 把事件交给对应的处理函数，每个事件都在单独的协程中处理
+func (rc *RemotePluginClient) dispatch(data jsontext.Value) {
+	var push remoteEventPush
+	if err := json.Unmarshal(data, &push); err != nil {
+		return
+	}
+	e, err := UnmarshalEvent(push.Event)
+	if err != nil {
+		return
+	}
+	rc.mutex.Lock()
+	s, ok := rc.subscriptions[push.Key]
+	rc.mutex.Unlock()
+	if !ok || s.handler == nil {
+		return
+	}
+	go s.handler(&RemoteEvent{Event: e, Key: push.Key, client: rc})
+}
+
+// write 发送一帧数据
+func (rc *RemotePluginClient) write(ws *websocket.Conn, f remoteFrame) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	rc.writeMutex.Lock()
+	defer rc.writeMutex.Unlock()
+	return ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// request 发送请求并等待结果
+func (rc *RemotePluginClient) request(ctx context.Context, op string, data any) (jsontext.Value, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rc.CallTimeout)
+		defer cancel()
+	}
+	id := strconv.FormatUint(rc.seq.Add(1), 10)
+	ch := make(chan remoteFrame, 1)
+	rc.mutex.Lock()
+	ws := rc.ws
+	if ws == nil {
+		rc.mutex.Unlock()
+		return nil, ErrRemoteDisconnected
+	}
+	rc.pending[id] = ch
+	rc.mutex.Unlock()
+
+	if err := rc.write(ws, remoteFrame{Op: op, Id: id, Data: raw}); err != nil {
+		rc.mutex.Lock()
+		delete(rc.pending, id)
+		rc.mutex.Unlock()
+		return nil, err
+	}
+	select {
+	case f, ok := <-ch:
+		if !ok {
+			return nil, ErrRemoteDisconnected
+		}
+		if f.Error != "" {
+			return nil, errors.New(f.Error)
+		}
+		return f.Data, nil
+	case <-ctx.Done():
+		rc.mutex.Lock()
+		delete(rc.pending, id)
+		rc.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,795 @@
+package cryo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	lgrmessage "github.com/LagrangeDev/LagrangeGo/message"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// ErrRenderEmpty 没有可以渲染的内容
+var ErrRenderEmpty = errors.New("没有可以渲染的内容")
+
+// ErrRenderSize 图片的尺寸或者字号超出了允许的范围
+var ErrRenderSize = errors.New("图片尺寸无效")
+
+// ErrRenderNoCJKFont 渲染的内容包含中日韩文字，但是没有包含这些字形的字体
+var ErrRenderNoCJKFont = errors.New("没有可以显示中日韩文字的字体，请通过 AddFallbackFont 或 AddFallbackFontFile 添加")
+
+// 图片尺寸的上限，避免错误的参数申请过多的内存
+const (
+	renderMaxWidth  = 4096
+	renderMaxHeight = 16384
+)
+
+// systemCJKFonts 常见系统中包含中文字形的字体路径，创建渲染器时会使用找到的第一个作为后备字体
+var systemCJKFonts = []string{
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/noto-cjk/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/truetype/wqy/wqy-microhei.ttc",
+	"/usr/share/fonts/wenquanyi/wqy-microhei/wqy-microhei.ttc",
+	"/System/Library/Fonts/PingFang.ttc",
+	"/System/Library/Fonts/STHeiti Medium.ttc",
+	`C:\Windows\Fonts\msyh.ttc`,
+	`C:\Windows\Fonts\simhei.ttf`,
+}
+
+// systemCJKFont 加载系统中的中文字体，只在第一次调用时查找，没有找到时返回nil
+var systemCJKFont = sync.OnceValue(func() *opentype.Font {
+	for _, path := range systemCJKFonts {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if f, err := parseFont(data); err == nil {
+			return f
+		}
+	}
+	return nil
+})
+
+// parseFont 解析TTF、OTF格式的字体，字体集合（TTC、OTC）使用其中的第一个字体
+func parseFont(data []byte) (*opentype.Font, error) {
+	f, err := opentype.Parse(data)
+	if err == nil {
+		return f, nil
+	}
+	c, cerr := opentype.ParseCollection(data)
+	if cerr != nil || c.NumFonts() == 0 {
+		return nil, err
+	}
+	return c.Font(0)
+}
+
+// RenderTheme 文本渲染为图片时使用的主题
+type RenderTheme struct {
+	Background     color.Color // 背景颜色
+	Foreground     color.Color // 正文颜色
+	Heading        color.Color // 标题颜色
+	Muted          color.Color // 引用、分割线等次要内容的颜色
+	CodeBackground color.Color // 代码块和表头的背景颜色
+	Border         color.Color // 表格边框的颜色
+	FontSize       float64     // 正文字号，单位是像素
+	LineSpacing    float64     // 行高相对字体高度的倍数
+	Padding        int         // 图片四周的留白
+}
+
+var (
+	// LightRenderTheme 浅色主题
+	LightRenderTheme = RenderTheme{
+		Background:     color.RGBA{0xff, 0xff, 0xff, 0xff},
+		Foreground:     color.RGBA{0x24, 0x29, 0x2f, 0xff},
+		Heading:        color.RGBA{0x09, 0x69, 0xda, 0xff},
+		Muted:          color.RGBA{0x65, 0x6d, 0x76, 0xff},
+		CodeBackground: color.RGBA{0xf6, 0xf8, 0xfa, 0xff},
+		Border:         color.RGBA{0xd0, 0xd7, 0xde, 0xff},
+		FontSize:       20,
+		LineSpacing:    1.4,
+		Padding:        24,
+	}
+	// DarkRenderTheme 深色主题
+	DarkRenderTheme = RenderTheme{
+		Background:     color.RGBA{0x0d, 0x11, 0x17, 0xff},
+		Foreground:     color.RGBA{0xe6, 0xed, 0xf3, 0xff},
+		Heading:        color.RGBA{0x58, 0xa6, 0xff, 0xff},
+		Muted:          color.RGBA{0x8b, 0x94, 0x9e, 0xff},
+		CodeBackground: color.RGBA{0x16, 0x1b, 0x22, 0xff},
+		Border:         color.RGBA{0x30, 0x36, 0x3d, 0xff},
+		FontSize:       20,
+		LineSpacing:    1.4,
+		Padding:        24,
+	}
+)
+
+// runStyle 文本片段的样式
+type runStyle int
+
+const (
+	styleRegular runStyle = iota
+	styleBold
+	styleMono
+)
+
+// textRun 一段相同样式的文本
+type textRun struct {
+	text  string
+	style runStyle
+}
+
+// blockKind 渲染块的类型
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockListItem
+	blockQuote
+	blockCode
+	blockRule
+	blockTable
+)
+
+// renderBlock 一个渲染块，例如一个段落、一个标题或者一个表格
+type renderBlock struct {
+	kind   blockKind
+	level  int // 标题的级别，或者列表的缩进层级
+	marker string
+	runs   []textRun
+	lines  []string   // 代码块的每一行
+	header []string   // 表格的表头
+	rows   [][]string // 表格的数据行
+}
+
+// TextRenderer 将文本、简单的Markdown或者表格渲染为PNG图片，不依赖浏览器
+//
+// 默认使用内置的Go字体，它不包含中文字形，创建时会尝试使用系统中的中文字体作为后备字体，
+// 没有找到时需要通过 AddFallbackFont 或 AddFallbackFontFile 添加一个包含中文的字体，否则渲染中日韩文字会返回 ErrRenderNoCJKFont
+type TextRenderer struct {
+	theme     RenderTheme
+	width     int
+	maxHeight int
+	regular   *opentype.Font
+	bold      *opentype.Font
+	mono      *opentype.Font
+	fallbacks []*opentype.Font
+}
+
+// NewTextRenderer 创建一个使用浅色主题、宽度为800像素的渲染器
+func NewTextRenderer() *TextRenderer {
+	regular, _ := opentype.Parse(goregular.TTF)
+	bold, _ := opentype.Parse(gobold.TTF)
+	mono, _ := opentype.Parse(gomono.TTF)
+	r := &TextRenderer{
+		theme:     LightRenderTheme,
+		width:     800,
+		maxHeight: 8000,
+		regular:   regular,
+		bold:      bold,
+		mono:      mono,
+	}
+	if f := systemCJKFont(); f != nil {
+		r.fallbacks = append(r.fallbacks, f)
+	}
+	return r
+}
+
+// WithTheme 设置渲染使用的主题
+func (r *TextRenderer) WithTheme(theme RenderTheme) *TextRenderer {
+	r.theme = theme
+	return r
+}
+
+// WithWidth 设置图片的宽度，需要大于主题的左右留白且不超过4096，否则渲染时返回 ErrRenderSize
+func (r *TextRenderer) WithWidth(width int) *TextRenderer {
+	r.width = width
+	return r
+}
+
+// WithMaxHeight 设置图片的最大高度，超出的内容会被截断，小于等于0时使用上限16384，超过上限时渲染返回 ErrRenderSize
+func (r *TextRenderer) WithMaxHeight(height int) *TextRenderer {
+	r.maxHeight = height
+	return r
+}
+
+// SetFont 替换正文、粗体和等宽字体，传入nil的字体保持不变，支持TTF、OTF格式和字体集合
+func (r *TextRenderer) SetFont(regular, bold, mono []byte) error {
+	fonts := []struct {
+		data   []byte
+		target **opentype.Font
+	}{{regular, &r.regular}, {bold, &r.bold}, {mono, &r.mono}}
+	for _, f := range fonts {
+		if f.data == nil {
+			continue
+		}
+		parsed, err := parseFont(f.data)
+		if err != nil {
+			return err
+		}
+		*f.target = parsed
+	}
+	return nil
+}
+
+// AddFallbackFont 添加一个后备字体，字体中没有的字形会依次从后备字体中查找，支持TTF、OTF格式和字体集合
+func (r *TextRenderer) AddFallbackFont(data []byte) error {
+	f, err := parseFont(data)
+	if err != nil {
+		return err
+	}
+	r.fallbacks = append(r.fallbacks, f)
+	return nil
+}
+
+// AddFallbackFontFile 从文件添加一个后备字体
+func (r *TextRenderer) AddFallbackFontFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return r.AddFallbackFont(data)
+}
+
+// RenderText 将纯文本渲染为图片元素，文本会按图片宽度自动换行
+func (r *TextRenderer) RenderText(text string) (*Image, error) {
+	var blocks []*renderBlock
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		blocks = append(blocks, &renderBlock{kind: blockParagraph, runs: []textRun{{text: line}}})
+	}
+	return r.render(blocks)
+}
+
+// RenderMarkdown 将简单的Markdown渲染为图片元素
+//
+// 支持标题、段落、有序和无序列表、引用、代码块、分割线、表格以及行内的粗体和代码
+func (r *TextRenderer) RenderMarkdown(md string) (*Image, error) {
+	return r.render(parseMarkdown(md))
+}
+
+// RenderTable 将表格数据渲染为图片元素，header 可以为nil
+func (r *TextRenderer) RenderTable(header []string, rows [][]string) (*Image, error) {
+	return r.render([]*renderBlock{{kind: blockTable, header: header, rows: rows}})
+}
+
+// RenderPNG 将简单的Markdown渲染为PNG数据
+func (r *TextRenderer) RenderPNG(md string) ([]byte, error) {
+	return r.renderPNG(parseMarkdown(md))
+}
+
+func (r *TextRenderer) render(blocks []*renderBlock) (*Image, error) {
+	data, err := r.renderPNG(blocks)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{ImageElement: *lgrmessage.NewImage(data)}, nil
+}
+
+// parseMarkdown 将Markdown解析为渲染块，只支持常用的一部分语法
+func parseMarkdown(md string) []*renderBlock {
+	var blocks []*renderBlock
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		indent := (len(line) - len(strings.TrimLeft(line, " \t"))) / 2
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			b := &renderBlock{kind: blockCode}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				b.lines = append(b.lines, strings.ReplaceAll(lines[i], "\t", "    "))
+			}
+			blocks = append(blocks, b)
+		case trimmed == "---" || trimmed == "***" || trimmed == "___":
+			blocks = append(blocks, &renderBlock{kind: blockRule})
+		case strings.HasPrefix(trimmed, "#"):
+			level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+			if level > 6 || (len(trimmed) > level && trimmed[level] != ' ') {
+				blocks = append(blocks, &renderBlock{kind: blockParagraph, runs: parseInline(trimmed)})
+				continue
+			}
+			blocks = append(blocks, &renderBlock{kind: blockHeading, level: level, runs: parseInline(strings.TrimSpace(trimmed[level:]))})
+		case strings.HasPrefix(trimmed, ">"):
+			blocks = append(blocks, &renderBlock{kind: blockQuote, runs: parseInline(strings.TrimSpace(trimmed[1:]))})
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") || strings.HasPrefix(trimmed, "+ "):
+			blocks = append(blocks, &renderBlock{kind: blockListItem, level: indent, marker: "•", runs: parseInline(trimmed[2:])})
+		case orderedListMarker(trimmed) != "":
+			marker := orderedListMarker(trimmed)
+			blocks = append(blocks, &renderBlock{kind: blockListItem, level: indent, marker: marker, runs: parseInline(strings.TrimSpace(trimmed[len(marker):]))})
+		case strings.HasPrefix(trimmed, "|"):
+			b := &renderBlock{kind: blockTable}
+			var rows [][]string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+				cells := splitTableRow(lines[i])
+				if isTableSeparator(cells) {
+					continue
+				}
+				rows = append(rows, cells)
+			}
+			i--
+			if len(rows) > 0 {
+				b.header, b.rows = rows[0], rows[1:]
+			}
+			blocks = append(blocks, b)
+		default:
+			blocks = append(blocks, &renderBlock{kind: blockParagraph, runs: parseInline(trimmed)})
+		}
+	}
+	return blocks
+}
+
+// orderedListMarker 获取有序列表项的序号部分，例如 "1."，不是有序列表时返回空字符串
+func orderedListMarker(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 || i+1 >= len(s) || s[i] != '.' || s[i+1] != ' ' {
+		return ""
+	}
+	return s[:i+1]
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+func isTableSeparator(cells []string) bool {
+	for _, c := range cells {
+		if strings.Trim(c, ":-") != "" || c == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseInline 解析行内的 **粗体** 和 `代码`
+func parseInline(s string) []textRun {
+	var runs []textRun
+	style := styleRegular
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			runs = append(runs, textRun{text: current.String(), style: style})
+			current.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case style != styleMono && strings.HasPrefix(s[i:], "**"):
+			flush()
+			if style == styleBold {
+				style = styleRegular
+			} else {
+				style = styleBold
+			}
+			i++
+		case s[i] == '`':
+			flush()
+			if style == styleMono {
+				style = styleRegular
+			} else {
+				style = styleMono
+			}
+		default:
+			current.WriteByte(s[i])
+		}
+	}
+	flush()
+	return runs
+}
+
+// renderFaces 一次渲染中使用的字体，opentype.Face 不能并发使用，所以每次渲染都单独创建
+//
+// 绘制的过程中不返回错误，创建字体失败和缺少中日韩字形时记录在 err 中，由 renderPNG 在测量之后返回
+type renderFaces struct {
+	r     *TextRenderer
+	cache map[faceKey]font.Face
+	err   error
+}
+
+type faceKey struct {
+	f    *opentype.Font
+	size float64
+}
+
+// face 获取指定字体和字号的字体，同样的字体和字号只创建一次
+func (fs *renderFaces) face(f *opentype.Font, size float64) (font.Face, error) {
+	key := faceKey{f, size}
+	if face, ok := fs.cache[key]; ok {
+		return face, nil
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("创建 %v 像素的字体失败：%w", size, err)
+	}
+	fs.cache[key] = face
+	return face, nil
+}
+
+// drawFace 获取绘制使用的字体，创建失败时记录错误并返回占位字体，让测量可以继续进行
+func (fs *renderFaces) drawFace(f *opentype.Font, size float64) font.Face {
+	face, err := fs.face(f, size)
+	if err != nil {
+		fs.fail(err)
+		return basicfont.Face7x13
+	}
+	return face
+}
+
+// fail 记录渲染中遇到的第一个错误
+func (fs *renderFaces) fail(err error) {
+	if fs.err == nil {
+		fs.err = err
+	}
+}
+
+// runeFace 选择包含这个字形的字体，都不包含时使用样式对应的字体
+func (fs *renderFaces) runeFace(style runStyle, size float64, ch rune) font.Face {
+	primary := fs.r.regular
+	switch style {
+	case styleBold:
+		primary = fs.r.bold
+	case styleMono:
+		primary = fs.r.mono
+	}
+	face := fs.drawFace(primary, size)
+	if _, ok := face.GlyphAdvance(ch); ok || unicode.IsSpace(ch) {
+		return face
+	}
+	for _, fb := range fs.r.fallbacks {
+		f := fs.drawFace(fb, size)
+		if _, ok := f.GlyphAdvance(ch); ok {
+			return f
+		}
+	}
+	if unicode.In(ch, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		fs.fail(ErrRenderNoCJKFont)
+	}
+	return face
+}
+
+func (fs *renderFaces) runeWidth(style runStyle, size float64, ch rune) fixed.Int26_6 {
+	adv, _ := fs.runeFace(style, size, ch).GlyphAdvance(ch)
+	return adv
+}
+
+func (fs *renderFaces) lineHeight(size float64) int {
+	m := fs.drawFace(fs.r.regular, size).Metrics()
+	return int(float64(m.Height.Ceil()) * fs.r.theme.LineSpacing)
+}
+
+// styledRune 带样式的字符
+type styledRune struct {
+	ch    rune
+	style runStyle
+}
+
+// wrapRuns 按宽度对文本片段换行，优先在空格处断开
+func (fs *renderFaces) wrapRuns(runs []textRun, size float64, width int) [][]styledRune {
+	limit := fixed.I(width)
+	var lines [][]styledRune
+	var line []styledRune
+	var lineWidth fixed.Int26_6
+	lastSpace := -1
+	for _, run := range runs {
+		for _, ch := range run.text {
+			if ch == '\t' {
+				ch = ' '
+			}
+			w := fs.runeWidth(run.style, size, ch)
+			if lineWidth+w > limit && len(line) > 0 {
+				if ch == ' ' {
+					lines = append(lines, line)
+					line, lineWidth, lastSpace = nil, 0, -1
+					continue
+				}
+				if lastSpace > 0 {
+					lines = append(lines, line[:lastSpace])
+					line = append([]styledRune(nil), line[lastSpace+1:]...)
+				} else {
+					lines = append(lines, line)
+					line = nil
+				}
+				lineWidth, lastSpace = 0, -1
+				for _, sr := range line {
+					lineWidth += fs.runeWidth(sr.style, size, sr.ch)
+				}
+			}
+			if ch == ' ' {
+				lastSpace = len(line)
+			}
+			line = append(line, styledRune{ch, run.style})
+			lineWidth += w
+		}
+	}
+	return append(lines, line)
+}
+
+func (fs *renderFaces) textWidth(s string, style runStyle, size float64) int {
+	var w fixed.Int26_6
+	for _, ch := range s {
+		w += fs.runeWidth(style, size, ch)
+	}
+	return w.Ceil()
+}
+
+// canvas 记录绘制的位置，dst 为nil时只计算高度
+type canvas struct {
+	fs  *renderFaces
+	dst *image.RGBA
+	y   int
+}
+
+func (c *canvas) fill(rect image.Rectangle, col color.Color) {
+	if c.dst != nil {
+		draw.Draw(c.dst, rect, image.NewUniform(col), image.Point{}, draw.Src)
+	}
+}
+
+// drawLine 在当前位置绘制一行文本并移动到下一行
+func (c *canvas) drawLine(x int, line []styledRune, size float64, col color.Color) {
+	lh := c.fs.lineHeight(size)
+	if c.dst != nil {
+		m := c.fs.drawFace(c.fs.r.regular, size).Metrics()
+		baseline := c.y + (lh+m.Ascent.Ceil()-m.Descent.Ceil())/2
+		d := &font.Drawer{Dst: c.dst, Src: image.NewUniform(col), Dot: fixed.P(x, baseline)}
+		for _, sr := range line {
+			d.Face = c.fs.runeFace(sr.style, size, sr.ch)
+			d.DrawString(string(sr.ch))
+		}
+	}
+	c.y += lh
+}
+
+func (r *TextRenderer) renderPNG(blocks []*renderBlock) ([]byte, error) {
+	if len(blocks) == 0 {
+		return nil, ErrRenderEmpty
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	fs := &renderFaces{r: r, cache: make(map[faceKey]font.Face)}
+	defer func() {
+		for _, f := range fs.cache {
+			_ = f.Close()
+		}
+	}()
+
+	// 第一遍只计算高度，第二遍实际绘制
+	measure := &canvas{fs: fs}
+	r.drawBlocks(measure, blocks)
+	if fs.err != nil {
+		return nil, fs.err
+	}
+	height := measure.y + r.theme.Padding
+	maxHeight := r.maxHeight
+	if maxHeight <= 0 {
+		maxHeight = renderMaxHeight
+	}
+	truncated := false
+	if height > maxHeight {
+		height, truncated = maxHeight, true
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, r.width, height))
+	c := &canvas{fs: fs, dst: img}
+	c.fill(img.Bounds(), r.theme.Background)
+	r.drawBlocks(c, blocks)
+	if truncated {
+		lh := fs.lineHeight(r.theme.FontSize)
+		c.y = height - lh - r.theme.Padding/2
+		c.fill(image.Rect(0, c.y, r.width, height), r.theme.Background)
+		notice := []styledRune{}
+		for _, ch := range "……" {
+			notice = append(notice, styledRune{ch, styleRegular})
+		}
+		c.drawLine(r.theme.Padding, notice, r.theme.FontSize, r.theme.Muted)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// validate 检查图片的尺寸和主题的字号
+func (r *TextRenderer) validate() error {
+	t := r.theme
+	switch {
+	case t.Padding < 0:
+		return fmt.Errorf("%w：留白 %d 不能小于0", ErrRenderSize, t.Padding)
+	case r.width <= 2*t.Padding || r.width > renderMaxWidth:
+		return fmt.Errorf("%w：宽度 %d 需要大于左右留白 %d 且不超过 %d", ErrRenderSize, r.width, 2*t.Padding, renderMaxWidth)
+	case r.maxHeight > renderMaxHeight:
+		return fmt.Errorf("%w：最大高度 %d 不能超过 %d", ErrRenderSize, r.maxHeight, renderMaxHeight)
+	case !(t.FontSize > 0 && t.FontSize <= 512):
+		return fmt.Errorf("%w：字号 %v 需要大于0且不超过512", ErrRenderSize, t.FontSize)
+	case !(t.LineSpacing > 0 && t.LineSpacing <= 10):
+		return fmt.Errorf("%w：行距 %v 需要大于0且不超过10", ErrRenderSize, t.LineSpacing)
+	}
+	return nil
+}
+
+func (r *TextRenderer) drawBlocks(c *canvas, blocks []*renderBlock) {
+	t := r.theme
+	left := t.Padding
+	contentWidth := r.width - 2*t.Padding
+	c.y = t.Padding
+	for _, b := range blocks {
+		switch b.kind {
+		case blockParagraph:
+			for _, line := range c.fs.wrapRuns(b.runs, t.FontSize, contentWidth) {
+				c.drawLine(left, line, t.FontSize, t.Foreground)
+			}
+		case blockHeading:
+			size := t.FontSize * headingScale(b.level)
+			runs := make([]textRun, len(b.runs))
+			for i, run := range b.runs {
+				runs[i] = textRun{text: run.text, style: styleBold}
+			}
+			c.y += int(t.FontSize / 2)
+			for _, line := range c.fs.wrapRuns(runs, size, contentWidth) {
+				c.drawLine(left, line, size, t.Heading)
+			}
+		case blockListItem:
+			indent := left + b.level*int(t.FontSize*1.5)
+			markerWidth := c.fs.textWidth(b.marker+" ", styleRegular, t.FontSize)
+			for i, line := range c.fs.wrapRuns(b.runs, t.FontSize, contentWidth-(indent-left)-markerWidth) {
+				if i == 0 {
+					y := c.y
+					c.drawLine(indent, toStyledRunes(b.marker, styleRegular), t.FontSize, t.Muted)
+					c.y = y
+				}
+				c.drawLine(indent+markerWidth, line, t.FontSize, t.Foreground)
+			}
+		case blockQuote:
+			bar := int(t.FontSize / 5)
+			start := c.y
+			for _, line := range c.fs.wrapRuns(b.runs, t.FontSize, contentWidth-bar*4) {
+				c.drawLine(left+bar*4, line, t.FontSize, t.Muted)
+			}
+			c.fill(image.Rect(left, start, left+bar, c.y), t.Border)
+		case blockCode:
+			pad := int(t.FontSize / 2)
+			start := c.y
+			var wrapped [][]styledRune
+			for _, l := range b.lines {
+				wrapped = append(wrapped, c.fs.wrapRuns([]textRun{{text: l, style: styleMono}}, t.FontSize*0.9, contentWidth-2*pad)...)
+			}
+			lh := c.fs.lineHeight(t.FontSize * 0.9)
+			c.fill(image.Rect(left, start, left+contentWidth, start+len(wrapped)*lh+2*pad), t.CodeBackground)
+			c.y += pad
+			for _, line := range wrapped {
+				c.drawLine(left+pad, line, t.FontSize*0.9, t.Foreground)
+			}
+			c.y += pad
+		case blockRule:
+			mid := c.y + c.fs.lineHeight(t.FontSize)/2
+			c.fill(image.Rect(left, mid, left+contentWidth, mid+1), t.Border)
+			c.y += c.fs.lineHeight(t.FontSize)
+		case blockTable:
+			r.drawTable(c, b, left, contentWidth)
+		}
+	}
+}
+
+func headingScale(level int) float64 {
+	switch level {
+	case 1:
+		return 1.6
+	case 2:
+		return 1.35
+	case 3:
+		return 1.15
+	}
+	return 1
+}
+
+func toStyledRunes(s string, style runStyle) []styledRune {
+	var result []styledRune
+	for _, ch := range s {
+		result = append(result, styledRune{ch, style})
+	}
+	return result
+}
+
+// drawTable 绘制表格，列宽按内容分配，总宽度超出时按比例缩小并在单元格内换行
+func (r *TextRenderer) drawTable(c *canvas, b *renderBlock, left, width int) {
+	t := r.theme
+	cols := len(b.header)
+	for _, row := range b.rows {
+		cols = max(cols, len(row))
+	}
+	if cols == 0 {
+		return
+	}
+	pad := int(t.FontSize / 2)
+	natural := make([]int, cols)
+	measureRow := func(row []string, style runStyle) {
+		for i, cell := range row {
+			natural[i] = max(natural[i], c.fs.textWidth(cell, style, t.FontSize)+2*pad)
+		}
+	}
+	measureRow(b.header, styleBold)
+	for _, row := range b.rows {
+		measureRow(row, styleRegular)
+	}
+	total := 0
+	for _, w := range natural {
+		total += w
+	}
+	widths := make([]int, cols)
+	for i, w := range natural {
+		widths[i] = w
+		if total > width {
+			widths[i] = max(w*width/total, 2*pad+int(t.FontSize))
+		}
+	}
+
+	lh := c.fs.lineHeight(t.FontSize)
+	drawRow := func(row []string, style runStyle, background color.Color) {
+		cells := make([][][]styledRune, cols)
+		lines := 1
+		for i := 0; i < cols; i++ {
+			text := ""
+			if i < len(row) {
+				text = row[i]
+			}
+			cells[i] = c.fs.wrapRuns([]textRun{{text: text, style: style}}, t.FontSize, widths[i]-2*pad)
+			lines = max(lines, len(cells[i]))
+		}
+		top := c.y
+		rowHeight := lines*lh + pad
+		x := left
+		for i := 0; i < cols; i++ {
+			c.fill(image.Rect(x, top, x+widths[i], top+rowHeight), background)
+			c.y = top + pad/2
+			for _, line := range cells[i] {
+				c.drawLine(x+pad, line, t.FontSize, t.Foreground)
+			}
+			x += widths[i]
+		}
+		// 边框
+		c.fill(image.Rect(left, top, x, top+1), t.Border)
+		c.fill(image.Rect(left, top+rowHeight, x+1, top+rowHeight+1), t.Border)
+		x = left
+		for i := 0; i <= cols; i++ {
+			c.fill(image.Rect(x, top, x+1, top+rowHeight), t.Border)
+			if i < cols {
+				x += widths[i]
+			}
+		}
+		c.y = top + rowHeight
+	}
+	if len(b.header) > 0 {
+		drawRow(b.header, styleBold, t.CodeBackground)
+	}
+	for _, row := range b.rows {
+		drawRow(row, styleRegular, t.Background)
+	}
+	c.y += pad
+}
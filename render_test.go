@@ -0,0 +1,73 @@
+package cryo_test
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/machinacanis/cryo"
+)
+
+// decodePNGSize 解码PNG数据并返回图片的尺寸
+func decodePNGSize(t *testing.T, data []byte) (width, height int) {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("渲染结果不是有效的PNG：%v", err)
+	}
+	b := img.Bounds()
+	return b.Dx(), b.Dy()
+}
+
+func TestRenderPNGSize(t *testing.T) {
+	r := cryo.NewTextRenderer().WithWidth(400)
+
+	short, err := r.RenderPNG("# Title\n\nhello **world**")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, h := decodePNGSize(t, short)
+	if w != 400 || h <= 2*cryo.LightRenderTheme.Padding {
+		t.Fatalf("图片尺寸为 %dx%d，期望宽度为 400 并且包含内容", w, h)
+	}
+
+	long, err := r.RenderPNG(strings.Repeat("- item\n", 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, lh := decodePNGSize(t, long); lh <= h {
+		t.Errorf("10 行列表的高度 %d 没有超过 2 行内容的高度 %d", lh, h)
+	}
+
+	// 超过最大高度的内容会被截断到最大高度
+	truncated, err := r.WithMaxHeight(120).RenderPNG(strings.Repeat("line\n\n", 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h := decodePNGSize(t, truncated); w != 400 || h != 120 {
+		t.Errorf("截断后的图片尺寸为 %dx%d，期望 400x120", w, h)
+	}
+}
+
+func TestRenderTableImage(t *testing.T) {
+	img, err := cryo.NewTextRenderer().WithWidth(300).RenderTable([]string{"name", "score"}, [][]string{{"a", "1"}, {"b", "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(img.Stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := decodePNGSize(t, data); w != 300 {
+		t.Errorf("表格图片的宽度为 %d，期望 300", w)
+	}
+}
+
+func TestRenderSizeError(t *testing.T) {
+	if _, err := cryo.NewTextRenderer().WithWidth(10).RenderPNG("hello"); !errors.Is(err, cryo.ErrRenderSize) {
+		t.Errorf("宽度小于留白时返回 %v，期望 ErrRenderSize", err)
+	}
+}
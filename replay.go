@@ -0,0 +1,140 @@
+package cryo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-json-experiment/json"
+)
+
+// ReplayTag 是回放的事件携带的标签，事件录制器不会录制带有这个标签的事件
+const ReplayTag = "replay"
+
+// RecordedEvent 是录制文件中的一条记录
+type RecordedEvent struct {
+	Time  time.Time // 事件被录制的时间，回放时用来计算事件之间的间隔
+	Event Event     // 录制的事件，没有绑定Bot客户端
+}
+
+// ReplayOptions 是回放录制的事件时的选项
+type ReplayOptions struct {
+	Speed  float64         // 回放速度的倍率，1为原速，10为十倍速，为0时不等待，依次发布所有事件
+	Client *LagrangeClient // 事件绑定的Bot客户端，为空时使用和录制时Uin相同的模拟客户端，没有时会自动连接一个，不会使用真实的客户端
+}
+
+// RecordingFiles 获取录制文件和它轮转出的旧文件，按照从旧到新的顺序排列，不存在的文件会被跳过
+func RecordingFiles(file string) []string {
+	var files []string
+	for i := 1; ; i++ {
+		name := file + "." + strconv.Itoa(i)
+		if _, err := os.Stat(name); err != nil {
+			break
+		}
+		files = append(files, name)
+	}
+	slices.Reverse(files)
+	if _, err := os.Stat(file); err == nil {
+		files = append(files, file)
+	}
+	return files
+}
+
+// ReadRecording 读取录制文件，传入多个文件时按照顺序拼接，可以配合 RecordingFiles 读取包括旧文件在内的完整录制
+func ReadRecording(files ...string) ([]RecordedEvent, error) {
+	var records []RecordedEvent
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		records, err = readRecording(f, file, records)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// readRecording 逐行解析录制文件并追加到 records 中
+func readRecording(r io.Reader, file string, records []RecordedEvent) ([]RecordedEvent, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20) // 带有长消息的事件可能会超过默认的单行长度限制
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var line recordLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行解析失败：%w", file, n, err)
+		}
+		e, err := UnmarshalEvent(line.Event)
+		if err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行：%w", file, n, err)
+		}
+		records = append(records, RecordedEvent{Time: line.Time, Event: e})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 %s 失败：%w", file, err)
+	}
+	return records, nil
+}
+
+// Replay 把录制的事件依次发布到事件总线上，按照录制时的间隔和回放速度等待，ctx 被取消时停止回放并返回它的错误
+//
+// 回放的事件会带上 ReplayTag 标签，每个事件都会在前一个事件的同步中间件执行完后才发布，
+// 不等待时同样的录制和同样的插件总是会得到同样的处理顺序，适合用来复现问题。
+// 回放会像真实收到的事件一样触发插件的回复和操作，没有指定 ReplayOptions.Client 时事件只会绑定到模拟客户端，
+// 即使Bot上连接着同一个账号的真实客户端，回复也不会被真的发送出去
+func (b *Bot) Replay(ctx context.Context, records []RecordedEvent, opt ...ReplayOptions) error {
+	var o ReplayOptions
+	if len(opt) > 0 {
+		o = opt[0]
+	}
+	for i, r := range records {
+		if o.Speed > 0 && i > 0 {
+			if d := time.Duration(float64(r.Time.Sub(records[i-1].Time)) / o.Speed); d > 0 {
+				timer := time.NewTimer(d)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e := r.Event.Clone() // 不修改录制的事件，同一份录制可以回放多次
+		u := e.GetUniEvent()
+		c := o.Client
+		if c == nil {
+			c = b.replayClient(u.ClientUin)
+		}
+		u.botClient = c
+		u.ClientId, u.ClientUin, u.ClientUid, u.ClientNickname = c.Id, c.Uin, c.Uid, c.Nickname // Bot.GetClient 通过 ClientId 查找客户端
+		u.EventTags = append(slices.Clone(u.EventTags), ReplayTag)
+		if mock, ok := c.protocol.(*MockProtocol); ok {
+			mock.learn(e)
+		}
+		b.bus.Publish(e)
+	}
+	return nil
+}
+
+// replayClient 获取回放事件使用的模拟客户端，没有对应Uin的模拟客户端时连接一个，真实的客户端不会被使用
+func (b *Bot) replayClient(uin uint32) *LagrangeClient {
+	for _, c := range b.getConnectedClients() {
+		if _, ok := c.protocol.(*MockProtocol); ok && c.Uin == uin {
+			return c
+		}
+	}
+	return b.ConnectWithProtocol(NewMockProtocol(uin))
+}
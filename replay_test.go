@@ -0,0 +1,79 @@
+package cryo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/machinacanis/cryo"
+	"github.com/machinacanis/cryo/cryotest"
+)
+
+// liveProtocol 包装模拟协议，让它在回放时被当作真实的客户端
+type liveProtocol struct {
+	*cryo.MockProtocol
+}
+
+// waitFor 等待 cond 返回true，超时时测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReplayRepliesThroughMockClient(t *testing.T) {
+	h := cryotest.New(t)
+	live := cryo.NewMockProtocol(secondUin)
+	live.AddGroup(100, "")
+	h.Bot().ConnectWithProtocol(liveProtocol{live})
+
+	replies := make(chan *cryo.LagrangeClient, 1)
+	h.Bot().OnCommand("/ping").Handle(func(e *cryo.GroupMessageEvent) {
+		if _, err := h.Bot().ReplyTo(e, "pong"); err != nil {
+			t.Errorf("回复回放的事件失败：%v", err)
+		}
+		replies <- h.Bot().GetClient(e)
+	}).Register()
+
+	msg, err := cryo.ParseMessage("/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := &cryo.GroupMessageEvent{UniMessageEvent: cryo.UniMessageEvent{
+		UniEvent:        cryo.UniEvent{EventType: cryo.GroupMessageEventType, EventId: "recorded", ClientId: "recorded-client", ClientUin: secondUin},
+		SenderUin:       1001,
+		GroupUin:        100,
+		MessageElements: msg,
+	}}
+	if err := h.Bot().Replay(t.Context(), []cryo.RecordedEvent{{Time: time.Now(), Event: recorded}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var c *cryo.LagrangeClient
+	select {
+	case c = <-replies:
+	case <-time.After(2 * time.Second):
+		t.Fatal("回放的事件没有被处理")
+	}
+	if c == nil {
+		t.Fatal("Bot.GetClient 找不到回放的事件对应的客户端")
+	}
+	mock, ok := c.GetProtocol().(*cryo.MockProtocol)
+	if !ok || c.Uin != secondUin {
+		t.Fatalf("回放的事件绑定到了 %d 的 %T，期望 %d 的模拟客户端", c.Uin, c.GetProtocol(), secondUin)
+	}
+	waitFor(t, "回复", func() bool { return len(mock.SentMessages()) == 1 })
+	if sent := mock.SentMessages()[0]; sent.GroupUin != 100 {
+		t.Errorf("回复发送到了群 %d，期望 100", sent.GroupUin)
+	}
+	if n := len(live.Actions()); n != 0 {
+		t.Errorf("真实的客户端不应该执行操作，实际执行了 %d 个：%v", n, live.Actions())
+	}
+	if recorded.ClientId != "recorded-client" {
+		t.Errorf("回放修改了录制的事件：%s", recorded.ClientId)
+	}
+}
@@ -73,3 +73,10 @@ func (b *Bot) OnKeyWord(keyword ...string) *OnResponser {
 func (b *Bot) OnAllKeyWord(keyword ...string) *OnResponser {
 	return NewOnResponser(b.bus, PrivateMessageEventType, GroupMessageEventType, TempMessageEventType).AddRule(AllKeyWordRule(keyword...)) // 使用内置的规则
 }
+
+// OnCommand 创建一个新的消息事件响应器
+//
+// 这个响应器在 OnMessage 的基础上添加了命令匹配的响应规则，命令需要包含前缀，例如 /ping
+func (b *Bot) OnCommand(command ...string) *OnResponser {
+	return NewOnResponser(b.bus, PrivateMessageEventType, GroupMessageEventType, TempMessageEventType).AddRule(CommandRule(command...)) // 使用内置的规则
+}
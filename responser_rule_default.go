@@ -1,5 +1,11 @@
 package cryo
 
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
 // ToMeRule 内置的 提及我 规则，接收到群聊消息时会检查是否有At到当前用户，否则退出消息事件的处理
 //
 // 如果指定了 removeAt 参数为 false，则不会移除 At 元素
@@ -330,3 +336,99 @@ func AllKeyWordRule(keyword ...string) Rule[Event] {
 			return true
 		})
 }
+
+// CommandRule 内置的命令匹配规则，检查消息的第一个元素是否是以指定命令开头的文本，命令后面需要是空白或者消息结尾
+//
+// 命令需要包含前缀，例如 /ping，会同时匹配私聊、群聊和临时会话的消息
+func CommandRule(command ...string) Rule[Event] {
+	return RuleFor(
+		func(e *UniMessageEvent) bool {
+			if len(command) == 0 {
+				return false
+			}
+
+			msg := *e.GetMessage()
+			if len(msg) == 0 {
+				return false
+			}
+			text, ok := msg[0].(*Text)
+			if !ok {
+				return false
+			}
+
+			content := strings.TrimLeft(text.Content, " ")
+			for _, c := range command {
+				rest, ok := strings.CutPrefix(content, c)
+				if ok && len(c) > 0 && (rest == "" || unicode.IsSpace([]rune(rest)[0])) {
+					return true
+				}
+			}
+			return false
+		})
+}
+
+// RuleConfig 是用配置描述的内置规则，在远程插件和 Webhook 这类没办法直接编写规则函数的地方使用
+//
+// Type 支持 to_me、at、start_with、end_with、full_match、keyword、all_keyword 和 command，Args 是规则的参数
+type RuleConfig struct {
+	Type string `json:"type"`
+	Args []any  `json:"args,omitzero"`
+}
+
+// Build 根据配置创建对应的内置规则
+func (rc RuleConfig) Build() (Rule[Event], error) {
+	strs := make([]string, 0, len(rc.Args))
+	for _, arg := range rc.Args {
+		if s, ok := arg.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	switch rc.Type {
+	case "to_me":
+		removeAt := true
+		if len(rc.Args) > 0 {
+			if b, ok := rc.Args[0].(bool); ok {
+				removeAt = b
+			}
+		}
+		return ToMeRule(removeAt), nil
+	case "at":
+		p := adapterParams{}
+		targets := make([]uint32, 0, len(rc.Args))
+		for _, arg := range rc.Args {
+			p["uin"] = arg
+			uin, err := p.requireUint32("uin")
+			if err != nil {
+				return nil, fmt.Errorf("at 规则的参数 %v 不是有效的QQ号", arg)
+			}
+			targets = append(targets, uin)
+		}
+		return AtRule(targets...), nil
+	case "start_with":
+		return StartWithRule(strs...), nil
+	case "end_with":
+		return EndWithRule(strs...), nil
+	case "full_match":
+		return FullMatchRule(strs...), nil
+	case "keyword":
+		return KeyWordRule(strs...), nil
+	case "all_keyword":
+		return AllKeyWordRule(strs...), nil
+	case "command":
+		return CommandRule(strs...), nil
+	}
+	return nil, fmt.Errorf("未知的规则 %q", rc.Type)
+}
+
+// BuildRules 根据配置创建多个内置规则
+func BuildRules(configs ...RuleConfig) ([]Rule[Event], error) {
+	rules := make([]Rule[Event], 0, len(configs))
+	for _, rc := range configs {
+		r, err := rc.Build()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
@@ -0,0 +1,391 @@
+package cryo
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/gorilla/websocket"
+)
+
+// SatoriConfig Satori 适配器的配置
+type SatoriConfig struct {
+	Addr           string        `json:"addr,omitempty,omitzero"`             // HTTP API 和事件 WebSocket 的监听地址，例如 127.0.0.1:5140，为空时不监听
+	Path           string        `json:"path,omitempty,omitzero"`             // API 的路径前缀，默认为 /v1
+	AccessToken    string        `json:"access_token,omitempty,omitzero"`     // 访问令牌，为空时不校验
+	WebhookURLs    []string      `json:"webhook_urls,omitempty,omitzero"`     // WebHook 上报地址
+	WebhookTimeout time.Duration `json:"webhook_timeout,omitempty,omitzero"`  // WebHook 上报的超时时间，默认为5秒
+	AllowLocalFile bool          `json:"allow_local_file,omitempty,omitzero"` // 是否允许消息中的媒体使用 file:// 读取本地文件，默认关闭，只在连接的应用完全可信时开启
+}
+
+// Satori 信令的操作码
+const (
+	satoriOpEvent    = 0
+	satoriOpPing     = 1
+	satoriOpPong     = 2
+	satoriOpIdentify = 3
+	satoriOpReady    = 4
+)
+
+// Satori 频道的类型
+const (
+	satoriTextChannel   = 0
+	satoriDirectChannel = 1
+)
+
+const satoriPlatform = "qq"
+
+// SatoriAdapter 是 Satori 协议的适配器，以插件的形式运行
+//
+// 适配器通过 HTTP API 和 {Path}/events 上的 WebSocket 同时为所有已连接的Bot账号提供服务，
+// 群聊对应 Satori 的群组和频道，群号同时作为群组ID和频道ID，私聊的频道ID为 private:<QQ号>
+type SatoriAdapter struct {
+	*adapterBase
+	conf       SatoriConfig
+	httpClient *http.Client
+	sn         atomic.Int64 // 事件序列号
+}
+
+// NewSatoriAdapter 创建一个新的 Satori 适配器，需要通过 Bot.AddPlugin 添加到Bot中才会生效
+func NewSatoriAdapter(conf SatoriConfig) *SatoriAdapter {
+	conf.Path = "/" + strings.Trim(conf.Path, "/")
+	if conf.Path == "/" {
+		conf.Path = "/v1"
+	}
+	if conf.WebhookTimeout <= 0 {
+		conf.WebhookTimeout = 5 * time.Second
+	}
+	a := &SatoriAdapter{
+		adapterBase: newAdapterBase("Satori", "0.1.0", "Satori HTTP 和 WebSocket 适配器"),
+		conf:        conf,
+		httpClient:  &http.Client{Timeout: conf.WebhookTimeout},
+	}
+	a.addr = conf.Addr
+	a.accessToken = conf.AccessToken
+	a.handler = a
+	a.report = a.reportEvent
+	a.receive = a.handleSignal
+	return a
+}
+
+// satoriSignal 是 WebSocket 上收发的信令
+type satoriSignal struct {
+	Op   int           `json:"op"`
+	Body adapterParams `json:"body,omitzero"`
+}
+
+// ServeHTTP 处理 HTTP API 和事件 WebSocket 请求，可以把适配器挂载到其他的 HTTP 服务上
+func (a *SatoriAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.Path, a.conf.Path+"/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if path == "events" {
+		if !websocket.IsWebSocketUpgrade(r) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		ws, err := a.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return // Upgrade 已经写入了错误响应
+		}
+		a.serveConn(&adapterConn{ws: ws}) // 令牌在 IDENTIFY 信令中校验
+		return
+	}
+
+	if status := checkAccessToken(r, a.conf.AccessToken); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	method, ok := satoriMethods[path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	params := adapterParams{}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 16<<20))
+	if err == nil && len(bytes.TrimSpace(body)) > 0 {
+		err = json.Unmarshal(body, &params)
+	}
+	if err != nil {
+		http.Error(w, "请求体不是有效的JSON对象", http.StatusBadRequest)
+		return
+	}
+
+	c, err := a.client(satoriSelfId(r.Header))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := method(a, c, params)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errAdapterParams) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	data, err := marshalOneBot(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// satoriSelfId 读取请求头中的Bot账号，兼容旧版本的 X-Self-ID
+func satoriSelfId(header http.Header) uint32 {
+	id := header.Get("Satori-User-ID")
+	if id == "" {
+		id = header.Get("X-Self-ID")
+	}
+	selfId, _ := strconv.ParseUint(id, 10, 32)
+	return uint32(selfId)
+}
+
+// handleSignal 处理事件 WebSocket 收到的信令
+func (a *SatoriAdapter) handleSignal(oc *adapterConn, data []byte) {
+	var sig satoriSignal
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return
+	}
+	switch sig.Op {
+	case satoriOpPing:
+		a.writeSignal(oc, satoriOpPong, nil)
+	case satoriOpIdentify:
+		if a.conf.AccessToken != "" && subtle.ConstantTimeCompare([]byte(sig.Body.getString("token")), []byte(a.conf.AccessToken)) != 1 {
+			_ = oc.ws.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "invalid token"), time.Now().Add(time.Second))
+			_ = oc.ws.Close()
+			return
+		}
+		a.writeSignal(oc, satoriOpReady, map[string]any{"logins": a.logins()})
+		oc.ready.Store(true)
+	}
+}
+
+// writeSignal 向连接发送一条信令
+func (a *SatoriAdapter) writeSignal(oc *adapterConn, op int, body any) {
+	sig := map[string]any{"op": op}
+	if body != nil {
+		sig["body"] = body
+	}
+	if data, err := marshalOneBot(sig); err == nil {
+		_ = oc.write(data)
+	}
+}
+
+// reportEvent 把事件发送给所有已经鉴权的连接和 WebHook 地址
+func (a *SatoriAdapter) reportEvent(e Event, ae *adapterEvent) {
+	event := a.convertEvent(ae)
+	if event == nil {
+		return
+	}
+	data, err := marshalOneBot(map[string]any{"op": satoriOpEvent, "body": event})
+	if err != nil {
+		a.logger.Errorf("[Satori] 序列化事件 %s 失败：%v", e.GetEventType().ToString(), err)
+		return
+	}
+	a.broadcast(ae.SelfId, data)
+	if len(a.conf.WebhookURLs) > 0 {
+		if body, err := marshalOneBot(event); err == nil {
+			go a.post(ae.SelfId, body)
+		}
+	}
+}
+
+// post 通过 WebHook 上报事件
+func (a *SatoriAdapter) post(selfId uint32, data []byte) {
+	for _, url := range a.conf.WebhookURLs {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+		if err != nil {
+			a.logger.Errorf("[Satori] WebHook 地址 %s 无效：%v", url, err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Satori-Opcode", strconv.Itoa(satoriOpEvent))
+		req.Header.Set("Satori-Platform", satoriPlatform)
+		req.Header.Set("Satori-User-ID", strconv.FormatUint(uint64(selfId), 10))
+		if a.conf.AccessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+a.conf.AccessToken)
+		}
+		resp, err := a.httpClient.Do(req)
+		if err != nil {
+			a.logger.Warnf("[Satori] 上报事件到 %s 失败：%v", url, err)
+			continue
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		_ = resp.Body.Close()
+	}
+}
+
+// satoriId 把QQ号、群号或者消息ID格式化为 Satori 中的字符串ID
+func satoriId[T uint32 | int32](id T) string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// satoriUser 构造 Satori 的用户对象
+func satoriUser(uin uint32, name string) map[string]any {
+	return map[string]any{
+		"id":     satoriId(uin),
+		"name":   name,
+		"avatar": "https://q1.qlogo.cn/g?b=qq&s=640&nk=" + satoriId(uin),
+	}
+}
+
+// satoriGuild 构造 Satori 的群组对象
+func satoriGuild(groupUin uint32, name string) map[string]any {
+	return map[string]any{
+		"id":     satoriId(groupUin),
+		"name":   name,
+		"avatar": "https://p.qlogo.cn/gh/" + satoriId(groupUin) + "/" + satoriId(groupUin) + "/640",
+	}
+}
+
+// satoriChannel 构造会话对应的 Satori 频道对象
+func satoriChannel(target SendTarget, name string) map[string]any {
+	switch target.Type {
+	case GroupTarget:
+		return map[string]any{"id": satoriId(target.GroupUin), "type": satoriTextChannel, "name": name}
+	case TempTarget:
+		return map[string]any{"id": "temp:" + satoriId(target.GroupUin) + ":" + satoriId(target.UserUin), "type": satoriDirectChannel}
+	}
+	return map[string]any{"id": "private:" + satoriId(target.UserUin), "type": satoriDirectChannel}
+}
+
+// satoriTarget 把 Satori 的频道ID解析为发送目标
+func satoriTarget(channelId string) (SendTarget, error) {
+	p := adapterParams{}
+	var target SendTarget
+	var err error
+	if uin, ok := strings.CutPrefix(channelId, "private:"); ok {
+		p["user_id"] = uin
+		target.Type = PrivateTarget
+		target.UserUin, err = p.requireUint32("user_id")
+	} else if id, ok := strings.CutPrefix(channelId, "temp:"); ok {
+		p["group_id"], p["user_id"], _ = strings.Cut(id, ":")
+		target.Type = TempTarget
+		target.GroupUin, target.UserUin, err = p.groupMember()
+	} else {
+		p["channel_id"] = channelId
+		target.Type = GroupTarget
+		target.GroupUin, err = p.requireUint32("channel_id")
+	}
+	return target, err
+}
+
+// satoriLogin 构造Bot账号的 Satori 登录信息
+func satoriLogin(selfId uint32, name string, online bool) map[string]any {
+	status := 0
+	if online {
+		status = 1
+	}
+	return map[string]any{
+		"user":     satoriUser(selfId, name),
+		"self_id":  satoriId(selfId),
+		"platform": satoriPlatform,
+		"status":   status,
+		"adapter":  "cryo",
+	}
+}
+
+// logins 获取所有在线Bot账号的登录信息
+func (a *SatoriAdapter) logins() []map[string]any {
+	result := make([]map[string]any, 0)
+	if a.bot != nil {
+		for _, c := range a.bot.getConnectedClients() {
+			result = append(result, satoriLogin(c.Uin, c.Nickname, c.IsOnline()))
+		}
+	}
+	return result
+}
+
+// convertEvent 把适配器的中间事件转换为 Satori 事件，没有对应的事件时返回nil
+func (a *SatoriAdapter) convertEvent(ae *adapterEvent) map[string]any {
+	sn := a.sn.Add(1)
+	p := map[string]any{
+		"id":        sn,
+		"sn":        sn,
+		"platform":  satoriPlatform,
+		"self_id":   satoriId(ae.SelfId),
+		"timestamp": ae.Time * 1000,
+		"user":      satoriUser(ae.UserId, ae.UserName),
+	}
+	if ae.GroupId != 0 && ae.Target.Type != TempTarget {
+		p["guild"] = satoriGuild(ae.GroupId, ae.GroupName)
+	}
+	if ae.OperatorId != 0 {
+		p["operator"] = satoriUser(ae.OperatorId, "")
+	}
+
+	switch ae.Type {
+	case "message":
+		p["type"] = "message-created"
+		p["channel"] = satoriChannel(ae.Target, ae.GroupName)
+		p["message"] = map[string]any{
+			"id":         satoriId(ae.MessageId),
+			"content":    a.formatContent(ae.SelfId, ae.Target, ae.Message),
+			"created_at": ae.Time * 1000,
+		}
+		if ae.Target.Type == GroupTarget {
+			p["member"] = map[string]any{"nick": ae.Card, "roles": []string{ae.Role}}
+		}
+	case "notice":
+		switch ae.Detail {
+		case "friend_recall", "group_recall":
+			p["type"] = "message-deleted"
+			p["channel"] = satoriChannel(ae.Target, ae.GroupName)
+			p["message"] = map[string]any{"id": satoriId(ae.MessageId)}
+		case "group_increase":
+			p["type"] = "guild-member-added"
+		case "group_decrease":
+			p["type"] = "guild-member-removed"
+		case "group_admin", "group_ban":
+			if ae.UserId == 0 {
+				return nil // 全体禁言没有对应的事件
+			}
+			p["type"] = "guild-member-updated"
+		case "notify":
+			if ae.SubType != "title" {
+				return nil
+			}
+			p["type"] = "guild-member-updated"
+			p["member"] = map[string]any{"title": ae.Title}
+		default:
+			return nil
+		}
+	case "request":
+		p["type"] = "friend-request"
+		if ae.Detail == "group" {
+			p["type"] = "guild-member-request"
+			if ae.SubType == "invite" {
+				p["type"] = "guild-request"
+			}
+		}
+		p["message"] = map[string]any{"id": ae.Flag, "content": ae.Comment}
+	case "meta_event":
+		delete(p, "user")
+		p["type"] = "login-added"
+		if ae.SubType == "disable" {
+			p["type"] = "login-removed"
+		}
+		p["login"] = satoriLogin(ae.SelfId, ae.UserName, ae.SubType == "enable")
+	default:
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,286 @@
+package cryo
+
+import (
+	"fmt"
+	"time"
+)
+
+// satoriMethod 是 Satori API 的处理函数
+type satoriMethod func(a *SatoriAdapter, c *LagrangeClient, p adapterParams) (any, error)
+
+// satoriMethods 支持的 Satori API，键为 {资源}.{方法}
+var satoriMethods = map[string]satoriMethod{
+	"message.create":       (*SatoriAdapter).createMessage,
+	"message.get":          (*SatoriAdapter).getMessage,
+	"message.delete":       (*SatoriAdapter).deleteMessage,
+	"channel.get":          (*SatoriAdapter).getChannel,
+	"channel.list":         (*SatoriAdapter).listChannels,
+	"user.channel.create":  (*SatoriAdapter).createUserChannel,
+	"guild.get":            (*SatoriAdapter).getGuild,
+	"guild.list":           (*SatoriAdapter).listGuilds,
+	"guild.approve":        (*SatoriAdapter).approve,
+	"guild.member.get":     (*SatoriAdapter).getGuildMember,
+	"guild.member.list":    (*SatoriAdapter).listGuildMembers,
+	"guild.member.kick":    (*SatoriAdapter).kickGuildMember,
+	"guild.member.mute":    (*SatoriAdapter).muteGuildMember,
+	"guild.member.approve": (*SatoriAdapter).approve,
+	"friend.list":          (*SatoriAdapter).listFriends,
+	"friend.approve":       (*SatoriAdapter).approve,
+	"user.get":             (*SatoriAdapter).getUser,
+	"login.get":            (*SatoriAdapter).getLogin,
+}
+
+// satoriList 构造分页列表，所有数据都在一页中返回
+func satoriList(data []map[string]any) map[string]any {
+	return map[string]any{"data": data}
+}
+
+// channelTarget 读取参数中的频道ID
+func (p adapterParams) channelTarget() (SendTarget, error) {
+	channelId := p.getString("channel_id")
+	if channelId == "" {
+		return SendTarget{}, fmt.Errorf("%w：缺少 channel_id", errAdapterParams)
+	}
+	return satoriTarget(channelId)
+}
+
+func (a *SatoriAdapter) createMessage(c *LagrangeClient, p adapterParams) (any, error) {
+	target, err := p.channelTarget()
+	if err != nil {
+		return nil, err
+	}
+	m, err := a.parseContent(p.getString("content"))
+	if err != nil {
+		return nil, err
+	}
+	id, err := a.sendMessage(c, target, m)
+	if err != nil {
+		return nil, err
+	}
+	return []map[string]any{{
+		"id":         satoriId(id),
+		"content":    a.formatContent(c.Uin, target, m),
+		"channel":    satoriChannel(target, ""),
+		"user":       satoriUser(c.Uin, c.Nickname),
+		"created_at": time.Now().UnixMilli(),
+	}}, nil
+}
+
+// message 根据参数中的 message_id 获取保存的消息
+func (a *SatoriAdapter) message(p adapterParams) (*adapterMessageRecord, error) {
+	id, ok, err := p.getInt64("message_id")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w：缺少 message_id", errAdapterParams)
+	}
+	rec := a.store.get(int32(id))
+	if rec == nil {
+		return nil, fmt.Errorf("%w：消息 %d 不存在或者已经过期", errAdapterParams, id)
+	}
+	return rec, nil
+}
+
+func (a *SatoriAdapter) getMessage(c *LagrangeClient, p adapterParams) (any, error) {
+	rec, err := a.message(p)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"id":         p.getString("message_id"),
+		"content":    a.formatContent(rec.SelfId, rec.Target, rec.Message),
+		"channel":    satoriChannel(rec.Target, ""),
+		"user":       satoriUser(rec.SenderUin, rec.SenderNickname),
+		"created_at": int64(rec.Time) * 1000,
+	}, nil
+}
+
+func (a *SatoriAdapter) deleteMessage(c *LagrangeClient, p adapterParams) (any, error) {
+	rec, err := a.message(p)
+	if err != nil {
+		return nil, err
+	}
+	return nil, a.recallMessage(c, rec)
+}
+
+// group 获取参数中的群信息，key 为群号参数的名称
+func (a *SatoriAdapter) group(c *LagrangeClient, p adapterParams, key string) (*GroupInfo, error) {
+	groupUin, err := p.requireUint32(key)
+	if err != nil {
+		return nil, err
+	}
+	g := c.GetGroup(groupUin)
+	if g == nil {
+		return nil, newActionError("get_group", groupUin, 0, ErrGroupNotFound, nil)
+	}
+	return g, nil
+}
+
+func (a *SatoriAdapter) getChannel(c *LagrangeClient, p adapterParams) (any, error) {
+	target, err := p.channelTarget()
+	if err != nil {
+		return nil, err
+	}
+	if target.Type != GroupTarget {
+		return satoriChannel(target, ""), nil
+	}
+	p["channel_id"] = satoriId(target.GroupUin)
+	g, err := a.group(c, p, "channel_id")
+	if err != nil {
+		return nil, err
+	}
+	return satoriChannel(target, g.GroupName), nil
+}
+
+func (a *SatoriAdapter) listChannels(c *LagrangeClient, p adapterParams) (any, error) {
+	g, err := a.group(c, p, "guild_id")
+	if err != nil {
+		return nil, err
+	}
+	return satoriList([]map[string]any{satoriChannel(SendTarget{Type: GroupTarget, GroupUin: g.GroupUin}, g.GroupName)}), nil
+}
+
+func (a *SatoriAdapter) createUserChannel(c *LagrangeClient, p adapterParams) (any, error) {
+	userUin, err := p.requireUint32("user_id")
+	if err != nil {
+		return nil, err
+	}
+	// 带有 guild_id 时使用临时会话
+	if groupUin, ok, err := p.getUint32("guild_id"); err != nil {
+		return nil, err
+	} else if ok && groupUin != 0 {
+		return satoriChannel(SendTarget{Type: TempTarget, GroupUin: groupUin, UserUin: userUin}, ""), nil
+	}
+	return satoriChannel(SendTarget{Type: PrivateTarget, UserUin: userUin}, ""), nil
+}
+
+func (a *SatoriAdapter) getGuild(c *LagrangeClient, p adapterParams) (any, error) {
+	g, err := a.group(c, p, "guild_id")
+	if err != nil {
+		return nil, err
+	}
+	return satoriGuild(g.GroupUin, g.GroupName), nil
+}
+
+func (a *SatoriAdapter) listGuilds(c *LagrangeClient, p adapterParams) (any, error) {
+	groups, err := c.GetGroupList()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, satoriGuild(g.GroupUin, g.GroupName))
+	}
+	return satoriList(result), nil
+}
+
+// satoriMember 构造 Satori 的群组成员对象
+func satoriMember(m *MemberInfo) map[string]any {
+	return map[string]any{
+		"user":      satoriUser(m.Uin, m.Nickname),
+		"nick":      m.MemberCard,
+		"joined_at": int64(m.JoinTime) * 1000,
+		"roles":     []string{adapterRole(m)},
+	}
+}
+
+func (a *SatoriAdapter) getGuildMember(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("guild_id")
+	if err != nil {
+		return nil, err
+	}
+	userUin, err := p.requireUint32("user_id")
+	if err != nil {
+		return nil, err
+	}
+	m := c.GetMember(groupUin, userUin)
+	if m == nil {
+		return nil, newActionError("get_group_member", groupUin, userUin, ErrMemberNotFound, nil)
+	}
+	return satoriMember(m), nil
+}
+
+func (a *SatoriAdapter) listGuildMembers(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("guild_id")
+	if err != nil {
+		return nil, err
+	}
+	members, err := c.GetMemberList(groupUin)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0, len(members))
+	for _, m := range members {
+		result = append(result, satoriMember(m))
+	}
+	return satoriList(result), nil
+}
+
+func (a *SatoriAdapter) kickGuildMember(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("guild_id")
+	if err != nil {
+		return nil, err
+	}
+	userUin, err := p.requireUint32("user_id")
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.KickGroupMember(groupUin, userUin, p.getBool("permanent", false))
+}
+
+func (a *SatoriAdapter) muteGuildMember(c *LagrangeClient, p adapterParams) (any, error) {
+	groupUin, err := p.requireUint32("guild_id")
+	if err != nil {
+		return nil, err
+	}
+	userUin, err := p.requireUint32("user_id")
+	if err != nil {
+		return nil, err
+	}
+	duration, _, err := p.getInt64("duration") // 单位为毫秒
+	if err != nil {
+		return nil, err
+	}
+	if duration <= 0 {
+		return nil, c.UnmuteGroupMember(groupUin, userUin)
+	}
+	return nil, c.MuteGroupMember(groupUin, userUin, durationOf(duration, time.Millisecond))
+}
+
+// approve 处理好友请求、加群请求和群邀请，message_id 是请求事件中的消息ID
+func (a *SatoriAdapter) approve(c *LagrangeClient, p adapterParams) (any, error) {
+	flag := p.getString("message_id")
+	if flag == "" {
+		return nil, fmt.Errorf("%w：缺少 message_id", errAdapterParams)
+	}
+	return nil, setAdapterRequest(c, flag, p.getBool("approve", true), p.getString("comment"))
+}
+
+func (a *SatoriAdapter) listFriends(c *LagrangeClient, p adapterParams) (any, error) {
+	friends, err := c.GetFriendList()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0, len(friends))
+	for _, f := range friends {
+		result = append(result, satoriUser(f.Uin, f.Nickname))
+	}
+	return satoriList(result), nil
+}
+
+func (a *SatoriAdapter) getUser(c *LagrangeClient, p adapterParams) (any, error) {
+	userUin, err := p.requireUint32("user_id")
+	if err != nil {
+		return nil, err
+	}
+	u, err := c.FetchUserInfo(userUin)
+	if err != nil {
+		return nil, err
+	}
+	return satoriUser(u.Uin, u.Nickname), nil
+}
+
+func (a *SatoriAdapter) getLogin(c *LagrangeClient, p adapterParams) (any, error) {
+	return satoriLogin(c.Uin, c.Nickname, c.IsOnline()), nil
+}
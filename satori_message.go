@@ -0,0 +1,238 @@
+package cryo
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// satoriEscaper 转义 Satori 消息中的文本和属性值
+var satoriEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// satoriTag 构造一个自闭合的 Satori 消息元素，attrs 是交替的键和值，值为空的属性会被忽略
+func satoriTag(name string, attrs ...string) string {
+	var sb strings.Builder
+	sb.WriteByte('<')
+	sb.WriteString(name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if attrs[i+1] == "" {
+			continue
+		}
+		sb.WriteByte(' ')
+		sb.WriteString(attrs[i])
+		sb.WriteString(`="`)
+		sb.WriteString(satoriEscaper.Replace(attrs[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteString("/>")
+	return sb.String()
+}
+
+// formatContent 把消息编码为 Satori 的消息内容，回复元素中的消息序号会被替换为消息ID
+//
+// 没有标准元素对应的QQ消息使用 qq: 前缀的元素，属性和CQ码的参数一致
+func (a *SatoriAdapter) formatContent(selfId uint32, target SendTarget, m Message) string {
+	var sb strings.Builder
+	for _, e := range m {
+		switch v := e.(type) {
+		case *Text:
+			sb.WriteString(satoriEscaper.Replace(v.Content))
+		case *At:
+			if v.TargetUin == 0 {
+				sb.WriteString(satoriTag("at", "type", "all"))
+			} else {
+				sb.WriteString(satoriTag("at", "id", satoriId(v.TargetUin), "name", strings.TrimPrefix(v.Display, "@")))
+			}
+		case *Face:
+			sb.WriteString(satoriTag("face", "id", strconv.FormatUint(uint64(v.FaceID), 10)))
+		case *Reply:
+			sb.WriteString(satoriTag("quote", "id", satoriId(a.replyId(selfId, target, v))))
+		case *Image:
+			sb.WriteString(satoriTag("img", "src", v.URL, "title", v.Summary))
+		case *Voice:
+			sb.WriteString(satoriTag("audio", "src", v.URL))
+		case *ShortVideo:
+			sb.WriteString(satoriTag("video", "src", v.URL))
+		case *File:
+			sb.WriteString(satoriTag("file", "src", v.FileURL, "title", v.FileName))
+		default:
+			if typ, params := elementToCQParams(e); typ != "" {
+				sb.WriteString(satoriTag("qq:"+typ, params...))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// parseContent 解析 Satori 的消息内容，引用元素的 id 会被还原为被回复的消息
+//
+// 只支持能转换为QQ消息的元素，其他元素只保留其中的文本，p 和 br 会被转换为换行
+func (a *SatoriAdapter) parseContent(content string) (Message, error) {
+	m := Message{}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			m.AddText(text.String())
+			text.Reset()
+		}
+	}
+	skip := 0 // 引用元素的嵌套深度，引用中的内容会被忽略
+	for len(content) > 0 {
+		start := strings.IndexByte(content, '<')
+		if start < 0 {
+			start = len(content)
+		}
+		if skip == 0 {
+			text.WriteString(html.UnescapeString(content[:start]))
+		}
+		content = content[start:]
+		if content == "" {
+			break
+		}
+		end := satoriTagEnd(content)
+		if end < 0 {
+			return nil, fmt.Errorf("%w：元素没有闭合 %q", errAdapterParams, content)
+		}
+		name, attrs, closing, selfClosing := parseSatoriTag(content[1:end])
+		content = content[end+1:]
+
+		if closing {
+			switch {
+			case name == "quote" && skip > 0:
+				skip--
+			case name == "p" && skip == 0:
+				text.WriteByte('\n')
+			}
+			continue
+		}
+		if skip > 0 {
+			if name == "quote" && !selfClosing {
+				skip++
+			}
+			continue
+		}
+		if name == "br" {
+			text.WriteByte('\n')
+			continue
+		}
+		e, err := satoriElement(name, attrs, CQParseOptions{AllowLocalFile: a.conf.AllowLocalFile})
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			flush()
+			m = append(m, e)
+		}
+		if name == "quote" && !selfClosing {
+			skip++
+		}
+	}
+	flush()
+	a.resolveReplies(m)
+	return m, nil
+}
+
+// satoriTagEnd 查找以 < 开头的元素标签的结束位置，会跳过引号中的 >
+func satoriTagEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseSatoriTag 解析不含尖括号的元素标签
+func parseSatoriTag(tag string) (name string, attrs map[string]string, closing, selfClosing bool) {
+	tag = strings.TrimSpace(tag)
+	if closing = strings.HasPrefix(tag, "/"); closing {
+		return strings.TrimSpace(tag[1:]), nil, true, false
+	}
+	if selfClosing = strings.HasSuffix(tag, "/"); selfClosing {
+		tag = tag[:len(tag)-1]
+	}
+	name, rest, _ := strings.Cut(tag, " ")
+	attrs = make(map[string]string)
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		i := strings.IndexAny(rest, "= ")
+		if i < 0 || rest[i] == ' ' { // 没有值的属性
+			if i < 0 {
+				i = len(rest)
+			}
+			attrs[rest[:i]] = "true"
+			rest = rest[i:]
+			continue
+		}
+		key := rest[:i]
+		rest = strings.TrimSpace(rest[i+1:])
+		var value string
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			j := strings.IndexByte(rest[1:], rest[0])
+			if j < 0 {
+				j = len(rest) - 1
+			}
+			value, rest = rest[1:j+1], rest[min(j+2, len(rest)):]
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+		attrs[key] = html.UnescapeString(value)
+	}
+	return name, attrs, false, selfClosing
+}
+
+// satoriElement 把 Satori 消息元素转换为消息元素，不支持的元素返回nil
+func satoriElement(name string, attrs map[string]string, opt CQParseOptions) (MessageElement, error) {
+	var typ string
+	params := map[string]string{}
+	switch name {
+	case "at":
+		typ = "at"
+		params["qq"], params["name"] = attrs["id"], attrs["name"]
+		if t := attrs["type"]; t == "all" || t == "here" {
+			params["qq"] = "all"
+		}
+	case "img", "image", "audio", "video", "file":
+		typ = map[string]string{"img": "image", "image": "image", "audio": "record", "video": "video", "file": "file"}[name]
+		params["file"] = satoriSrc(attrs["src"])
+		if name == "file" {
+			params["name"] = attrs["title"]
+		}
+	case "quote":
+		typ = "reply"
+		params["id"] = attrs["id"]
+	case "face":
+		typ = "face"
+		params["id"] = attrs["id"]
+	default:
+		// qq: 前缀的元素和CQ码的参数一致
+		var ok bool
+		if typ, ok = strings.CutPrefix(name, "qq:"); !ok {
+			return nil, nil
+		}
+		params = attrs
+	}
+	e, err := cqElement(typ, params, opt)
+	if err != nil {
+		return nil, fmt.Errorf("%w：%w", errAdapterParams, err)
+	}
+	return e, nil
+}
+
+// satoriSrc 把 data URL 转换为CQ码使用的 base64:// 格式，其他地址保持不变
+func satoriSrc(src string) string {
+	if rest, ok := strings.CutPrefix(src, "data:"); ok {
+		if _, data, ok := strings.Cut(rest, ";base64,"); ok {
+			return "base64://" + data
+		}
+	}
+	return src
+}
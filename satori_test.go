@@ -0,0 +1,119 @@
+package cryo_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/machinacanis/cryo"
+)
+
+func TestSatoriEventMapping(t *testing.T) {
+	h, second, srv := newAdapterServer(t, cryo.NewSatoriAdapter(cryo.SatoriConfig{}))
+	ws := dialWebSocket(t, srv, "/v1/events")
+	if err := ws.WriteJSON(map[string]any{"op": 3, "body": map[string]any{"token": ""}}); err != nil {
+		t.Fatal(err)
+	}
+	ready := readUntil(t, ws, func(v map[string]any) bool { return v["op"] == float64(4) })
+	if logins := ready["body"].(map[string]any)["logins"].([]any); len(logins) != 2 {
+		t.Fatalf("READY 中有 %d 个账号，期望 2 个：%v", len(logins), logins)
+	}
+
+	isMessage := func(v map[string]any) bool {
+		body, _ := v["body"].(map[string]any)
+		return v["op"] == float64(0) && body["type"] == "message-created"
+	}
+
+	h.Mock().AddGroup(100, "测试群")
+	h.Mock().ReceiveGroupMessage(100, 1001, "群消息")
+	e := readUntil(t, ws, isMessage)["body"].(map[string]any)
+	if e["self_id"] != "10000" || e["platform"] != "qq" {
+		t.Errorf("群消息事件的账号不正确：%v", e)
+	}
+	if channel := e["channel"].(map[string]any); channel["id"] != "100" || channel["type"] != float64(0) {
+		t.Errorf("群消息事件的频道不正确：%v", channel)
+	}
+	if guild := e["guild"].(map[string]any); guild["id"] != "100" || guild["name"] != "测试群" {
+		t.Errorf("群消息事件的群组不正确：%v", guild)
+	}
+	if user := e["user"].(map[string]any); user["id"] != "1001" {
+		t.Errorf("群消息事件的用户不正确：%v", user)
+	}
+	if msg := e["message"].(map[string]any); msg["content"] != "群消息" {
+		t.Errorf("群消息事件的内容不正确：%v", msg)
+	}
+
+	second.ReceivePrivateMessage(2002, "私聊消息")
+	e = readUntil(t, ws, isMessage)["body"].(map[string]any)
+	if e["self_id"] != "20000" {
+		t.Errorf("私聊消息事件的账号为 %v，期望 20000", e["self_id"])
+	}
+	if channel := e["channel"].(map[string]any); channel["id"] != "private:2002" || channel["type"] != float64(1) {
+		t.Errorf("私聊消息事件的频道不正确：%v", channel)
+	}
+	if _, ok := e["guild"]; ok {
+		t.Errorf("私聊消息事件不应该有群组：%v", e["guild"])
+	}
+}
+
+func TestSatoriHTTPMapping(t *testing.T) {
+	h, second, srv := newAdapterServer(t, cryo.NewSatoriAdapter(cryo.SatoriConfig{}))
+	h.Mock().AddGroup(100, "第一个账号的群")
+	second.AddGroup(200, "第二个账号的群")
+	second.AddFriend(2002, "好友")
+
+	header := http.Header{}
+	header.Set("Satori-Platform", "qq")
+	header.Set("Satori-User-ID", "20000")
+	var created []map[string]any
+	status := postJSON(t, srv.URL+"/v1/message.create", header, map[string]any{"channel_id": "200", "content": "hello &amp; <at id=\"2001\"/>"}, &created)
+	if status != http.StatusOK || len(created) != 1 {
+		t.Fatalf("发送群消息返回 %d %v", status, created)
+	}
+	if user := created[0]["user"].(map[string]any); user["id"] != "20000" {
+		t.Errorf("发送的消息的用户为 %v，期望 20000", user["id"])
+	}
+	if channel := created[0]["channel"].(map[string]any); channel["id"] != "200" {
+		t.Errorf("发送的消息的频道为 %v，期望 200", channel["id"])
+	}
+	if status := postJSON(t, srv.URL+"/v1/message.create", header, map[string]any{"channel_id": "private:2002", "content": "私聊"}, nil); status != http.StatusOK {
+		t.Fatalf("发送私聊消息返回 %d", status)
+	}
+	sent := second.SentMessages()
+	if len(sent) != 2 {
+		t.Fatalf("第二个账号发送了 %d 条消息，期望 2 条", len(sent))
+	}
+	if sent[0].Action != "send_group_message" || sent[0].GroupUin != 200 {
+		t.Errorf("群消息发送到了错误的目标：%+v", sent[0])
+	}
+	if len(sent[0].Message) != 2 {
+		t.Errorf("群消息的元素数量为 %d，期望 2：%s", len(sent[0].Message), sent[0].Message.ToString())
+	} else if at, ok := sent[0].Message[1].(*cryo.At); !ok || at.TargetUin != 2001 {
+		t.Errorf("群消息的第二个元素不是@2001：%#v", sent[0].Message[1])
+	}
+	if text := sent[0].Message[0].(*cryo.Text).Content; text != "hello & " {
+		t.Errorf("群消息的文本为 %q", text)
+	}
+	if sent[1].Action != "send_private_message" || sent[1].UserUin != 2002 {
+		t.Errorf("私聊消息发送到了错误的目标：%+v", sent[1])
+	}
+	if n := len(h.Mock().SentMessages()); n != 0 {
+		t.Errorf("第一个账号不应该发送消息，实际发送了 %d 条", n)
+	}
+
+	// 没有在线的对应账号时返回错误
+	header.Set("Satori-User-ID", "30000")
+	if status := postJSON(t, srv.URL+"/v1/message.create", header, map[string]any{"channel_id": "200", "content": "x"}, nil); status != http.StatusBadRequest {
+		t.Errorf("未知账号返回 %d，期望 400", status)
+	}
+	// 不支持的方法
+	header.Set("Satori-User-ID", "20000")
+	if status := postJSON(t, srv.URL+"/v1/unknown.method", header, map[string]any{}, nil); status != http.StatusNotFound {
+		t.Errorf("未知方法返回 %d，期望 404", status)
+	}
+
+	var login map[string]any
+	status = postJSON(t, srv.URL+"/v1/login.get", header, map[string]any{}, &login)
+	if status != http.StatusOK || login["self_id"] != "20000" || login["platform"] != "qq" {
+		t.Errorf("login.get 返回 %d %v", status, login)
+	}
+}
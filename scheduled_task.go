@@ -94,11 +94,11 @@ func (st *ScheduledTask) Set(b *Bot) {
 			SendScheduledTaskStoppedEvent(b, st)
 		}
 
+		// 使用只执行一次的间隔任务，这样触发时间会跟随调度器的时钟
 		job, _ := b.scheduler.NewJob(
-			gocron.OneTimeJob(
-				gocron.OneTimeJobStartDateTime(time.Now().Add(time.Duration(st.duration))), // 设置触发时间
-			),
+			gocron.DurationJob(time.Duration(st.duration)), // 设置触发时间
 			gocron.NewTask(task),
+			gocron.WithLimitedRuns(1),
 		)
 		st.Job = job // 传递任务对象
 	}
@@ -0,0 +1,429 @@
+package cryo
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// SendPriority 消息发送优先级别名
+type SendPriority int
+
+const (
+	LowPriority    SendPriority = iota // 低优先级，适合批量推送之类不着急的消息
+	NormalPriority                     // 普通优先级，默认的优先级
+	HighPriority                       // 高优先级，会被优先发送
+)
+
+// SendTargetType 消息发送目标类型别名
+type SendTargetType int
+
+const (
+	PrivateTarget SendTargetType = iota // 私聊
+	GroupTarget                         // 群聊
+	TempTarget                          // 临时会话
+)
+
+var (
+	ErrSendQueueFull    = errors.New("消息发送队列已满")
+	ErrSendQueueStopped = errors.New("消息发送队列已停止")
+)
+
+// SendTarget 消息发送的目标
+type SendTarget struct {
+	Type     SendTargetType // 目标类型
+	GroupUin uint32         // 群号，私聊时为空
+	UserUin  uint32         // 用户Uin，群聊时为空
+}
+
+// key 获取目标的唯一标识，用于按目标限速
+func (t SendTarget) key() uint64 {
+	switch t.Type {
+	case GroupTarget:
+		return uint64(GroupTarget)<<32 | uint64(t.GroupUin)
+	default:
+		// 临时会话和私聊都是对同一个用户发送，共用同一个限速器
+		return uint64(PrivateTarget)<<32 | uint64(t.UserUin)
+	}
+}
+
+// SendQueueConfig 消息发送队列的配置项，值为0的配置项会使用默认值
+type SendQueueConfig struct {
+	GlobalRate    float64 `json:"global_rate,omitempty,omitzero"`     // 全局每秒最多发送的消息数
+	GlobalBurst   int     `json:"global_burst,omitempty,omitzero"`    // 全局允许的突发消息数
+	TargetRate    float64 `json:"target_rate,omitempty,omitzero"`     // 对单个群或用户每秒最多发送的消息数
+	TargetBurst   int     `json:"target_burst,omitempty,omitzero"`    // 对单个群或用户允许的突发消息数
+	MaxRetry      int     `json:"max_retry,omitempty,omitzero"`       // 发送失败时的最大重试次数，小于0时不重试
+	RetryDelay    int     `json:"retry_delay,omitempty,omitzero"`     // 第一次重试前的等待时间，单位 毫秒，之后每次翻倍
+	MaxRetryDelay int     `json:"max_retry_delay,omitempty,omitzero"` // 重试等待时间的上限，单位 毫秒
+	MaxPending    int     `json:"max_pending,omitempty,omitzero"`     // 队列中最多等待发送的消息数
+}
+
+// withDefault 给没有设置的配置项填充默认值
+func (sc SendQueueConfig) withDefault() SendQueueConfig {
+	if sc.GlobalRate <= 0 {
+		sc.GlobalRate = 2
+	}
+	if sc.GlobalBurst <= 0 {
+		sc.GlobalBurst = 5
+	}
+	if sc.TargetRate <= 0 {
+		sc.TargetRate = 1
+	}
+	if sc.TargetBurst <= 0 {
+		sc.TargetBurst = 3
+	}
+	if sc.MaxRetry == 0 {
+		sc.MaxRetry = 3
+	}
+	if sc.RetryDelay <= 0 {
+		sc.RetryDelay = 1000
+	}
+	if sc.MaxRetryDelay <= 0 {
+		sc.MaxRetryDelay = 30000
+	}
+	if sc.MaxPending <= 0 {
+		sc.MaxPending = 1000
+	}
+	return sc
+}
+
+// SendResult 是一次排队发送的结果，可以用来等待消息真正发送出去
+type SendResult struct {
+	done chan struct{}
+	sent *SentMessage
+	err  error
+}
+
+func newSendResult() *SendResult {
+	return &SendResult{done: make(chan struct{})}
+}
+
+// resolve 设置发送结果，只应该被调用一次
+func (r *SendResult) resolve(sent *SentMessage, err error) {
+	r.sent = sent
+	r.err = err
+	close(r.done)
+}
+
+// Done 返回一个在消息发送完成（或最终失败）时关闭的通道
+func (r *SendResult) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait 阻塞直到消息发送完成，返回已发送的消息和错误
+func (r *SendResult) Wait() (*SentMessage, error) {
+	<-r.done
+	return r.sent, r.err
+}
+
+// WaitTimeout 在指定的时间内等待消息发送完成，超时后返回 ok 为 false，但消息仍然会继续在队列中等待发送
+func (r *SendResult) WaitTimeout(timeout time.Duration) (sent *SentMessage, err error, ok bool) {
+	select {
+	case <-r.done:
+		return r.sent, r.err, true
+	case <-time.After(timeout):
+		return nil, nil, false
+	}
+}
+
+// tokenBucket 简单的令牌桶限速器
+type tokenBucket struct {
+	rate   float64   // 每秒生成的令牌数
+	burst  float64   // 令牌桶容量
+	tokens float64   // 当前的令牌数
+	last   time.Time // 上次更新令牌数的时间
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// refill 根据经过的时间补充令牌
+func (b *tokenBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// wait 获取距离下一个令牌可用还需要等待的时间，不会消耗令牌
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// take 消耗一个令牌
+func (b *tokenBucket) take(now time.Time) {
+	b.refill(now)
+	b.tokens--
+}
+
+// full 令牌桶是否已经装满，装满的限速器可以被安全的回收
+func (b *tokenBucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+// sendJob 队列中等待发送的一条消息
+type sendJob struct {
+	seq       uint64       // 入队序号，同优先级的消息按入队顺序发送
+	priority  SendPriority // 优先级
+	target    SendTarget   // 发送目标
+	msg       *Message     // 消息内容
+	attempt   int          // 已经重试的次数
+	notBefore time.Time    // 重试时在这个时间之前不会发送
+	result    *SendResult  // 发送结果
+}
+
+// before 判断该任务是否应该排在另一个任务之前
+func (j *sendJob) before(o *sendJob) bool {
+	if j.priority != o.priority {
+		return j.priority > o.priority
+	}
+	return j.seq < o.seq
+}
+
+// SendQueue 是每个Bot客户端独立的消息发送队列
+//
+// 队列由一个单独的goroutine按优先级依次发送消息，同时限制全局和每个发送目标的发送速率，避免短时间内大量回复导致账号被风控
+type SendQueue struct {
+	conf   SendQueueConfig
+	client *LagrangeClient
+	clock  clockwork.Clock // 限速和重试使用的时钟
+
+	mutex   sync.Mutex
+	jobs    []*sendJob              // 按优先级排好序的待发送任务
+	global  *tokenBucket            // 全局限速器
+	targets map[uint64]*tokenBucket // 每个发送目标的限速器
+	seq     uint64                  // 入队序号计数器
+	stopped bool                    // 是否已经停止
+
+	wake chan struct{} // 有新任务时唤醒发送goroutine
+	stop chan struct{} // 停止发送goroutine
+}
+
+// NewSendQueue 创建一个新的消息发送队列并启动发送goroutine
+func NewSendQueue(client *LagrangeClient, conf SendQueueConfig) *SendQueue {
+	return NewSendQueueWithClock(client, conf, clockwork.NewRealClock())
+}
+
+// NewSendQueueWithClock 使用指定的时钟创建消息发送队列，一般在测试中配合假时钟使用
+func NewSendQueueWithClock(client *LagrangeClient, conf SendQueueConfig, clock clockwork.Clock) *SendQueue {
+	conf = conf.withDefault()
+	q := &SendQueue{
+		conf:    conf,
+		client:  client,
+		clock:   clock,
+		jobs:    make([]*sendJob, 0),
+		global:  newTokenBucket(conf.GlobalRate, conf.GlobalBurst, clock.Now()),
+		targets: make(map[uint64]*tokenBucket),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Push 将消息加入发送队列，返回的 SendResult 可以用来等待发送结果
+//
+// Push 不会检查消息内容，也不会下载只有链接的图片，一般应该通过 LagrangeClient 的 SendAsync 发送
+func (q *SendQueue) Push(target SendTarget, msg *Message, priority ...SendPriority) *SendResult {
+	p := NormalPriority
+	if len(priority) > 0 {
+		p = priority[0]
+	}
+	result := newSendResult()
+
+	q.mutex.Lock()
+	if q.stopped {
+		q.mutex.Unlock()
+		result.resolve(nil, ErrSendQueueStopped)
+		return result
+	}
+	if len(q.jobs) >= q.conf.MaxPending {
+		q.mutex.Unlock()
+		result.resolve(nil, ErrSendQueueFull)
+		return result
+	}
+	q.seq++
+	q.insert(&sendJob{
+		seq:      q.seq,
+		priority: p,
+		target:   target,
+		msg:      msg,
+		result:   result,
+	})
+	q.mutex.Unlock()
+
+	q.notify()
+	return result
+}
+
+// Len 获取队列中等待发送的消息数
+func (q *SendQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.jobs)
+}
+
+// Stop 停止发送队列，所有还没有发送的消息都会以 ErrSendQueueStopped 结束
+func (q *SendQueue) Stop() {
+	q.mutex.Lock()
+	if q.stopped {
+		q.mutex.Unlock()
+		return
+	}
+	q.stopped = true
+	jobs := q.jobs
+	q.jobs = nil
+	q.mutex.Unlock()
+
+	close(q.stop)
+	for _, job := range jobs {
+		job.result.resolve(nil, ErrSendQueueStopped)
+	}
+}
+
+// insert 按优先级将任务插入队列，调用前需要持有锁
+func (q *SendQueue) insert(job *sendJob) {
+	i := len(q.jobs)
+	for i > 0 && job.before(q.jobs[i-1]) {
+		i--
+	}
+	q.jobs = append(q.jobs, nil)
+	copy(q.jobs[i+1:], q.jobs[i:])
+	q.jobs[i] = job
+}
+
+// notify 唤醒发送goroutine
+func (q *SendQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next 取出下一个可以立即发送的任务，如果没有则返回需要等待的时间，等待时间为0表示队列为空
+func (q *SendQueue) next(now time.Time) (*sendJob, time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.jobs) == 0 {
+		return nil, 0
+	}
+	if wait := q.global.wait(now); wait > 0 {
+		return nil, wait
+	}
+
+	var minWait time.Duration = -1
+	for i, job := range q.jobs {
+		wait := job.notBefore.Sub(now)
+		bucket := q.bucket(job.target, now)
+		if w := bucket.wait(now); w > wait {
+			wait = w
+		}
+		if wait <= 0 {
+			// 找到了可以发送的任务
+			q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+			q.global.take(now)
+			bucket.take(now)
+			return job, 0
+		}
+		if minWait < 0 || wait < minWait {
+			minWait = wait
+		}
+	}
+	return nil, minWait
+}
+
+// bucket 获取发送目标对应的限速器，调用前需要持有锁
+func (q *SendQueue) bucket(target SendTarget, now time.Time) *tokenBucket {
+	key := target.key()
+	b, ok := q.targets[key]
+	if !ok {
+		// 顺便回收一下已经闲置的限速器，避免无限增长
+		if len(q.targets) >= 1024 {
+			for k, v := range q.targets {
+				if v.full(now) {
+					delete(q.targets, k)
+				}
+			}
+		}
+		b = newTokenBucket(q.conf.TargetRate, q.conf.TargetBurst, now)
+		q.targets[key] = b
+	}
+	return b
+}
+
+// run 发送goroutine的主循环
+func (q *SendQueue) run() {
+	timer := q.clock.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		job, wait := q.next(q.clock.Now())
+		if job != nil {
+			q.execute(job)
+			continue
+		}
+		if wait > 0 {
+			timer.Reset(wait)
+		}
+		select {
+		case <-q.stop:
+			timer.Stop()
+			return
+		case <-q.wake:
+		case <-timer.Chan():
+		}
+		timer.Stop()
+	}
+}
+
+// execute 发送一条消息，遇到临时性错误时按退避时间重新入队
+func (q *SendQueue) execute(job *sendJob) {
+	sent, err := q.client.sendDirect(job.target, job.msg)
+	if err == nil {
+		job.result.resolve(sent, nil)
+		return
+	}
+	// 已经发送出一部分的消息不能重试，否则会重复发送
+	if q.conf.MaxRetry > 0 && job.attempt < q.conf.MaxRetry && IsTransientError(err) && !errors.Is(err, ErrPartiallySent) {
+		job.attempt++
+		delay := q.retryDelay(job.attempt)
+		q.client.logger.Warnf("[Cryo] 消息发送失败，将在 %v 后进行第 %d 次重试：%v", delay, job.attempt, err)
+
+		q.mutex.Lock()
+		if !q.stopped {
+			job.notBefore = q.clock.Now().Add(delay)
+			q.insert(job)
+			q.mutex.Unlock()
+			return
+		}
+		q.mutex.Unlock()
+		job.result.resolve(nil, ErrSendQueueStopped)
+		return
+	}
+	job.result.resolve(sent, err)
+}
+
+// retryDelay 计算第n次重试前的退避时间
+func (q *SendQueue) retryDelay(attempt int) time.Duration {
+	delay := time.Duration(q.conf.RetryDelay) * time.Millisecond
+	maxDelay := time.Duration(q.conf.MaxRetryDelay) * time.Millisecond
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,145 @@
+package cryo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/machinacanis/cryo"
+	"github.com/machinacanis/cryo/cryotest"
+)
+
+// newTestSendQueue 创建一个使用假时钟的发送队列，消息会发送到模拟协议中的群 100
+func newTestSendQueue(t *testing.T, conf cryo.SendQueueConfig) (*cryotest.Harness, *cryo.SendQueue, *clockwork.FakeClock) {
+	h := cryotest.New(t)
+	h.Group(100)
+	clock := clockwork.NewFakeClock()
+	q := cryo.NewSendQueueWithClock(h.Client(), conf, clock)
+	t.Cleanup(q.Stop)
+	return h, q, clock
+}
+
+// pushText 将一条文本消息加入发送队列
+func pushText(q *cryo.SendQueue, text string, priority ...cryo.SendPriority) *cryo.SendResult {
+	msg := cryo.Message{}
+	msg.AddText(text)
+	return q.Push(cryo.SendTarget{Type: cryo.GroupTarget, GroupUin: 100}, &msg, priority...)
+}
+
+// advance 等待发送goroutine开始计时后再拨动假时钟，避免时钟在计时器创建之前就被拨动
+func advance(t *testing.T, clock *clockwork.FakeClock, d time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := clock.BlockUntilContext(ctx, 1); err != nil {
+		t.Fatalf("等待发送队列计时超时：%v", err)
+	}
+	clock.Advance(d)
+}
+
+func isDone(r *cryo.SendResult) bool {
+	select {
+	case <-r.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestSendQueueRefill(t *testing.T) {
+	_, q, clock := newTestSendQueue(t, cryo.SendQueueConfig{GlobalRate: 1, GlobalBurst: 1, TargetRate: 100, TargetBurst: 100})
+
+	if _, err := pushText(q, "1").Wait(); err != nil {
+		t.Fatalf("第一条消息发送失败：%v", err)
+	}
+	second := pushText(q, "2")
+	advance(t, clock, 999*time.Millisecond)
+	if isDone(second) {
+		t.Fatal("令牌还没有补充时消息就被发送了")
+	}
+	clock.Advance(time.Millisecond)
+	if _, err, ok := second.WaitTimeout(2 * time.Second); !ok || err != nil {
+		t.Fatalf("令牌补充后消息没有被发送：ok=%v err=%v", ok, err)
+	}
+}
+
+func TestSendQueuePriority(t *testing.T) {
+	h, q, clock := newTestSendQueue(t, cryo.SendQueueConfig{GlobalRate: 1, GlobalBurst: 1, TargetRate: 100, TargetBurst: 100})
+
+	if _, err := pushText(q, "first").Wait(); err != nil {
+		t.Fatalf("第一条消息发送失败：%v", err)
+	}
+	pushText(q, "low", cryo.LowPriority)
+	pushText(q, "normal")
+	pushText(q, "high", cryo.HighPriority)
+	for i := 2; i <= 4; i++ {
+		advance(t, clock, time.Second)
+		waitFor(t, "消息发送", func() bool { return len(h.Mock().SentMessages()) == i })
+	}
+
+	want := []string{"first", "high", "normal", "low"}
+	for i, m := range h.Mock().SentMessages() {
+		if got := m.Message.ToString(); got != want[i] {
+			t.Errorf("第 %d 条发送的消息为 %q，期望 %q", i, got, want[i])
+		}
+	}
+}
+
+func TestSendQueueRetry(t *testing.T) {
+	h, q, clock := newTestSendQueue(t, cryo.SendQueueConfig{
+		GlobalRate: 100, GlobalBurst: 100, TargetRate: 100, TargetBurst: 100,
+		MaxRetry: 2, RetryDelay: 1000, MaxRetryDelay: 30000,
+	})
+	h.Mock().Fail("send_group_message", cryo.ErrNetwork, 2)
+
+	result := pushText(q, "retry")
+	waitFor(t, "第一次发送", func() bool { return len(h.Mock().ActionsOf("send_group_message")) == 1 })
+
+	// 第一次重试前等待1秒，第二次重试前等待2秒
+	advance(t, clock, 999*time.Millisecond)
+	if n := len(h.Mock().ActionsOf("send_group_message")); n != 1 {
+		t.Fatalf("退避时间还没有结束就进行了重试，共发送了 %d 次", n)
+	}
+	clock.Advance(time.Millisecond)
+	waitFor(t, "第一次重试", func() bool { return len(h.Mock().ActionsOf("send_group_message")) == 2 })
+	advance(t, clock, 1999*time.Millisecond)
+	if n := len(h.Mock().ActionsOf("send_group_message")); n != 2 {
+		t.Fatalf("退避时间没有翻倍，共发送了 %d 次", n)
+	}
+	clock.Advance(time.Millisecond)
+
+	sent, err, ok := result.WaitTimeout(2 * time.Second)
+	if !ok || err != nil || sent == nil {
+		t.Fatalf("重试后消息没有发送成功：ok=%v err=%v", ok, err)
+	}
+	if n := len(h.Mock().ActionsOf("send_group_message")); n != 3 {
+		t.Errorf("共发送了 %d 次，期望 3 次", n)
+	}
+}
+
+func TestSendQueueStop(t *testing.T) {
+	h, q, _ := newTestSendQueue(t, cryo.SendQueueConfig{GlobalRate: 1, GlobalBurst: 1, TargetRate: 100, TargetBurst: 100})
+
+	if _, err := pushText(q, "first").Wait(); err != nil {
+		t.Fatalf("第一条消息发送失败：%v", err)
+	}
+	pending := []*cryo.SendResult{pushText(q, "a"), pushText(q, "b", cryo.HighPriority)}
+	q.Stop()
+
+	for i, r := range pending {
+		if _, err, ok := r.WaitTimeout(time.Second); !ok || !errors.Is(err, cryo.ErrSendQueueStopped) {
+			t.Errorf("第 %d 条等待发送的消息返回 ok=%v err=%v，期望 ErrSendQueueStopped", i, ok, err)
+		}
+	}
+	if n := q.Len(); n != 0 {
+		t.Errorf("停止后队列中还有 %d 条消息", n)
+	}
+	if _, err := pushText(q, "late").Wait(); !errors.Is(err, cryo.ErrSendQueueStopped) {
+		t.Errorf("停止后加入队列返回 %v，期望 ErrSendQueueStopped", err)
+	}
+	if n := len(h.Mock().SentMessages()); n != 1 {
+		t.Errorf("停止后仍然发送了消息，共发送 %d 条", n)
+	}
+}
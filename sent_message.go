@@ -0,0 +1,96 @@
+package cryo
+
+import (
+	"sync"
+	"time"
+)
+
+// SentMessage 是Bot已经发送出去的一条消息，可以用来撤回或者重新发送这条消息
+type SentMessage struct {
+	client *LagrangeClient
+
+	Target     SendTarget // 消息的发送目标
+	MessageId  uint32     // 消息ID，即消息的序号
+	InternalId uint32     // 消息的随机数，撤回私聊消息时需要使用
+	ClientSeq  uint32     // 客户端序号，撤回私聊消息时需要使用
+	Time       uint32     // 消息的发送时间
+	Elements   Message    // 消息内容
+
+	mutex       sync.Mutex
+	recallTimer *time.Timer // 自动撤回的计时器
+}
+
+// newSentMessage 创建一个新的已发送消息
+func newSentMessage(c *LagrangeClient, target SendTarget, elements Message, messageId, internalId, clientSeq, time uint32) *SentMessage {
+	return &SentMessage{
+		client:     c,
+		Target:     target,
+		MessageId:  messageId,
+		InternalId: internalId,
+		ClientSeq:  clientSeq,
+		Time:       time,
+		Elements:   elements,
+	}
+}
+
+// GetClient 获取发送这条消息的Bot客户端
+func (m *SentMessage) GetClient() *LagrangeClient {
+	return m.client
+}
+
+// Recall 撤回这条消息，临时会话中的消息无法撤回
+func (m *SentMessage) Recall() error {
+	m.CancelRecall()
+	switch m.Target.Type {
+	case GroupTarget:
+		return m.client.RecallGroupMessage(m.Target.GroupUin, m.MessageId)
+	case PrivateTarget:
+		return m.client.RecallPrivateMessage(m.Target.UserUin, m.MessageId, m.InternalId, m.ClientSeq, m.Time)
+	default:
+		return newActionError("recall_message", m.Target.GroupUin, m.Target.UserUin, ErrUnsupportedTarget, nil)
+	}
+}
+
+// RecallAfter 在指定的时间后自动撤回这条消息，重复调用会重新计时
+func (m *SentMessage) RecallAfter(d time.Duration) *SentMessage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.recallTimer != nil {
+		m.recallTimer.Stop()
+	}
+	m.recallTimer = time.AfterFunc(d, func() {
+		m.mutex.Lock()
+		m.recallTimer = nil
+		m.mutex.Unlock()
+		if err := m.Recall(); err != nil {
+			m.client.logger.Errorf("自动撤回消息 %d 时出现错误：%v", m.MessageId, err)
+		}
+	})
+	return m
+}
+
+// CancelRecall 取消自动撤回，返回值表示是否有被取消的自动撤回
+func (m *SentMessage) CancelRecall() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.recallTimer == nil {
+		return false
+	}
+	stopped := m.recallTimer.Stop()
+	m.recallTimer = nil
+	return stopped
+}
+
+// Resend 向同一个目标重新发送一遍相同的消息内容
+func (m *SentMessage) Resend() (*SentMessage, error) {
+	elements := m.Elements
+	return m.client.sendMessage(m.Target, &elements)
+}
+
+// React 给这条消息添加表态，只支持群消息
+func (m *SentMessage) React(code string) error {
+	if m.Target.Type != GroupTarget {
+		return newActionError("set_group_reaction", m.Target.GroupUin, m.Target.UserUin, ErrUnsupportedTarget, nil)
+	}
+	return m.client.SetGroupReaction(m.Target.GroupUin, m.MessageId, code, true)
+}
@@ -0,0 +1,433 @@
+package cryo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// ErrTemplateNotFound 模板包中没有找到指定的模板
+var ErrTemplateNotFound = errors.New("模板不存在")
+
+// PluralKey 模板使用复数形式时，用来选择形式的变量名
+var PluralKey = "count"
+
+// templatePart 模板中的一段，literal 为true时是纯文本，否则是占位符
+type templatePart struct {
+	literal bool
+	text    string // 纯文本内容或者占位符的变量名
+	kind    string // 占位符的类型，为空时表示普通变量，at 或 face 表示对应的消息元素
+}
+
+// Template 解析后的消息模板
+//
+// 模板中使用 {name} 引用变量，{at:sender} 或 {at:123} 插入提及，{at:all} 提及全体成员，{face:14} 插入表情，
+// 需要输出花括号时使用 {{ 和 }}
+type Template struct {
+	source string
+	parts  []templatePart
+}
+
+// ParseTemplate 解析消息模板
+func ParseTemplate(s string) (*Template, error) {
+	t := &Template{source: s}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			t.parts = append(t.parts, templatePart{literal: true, text: text.String()})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			if i+1 < len(s) && s[i+1] == '{' {
+				text.WriteByte('{')
+				i++
+				continue
+			}
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("模板 %q 的第 %d 个字节处的占位符没有闭合", s, i)
+			}
+			placeholder := strings.TrimSpace(s[i+1 : i+end])
+			if placeholder == "" {
+				return nil, fmt.Errorf("模板 %q 的第 %d 个字节处的占位符为空", s, i)
+			}
+			part := templatePart{text: placeholder}
+			if kind, name, ok := strings.Cut(placeholder, ":"); ok {
+				if kind != "at" && kind != "face" {
+					return nil, fmt.Errorf("模板 %q 中有不支持的占位符类型 %q", s, kind)
+				}
+				part.kind, part.text = kind, strings.TrimSpace(name)
+			}
+			flush()
+			t.parts = append(t.parts, part)
+			i += end
+		case '}':
+			if i+1 < len(s) && s[i+1] == '}' {
+				i++
+			}
+			text.WriteByte('}')
+		default:
+			text.WriteByte(s[i])
+		}
+	}
+	flush()
+	return t, nil
+}
+
+// String 获取模板的原始内容
+func (t *Template) String() string {
+	return t.source
+}
+
+// Render 使用事件和变量渲染模板，vars 中的变量会覆盖从事件中获取的同名变量
+//
+// 事件的所有JSON字段都可以作为变量使用，消息事件额外提供 sender 和 sender_name，e 可以为nil
+func (t *Template) Render(e Event, vars map[string]any) (*Message, error) {
+	data := templateData(e, vars)
+	m := &Message{}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			m.AddText(text.String())
+			text.Reset()
+		}
+	}
+	for _, p := range t.parts {
+		switch {
+		case p.literal:
+			text.WriteString(p.text)
+		case p.kind == "at":
+			if p.text == "all" {
+				flush()
+				m.AddAt(0)
+				continue
+			}
+			uin, err := templateUin(p.text, data)
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			if p.text == "sender" {
+				if name, ok := data["sender_name"].(string); ok && name != "" {
+					m.AddAt(uin, "@"+name)
+					continue
+				}
+			}
+			m.AddAt(uin)
+		case p.kind == "face":
+			id, err := templateUin(p.text, data)
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			m.AddFace(id)
+		default:
+			v, ok := data[p.text]
+			if !ok {
+				return nil, fmt.Errorf("渲染模板 %q 时缺少变量 %q", t.source, p.text)
+			}
+			text.WriteString(formatTemplateValue(v))
+		}
+	}
+	flush()
+	return m, nil
+}
+
+// templateData 合并事件中的字段和传入的变量
+func templateData(e Event, vars map[string]any) map[string]any {
+	data := make(map[string]any)
+	if e != nil {
+		if raw, err := json.Marshal(e); err == nil {
+			_ = json.Unmarshal(raw, &data)
+		}
+		if me, ok := e.(MessageEvent); ok {
+			u := me.GetUniMessageEvent()
+			data["sender"] = u.SenderUin
+			name := u.SenderCardname
+			if name == "" {
+				name = u.SenderNickname
+			}
+			data["sender_name"] = name
+		}
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+	return data
+}
+
+// templateUin 解析at和face占位符的参数，可以是数字或者变量名
+func templateUin(arg string, data map[string]any) (uint32, error) {
+	if n, err := strconv.ParseUint(arg, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+	v, ok := data[arg]
+	if !ok {
+		return 0, fmt.Errorf("渲染模板时缺少变量 %q", arg)
+	}
+	n, ok := templateNumber(v)
+	if !ok || n < 0 || n > math.MaxUint32 {
+		return 0, fmt.Errorf("模板变量 %q 的值 %v 不是有效的数字", arg, v)
+	}
+	return uint32(n), nil
+}
+
+// templateNumber 将变量转换为数字
+func templateNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// formatTemplateValue 将变量格式化为文本，整数形式的浮点数不输出小数部分
+func formatTemplateValue(v any) string {
+	switch n := v.(type) {
+	case string:
+		return n
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(n), 'f', -1, 32)
+	}
+	return fmt.Sprint(v)
+}
+
+// pluralTemplate 带有复数形式的模板，按 PluralKey 变量的值选择
+//
+// 优先匹配 "=数值" 形式的键，然后是模板语言的CLDR复数规则给出的 zero、one、two、few 或 many，
+// 值为0时还会尝试 zero，最后使用 other
+type pluralTemplate map[string]*Template
+
+// pluralFormNames CLDR复数形式对应的键
+var pluralFormNames = map[plural.Form]string{
+	plural.Zero: "zero",
+	plural.One:  "one",
+	plural.Two:  "two",
+	plural.Few:  "few",
+	plural.Many: "many",
+}
+
+func (p pluralTemplate) choose(lang language.Tag, data map[string]any) (*Template, error) {
+	v, ok := data[PluralKey]
+	if !ok {
+		return nil, fmt.Errorf("使用复数形式的模板缺少变量 %q", PluralKey)
+	}
+	n, ok := templateNumber(v)
+	if !ok {
+		return nil, fmt.Errorf("模板变量 %q 的值 %v 不是有效的数字", PluralKey, v)
+	}
+	candidates := []string{"=" + strconv.FormatFloat(n, 'f', -1, 64)}
+	if form, ok := pluralFormNames[pluralForm(lang, n)]; ok {
+		candidates = append(candidates, form)
+	}
+	if n == 0 {
+		candidates = append(candidates, "zero")
+	}
+	candidates = append(candidates, "other")
+	for _, c := range candidates {
+		if t, ok := p[c]; ok {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("复数形式的模板没有匹配 %v 的形式", n)
+}
+
+// pluralForm 使用CLDR的基数复数规则计算数字在指定语言中的复数形式
+func pluralForm(lang language.Tag, n float64) plural.Form {
+	intPart, frac, _ := strings.Cut(strconv.FormatFloat(math.Abs(n), 'f', -1, 64), ".")
+	digits := make([]byte, 0, len(intPart)+len(frac))
+	for _, c := range intPart + frac {
+		digits = append(digits, byte(c-'0'))
+	}
+	return plural.Cardinal.MatchDigits(lang, digits, len(intPart), len(frac))
+}
+
+// TemplateBundle 多语言的模板包，每个群可以单独选择语言
+type TemplateBundle struct {
+	mutex          sync.RWMutex
+	defaultLang    string
+	templates      map[string]map[string]pluralTemplate // 语言 -> 模板名 -> 模板
+	groupLanguages map[uint32]string
+}
+
+// NewTemplateBundle 创建一个新的模板包，defaultLang 是没有单独设置语言的群和私聊使用的语言
+func NewTemplateBundle(defaultLang string) *TemplateBundle {
+	return &TemplateBundle{
+		defaultLang:    defaultLang,
+		templates:      make(map[string]map[string]pluralTemplate),
+		groupLanguages: make(map[uint32]string),
+	}
+}
+
+// Add 添加一个模板
+func (b *TemplateBundle) Add(lang, key, tmpl string) error {
+	t, err := ParseTemplate(tmpl)
+	if err != nil {
+		return err
+	}
+	b.set(lang, key, pluralTemplate{"other": t})
+	return nil
+}
+
+// AddPlural 添加一个带有复数形式的模板，forms 的键可以是 zero、one、two、few、many、other 或者 "=数值"，
+// 除了 "=数值" 和 other 以外的形式按照 lang 对应语言的CLDR复数规则选择
+func (b *TemplateBundle) AddPlural(lang, key string, forms map[string]string) error {
+	p := make(pluralTemplate, len(forms))
+	for form, tmpl := range forms {
+		t, err := ParseTemplate(tmpl)
+		if err != nil {
+			return err
+		}
+		p[form] = t
+	}
+	b.set(lang, key, p)
+	return nil
+}
+
+func (b *TemplateBundle) set(lang, key string, p pluralTemplate) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.templates[lang] == nil {
+		b.templates[lang] = make(map[string]pluralTemplate)
+	}
+	b.templates[lang][key] = p
+}
+
+// LoadFile 从JSON文件加载一种语言的模板
+//
+// 文件内容是模板名到模板的对象，模板可以是字符串，也可以是包含复数形式的对象，例如
+//
+//	{"points": {"one": "{at:sender} 你有 1 点积分", "other": "{at:sender} 你有 {count} 点积分"}}
+func (b *TemplateBundle) LoadFile(lang, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]jsontext.Value
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("解析模板文件 %s 失败：%w", path, err)
+	}
+	for key, v := range raw {
+		var s string
+		if err = json.Unmarshal(v, &s); err == nil {
+			err = b.Add(lang, key, s)
+		} else {
+			var forms map[string]string
+			if err = json.Unmarshal(v, &forms); err != nil {
+				return fmt.Errorf("模板文件 %s 中的模板 %q 既不是字符串也不是复数形式的对象", path, key)
+			}
+			err = b.AddPlural(lang, key, forms)
+		}
+		if err != nil {
+			return fmt.Errorf("加载模板文件 %s 中的模板 %q 失败：%w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// LoadDir 加载目录中的所有 .json 模板文件，文件名就是语言名，例如 zh-CN.json
+func (b *TemplateBundle) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		lang := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		if err = b.LoadFile(lang, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetGroupLanguage 设置群使用的语言，lang 为空时恢复为默认语言
+func (b *TemplateBundle) SetGroupLanguage(groupUin uint32, lang string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if lang == "" {
+		delete(b.groupLanguages, groupUin)
+		return
+	}
+	b.groupLanguages[groupUin] = lang
+}
+
+// Language 获取群使用的语言，groupUin 为0时返回默认语言
+func (b *TemplateBundle) Language(groupUin uint32) string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if lang, ok := b.groupLanguages[groupUin]; ok {
+		return lang
+	}
+	return b.defaultLang
+}
+
+// Render 渲染模板，根据事件所在的群选择语言，找不到对应语言的模板时使用默认语言
+func (b *TemplateBundle) Render(e Event, key string, vars map[string]any) (*Message, error) {
+	lang := b.defaultLang
+	if me, ok := e.(MessageEvent); ok && me.GetEventType() == GroupMessageEventType {
+		lang = b.Language(me.GetUniMessageEvent().GroupUin)
+	}
+	return b.RenderLang(lang, e, key, vars)
+}
+
+// RenderLang 使用指定的语言渲染模板，找不到对应语言的模板时使用默认语言
+func (b *TemplateBundle) RenderLang(lang string, e Event, key string, vars map[string]any) (*Message, error) {
+	b.mutex.RLock()
+	p, ok := b.templates[lang][key]
+	if !ok {
+		lang = b.defaultLang
+		p, ok = b.templates[lang][key]
+	}
+	b.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w：%s", ErrTemplateNotFound, key)
+	}
+	t := p["other"]
+	if len(p) > 1 || t == nil {
+		var err error
+		if t, err = p.choose(language.Make(lang), templateData(e, vars)); err != nil {
+			return nil, fmt.Errorf("渲染模板 %s 失败：%w", key, err)
+		}
+	}
+	return t.Render(e, vars)
+}
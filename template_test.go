@@ -0,0 +1,58 @@
+package cryo_test
+
+import (
+	"testing"
+
+	"github.com/machinacanis/cryo"
+)
+
+func TestTemplatePlural(t *testing.T) {
+	b := cryo.NewTemplateBundle("en")
+	forms := map[string]map[string]string{
+		"en": {"one": "{count} apple", "other": "{count} apples", "=0": "no apples"},
+		"ru": {"one": "{count} яблоко", "few": "{count} яблока", "many": "{count} яблок", "other": "{count} яблока*"},
+		"zh": {"zero": "没有苹果", "other": "{count} 个苹果"},
+	}
+	for lang, f := range forms {
+		if err := b.AddPlural(lang, "apples", f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		lang  string
+		count any
+		want  string
+	}{
+		{"en", 0, "no apples"}, // "=数值" 优先于复数规则
+		{"en", 1, "1 apple"},
+		{"en", 2, "2 apples"},
+		{"en", 1.5, "1.5 apples"},
+		{"en", "1", "1 apple"},
+		{"ru", 1, "1 яблоко"},
+		{"ru", 3, "3 яблока"},
+		{"ru", 5, "5 яблок"},
+		{"ru", 21, "21 яблоко"},
+		{"ru", 1.5, "1.5 яблока*"},
+		{"zh", 0, "没有苹果"}, // 中文没有 zero 形式，值为0时仍然会尝试 zero
+		{"zh", 1, "1 个苹果"},
+		{"fr", 1, "1 apple"}, // 没有这个语言的模板时使用默认语言
+	}
+	for _, tt := range tests {
+		m, err := b.RenderLang(tt.lang, nil, "apples", map[string]any{"count": tt.count})
+		if err != nil {
+			t.Errorf("%s 语言渲染 %v 失败：%v", tt.lang, tt.count, err)
+			continue
+		}
+		if got := m.ToString(); got != tt.want {
+			t.Errorf("%s 语言渲染 %v 得到 %q，期望 %q", tt.lang, tt.count, got, tt.want)
+		}
+	}
+
+	if _, err := b.RenderLang("en", nil, "apples", nil); err == nil {
+		t.Error("缺少 count 变量时没有返回错误")
+	}
+	if _, err := b.RenderLang("en", nil, "apples", map[string]any{"count": "many"}); err == nil {
+		t.Error("count 不是数字时没有返回错误")
+	}
+}
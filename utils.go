@@ -103,7 +103,9 @@ func IMessageElementsToString(elements []lgrMsg.IMessageElement) string {
 	return result
 }
 
-// ToJson 将数据转换为JSON格式
+// ToJson 将数据转换为JSON格式，转换失败时返回 nil
+//
+// Deprecated: 这个函数会丢弃转换失败的原因，请直接使用 json.Marshal，事件请使用 MarshalEvent 以便之后还原
 func ToJson(e any) []byte {
 	res, err := json.Marshal(e)
 	if err != nil {
@@ -112,7 +114,9 @@ func ToJson(e any) []byte {
 	return res
 }
 
-// ToJsonString 将数据转换为JSON格式的字符串
+// ToJsonString 将数据转换为JSON格式的字符串，转换失败时返回空字符串
+//
+// Deprecated: 这个函数会丢弃转换失败的原因，请直接使用 json.Marshal
 func ToJsonString(e any) string {
 	res, err := json.Marshal(e)
 	if err != nil {
@@ -150,6 +154,9 @@ func ProcessMessageContent(args ...interface{}) *Message {
 		case *Message:
 			// 如果参数是指向CryoMessage的指针，则将其添加到消息元素中
 			result.Add(*v...)
+		case MessageElement:
+			// 如果参数是单个消息元素，例如 NewFile() 创建的文件元素，则直接添加
+			result.Add(v)
 		}
 	}
 	return &result
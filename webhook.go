@@ -0,0 +1,314 @@
+package cryo
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/machinacanis/cryo/log"
+)
+
+// WebhookConfig 是一个 Webhook 推送目标的配置项，事件会被序列化为JSON后通过 POST 请求推送到 URL
+//
+// 事件需要同时满足事件类型、标签、Bot客户端和规则的过滤条件才会被推送，没有设置的条件不做过滤
+type WebhookConfig struct {
+	URL            string            `json:"url"`                                 // 推送地址
+	Secret         string            `json:"secret,omitempty,omitzero"`           // HMAC-SHA256 签名使用的密钥，为空时不签名
+	EventTypes     []string          `json:"event_types,omitempty,omitzero"`      // 推送的事件类型名称，例如 GroupMemberIncreaseEvent
+	Tags           []string          `json:"tags,omitempty,omitzero"`             // 事件带有其中任意一个标签时才会推送
+	Clients        []string          `json:"clients,omitempty,omitzero"`          // 只推送这些Bot客户端收到的事件，可以是客户端ID或者Uin
+	Rules          []RuleConfig      `json:"rules,omitempty,omitzero"`            // 所有规则都通过时才会推送
+	Headers        map[string]string `json:"headers,omitempty,omitzero"`          // 额外的请求头
+	Timeout        int               `json:"timeout,omitempty,omitzero"`          // 单次请求的超时时间（毫秒），默认为5000
+	MaxRetry       int               `json:"max_retry,omitempty,omitzero"`        // 推送失败时的最大重试次数，默认为3，设置为负数时不重试
+	RetryDelay     int               `json:"retry_delay,omitempty,omitzero"`      // 第一次重试前的等待时间（毫秒），之后每次翻倍，默认为1000
+	MaxRetryDelay  int               `json:"max_retry_delay,omitempty,omitzero"`  // 重试等待时间的上限（毫秒），默认为30000
+	DeadLetterFile string            `json:"dead_letter_file,omitempty,omitzero"` // 重试后仍然失败的事件会追加到这个文件中，为空时只记录日志
+	Workers        int               `json:"workers,omitempty,omitzero"`          // 同时进行推送的请求数，默认为4
+	QueueSize      int               `json:"queue_size,omitempty,omitzero"`       // 等待推送和等待重试的事件数量上限，默认为1000，超出时直接写入死信文件
+}
+
+// Webhook 请求中携带的请求头
+const (
+	WebhookEventHeader     = "X-Cryo-Event"     // 事件类型的名称
+	WebhookDeliveryHeader  = "X-Cryo-Delivery"  // 事件ID，重试时保持不变，可以用来去重
+	WebhookTimestampHeader = "X-Cryo-Timestamp" // 发送请求时的秒级时间戳
+	WebhookSignatureHeader = "X-Cryo-Signature" // 签名，格式为 sha256=<hex>
+)
+
+// deadLetterMutex 保护死信文件的写入，多个 Webhook 可能使用同一个文件
+var deadLetterMutex sync.Mutex
+
+// webhookDeadLetter 是死信文件中的一行记录
+type webhookDeadLetter struct {
+	Time     int64          `json:"time"`
+	URL      string         `json:"url"`
+	Attempts int            `json:"attempts"`
+	Error    string         `json:"error"`
+	Event    jsontext.Value `json:"event"`
+}
+
+// webhookStatusError 是推送地址返回的非 2xx 状态码
+type webhookStatusError struct {
+	StatusCode int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("推送地址返回了状态码 %d", e.StatusCode)
+}
+
+// errWebhookQueueFull 表示等待推送的事件过多，新的事件会直接写入死信文件
+var errWebhookQueueFull = errors.New("Webhook 推送队列已满")
+
+// webhookDelivery 是一个等待推送的事件
+type webhookDelivery struct {
+	id       string        // 事件ID
+	typ      string        // 事件类型的名称
+	body     []byte        // 序列化后的事件
+	attempts int           // 已经推送的次数
+	delay    time.Duration // 下一次重试前的等待时间
+}
+
+// webhookSink 是一个 Webhook 推送目标
+//
+// 事件会先进入队列，由固定数量的 worker 推送，重试通过定时器重新放回队列，不会占用 worker
+type webhookSink struct {
+	conf       WebhookConfig
+	logger     log.CryoLogger
+	httpClient *http.Client
+	types      []EventType
+	rules      []Rule[Event]
+	queue      chan *webhookDelivery
+	pending    atomic.Int64 // 等待推送和等待重试的事件数量，不会超过 QueueSize
+	startOnce  sync.Once
+}
+
+// newWebhookSink 检查配置并创建推送目标，没有设置的配置项使用默认值
+func newWebhookSink(conf WebhookConfig, logger log.CryoLogger) (*webhookSink, error) {
+	if conf.URL == "" {
+		return nil, errors.New("Webhook 的 URL 不能为空")
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = 5000
+	}
+	if conf.MaxRetry == 0 {
+		conf.MaxRetry = 3
+	}
+	if conf.RetryDelay <= 0 {
+		conf.RetryDelay = 1000
+	}
+	if conf.MaxRetryDelay <= 0 {
+		conf.MaxRetryDelay = 30000
+	}
+	if conf.Workers <= 0 {
+		conf.Workers = 4
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = 1000
+	}
+	types := make([]EventType, 0, len(conf.EventTypes))
+	for _, name := range conf.EventTypes {
+		et, ok := eventTypeByName[name]
+		if !ok {
+			return nil, fmt.Errorf("未知的事件类型 %q", name)
+		}
+		types = append(types, et)
+	}
+	rules, err := BuildRules(conf.Rules...)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookSink{
+		conf:       conf,
+		logger:     logger,
+		httpClient: &http.Client{Timeout: time.Duration(conf.Timeout) * time.Millisecond},
+		types:      types,
+		rules:      rules,
+		queue:      make(chan *webhookDelivery, conf.QueueSize),
+	}, nil
+}
+
+// match 判断事件是否满足标签、Bot客户端和规则的过滤条件，事件类型已经由中间件过滤
+func (s *webhookSink) match(e Event) bool {
+	u := e.GetUniEvent()
+	if len(s.conf.Tags) > 0 && !slices.ContainsFunc(e.GetEventTag(), func(tag string) bool {
+		return slices.Contains(s.conf.Tags, tag)
+	}) {
+		return false
+	}
+	if len(s.conf.Clients) > 0 && !slices.Contains(s.conf.Clients, u.ClientId) &&
+		!slices.Contains(s.conf.Clients, strconv.FormatUint(uint64(u.ClientUin), 10)) {
+		return false
+	}
+	for _, rule := range s.rules {
+		if !rule(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// handle 把事件放入推送队列，队列已满时直接写入死信文件
+func (s *webhookSink) handle(e Event) Event {
+	if !s.match(e) {
+		return e
+	}
+	body, err := MarshalEvent(e)
+	if err != nil {
+		s.logger.Errorf("[Webhook] 序列化事件失败：%v", err)
+		return e
+	}
+	d := &webhookDelivery{
+		id:    e.GetEventId(),
+		typ:   e.GetEventType().ToString(),
+		body:  body,
+		delay: time.Duration(s.conf.RetryDelay) * time.Millisecond,
+	}
+	if s.pending.Add(1) > int64(s.conf.QueueSize) {
+		s.pending.Add(-1)
+		s.logger.Warnf("[Webhook] 推送到 %s 的事件过多，事件 %s 已写入死信文件", s.conf.URL, d.id)
+		s.deadLetter(d, errWebhookQueueFull)
+		return e
+	}
+	s.startOnce.Do(s.start)
+	s.queue <- d // pending 不超过队列的容量，这里不会阻塞
+	return e
+}
+
+// start 启动推送事件的 worker
+func (s *webhookSink) start() {
+	for range s.conf.Workers {
+		go func() {
+			for d := range s.queue {
+				s.deliver(d)
+			}
+		}()
+	}
+}
+
+// deliver 推送一次事件，失败时按照指数退避在定时器到期后重新放回队列，全部失败后写入死信文件
+func (s *webhookSink) deliver(d *webhookDelivery) {
+	d.attempts++
+	err := s.post(d)
+	if err == nil {
+		s.pending.Add(-1)
+		return
+	}
+	if d.attempts > s.conf.MaxRetry || !webhookRetryable(err) {
+		s.pending.Add(-1)
+		s.logger.Errorf("[Webhook] 推送事件 %s 到 %s 失败：%v", d.id, s.conf.URL, err)
+		s.deadLetter(d, err)
+		return
+	}
+	s.logger.Debugf("[Webhook] 推送事件 %s 到 %s 失败，%v 后重试：%v", d.id, s.conf.URL, d.delay, err)
+	delay := d.delay
+	d.delay = min(d.delay*2, time.Duration(s.conf.MaxRetryDelay)*time.Millisecond)
+	time.AfterFunc(delay, func() { s.queue <- d })
+}
+
+// post 发送一次推送请求
+func (s *webhookSink) post(d *webhookDelivery) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, s.conf.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cryo")
+	req.Header.Set(WebhookEventHeader, d.typ)
+	req.Header.Set(WebhookDeliveryHeader, d.id)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	if s.conf.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, WebhookSignature(s.conf.Secret, timestamp, d.body))
+	}
+	for k, v := range s.conf.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &webhookStatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// deadLetter 把推送失败的事件追加到死信文件中
+func (s *webhookSink) deadLetter(d *webhookDelivery, cause error) {
+	if s.conf.DeadLetterFile == "" {
+		return
+	}
+	line, err := json.Marshal(webhookDeadLetter{
+		Time:     time.Now().Unix(),
+		URL:      s.conf.URL,
+		Attempts: d.attempts,
+		Error:    cause.Error(),
+		Event:    d.body,
+	})
+	if err != nil {
+		return
+	}
+	deadLetterMutex.Lock()
+	defer deadLetterMutex.Unlock()
+	f, err := os.OpenFile(s.conf.DeadLetterFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		s.logger.Errorf("[Webhook] 写入死信文件失败：%v", err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		s.logger.Errorf("[Webhook] 写入死信文件失败：%v", err)
+	}
+}
+
+// webhookRetryable 判断推送失败后是否需要重试，网络错误、429 和 5xx 会重试，其他的状态码说明请求本身有问题
+func webhookRetryable(err error) bool {
+	var se *webhookStatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	return true
+}
+
+// WebhookSignature 计算 Webhook 请求的签名，接收方可以用它来校验请求
+//
+// 签名的内容是 时间戳 + "." + 请求体，使用 HMAC-SHA256 计算，结果的格式为 sha256=<hex>
+func WebhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// AddWebhook 添加一个 Webhook 推送目标，返回注册的中间件ID，可以通过 EventBus.RemoveMiddlewareById 移除
+//
+// 推送在并发处理中间件中进行，不会阻塞事件的处理，同时进行的请求数和等待推送的事件数量分别由 Workers 和 QueueSize 限制
+func (b *Bot) AddWebhook(conf WebhookConfig) (string, error) {
+	s, err := newWebhookSink(conf, b.Logger)
+	if err != nil {
+		return "", err
+	}
+	mw := NewUniMiddleware(s.types...)
+	mw.AddTag("webhook")
+	mw.AddHandler(s.handle)
+	b.bus.AddAsyncMiddleware(mw)
+	return mw.GetId(), nil
+}
@@ -0,0 +1,244 @@
+package cryo
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/machinacanis/cryo/log"
+)
+
+// webhookRequest 是测试服务器收到的一次推送请求
+type webhookRequest struct {
+	time   time.Time
+	header http.Header
+	body   []byte
+}
+
+// webhookServer 是记录推送请求的测试服务器，status 决定每次请求返回的状态码
+type webhookServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests []webhookRequest
+	received chan struct{}
+}
+
+func newWebhookServer(t *testing.T, status func(n int) int) *webhookServer {
+	ws := &webhookServer{received: make(chan struct{}, 100)}
+	ws.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ws.mutex.Lock()
+		ws.requests = append(ws.requests, webhookRequest{time: time.Now(), header: r.Header.Clone(), body: body})
+		n := len(ws.requests)
+		ws.mutex.Unlock()
+		w.WriteHeader(status(n))
+		ws.received <- struct{}{}
+	}))
+	t.Cleanup(ws.Close)
+	return ws
+}
+
+// wait 等待服务器收到 n 次请求
+func (ws *webhookServer) wait(t *testing.T, n int) []webhookRequest {
+	t.Helper()
+	for range n {
+		select {
+		case <-ws.received:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("等待第 %d 次推送请求超时", n)
+		}
+	}
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	return append([]webhookRequest{}, ws.requests...)
+}
+
+// webhookEvent 构造一个用于推送的群消息事件
+func webhookEvent(id string) Event {
+	return &GroupMessageEvent{UniMessageEvent: UniMessageEvent{
+		UniEvent:  UniEvent{EventType: GroupMessageEventType, EventId: id, ClientUin: 10000},
+		GroupUin:  100,
+		SenderUin: 1001,
+	}}
+}
+
+// readDeadLetters 读取死信文件中的所有记录
+func readDeadLetters(t *testing.T, path string) []webhookDeadLetter {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("打开死信文件失败：%v", err)
+	}
+	defer f.Close()
+	var letters []webhookDeadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var l webhookDeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			t.Fatalf("解析死信记录 %q 失败：%v", scanner.Text(), err)
+		}
+		letters = append(letters, l)
+	}
+	return letters
+}
+
+// waitDeadLetters 等待死信文件中出现 n 条记录
+func waitDeadLetters(t *testing.T, path string, n int) []webhookDeadLetter {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			if letters := readDeadLetters(t, path); len(letters) >= n {
+				return letters
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待 %d 条死信记录超时", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	ws := newWebhookServer(t, func(int) int { return http.StatusOK })
+	s, err := newWebhookSink(WebhookConfig{URL: ws.URL, Secret: "secret"}, log.NewLoggerBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.handle(webhookEvent("event-1"))
+	r := ws.wait(t, 1)[0]
+
+	timestamp := r.header.Get(WebhookTimestampHeader)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Fatalf("时间戳 %q 不是数字", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(timestamp + "." + string(r.body)))
+	if want, got := "sha256="+hex.EncodeToString(mac.Sum(nil)), r.header.Get(WebhookSignatureHeader); got != want {
+		t.Errorf("签名为 %q，期望 %q", got, want)
+	}
+	if got := r.header.Get(WebhookDeliveryHeader); got != "event-1" {
+		t.Errorf("%s 为 %q，期望事件ID", WebhookDeliveryHeader, got)
+	}
+	if got := r.header.Get(WebhookEventHeader); got != GroupMessageEventType.ToString() {
+		t.Errorf("%s 为 %q，期望 %q", WebhookEventHeader, got, GroupMessageEventType.ToString())
+	}
+}
+
+func TestWebhookRetrySchedule(t *testing.T) {
+	ws := newWebhookServer(t, func(n int) int {
+		if n < 4 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	s, err := newWebhookSink(WebhookConfig{URL: ws.URL, MaxRetry: 3, RetryDelay: 20, MaxRetryDelay: 30}, log.NewLoggerBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.handle(webhookEvent("event-1"))
+	requests := ws.wait(t, 4)
+
+	// 重试前的等待时间每次翻倍，但是不超过 MaxRetryDelay
+	for i, want := range []time.Duration{20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond} {
+		if gap := requests[i+1].time.Sub(requests[i].time); gap < want {
+			t.Errorf("第 %d 次重试只等待了 %v，期望至少等待 %v", i+1, gap, want)
+		}
+		if id := requests[i+1].header.Get(WebhookDeliveryHeader); id != "event-1" {
+			t.Errorf("第 %d 次重试的事件ID为 %q", i+1, id)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(ws.wait(t, 0)); n != 4 {
+		t.Errorf("推送成功后仍然在重试，共推送了 %d 次", n)
+	}
+	if n := s.pending.Load(); n != 0 {
+		t.Errorf("推送成功后还有 %d 个等待推送的事件", n)
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	ws := newWebhookServer(t, func(n int) int {
+		if n == 1 {
+			return http.StatusBadRequest // 不会重试的状态码
+		}
+		return http.StatusInternalServerError
+	})
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	s, err := newWebhookSink(WebhookConfig{URL: ws.URL, MaxRetry: 2, RetryDelay: 1, Workers: 1, DeadLetterFile: path}, log.NewLoggerBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.handle(webhookEvent("bad-request"))
+	ws.wait(t, 1)
+	s.handle(webhookEvent("server-error"))
+	ws.wait(t, 3)
+
+	letters := waitDeadLetters(t, path, 2)
+	for i, want := range []struct {
+		id       string
+		attempts int
+	}{{"bad-request", 1}, {"server-error", 3}} {
+		l := letters[i]
+		var e GroupMessageEvent
+		if err := json.Unmarshal(l.Event, &e); err != nil {
+			t.Fatalf("死信记录中的事件无法解析：%v", err)
+		}
+		if e.EventId != want.id || l.Attempts != want.attempts || l.URL != ws.URL || l.Error == "" {
+			t.Errorf("第 %d 条死信记录为 %+v（事件 %s），期望事件 %s 推送了 %d 次", i, l, e.EventId, want.id, want.attempts)
+		}
+	}
+}
+
+func TestWebhookQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	ws := newWebhookServer(t, func(int) int {
+		<-release
+		return http.StatusOK
+	})
+	t.Cleanup(func() { once.Do(func() { close(release) }) })
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	s, err := newWebhookSink(WebhookConfig{URL: ws.URL, Workers: 1, QueueSize: 2, DeadLetterFile: path}, log.NewLoggerBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一个事件正在推送，第二个事件在队列中等待，第三个事件超出了队列的容量
+	for _, id := range []string{"1", "2", "3"} {
+		s.handle(webhookEvent(id))
+	}
+	letters := waitDeadLetters(t, path, 1)
+	var e GroupMessageEvent
+	if err := json.Unmarshal(letters[0].Event, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.EventId != "3" || letters[0].Attempts != 0 || letters[0].Error != errWebhookQueueFull.Error() {
+		t.Errorf("死信记录为 %+v（事件 %s），期望事件 3 因为队列已满被丢弃", letters[0], e.EventId)
+	}
+
+	once.Do(func() { close(release) })
+	ws.wait(t, 2)
+	deadline := time.Now().Add(2 * time.Second)
+	for s.pending.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("推送完成后还有 %d 个等待推送的事件", s.pending.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := len(readDeadLetters(t, path)); n != 1 {
+		t.Errorf("死信文件中有 %d 条记录，期望 1 条", n)
+	}
+}